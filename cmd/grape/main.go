@@ -12,11 +12,15 @@ import (
 	"time"
 
 	backupcmd "github.com/graperegistry/grape/cmd/backup"
+	"github.com/graperegistry/grape/internal/auth"
 	"github.com/graperegistry/grape/internal/config"
 	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/importer"
 	"github.com/graperegistry/grape/internal/logger"
 	"github.com/graperegistry/grape/internal/server"
 	"github.com/graperegistry/grape/internal/server/handler"
+	"github.com/graperegistry/grape/internal/storage"
+	"github.com/graperegistry/grape/internal/storage/backend"
 )
 
 var (
@@ -37,6 +41,12 @@ func printUsage() {
 	fmt.Println("  grape backup [options]       Create a backup")
 	fmt.Println("  grape restore [options]      Restore from backup")
 	fmt.Println("  grape list [options]         List backup contents")
+	fmt.Println("  grape import-users [options] Import users from an htpasswd file")
+	fmt.Println("  grape import verdaccio [options]")
+	fmt.Println("                               Import packages from a Verdaccio storage directory")
+	fmt.Println("  grape sync [options]         Copy packages from another registry")
+	fmt.Println("  grape reindex [options]      Rebuild the package index from storage")
+	fmt.Println("  grape migrate <command>      Show, apply or roll back database migrations")
 	fmt.Println()
 	fmt.Println("Server Options:")
 	flag.PrintDefaults()
@@ -66,6 +76,21 @@ func main() {
 		case "list":
 			runListCommand(os.Args[2:])
 			return
+		case "import-users":
+			runImportUsersCommand(os.Args[2:])
+			return
+		case "import":
+			runImportCommand(os.Args[2:])
+			return
+		case "sync":
+			runSyncCommand(os.Args[2:])
+			return
+		case "reindex":
+			runReindexCommand(os.Args[2:])
+			return
+		case "migrate":
+			runMigrateCommand(os.Args[2:])
+			return
 		case "help", "--help", "-h":
 			printUsage()
 			return
@@ -91,30 +116,17 @@ func main() {
 	}
 	defer logger.Sync()
 
-	// 初始化数据库
-	if err := db.Init(&db.Config{
-		Type: cfg.Database.Type,
-		DSN:  cfg.Database.DSN,
-	}); err != nil {
-		logger.Fatalf("Failed to initialize database: %v", err)
+	if err := cfg.Replication.Validate(); err != nil {
+		logger.Fatalf("%v", err)
 	}
-	defer db.Close()
 
-	// 自动迁移数据库表
-	if err := db.Migrate(
-		&db.User{}, &db.Package{}, &db.PackageVersion{}, &db.Webhook{},
-		&db.AuditLog{}, &db.Token{}, &db.PackageOwner{},
-		&db.PackageGCMetadata{}, &db.OrphanedFile{}, &db.PackageDeprecation{},
-	); err != nil {
-		logger.Fatalf("Failed to migrate database: %v", err)
-	}
-
-	// 运行 SQL 迁移
-	if err := db.RunMigrations(db.DB); err != nil {
-		logger.Warnf("Failed to run SQL migrations: %v", err)
+	// 初始化数据库
+	if err := openDatabase(cfg); err != nil {
+		logger.Fatalf("%v", err)
 	}
+	defer db.Close()
 
-	logger.Info("✅ Database initialized")
+	logger.Infof("✅ Database initialized (%s)", cfg.Database.Type)
 
 	srv := server.New(cfg, version)
 
@@ -141,6 +153,406 @@ func main() {
 	logger.Info("👋 Grape stopped")
 }
 
+// connectDatabase 校验配置并连接数据库，不修改表结构
+func connectDatabase(cfg *config.Config) error {
+	if err := cfg.Database.Validate(); err != nil {
+		return err
+	}
+	if err := db.Init(&db.Config{
+		Type:            cfg.Database.Type,
+		DSN:             cfg.Database.DSN,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	}); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return nil
+}
+
+// openDatabase 连接数据库并执行表结构迁移，服务器和需要访问数据库的子命令共用。
+// 数据库中有当前版本不认识的迁移或已执行的迁移被修改时，在修改表结构之前拒绝启动
+func openDatabase(cfg *config.Config) error {
+	if err := connectDatabase(cfg); err != nil {
+		return err
+	}
+
+	// 先只读地检查 SQL 迁移，避免旧版本程序对新版本的表结构执行 AutoMigrate
+	steps, err := db.PlanMigrations(db.DB, -1)
+	if err != nil {
+		return fmt.Errorf("failed to check SQL migrations: %w", err)
+	}
+	if len(steps) > 0 && !cfg.Database.AutoMigrate {
+		return fmt.Errorf("%d pending SQL migrations, run `grape migrate up` first (database.auto_migrate is disabled)", len(steps))
+	}
+
+	// 自动迁移数据库表；GC 和 deprecation 表由 SQL 迁移管理
+	if err := db.Migrate(
+		&db.User{}, &db.Package{}, &db.PackageVersion{}, &db.Webhook{},
+		&db.AuditLog{}, &db.Token{}, &db.PackageOwner{},
+		&db.WebLoginSession{}, &db.OIDCLoginState{}, &db.UserTwoFactor{}, &db.UserSession{},
+		&db.PasswordReset{}, &db.AccountLockout{}, &db.SyncState{}, &db.PackageChange{}, &db.ReplicationState{},
+		&db.PackageAccess{}, &db.PackageGrant{},
+		&db.Organization{}, &db.OrgMember{}, &db.Team{}, &db.TeamMember{}, &db.TeamGrant{},
+	); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	// 运行 SQL 迁移（没有待执行的迁移时只补写旧记录的校验和）
+	if err := db.ApplyMigrations(db.DB, steps); err != nil {
+		return fmt.Errorf("failed to run SQL migrations: %w", err)
+	}
+	return nil
+}
+
+// openStorage 按 storage.type 创建存储，失败时退出
+func openStorage(cfg *config.Config) storage.Storage {
+	store, err := backend.New(cfg.Storage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+// runImportUsersCommand 从 htpasswd 文件（如 Verdaccio 的 htpasswd）导入用户
+func runImportUsersCommand(args []string) {
+	fs := flag.NewFlagSet("import-users", flag.ExitOnError)
+	cfgPath := fs.String("config", "", "Path to config file")
+	fs.StringVar(cfgPath, "c", "", "Path to config file (shorthand)")
+	file := fs.String("file", "", "htpasswd file to import")
+	fs.StringVar(file, "f", "", "htpasswd file to import (shorthand)")
+	role := fs.String("role", "developer", "Role of imported users")
+	overwrite := fs.Bool("overwrite", false, "Overwrite passwords of existing local users")
+	mustChange := fs.Bool("must-change-password", false, "Require imported users to change their password at next login")
+	dryRun := fs.Bool("dry-run", false, "Show what would be imported without writing to the database")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: grape import-users [options]")
+		fmt.Println()
+		fmt.Println("Import users from an htpasswd file (bcrypt, SHA1 and crypt entries)")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if *file == "" {
+		fmt.Fprintf(os.Stderr, "Error: htpasswd file required (--file)\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.Init("warn"); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	if err := openDatabase(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	result, err := auth.ImportHtpasswd(f, auth.HtpasswdImportOptions{
+		Role:               *role,
+		Overwrite:          *overwrite,
+		MustChangePassword: *mustChange,
+		DryRun:             *dryRun,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		fmt.Println("Dry run, nothing was written")
+	} else {
+		// RecordAudit 是异步写入，命令行退出前需要同步写入
+		db.DB.Create(&db.AuditLog{
+			Action:   "user_import",
+			Username: "cli",
+			Detail:   fmt.Sprintf("从 htpasswd 导入用户: 新建 %d, 更新 %d", len(result.Created), len(result.Updated)),
+		})
+	}
+	fmt.Printf("Created: %d %s\n", len(result.Created), strings.Join(result.Created, ", "))
+	fmt.Printf("Updated: %d %s\n", len(result.Updated), strings.Join(result.Updated, ", "))
+	fmt.Printf("Skipped (already exist): %d %s\n", len(result.Skipped), strings.Join(result.Skipped, ", "))
+	for _, e := range result.Errors {
+		fmt.Printf("Error: %s\n", e)
+	}
+	if len(result.Errors) > 0 {
+		os.Exit(2)
+	}
+}
+
+func runImportCommand(args []string) {
+	if len(args) == 0 || args[0] != "verdaccio" {
+		fmt.Fprintln(os.Stderr, "Usage: grape import verdaccio [options]")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("import verdaccio", flag.ExitOnError)
+	cfgPath := fs.String("config", "", "Path to config file")
+	fs.StringVar(cfgPath, "c", "", "Path to config file (shorthand)")
+	storageDir := fs.String("storage", "", "Verdaccio storage directory")
+	includeCache := fs.Bool("include-cache", false, "Also import packages cached from uplinks")
+	overwrite := fs.Bool("overwrite", false, "Overwrite packages that already exist in Grape")
+	apply := fs.Bool("apply", false, "Import the packages (without it only the dry-run report is printed)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: grape import verdaccio --storage <dir> [options]")
+		fmt.Println()
+		fmt.Println("Import packages from a Verdaccio storage directory. Prints a dry-run report")
+		fmt.Println("unless --apply is given.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args[1:])
+
+	if *storageDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: Verdaccio storage directory required (--storage)\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.Init("warn"); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	if err := openDatabase(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	store := openStorage(cfg)
+	report, err := importer.ImportVerdaccio(store, auth.NewPackageAuthorizer(store), importer.VerdaccioOptions{
+		StorageDir:   *storageDir,
+		IncludeCache: *includeCache,
+		Overwrite:    *overwrite,
+		DryRun:       !*apply,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	printVerdaccioPackages := func(title string, packages []*importer.VerdaccioPackage) {
+		fmt.Printf("%s: %d\n", title, len(packages))
+		for _, p := range packages {
+			fmt.Printf("  %-40s %3d versions  %3d tarballs  %s\n", p.Name, p.Versions, p.Tarballs, p.Action)
+			if len(p.MissingTarballs) > 0 {
+				fmt.Printf("      missing tarballs: %s\n", strings.Join(p.MissingTarballs, ", "))
+			}
+			if len(p.CorruptTarballs) > 0 {
+				fmt.Printf("      shasum mismatch (skipped): %s\n", strings.Join(p.CorruptTarballs, ", "))
+			}
+			if len(p.UnknownMaintainers) > 0 {
+				fmt.Printf("      maintainers without account: %s\n", strings.Join(p.UnknownMaintainers, ", "))
+			}
+		}
+	}
+	printVerdaccioPackages("Private packages", report.Private)
+	printVerdaccioPackages("Cached packages", report.Cached)
+	for _, e := range report.Errors {
+		fmt.Printf("Error: %s\n", e)
+	}
+
+	if !*apply {
+		fmt.Println()
+		fmt.Println("Dry run, nothing was written. Re-run with --apply to import.")
+	} else {
+		// RecordAudit 是异步写入，命令行退出前需要同步写入
+		db.DB.Create(&db.AuditLog{
+			Action:   "package_import",
+			Username: "cli",
+			Detail:   fmt.Sprintf("从 Verdaccio 导入包: %d 个", len(report.Imported)),
+		})
+		fmt.Printf("\nImported: %d %s\n", len(report.Imported), strings.Join(report.Imported, ", "))
+	}
+	if len(report.Errors) > 0 {
+		os.Exit(2)
+	}
+}
+
+func runSyncCommand(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	cfgPath := fs.String("config", "", "Path to config file")
+	fs.StringVar(cfgPath, "c", "", "Path to config file (shorthand)")
+	from := fs.String("from", "", "Source registry URL")
+	scope := fs.String("scope", "", "Copy all packages of this scope, e.g. @acme")
+	packages := fs.String("packages", "", "Comma-separated list of additional packages to copy")
+	token := fs.String("token", os.Getenv("GRAPE_SYNC_TOKEN"), "Token for the source registry (default $GRAPE_SYNC_TOKEN)")
+	full := fs.Bool("full", false, "Check every package even if it has not changed since the last sync")
+	dryRun := fs.Bool("dry-run", false, "Show which versions would be copied without writing anything")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: grape sync --from <registry-url> [--scope @acme] [--packages a,b] [options]")
+		fmt.Println()
+		fmt.Println("Copy packages with all versions from another Grape or npm registry as private packages.")
+		fmt.Println("Runs are incremental and can be resumed after an interruption.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if *from == "" {
+		fmt.Fprintf(os.Stderr, "Error: source registry required (--from)\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.Init("warn"); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	if err := openDatabase(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	var names []string
+	for _, name := range strings.Split(*packages, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	store := openStorage(cfg)
+	syncer, err := importer.NewSyncer(store, auth.NewPackageAuthorizer(store), importer.SyncOptions{
+		From:     *from,
+		Token:    *token,
+		Scope:    *scope,
+		Packages: names,
+		Full:     *full,
+		DryRun:   *dryRun,
+		Progress: func(p importer.SyncProgress) {
+			pkg := p.Package
+			status := fmt.Sprintf("%d new, %d up to date", len(pkg.Downloaded), pkg.UpToDate)
+			if pkg.Unchanged {
+				status = "unchanged"
+			}
+			fmt.Printf("[%d/%d] %s: %s\n", p.Index, p.Total, pkg.Name, status)
+			for _, e := range pkg.Errors {
+				fmt.Printf("    error: %s\n", e)
+			}
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Ctrl+C 时停止同步，已完成的版本在下次运行时跳过
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := syncer.Run(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		fmt.Printf("\nDry run, nothing was written. %d versions would be copied.\n", result.Downloaded)
+	} else {
+		// RecordAudit 是异步写入，命令行退出前需要同步写入
+		db.DB.Create(&db.AuditLog{
+			Action:   "package_sync",
+			Username: "cli",
+			Detail:   fmt.Sprintf("从 %s 同步包: %d 个包, %d 个新版本", result.Source, len(result.Packages), result.Downloaded),
+		})
+		fmt.Printf("\nSynced %d packages, %d versions copied, %d failed\n", len(result.Packages), result.Downloaded, result.Failed)
+	}
+	if result.Failed > 0 {
+		os.Exit(2)
+	}
+}
+
+// runReindexCommand 按存储目录中的 metadata 重建 packages 和 package_versions
+func runReindexCommand(args []string) {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	cfgPath := fs.String("config", "", "Path to config file")
+	fs.StringVar(cfgPath, "c", "", "Path to config file (shorthand)")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: grape reindex [options]")
+		fmt.Println()
+		fmt.Println("Rebuild the package index used by the package list, search and stats APIs")
+		fmt.Println("from the metadata in the storage directory. Run it after restoring a backup")
+		fmt.Println("or editing the storage directory by hand.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.Init("warn"); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	if err := openDatabase(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	result, err := importer.Reindex(openStorage(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, e := range result.Errors {
+		fmt.Printf("error: %s\n", e)
+	}
+
+	// RecordAudit 是异步写入，命令行退出前需要同步写入
+	db.DB.Create(&db.AuditLog{
+		Action:   "package_reindex",
+		Username: "cli",
+		Detail:   fmt.Sprintf("重建包索引: %d 个包, %d 个版本, 删除 %d 条记录", result.Packages, result.Versions, result.Removed),
+	})
+	fmt.Printf("Indexed %d packages (%d versions), removed %d stale entries, %d failed\n",
+		result.Packages, result.Versions, result.Removed, len(result.Errors))
+	if len(result.Errors) > 0 {
+		os.Exit(2)
+	}
+}
+
 func runBackupCommand(args []string) {
 	fs := flag.NewFlagSet("backup", flag.ExitOnError)
 	output := fs.String("output", "", "Output file path")
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// runMigrateCommand 查看、执行或回滚 SQL 迁移
+func runMigrateCommand(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	cfgPath := fs.String("config", "", "Path to config file")
+	fs.StringVar(cfgPath, "c", "", "Path to config file (shorthand)")
+	dryRun := fs.Bool("dry-run", false, "Print the SQL that would be executed without running it")
+	steps := fs.Int("steps", 1, "Number of migrations to roll back with down")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: grape migrate <command> [options]")
+		fmt.Println()
+		fmt.Println("Commands:")
+		fmt.Println("  status     Show applied and pending migrations")
+		fmt.Println("  up         Apply all pending migrations")
+		fmt.Println("  down       Roll back the last migration (--steps N for more)")
+		fmt.Println("  to <n>     Migrate up or down to version n (0 rolls back everything)")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+	}
+
+	// 子命令前后都可以带选项
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	command := positional[0]
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.Init("warn"); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	if err := connectDatabase(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if command == "status" {
+		if !printMigrationStatus(cfg) {
+			os.Exit(2)
+		}
+		return
+	}
+
+	target := -1
+	switch command {
+	case "up":
+	case "down":
+		if *steps < 1 {
+			fmt.Fprintln(os.Stderr, "Error: --steps must be at least 1")
+			os.Exit(1)
+		}
+		target, err = rollbackTarget(*steps)
+	case "to":
+		if len(positional) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: grape migrate to <version>")
+			os.Exit(1)
+		}
+		target, err = strconv.Atoi(positional[1])
+		if err != nil || target < 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid version %q\n", positional[1])
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown migrate command: %s\n\n", command)
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	plan, err := db.PlanMigrations(db.DB, target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(plan) == 0 {
+		fmt.Println("Nothing to migrate, the database is at the requested version")
+		return
+	}
+
+	if *dryRun {
+		for _, step := range plan {
+			fmt.Printf("-- %s\n%s\n", describeStep(step), strings.TrimSpace(step.SQLText()))
+		}
+		fmt.Printf("\nDry run, nothing was executed. %d migrations would run.\n", len(plan))
+		return
+	}
+
+	for _, step := range plan {
+		fmt.Println(describeStep(step))
+		if err := db.ApplyMigrations(db.DB, []db.MigrationStep{step}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	version, err := db.GetSchemaVersion(db.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// RecordAudit 是异步写入，命令行退出前需要同步写入；全新数据库可能还没有 audit_logs 表
+	if db.DB.Migrator().HasTable(&db.AuditLog{}) {
+		db.DB.Create(&db.AuditLog{
+			Action:   "db_migrate",
+			Username: "cli",
+			Detail:   fmt.Sprintf("数据库迁移 %s: 执行 %d 个迁移, 当前版本 %d", command, len(plan), version),
+		})
+	}
+	fmt.Printf("Done, %d migrations executed, schema version is now %d\n", len(plan), version)
+}
+
+// printMigrationStatus 打印迁移状态，有被修改或未知的迁移时返回 false
+func printMigrationStatus(cfg *config.Config) bool {
+	statuses, err := db.MigrationStatuses(db.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Database: %s (%s)\n\n", cfg.Database.RedactedDSN(), cfg.Database.Type)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT\tDESCRIPTION")
+	ok := true
+	pending := 0
+	for _, s := range statuses {
+		status := "pending"
+		switch {
+		case s.Unknown:
+			status, ok = "unknown", false
+		case s.Modified:
+			status, ok = "modified", false
+		case s.Applied:
+			status = "applied"
+		default:
+			pending++
+		}
+		appliedAt := ""
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, status, appliedAt, s.Description)
+	}
+	w.Flush()
+
+	fmt.Println()
+	if !ok {
+		fmt.Println("The database has unknown or modified migrations, the server will refuse to start.")
+	} else if pending > 0 {
+		fmt.Printf("%d pending migrations, run `grape migrate up` to apply them.\n", pending)
+	} else {
+		fmt.Println("The database is up to date.")
+	}
+	return ok
+}
+
+// rollbackTarget 返回回滚最近 steps 个已执行迁移后的目标版本
+func rollbackTarget(steps int) (int, error) {
+	statuses, err := db.MigrationStatuses(db.DB)
+	if err != nil {
+		return 0, err
+	}
+	var applied []int
+	for _, s := range statuses {
+		if s.Applied {
+			applied = append(applied, s.Version)
+		}
+	}
+	if len(applied) == 0 {
+		return 0, fmt.Errorf("no migrations have been applied")
+	}
+	if steps >= len(applied) {
+		return 0, nil
+	}
+	return applied[len(applied)-steps-1], nil
+}
+
+func describeStep(step db.MigrationStep) string {
+	direction := "up"
+	if step.Down {
+		direction = "down"
+	}
+	return fmt.Sprintf("%s %03d %s", direction, step.Version, step.Description)
+}
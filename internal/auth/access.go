@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// 包访问级别
+const (
+	AccessPublic     = "public"
+	AccessRestricted = "restricted"
+)
+
+// 包授权权限
+const (
+	PermissionReadOnly  = "read-only"
+	PermissionReadWrite = "read-write"
+)
+
+// ValidAccessLevel 检查访问级别是否合法
+func ValidAccessLevel(access string) bool {
+	return access == AccessPublic || access == AccessRestricted
+}
+
+// ValidPermission 检查授权权限是否合法
+func ValidPermission(permission string) bool {
+	return permission == PermissionReadOnly || permission == PermissionReadWrite
+}
+
+// PackageScope 返回包名的 scope（如 @acme/ui -> @acme），非 scoped 包返回空字符串
+func PackageScope(packageName string) string {
+	if !strings.HasPrefix(packageName, "@") {
+		return ""
+	}
+	idx := strings.Index(packageName, "/")
+	if idx <= 0 {
+		return ""
+	}
+	return packageName[:idx]
+}
+
+// scopeAccessRule 返回 scope 级访问规则的名称（如 @acme/*）
+func scopeAccessRule(packageName string) string {
+	scope := PackageScope(packageName)
+	if scope == "" {
+		return ""
+	}
+	return scope + "/*"
+}
+
+// PackageAccessLevel 返回包的访问级别：包自身的设置优先，其次是 scope 级设置，默认 public
+// 读取访问规则失败时按 restricted 处理，避免数据库故障时暴露受限包
+func PackageAccessLevel(packageName string) string {
+	names := []string{packageName}
+	if rule := scopeAccessRule(packageName); rule != "" {
+		names = append(names, rule)
+	}
+
+	var rules []db.PackageAccess
+	if err := db.DB.Where("package_name IN ?", names).Find(&rules).Error; err != nil {
+		logger.Warnf("Failed to load package access for %s: %v", packageName, err)
+		return AccessRestricted
+	}
+	return resolveAccessLevel(packageName, rules)
+}
+
+// resolveAccessLevel 从规则列表中解析包的访问级别
+func resolveAccessLevel(packageName string, rules []db.PackageAccess) string {
+	scopeRule := scopeAccessRule(packageName)
+	level := AccessPublic
+	for _, rule := range rules {
+		if rule.PackageName == packageName {
+			return rule.Access
+		}
+		if scopeRule != "" && rule.PackageName == scopeRule {
+			level = rule.Access
+		}
+	}
+	return level
+}
+
+// SetPackageAccess 设置包或 scope（@scope/*）的访问级别
+func SetPackageAccess(name, access, operator string) error {
+	var rule db.PackageAccess
+	err := db.DB.Where("package_name = ?", name).
+		Assign(db.PackageAccess{Access: access, UpdatedBy: operator}).
+		FirstOrCreate(&rule, db.PackageAccess{PackageName: name}).Error
+	return err
+}
+
+// IsPackageOwner 检查用户是否为包的 owner（包括匹配包名的通配符规则）
+func IsPackageOwner(user *User, packageName string) bool {
+	if user == nil {
+		return false
+	}
+	var rules []db.PackageOwner
+	db.DB.Where("user_id = ? AND (package_name = ? OR package_name LIKE ?)", user.ID, packageName, "%*%").
+		Find(&rules)
+	return len(filterOwnerRules(packageName, rules)) > 0
+}
+
+// HasPackageGrant 检查用户是否拥有包的授权；permission 为 read-write 时只匹配读写授权
+func HasPackageGrant(user *User, packageName, permission string) bool {
+	if user == nil {
+		return false
+	}
+	query := db.DB.Model(&db.PackageGrant{}).
+		Where("package_name = ? AND user_id = ?", packageName, user.ID)
+	if permission == PermissionReadWrite {
+		query = query.Where("permission = ?", PermissionReadWrite)
+	}
+	var count int64
+	query.Count(&count)
+	return count > 0
+}
+
+// CanReadPackage 检查用户是否可以读取包（元数据、tarball、搜索结果）
+func CanReadPackage(user *User, packageName string) bool {
+	if PackageAccessLevel(packageName) != AccessRestricted {
+		return true
+	}
+	return canReadRestricted(user, packageName)
+}
+
+// AuthorizeRead 检查当前请求能否读取包；细粒度 token 范围外的包按匿名用户处理
+func AuthorizeRead(c *gin.Context, packageName string) bool {
+	if !TokenAllows(c, ActionRead, packageName) {
+		return CanReadPackage(nil, packageName)
+	}
+	return CanReadPackage(GetCurrentUser(c), packageName)
+}
+
+// canReadRestricted 受限包只对 admin、owner、被授权用户/团队以及所属组织的 owner/admin 可见
+func canReadRestricted(user *User, packageName string) bool {
+	if user == nil {
+		return false
+	}
+	if user.Role == "admin" {
+		return true
+	}
+	return IsPackageOwner(user, packageName) ||
+		HasPackageGrant(user, packageName, PermissionReadOnly) ||
+		HasTeamGrant(user, packageName, PermissionReadOnly) ||
+		isOrgManagerForPackage(user, packageName)
+}
+
+// ReadFilter 批量过滤可读包，避免列表/搜索时逐个查询访问规则
+type ReadFilter struct {
+	user   *User
+	token  *TokenInfo // 细粒度 token 范围外的包按匿名用户处理
+	rules  map[string]db.PackageAccess
+	failed bool // 访问规则加载失败，所有包按 restricted 处理
+}
+
+// NewRequestReadFilter 为当前请求创建 ReadFilter，与 AuthorizeRead 一样考虑 token 的包范围
+func NewRequestReadFilter(c *gin.Context) *ReadFilter {
+	f := NewReadFilter(GetCurrentUser(c))
+	f.token = GetTokenInfo(c)
+	return f
+}
+
+// NewReadFilter 为当前用户创建 ReadFilter
+func NewReadFilter(user *User) *ReadFilter {
+	f := &ReadFilter{
+		user:  user,
+		rules: make(map[string]db.PackageAccess),
+	}
+	var rules []db.PackageAccess
+	if err := db.DB.Find(&rules).Error; err != nil {
+		logger.Warnf("Failed to load package access rules: %v", err)
+		f.failed = true
+	}
+	for _, rule := range rules {
+		f.rules[rule.PackageName] = rule
+	}
+	return f
+}
+
+// CanRead 检查包是否对当前用户可见
+func (f *ReadFilter) CanRead(packageName string) bool {
+	user := f.user
+	if f.token != nil && !f.token.Allows(ActionRead, packageName) {
+		user = nil
+	}
+	if f.failed {
+		return canReadRestricted(user, packageName)
+	}
+	if len(f.rules) == 0 {
+		return true
+	}
+	var rules []db.PackageAccess
+	if rule, ok := f.rules[packageName]; ok {
+		rules = append(rules, rule)
+	}
+	if rule, ok := f.rules[scopeAccessRule(packageName)]; ok {
+		rules = append(rules, rule)
+	}
+	if resolveAccessLevel(packageName, rules) != AccessRestricted {
+		return true
+	}
+	return canReadRestricted(user, packageName)
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+func TestPackageScope(t *testing.T) {
+	tests := map[string]string{
+		"@acme/ui": "@acme",
+		"lodash":   "",
+		"@broken":  "",
+	}
+	for name, want := range tests {
+		if got := PackageScope(name); got != want {
+			t.Errorf("PackageScope(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestResolveAccessLevel(t *testing.T) {
+	scopeRestricted := db.PackageAccess{PackageName: "@acme/*", Access: AccessRestricted}
+	pkgPublic := db.PackageAccess{PackageName: "@acme/open", Access: AccessPublic}
+
+	tests := []struct {
+		name  string
+		pkg   string
+		rules []db.PackageAccess
+		want  string
+	}{
+		{name: "no rules", pkg: "lodash", rules: nil, want: AccessPublic},
+		{name: "scope rule applies", pkg: "@acme/ui", rules: []db.PackageAccess{scopeRestricted}, want: AccessRestricted},
+		{name: "package rule overrides scope", pkg: "@acme/open", rules: []db.PackageAccess{scopeRestricted, pkgPublic}, want: AccessPublic},
+		{name: "package rule listed first", pkg: "@acme/open", rules: []db.PackageAccess{pkgPublic, scopeRestricted}, want: AccessPublic},
+		{name: "other scope", pkg: "@other/ui", rules: []db.PackageAccess{scopeRestricted}, want: AccessPublic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveAccessLevel(tt.pkg, tt.rules); got != tt.want {
+				t.Errorf("resolveAccessLevel(%s) = %s, want %s", tt.pkg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackageAccessFailsClosed(t *testing.T) {
+	dbtest.Open(t, &db.User{}, &db.PackageAccess{}, &db.PackageOwner{}, &db.PackageGrant{},
+		&db.Organization{}, &db.OrgMember{}, &db.Team{}, &db.TeamMember{}, &db.TeamGrant{})
+	if level := PackageAccessLevel("lodash"); level != AccessPublic {
+		t.Fatalf("Expected public without rules, got %s", level)
+	}
+
+	// 访问规则表不可读时按 restricted 处理
+	if err := db.DB.Migrator().DropTable(&db.PackageAccess{}); err != nil {
+		t.Fatalf("DropTable failed: %v", err)
+	}
+	if level := PackageAccessLevel("lodash"); level != AccessRestricted {
+		t.Errorf("Expected restricted when rules cannot be loaded, got %s", level)
+	}
+	if CanReadPackage(nil, "lodash") {
+		t.Error("Anonymous user can read a package while access rules are unavailable")
+	}
+	if NewReadFilter(nil).CanRead("lodash") {
+		t.Error("ReadFilter allows a package while access rules are unavailable")
+	}
+	if !NewReadFilter(&User{ID: 1, Username: "root", Role: "admin"}).CanRead("lodash") {
+		t.Error("Admin should still be able to read packages")
+	}
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// PackageAction 包级操作
+type PackageAction string
+
+const (
+	ActionPublish      PackageAction = "publish"
+	ActionUnpublish    PackageAction = "unpublish"
+	ActionDeprecate    PackageAction = "deprecate"
+	ActionDistTag      PackageAction = "dist-tag"
+	ActionManageOwners PackageAction = "owner"
+)
+
+// MetadataStore 授权服务同步 metadata.json 中 maintainers 所需的存储能力
+type MetadataStore interface {
+	HasPackage(name string) bool
+	GetMetadata(name string) ([]byte, error)
+	SaveMetadata(name string, data []byte) error
+}
+
+// PackageAuthorizer 统一的包级授权服务
+// package_owners 表是唯一的权限来源，metadata.json 中的 maintainers 只是它的镜像
+type PackageAuthorizer struct {
+	store MetadataStore
+}
+
+// NewPackageAuthorizer 创建 PackageAuthorizer
+func NewPackageAuthorizer(store MetadataStore) *PackageAuthorizer {
+	return &PackageAuthorizer{store: store}
+}
+
+// Can 检查用户能否对包执行指定操作
+//
+//   - admin 可以执行所有操作
+//   - 包所属组织的 owner/admin 可以执行所有操作
+//   - owner（含通配符规则）可以管理 owners；CanPublish 的 owner 还可以发布、删除、废弃、修改 dist-tag
+//   - read-write 授权（个人或团队）可以发布、废弃、修改 dist-tag
+//   - 没有任何 owner 规则的包允许任何人发布（向后兼容），其他操作被拒绝
+func (a *PackageAuthorizer) Can(user *User, packageName string, action PackageAction) bool {
+	if user == nil {
+		return false
+	}
+	if user.Role == "admin" {
+		return true
+	}
+	if isOrgManagerForPackage(user, packageName) {
+		return true
+	}
+
+	owners, err := EffectiveOwners(packageName)
+	if err != nil {
+		logger.Warnf("Failed to check package owners: %v", err)
+		return false
+	}
+
+	if len(owners) == 0 && action == ActionPublish {
+		return CanCreatePackage(user, packageName)
+	}
+
+	for _, owner := range owners {
+		if owner.UserID != user.ID {
+			continue
+		}
+		if action == ActionManageOwners || owner.CanPublish {
+			return true
+		}
+	}
+
+	switch action {
+	case ActionPublish, ActionDeprecate, ActionDistTag:
+		return HasPackageGrant(user, packageName, PermissionReadWrite) ||
+			HasTeamGrant(user, packageName, PermissionReadWrite)
+	}
+	return false
+}
+
+// Authorize 检查当前请求能否对包执行操作：用户需要有权限，使用 token 认证时 token 的范围也必须允许
+func (a *PackageAuthorizer) Authorize(c *gin.Context, packageName string, action PackageAction) bool {
+	if !TokenAllows(c, action, packageName) {
+		return false
+	}
+	return a.Can(GetCurrentUser(c), packageName, action)
+}
+
+// AddOwner 添加包 owner 并同步 maintainers（已存在时保留原有的 CanPublish）
+func (a *PackageAuthorizer) AddOwner(packageName string, userID uint) error {
+	var owner db.PackageOwner
+	if err := db.DB.Where("package_name = ? AND user_id = ?", packageName, userID).
+		Attrs(db.PackageOwner{CanPublish: true}).
+		FirstOrCreate(&owner, db.PackageOwner{PackageName: packageName, UserID: userID}).Error; err != nil {
+		return err
+	}
+	return a.SyncMaintainers(packageName)
+}
+
+// RemoveOwner 移除包 owner 并同步 maintainers
+func (a *PackageAuthorizer) RemoveOwner(packageName string, userID uint) error {
+	if err := db.DB.Where("package_name = ? AND user_id = ?", packageName, userID).
+		Delete(&db.PackageOwner{}).Error; err != nil {
+		return err
+	}
+	return a.SyncMaintainers(packageName)
+}
+
+// SyncMaintainers 用 package_owners 中的精确规则重写 metadata.json 的 maintainers
+// 通配符规则不写入 maintainers；包不存在或没有 owner 时不做修改
+func (a *PackageAuthorizer) SyncMaintainers(packageName string) error {
+	if IsOwnerPattern(packageName) || a.store == nil || !a.store.HasPackage(packageName) {
+		return nil
+	}
+
+	var owners []db.PackageOwner
+	if err := db.DB.Where("package_name = ?", packageName).Preload("User").Order("id").Find(&owners).Error; err != nil {
+		return err
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+
+	data, err := a.store.GetMetadata(packageName)
+	if err != nil {
+		return err
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("invalid metadata for %s: %w", packageName, err)
+	}
+
+	meta["maintainers"] = maintainersFromOwners(owners)
+	updated, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return a.store.SaveMetadata(packageName, updated)
+}
+
+// ReconcileResult maintainers 与 package_owners 对账结果
+type ReconcileResult struct {
+	Packages    int `json:"packages"`    // 检查的包数量
+	OwnersAdded int `json:"ownersAdded"` // 根据 maintainers 补充的 owner 记录
+	Synced      int `json:"synced"`      // 重写了 maintainers 的包数量
+}
+
+// Reconcile 对账私有包的 maintainers 与 package_owners：
+// maintainers 中存在且在本地有账号的用户补充为 owner，然后以 package_owners 为准重写 maintainers
+func (a *PackageAuthorizer) Reconcile(packageNames []string) (*ReconcileResult, error) {
+	result := &ReconcileResult{}
+	for _, name := range packageNames {
+		result.Packages++
+
+		data, err := a.store.GetMetadata(name)
+		if err != nil {
+			logger.Warnf("Reconcile: failed to read metadata of %s: %v", name, err)
+			continue
+		}
+		var meta map[string]interface{}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			logger.Warnf("Reconcile: invalid metadata of %s: %v", name, err)
+			continue
+		}
+
+		list, _ := meta["maintainers"].([]interface{})
+		for _, username := range MaintainerNames(list) {
+			var user db.User
+			if err := db.DB.Where("username = ?", username).First(&user).Error; err != nil {
+				continue
+			}
+			var owner db.PackageOwner
+			res := db.DB.Where(db.PackageOwner{PackageName: name, UserID: user.ID}).
+				Attrs(db.PackageOwner{CanPublish: true}).
+				FirstOrCreate(&owner)
+			if res.Error != nil {
+				return result, res.Error
+			}
+			if res.RowsAffected > 0 {
+				result.OwnersAdded++
+			}
+		}
+
+		if err := a.SyncMaintainers(name); err != nil {
+			logger.Warnf("Reconcile: failed to sync maintainers of %s: %v", name, err)
+			continue
+		}
+		result.Synced++
+	}
+	return result, nil
+}
+
+// maintainersFromOwners 将 owner 记录转换为 npm maintainers 格式
+func maintainersFromOwners(owners []db.PackageOwner) []map[string]string {
+	maintainers := make([]map[string]string, 0, len(owners))
+	for _, owner := range owners {
+		if owner.User == nil {
+			continue
+		}
+		m := map[string]string{"name": owner.User.Username}
+		if owner.User.Email != "" {
+			m["email"] = owner.User.Email
+		}
+		maintainers = append(maintainers, m)
+	}
+	return maintainers
+}
+
+// MaintainerNames 从 npm maintainers 列表中读取用户名，支持对象和 "name <email>" 字符串两种格式
+func MaintainerNames(list []interface{}) []string {
+	var names []string
+	for _, m := range list {
+		switch v := m.(type) {
+		case map[string]interface{}:
+			if name, ok := v["name"].(string); ok && name != "" {
+				names = append(names, name)
+			}
+		case string:
+			// 旧格式 "name <email>"
+			if name := parsePersonName(v); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// parsePersonName 解析 "name <email> (url)" 形式的 person 字符串
+func parsePersonName(s string) string {
+	if idx := strings.IndexAny(s, "<("); idx >= 0 {
+		s = s[:idx]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+// memoryMetadataStore 测试用的内存元数据存储
+type memoryMetadataStore map[string][]byte
+
+func (m memoryMetadataStore) HasPackage(name string) bool {
+	_, ok := m[name]
+	return ok
+}
+
+func (m memoryMetadataStore) GetMetadata(name string) ([]byte, error) {
+	return m[name], nil
+}
+
+func (m memoryMetadataStore) SaveMetadata(name string, data []byte) error {
+	m[name] = data
+	return nil
+}
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	dbtest.Open(t,
+		&db.User{}, &db.UserTwoFactor{}, &db.UserSession{}, &db.Token{}, &db.PasswordReset{}, &db.AccountLockout{},
+		&db.PackageOwner{}, &db.PackageGrant{},
+		&db.Organization{}, &db.OrgMember{}, &db.Team{}, &db.TeamMember{}, &db.TeamGrant{},
+	)
+}
+
+func createTestUser(t *testing.T, username, role string) *User {
+	t.Helper()
+	u := &db.User{Username: username, Password: "x", Email: username + "@example.com", Role: role}
+	if err := db.DB.Create(u).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return &User{ID: u.ID, Username: u.Username, Role: u.Role}
+}
+
+func readMaintainers(t *testing.T, store memoryMetadataStore, name string) []string {
+	t.Helper()
+	var meta map[string]interface{}
+	if err := json.Unmarshal(store[name], &meta); err != nil {
+		t.Fatalf("Invalid metadata: %v", err)
+	}
+	list, _ := meta["maintainers"].([]interface{})
+	return MaintainerNames(list)
+}
+
+func TestPackageAuthorizer_Can(t *testing.T) {
+	setupTestDB(t)
+	authz := NewPackageAuthorizer(memoryMetadataStore{})
+
+	admin := createTestUser(t, "root", "admin")
+	alice := createTestUser(t, "alice", "developer")
+	bob := createTestUser(t, "bob", "developer")
+	carol := createTestUser(t, "carol", "developer")
+
+	if err := authz.AddOwner("lib", alice.ID); err != nil {
+		t.Fatalf("AddOwner failed: %v", err)
+	}
+	db.DB.Create(&db.PackageGrant{PackageName: "lib", UserID: bob.ID, Permission: PermissionReadWrite})
+
+	tests := []struct {
+		name   string
+		user   *User
+		pkg    string
+		action PackageAction
+		want   bool
+	}{
+		{"anonymous", nil, "lib", ActionPublish, false},
+		{"admin unpublish", admin, "lib", ActionUnpublish, true},
+		{"owner unpublish", alice, "lib", ActionUnpublish, true},
+		{"owner manages owners", alice, "lib", ActionManageOwners, true},
+		{"grant publish", bob, "lib", ActionPublish, true},
+		{"grant dist-tag", bob, "lib", ActionDistTag, true},
+		{"grant cannot unpublish", bob, "lib", ActionUnpublish, false},
+		{"grant cannot manage owners", bob, "lib", ActionManageOwners, false},
+		{"stranger publish", carol, "lib", ActionPublish, false},
+		{"unowned package publish", carol, "fresh", ActionPublish, true},
+		{"unowned package unpublish", carol, "fresh", ActionUnpublish, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := authz.Can(tt.user, tt.pkg, tt.action); got != tt.want {
+				t.Errorf("Can(%s, %s) = %v, want %v", tt.pkg, tt.action, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackageAuthorizer_SyncMaintainers(t *testing.T) {
+	setupTestDB(t)
+	store := memoryMetadataStore{"lib": []byte(`{"name":"lib","maintainers":[{"name":"ghost"}]}`)}
+	authz := NewPackageAuthorizer(store)
+
+	alice := createTestUser(t, "alice", "developer")
+	bob := createTestUser(t, "bob", "developer")
+
+	if err := authz.AddOwner("lib", alice.ID); err != nil {
+		t.Fatalf("AddOwner failed: %v", err)
+	}
+	if err := authz.AddOwner("lib", bob.ID); err != nil {
+		t.Fatalf("AddOwner failed: %v", err)
+	}
+	if got := readMaintainers(t, store, "lib"); len(got) != 2 || got[0] != "alice" || got[1] != "bob" {
+		t.Fatalf("Unexpected maintainers after add: %v", got)
+	}
+
+	// 再次添加已存在的 owner 不会恢复被收回的发布权限
+	db.DB.Model(&db.PackageOwner{}).Where("package_name = ? AND user_id = ?", "lib", bob.ID).Update("can_publish", false)
+	if err := authz.AddOwner("lib", bob.ID); err != nil {
+		t.Fatalf("AddOwner failed: %v", err)
+	}
+	var owner db.PackageOwner
+	db.DB.Where("package_name = ? AND user_id = ?", "lib", bob.ID).First(&owner)
+	if owner.CanPublish {
+		t.Error("AddOwner should keep CanPublish of an existing owner")
+	}
+
+	if err := authz.RemoveOwner("lib", alice.ID); err != nil {
+		t.Fatalf("RemoveOwner failed: %v", err)
+	}
+	if got := readMaintainers(t, store, "lib"); len(got) != 1 || got[0] != "bob" {
+		t.Fatalf("Unexpected maintainers after remove: %v", got)
+	}
+}
+
+func TestPackageAuthorizer_Reconcile(t *testing.T) {
+	setupTestDB(t)
+	store := memoryMetadataStore{
+		"lib":    []byte(`{"name":"lib","maintainers":[{"name":"alice"},"ghost <ghost@example.com>"]}`),
+		"legacy": []byte(`{"name":"legacy","maintainers":["bob <bob@example.com>"]}`),
+	}
+	authz := NewPackageAuthorizer(store)
+
+	alice := createTestUser(t, "alice", "developer")
+	bob := createTestUser(t, "bob", "developer")
+
+	result, err := authz.Reconcile([]string{"lib", "legacy"})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result.Packages != 2 || result.OwnersAdded != 2 {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+
+	if !IsPackageOwner(alice, "lib") || !IsPackageOwner(bob, "legacy") {
+		t.Fatal("Expected maintainers to become owners")
+	}
+	// 本地不存在的 maintainer 不再出现在 maintainers 中
+	if got := readMaintainers(t, store, "lib"); len(got) != 1 || got[0] != "alice" {
+		t.Fatalf("Unexpected maintainers: %v", got)
+	}
+
+	// 再次对账不会重复添加
+	result, err = authz.Reconcile([]string{"lib", "legacy"})
+	if err != nil || result.OwnersAdded != 0 {
+		t.Fatalf("Expected idempotent reconcile, got %+v, %v", result, err)
+	}
+}
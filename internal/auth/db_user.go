@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
 	"gorm.io/gorm"
 )
 
@@ -14,14 +15,31 @@ var (
 	ErrUserAlreadyExists = errors.New("user already exists")
 )
 
+// 用户认证来源
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+	AuthSourceOIDC  = "oidc"
+)
+
 type User struct {
 	ID         uint       `json:"id"`
 	Username   string     `json:"username"`
 	Email      string     `json:"email"`
+	FullName   string     `json:"fullname,omitempty"`
 	Password   string     `json:"-"`
 	Role       string     `json:"role"`
+	AuthSource string     `json:"authSource,omitempty"` // local | ldap | oidc
 	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
 	LastLogin  *time.Time `json:"lastLogin,omitempty"`
+
+	MustChangePassword bool `json:"mustChangePassword,omitempty"` // 下次登录必须修改密码
+}
+
+// IsExternal 账号是否由外部身份源（LDAP、OIDC）管理，此类账号没有可用的本地密码
+func (u *User) IsExternal() bool {
+	return u.AuthSource != "" && u.AuthSource != AuthSourceLocal
 }
 
 type UserStore interface {
@@ -62,9 +80,16 @@ func (s *DBUserStore) Create(user *User) error {
 	}
 
 	dbUser := &db.User{
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
+		Username:   user.Username,
+		Email:      user.Email,
+		FullName:   user.FullName,
+		Role:       user.Role,
+		AuthSource: user.AuthSource,
+
+		MustChangePassword: user.MustChangePassword,
+	}
+	if dbUser.AuthSource == "" {
+		dbUser.AuthSource = AuthSourceLocal
 	}
 
 	if err := dbUser.SetPassword(user.Password); err != nil {
@@ -76,7 +101,9 @@ func (s *DBUserStore) Create(user *User) error {
 		return result.Error
 	}
 
+	user.ID = dbUser.ID
 	user.CreatedAt = dbUser.CreatedAt
+	user.UpdatedAt = dbUser.UpdatedAt
 	return nil
 }
 
@@ -88,11 +115,16 @@ func (s *DBUserStore) Update(user *User) error {
 	}
 
 	dbUser.Email = user.Email
+	dbUser.FullName = user.FullName
 	dbUser.Role = user.Role
 	dbUser.LastLogin = user.LastLogin
+	dbUser.MustChangePassword = user.MustChangePassword
 
-	// 如果 Password 非空，则更新密码
+	// 如果 Password 非空，则更新密码；外部账号的密码由身份源管理
 	if user.Password != "" {
+		if s.toUser(&dbUser).IsExternal() {
+			return ErrExternalPassword
+		}
 		if err := dbUser.SetPassword(user.Password); err != nil {
 			return err
 		}
@@ -102,14 +134,27 @@ func (s *DBUserStore) Update(user *User) error {
 }
 
 func (s *DBUserStore) Delete(username string) error {
-	result := db.DB.Where("username = ?", username).Delete(&db.User{})
-	if result.Error != nil {
-		return result.Error
+	var dbUser db.User
+	if err := db.DB.Where("username = ?", username).First(&dbUser).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return ErrUserNotFound
+	if err := db.DB.Delete(&dbUser).Error; err != nil {
+		return err
 	}
-	return nil
+	// 撤销登录会话和持久化 token，并清理两步验证密钥和锁定记录
+	if _, err := RevokeUserSessions(dbUser.ID, ""); err != nil {
+		return err
+	}
+	if err := db.DB.Where("user_id = ?", dbUser.ID).Delete(&db.Token{}).Error; err != nil {
+		return err
+	}
+	if err := UnlockAccount(dbUser.ID); err != nil {
+		return err
+	}
+	return DisableTwoFactor(dbUser.ID)
 }
 
 func (s *DBUserStore) List() []*User {
@@ -130,20 +175,43 @@ func (s *DBUserStore) Validate(username, password string) (*User, error) {
 		return nil, ErrUserNotFound
 	}
 
+	user := s.toUser(&dbUser)
+	if user.IsExternal() {
+		return nil, ErrInvalidPassword
+	}
+
+	// 从 htpasswd 导入的 SHA1 / crypt 哈希校验通过后升级为 bcrypt
+	if isLegacyHash(dbUser.Password) {
+		if !checkLegacyPassword(dbUser.Password, password) {
+			return nil, ErrInvalidPassword
+		}
+		if err := dbUser.SetPassword(password); err == nil {
+			if err := db.DB.Model(&dbUser).Update("password", dbUser.Password).Error; err != nil {
+				logger.Errorf("Failed to upgrade password hash of %s: %v", username, err)
+			}
+		}
+		return user, nil
+	}
+
 	if !dbUser.CheckPassword(password) {
 		return nil, ErrInvalidPassword
 	}
 
-	return s.toUser(&dbUser), nil
+	return user, nil
 }
 
 func (s *DBUserStore) toUser(dbUser *db.User) *User {
 	return &User{
-		ID:        dbUser.ID,
-		Username:  dbUser.Username,
-		Email:     dbUser.Email,
-		Role:      dbUser.Role,
-		CreatedAt: dbUser.CreatedAt,
-		LastLogin: dbUser.LastLogin,
+		ID:         dbUser.ID,
+		Username:   dbUser.Username,
+		Email:      dbUser.Email,
+		FullName:   dbUser.FullName,
+		Role:       dbUser.Role,
+		AuthSource: dbUser.AuthSource,
+		CreatedAt:  dbUser.CreatedAt,
+		UpdatedAt:  dbUser.UpdatedAt,
+		LastLogin:  dbUser.LastLogin,
+
+		MustChangePassword: dbUser.MustChangePassword,
 	}
 }
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+var (
+	ErrNoPermittedGroup = errors.New("user is not a member of any permitted group")
+	ErrExternalPassword = errors.New("password is managed by an external identity provider")
+	ErrAccountConflict  = errors.New("a local account with this username already exists")
+)
+
+// rolePriority 用户属于多个映射组时取权限最高的角色
+var rolePriority = map[string]int{
+	"admin":     3,
+	"developer": 2,
+	"readonly":  1,
+}
+
+// ExternalIdentity 外部身份源（LDAP 目录、OIDC IdP）认证通过的用户
+type ExternalIdentity struct {
+	Source   string // AuthSourceLDAP | AuthSourceOIDC
+	Username string
+	Email    string
+	FullName string
+	Role     string
+}
+
+// externalSyncOptions 同步外部用户到本地账号的选项
+type externalSyncOptions struct {
+	AutoProvision bool // 本地账号不存在时自动创建
+	SyncRole      bool // 以身份源的角色覆盖本地角色
+	// CanLink 是否允许接管同名的本地账号（或其他身份源创建的账号），为空时不允许
+	CanLink func(existing *User) bool
+}
+
+// MapGroupsToRole 根据组映射角色（组名不区分大小写），多个组时取最高权限；没有匹配时返回默认角色
+func MapGroupsToRole(groups []string, mapping map[string]string, defaultRole string) string {
+	role := ""
+	for _, group := range groups {
+		for name, mapped := range mapping {
+			if strings.EqualFold(name, group) && rolePriority[mapped] > rolePriority[role] {
+				role = mapped
+			}
+		}
+	}
+	if role == "" {
+		role = defaultRole
+	}
+	return role
+}
+
+// syncExternalUser 将外部用户同步到本地 users 表，按需自动创建
+func syncExternalUser(identity *ExternalIdentity, opts externalSyncOptions) (*User, error) {
+	local := NewDBUserStore()
+	user, err := local.Get(identity.Username)
+	if errors.Is(err, ErrUserNotFound) {
+		if !opts.AutoProvision {
+			return nil, ErrUserNotFound
+		}
+		user = &User{
+			Username:   identity.Username,
+			Email:      identity.Email,
+			FullName:   identity.FullName,
+			Role:       identity.Role,
+			AuthSource: identity.Source,
+			Password:   randomPassword(),
+		}
+		if err := local.Create(user); err != nil {
+			return nil, err
+		}
+		logger.Infof("Provisioned %s user: %s (%s)", identity.Source, user.Username, user.Role)
+		return local.Get(identity.Username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.AuthSource != identity.Source && (opts.CanLink == nil || !opts.CanLink(user)) {
+		return nil, ErrAccountConflict
+	}
+
+	updates := map[string]interface{}{
+		"full_name":   identity.FullName,
+		"auth_source": identity.Source,
+	}
+	if identity.Email != "" {
+		updates["email"] = identity.Email
+	}
+	if opts.SyncRole {
+		updates["role"] = identity.Role
+	}
+	if err := db.DB.Model(&db.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	// 身份源中的角色变化后，按旧角色签发的会话失效
+	if opts.SyncRole && user.Role != identity.Role {
+		if _, err := RevokeUserSessions(user.ID, ""); err != nil {
+			return nil, err
+		}
+	}
+	return local.Get(identity.Username)
+}
+
+// randomPassword 为外部用户生成无法使用的本地密码
+func randomPassword() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+	"gorm.io/gorm"
+)
+
+// htpasswd 中支持的哈希格式
+const (
+	HashBcrypt = "bcrypt"
+	HashSHA1   = "sha1"
+	HashCrypt  = "crypt"
+)
+
+var ErrUnsupportedHash = errors.New("unsupported password hash format, only bcrypt, SHA1 and crypt are supported")
+
+// HtpasswdEntry htpasswd 文件中的一个账号
+type HtpasswdEntry struct {
+	Line     int
+	Username string
+	Hash     string
+}
+
+// HtpasswdImportOptions 导入选项
+type HtpasswdImportOptions struct {
+	Role               string // 新账号的角色，默认 developer
+	Overwrite          bool   // 覆盖已存在的本地账号的密码
+	MustChangePassword bool   // 导入的账号首次登录时必须修改密码
+	DryRun             bool   // 只检查，不写入数据库
+}
+
+// HtpasswdImportResult 导入结果
+type HtpasswdImportResult struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Skipped []string `json:"skipped"` // 已存在且未指定覆盖
+	Errors  []string `json:"errors"`  // 无法导入的行
+}
+
+// ParseHtpasswd 解析 htpasswd 文件，每行形如 user:hash，Verdaccio 还会在后面追加 :autocreated <时间>
+// 无法解析的行作为错误返回，不影响其他行
+func ParseHtpasswd(r io.Reader) ([]HtpasswdEntry, []string, error) {
+	var entries []HtpasswdEntry
+	var problems []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			problems = append(problems, fmt.Sprintf("line %d: expected user:hash", line))
+			continue
+		}
+		username, hash := parts[0], parts[1]
+		if htpasswdHashKind(hash) == "" {
+			problems = append(problems, fmt.Sprintf("line %d: %s: %v", line, username, ErrUnsupportedHash))
+			continue
+		}
+		if seen[username] {
+			problems = append(problems, fmt.Sprintf("line %d: %s: duplicate user", line, username))
+			continue
+		}
+		seen[username] = true
+		entries = append(entries, HtpasswdEntry{Line: line, Username: username, Hash: hash})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return entries, problems, nil
+}
+
+// htpasswdHashKind 识别哈希格式，不支持的格式（如 $apr1$、$6$）返回空字符串
+func htpasswdHashKind(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return HashBcrypt
+	case strings.HasPrefix(hash, "{SHA}"):
+		return HashSHA1
+	case len(hash) == 13 && strings.Trim(hash, cryptAlphabet) == "":
+		return HashCrypt
+	}
+	return ""
+}
+
+// isLegacyHash 是否为从 htpasswd 导入、登录时需要升级为 bcrypt 的哈希
+func isLegacyHash(hash string) bool {
+	kind := htpasswdHashKind(hash)
+	return kind == HashSHA1 || kind == HashCrypt
+}
+
+// checkLegacyPassword 校验 SHA1 / crypt 格式的密码
+func checkLegacyPassword(hash, password string) bool {
+	var expected string
+	switch htpasswdHashKind(hash) {
+	case HashSHA1:
+		sum := sha1.Sum([]byte(password))
+		expected = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	case HashCrypt:
+		expected = desCrypt(password, hash[:2])
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) == 1
+}
+
+// ImportHtpasswd 把 htpasswd 中的账号导入为本地用户，直接保存原始哈希
+// SHA1 / crypt 哈希在用户第一次登录成功时由 DBUserStore.Validate 升级为 bcrypt
+func ImportHtpasswd(r io.Reader, opts HtpasswdImportOptions) (*HtpasswdImportResult, error) {
+	if err := ValidateRole(opts.Role); err != nil {
+		return nil, err
+	}
+	role := opts.Role
+	if role == "" {
+		role = "developer"
+	}
+
+	entries, problems, err := ParseHtpasswd(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &HtpasswdImportResult{
+		Created: []string{},
+		Updated: []string{},
+		Skipped: []string{},
+		Errors:  problems,
+	}
+	if result.Errors == nil {
+		result.Errors = []string{}
+	}
+
+	var revoke []uint
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range entries {
+			var existing db.User
+			err := tx.Where("username = ?", entry.Username).First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if !opts.DryRun {
+					user := &db.User{
+						Username:           entry.Username,
+						Password:           entry.Hash,
+						Role:               role,
+						AuthSource:         AuthSourceLocal,
+						MustChangePassword: opts.MustChangePassword,
+					}
+					if err := tx.Create(user).Error; err != nil {
+						return err
+					}
+				}
+				result.Created = append(result.Created, entry.Username)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case existing.AuthSource != "" && existing.AuthSource != AuthSourceLocal:
+				result.Errors = append(result.Errors, fmt.Sprintf("line %d: %s: %v", entry.Line, entry.Username, ErrExternalPassword))
+			case !opts.Overwrite:
+				result.Skipped = append(result.Skipped, entry.Username)
+			default:
+				if !opts.DryRun {
+					updates := map[string]any{"password": entry.Hash}
+					if opts.MustChangePassword {
+						updates["must_change_password"] = true
+					}
+					if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+						return err
+					}
+					revoke = append(revoke, existing.ID)
+				}
+				result.Updated = append(result.Updated, entry.Username)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 密码被覆盖的账号之前签发的会话全部失效
+	for _, id := range revoke {
+		if _, err := RevokeUserSessions(id, ""); err != nil {
+			logger.Errorf("Failed to revoke sessions of user %d: %v", id, err)
+		}
+	}
+	return result, nil
+}
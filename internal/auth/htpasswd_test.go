@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDesCrypt(t *testing.T) {
+	// 与 glibc / perl crypt() 的结果对比
+	tests := []struct{ password, salt, want string }{
+		{"password", "ab", "abJnggxhB/yWI"},
+		{"test", "aa", "aaqPiZY5xR5l."},
+		{"verylongpassword", "Zz", "ZzaGq1X64h1eU"}, // 只取前 8 个字符
+		{"", "./", "./Una9Fi.seRo"},
+	}
+	for _, tt := range tests {
+		if got := desCrypt(tt.password, tt.salt); got != tt.want {
+			t.Errorf("desCrypt(%q, %q) = %q, want %q", tt.password, tt.salt, got, tt.want)
+		}
+	}
+}
+
+func TestImportHtpasswd(t *testing.T) {
+	setupTestDB(t)
+	store := NewDBUserStore()
+	createTestUser(t, "existing", "developer")
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("bcrypt-pass"), bcrypt.MinCost)
+	bcryptHash := strings.Replace(string(hash), "$2a$", "$2y$", 1) // htpasswd -B 生成的前缀
+	htpasswd := strings.Join([]string{
+		"# exported from verdaccio",
+		"alice:" + bcryptHash + ":autocreated 2024-01-15T08:00:00.000Z",
+		"bob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", // sha1("password")
+		"carol:abJnggxhB/yWI",                   // crypt("password", "ab")
+		"dave:$apr1$salt$hash",
+		"broken-line",
+		"bob:{SHA}duplicate",
+		"existing:abJnggxhB/yWI",
+	}, "\n")
+
+	// dry run 不写入数据库
+	result, err := ImportHtpasswd(strings.NewReader(htpasswd), HtpasswdImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportHtpasswd failed: %v", err)
+	}
+	if len(result.Created) != 3 || len(result.Skipped) != 1 || len(result.Errors) != 3 {
+		t.Fatalf("Unexpected dry run result: %+v", result)
+	}
+	if _, err := store.Get("alice"); err != ErrUserNotFound {
+		t.Fatal("Expected dry run not to create users")
+	}
+
+	if _, err := ImportHtpasswd(strings.NewReader(htpasswd), HtpasswdImportOptions{Role: "readonly"}); err != nil {
+		t.Fatalf("ImportHtpasswd failed: %v", err)
+	}
+	if u, _ := store.Get("alice"); u == nil || u.Role != "readonly" {
+		t.Fatalf("Expected alice to be imported as readonly, got %+v", u)
+	}
+	if _, err := store.Validate("existing", "password"); err == nil {
+		t.Error("Expected existing user to be skipped without overwrite")
+	}
+
+	logins := map[string]string{"alice": "bcrypt-pass", "bob": "password", "carol": "password"}
+	for name, password := range logins {
+		if _, err := store.Validate(name, "wrong-password"); err != ErrInvalidPassword {
+			t.Errorf("%s: expected wrong password to be rejected, got %v", name, err)
+		}
+		if _, err := store.Validate(name, password); err != nil {
+			t.Errorf("%s: Validate failed: %v", name, err)
+		}
+
+		// 校验通过后 SHA1 / crypt 升级为 bcrypt
+		var dbUser db.User
+		db.DB.Where("username = ?", name).First(&dbUser)
+		if isLegacyHash(dbUser.Password) {
+			t.Errorf("%s: expected legacy hash to be upgraded", name)
+		}
+		if _, err := store.Validate(name, password); err != nil {
+			t.Errorf("%s: Validate after upgrade failed: %v", name, err)
+		}
+	}
+
+	// 覆盖已存在账号的密码
+	result, err = ImportHtpasswd(strings.NewReader("existing:abJnggxhB/yWI"), HtpasswdImportOptions{Overwrite: true, MustChangePassword: true})
+	if err != nil || len(result.Updated) != 1 {
+		t.Fatalf("Expected existing user to be updated, got %+v, %v", result, err)
+	}
+	if u, err := store.Validate("existing", "password"); err != nil || !u.MustChangePassword {
+		t.Errorf("Expected overwritten password with must-change flag, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksMinRefresh 遇到未知 kid 时两次刷新 JWKS 的最小间隔，避免被伪造的 token 放大请求
+const jwksMinRefresh = 30 * time.Second
+
+// KeySet 远程 JWKS 公钥集合，按 kid 缓存，遇到未知 kid 时重新拉取（应对 IdP 轮换密钥）
+type KeySet struct {
+	url     string
+	client  *http.Client
+	mu      sync.Mutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// jsonWebKey JWKS 中的单个公钥（仅支持 RSA 与 EC 签名密钥）
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewKeySet 创建 KeySet，client 为空时使用 http.DefaultClient
+func NewKeySet(url string, client *http.Client) *KeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &KeySet{url: url, client: client}
+}
+
+// Keyfunc 供 jwt.Parse 使用，根据 token header 中的 kid 返回公钥
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+	if k.keys != nil && time.Since(k.fetched) < jwksMinRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := k.refresh(); err != nil {
+		return nil, err
+	}
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup 按 kid 查找公钥；token 未指定 kid 且只有一个密钥时直接使用该密钥
+func (k *KeySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// refresh 重新拉取 JWKS，调用方需持有锁
+func (k *KeySet) refresh() error {
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue // 忽略不支持的密钥类型
+		}
+		keys[jwk.Kid] = key
+	}
+	k.keys = keys
+	k.fetched = time.Now()
+	return nil
+}
+
+// publicKey 将 JWK 转换为 crypto 公钥
+func (j *jsonWebKey) publicKey() (interface{}, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decodeJWKInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -34,21 +36,33 @@ func NewJWTService(secret string, expiresIn time.Duration) *JWTService {
 	}
 }
 
-func (s *JWTService) GenerateToken(user *User) (string, error) {
+// signToken 签发带 jti 的 JWT，返回 token 和过期时间
+func (s *JWTService) signToken(user *User, jti string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.expiresIn)
 	claims := &Claims{
 		Username: user.Username,
 		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.expiresIn)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "grape",
 			Subject:   user.Username,
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secret)
+	signed, err := token.SignedString(s.secret)
+	return signed, expiresAt, err
+}
+
+// newJTI 生成 128 位随机 JWT ID
+func newJTI() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
@@ -82,26 +96,3 @@ func (s *JWTService) UpdateSecret(secret string, expiresIn time.Duration) {
 		s.expiresIn = expiresIn
 	}
 }
-
-func (s *JWTService) RefreshToken(tokenString string) (string, error) {
-	claims, err := s.ValidateToken(tokenString)
-	if err != nil {
-		return "", err
-	}
-
-	// 创建新的 claims
-	newClaims := &Claims{
-		Username: claims.Username,
-		Role:     claims.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.expiresIn)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "grape",
-			Subject:   claims.Username,
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
-	return token.SignedString(s.secret)
-}
@@ -17,7 +17,7 @@ func TestJWTService_GenerateAndValidate(t *testing.T) {
 	}
 
 	// 生成 token
-	token, err := service.GenerateToken(user)
+	token, _, err := service.signToken(user, newJTI())
 	if err != nil {
 		t.Fatalf("Failed to generate token: %v", err)
 	}
@@ -57,7 +57,7 @@ func TestJWTService_WrongSecret(t *testing.T) {
 
 	user := &User{Username: "test", Role: "developer"}
 
-	token, _ := service1.GenerateToken(user)
+	token, _, _ := service1.signToken(user, newJTI())
 
 	// 使用不同密钥验证
 	_, err := service2.ValidateToken(token)
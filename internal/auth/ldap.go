@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// LDAPUserStore LDAP / Active Directory 用户存储
+// 凭据在目录中校验，本地 users 表保存账号镜像，供 token、package owner 等关联数据使用；
+// 目录中不存在的本地账号（如默认管理员）以及未开启 link_local_accounts 时与目录同名的本地账号仍使用本地密码登录
+type LDAPUserStore struct {
+	cfg   *config.LDAPConfig
+	local *DBUserStore
+}
+
+// ldapEntry 目录中的用户
+type ldapEntry struct {
+	DN       string
+	Username string
+	Email    string
+	FullName string
+	Groups   []string
+}
+
+// NewLDAPUserStore 创建 LDAPUserStore
+func NewLDAPUserStore(cfg *config.LDAPConfig) *LDAPUserStore {
+	return &LDAPUserStore{cfg: cfg, local: NewDBUserStore()}
+}
+
+func (s *LDAPUserStore) Get(username string) (*User, error) {
+	return s.local.Get(username)
+}
+
+func (s *LDAPUserStore) Create(user *User) error {
+	return s.local.Create(user)
+}
+
+// Update 目录用户的密码由目录管理，本地存储会拒绝修改
+func (s *LDAPUserStore) Update(user *User) error {
+	return s.local.Update(user)
+}
+
+func (s *LDAPUserStore) Delete(username string) error {
+	return s.local.Delete(username)
+}
+
+func (s *LDAPUserStore) List() []*User {
+	return s.local.List()
+}
+
+// Validate 在目录中校验用户名密码，成功后同步（或自动创建）本地账号
+func (s *LDAPUserStore) Validate(username, password string) (*User, error) {
+	// 空密码会变成匿名 bind，必须拒绝
+	if password == "" {
+		return nil, ErrInvalidPassword
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		logger.Warnf("LDAP unavailable, falling back to local accounts: %v", err)
+		user, lerr := s.validateLocal(username, password)
+		// 目录不可用时无法判断用户名是否属于目录，不能当作不存在的用户（否则会走自助注册）
+		if lerr == ErrUserNotFound {
+			return nil, fmt.Errorf("ldap unavailable: %w", err)
+		}
+		return user, lerr
+	}
+	defer conn.Close()
+
+	entry, err := s.findUser(conn, username)
+	if errors.Is(err, ErrUserNotFound) {
+		return s.validateLocal(username, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 管理员未开启关联时，同名的本地账号不能被目录接管，仍使用本地密码
+	if existing, err := s.local.Get(entry.Username); err == nil && !existing.IsExternal() && !s.cfg.LinkLocalAccounts {
+		logger.Warnf("LDAP user %s matches a local account, linking is disabled", entry.Username)
+		return s.local.Validate(existing.Username, password)
+	}
+
+	// 先用服务账号读取组，再以用户身份 bind 校验密码
+	if entry.Groups, err = s.userGroups(conn, entry); err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidPassword
+		}
+		return nil, fmt.Errorf("ldap bind failed: %w", err)
+	}
+
+	role := MapGroupsToRole(entry.Groups, s.cfg.RoleMapping, s.cfg.DefaultRole)
+	if role == "" {
+		return nil, ErrNoPermittedGroup
+	}
+	return syncExternalUser(&ExternalIdentity{
+		Source:   AuthSourceLDAP,
+		Username: entry.Username,
+		Email:    entry.Email,
+		FullName: entry.FullName,
+		Role:     role,
+	}, externalSyncOptions{
+		AutoProvision: s.cfg.AutoProvision,
+		SyncRole:      len(s.cfg.RoleMapping) > 0,
+		CanLink:       s.canLink,
+	})
+}
+
+// canLink 只有管理员开启 link_local_accounts 时才允许目录用户关联同名的本地账号，OIDC 账号始终不能关联
+func (s *LDAPUserStore) canLink(existing *User) bool {
+	return s.cfg.LinkLocalAccounts && !existing.IsExternal()
+}
+
+// validateLocal 目录中不存在的用户使用本地账号校验；来自目录的账号不允许本地登录
+func (s *LDAPUserStore) validateLocal(username, password string) (*User, error) {
+	if _, err := s.local.Get(username); err != nil {
+		return nil, err
+	}
+	return s.local.Validate(username, password)
+}
+
+// connect 连接目录并以服务账号 bind
+func (s *LDAPUserStore) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(s.cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.Timeout > 0 {
+		conn.SetTimeout(s.cfg.Timeout)
+	}
+	if s.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap starttls failed: %w", err)
+		}
+	}
+	if s.cfg.BindDN != "" {
+		if err := conn.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap service bind failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// findUser 按用户名搜索目录用户
+func (s *LDAPUserStore) findUser(conn *ldap.Conn, username string) (*ldapEntry, error) {
+	req := ldap.NewSearchRequest(
+		s.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(s.cfg.UserFilter, ldap.EscapeFilter(username)),
+		[]string{s.cfg.UsernameAttribute, s.cfg.EmailAttribute, s.cfg.NameAttribute, "memberOf"},
+		nil,
+	)
+	result, err := conn.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("ldap search failed: %w", err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrUserNotFound
+	case 1:
+	default:
+		return nil, fmt.Errorf("ldap search for %s returned multiple entries", username)
+	}
+
+	e := result.Entries[0]
+	entry := &ldapEntry{
+		DN:       e.DN,
+		Username: e.GetAttributeValue(s.cfg.UsernameAttribute),
+		Email:    e.GetAttributeValue(s.cfg.EmailAttribute),
+		FullName: e.GetAttributeValue(s.cfg.NameAttribute),
+	}
+	if entry.Username == "" {
+		entry.Username = username
+	}
+	// 未配置组搜索时使用 memberOf（Active Directory）
+	if s.cfg.GroupBaseDN == "" {
+		for _, dn := range e.GetAttributeValues("memberOf") {
+			entry.Groups = append(entry.Groups, groupNameFromDN(dn))
+		}
+	}
+	return entry, nil
+}
+
+// userGroups 返回用户所属的组名
+func (s *LDAPUserStore) userGroups(conn *ldap.Conn, entry *ldapEntry) ([]string, error) {
+	if s.cfg.GroupBaseDN == "" {
+		return entry.Groups, nil
+	}
+
+	req := ldap.NewSearchRequest(
+		s.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(s.cfg.GroupFilter, ldap.EscapeFilter(entry.DN)),
+		[]string{s.cfg.GroupAttribute},
+		nil,
+	)
+	result, err := conn.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("ldap group search failed: %w", err)
+	}
+	groups := make([]string, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		if name := e.GetAttributeValue(s.cfg.GroupAttribute); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups, nil
+}
+
+// groupNameFromDN 取 DN 第一个 RDN 的值，如 cn=developers,ou=groups,dc=example,dc=com -> developers
+func groupNameFromDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
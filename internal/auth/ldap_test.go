@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/graperegistry/grape/internal/config"
+)
+
+// testLDAPEntry 测试目录中的条目
+type testLDAPEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// startTestLDAP 启动进程内的最小 LDAP 服务，只支持 simple bind 和 and/or/not/equality/present 过滤器
+func startTestLDAP(t *testing.T, entries []testLDAPEntry) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestLDAP(conn, entries)
+		}
+	}()
+	return "ldap://" + ln.Addr().String()
+}
+
+func serveTestLDAP(conn net.Conn, entries []testLDAPEntry) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, _ := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := int64(ldap.LDAPResultInvalidCredentials)
+			for _, e := range entries {
+				if strings.EqualFold(e.dn, dn) && password != "" && e.password == password {
+					code = ldap.LDAPResultSuccess
+				}
+			}
+			conn.Write(testLDAPMessage(id, ldap.ApplicationBindResponse, testLDAPResult(code)...))
+		case ldap.ApplicationSearchRequest:
+			base, _ := op.Children[0].Value.(string)
+			for _, e := range entries {
+				if !strings.HasSuffix(strings.ToLower(e.dn), strings.ToLower(base)) || !matchTestFilter(op.Children[6], e) {
+					continue
+				}
+				attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+				for name, values := range e.attrs {
+					attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+					attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+					vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+					for _, v := range values {
+						vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+					}
+					attr.AppendChild(vals)
+					attrs.AppendChild(attr)
+				}
+				conn.Write(testLDAPMessage(id, ldap.ApplicationSearchResultEntry,
+					ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"),
+					attrs,
+				))
+			}
+			conn.Write(testLDAPMessage(id, ldap.ApplicationSearchResultDone, testLDAPResult(ldap.LDAPResultSuccess)...))
+		default:
+			return
+		}
+	}
+}
+
+func testLDAPMessage(id int64, tag ber.Tag, children ...*ber.Packet) []byte {
+	msg := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "message")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "id"))
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "op")
+	for _, c := range children {
+		op.AppendChild(c)
+	}
+	msg.AppendChild(op)
+	return msg.Bytes()
+}
+
+func testLDAPResult(code int64) []*ber.Packet {
+	return []*ber.Packet{
+		ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"),
+		ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"),
+		ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"),
+	}
+}
+
+func matchTestFilter(f *ber.Packet, e testLDAPEntry) bool {
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, c := range f.Children {
+			if !matchTestFilter(c, e) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, c := range f.Children {
+			if matchTestFilter(c, e) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matchTestFilter(f.Children[0], e)
+	case ldap.FilterEqualityMatch:
+		name, _ := f.Children[0].Value.(string)
+		value, _ := f.Children[1].Value.(string)
+		for _, v := range testAttr(e, name) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return strings.EqualFold(f.Data.String(), "objectClass") || len(testAttr(e, f.Data.String())) > 0
+	}
+	return false
+}
+
+func testAttr(e testLDAPEntry, name string) []string {
+	for k, v := range e.attrs {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// testDirectory 一个 OpenLDAP 风格（groupOfNames）加一个 AD 风格（memberOf）用户
+func testDirectory() []testLDAPEntry {
+	return []testLDAPEntry{
+		{dn: "cn=service,dc=example,dc=com", password: "service-secret"},
+		{
+			dn: "uid=alice,ou=people,dc=example,dc=com", password: "alice-secret",
+			attrs: map[string][]string{"uid": {"alice"}, "mail": {"alice@example.com"}, "cn": {"Alice Liddell"}},
+		},
+		{
+			dn: "uid=bob,ou=people,dc=example,dc=com", password: "bob-secret",
+			attrs: map[string][]string{
+				"uid": {"bob"}, "mail": {"bob@example.com"}, "cn": {"Bob"},
+				"memberOf": {"cn=npm-admins,ou=groups,dc=example,dc=com"},
+			},
+		},
+		{
+			dn: "uid=eve,ou=people,dc=example,dc=com", password: "eve-secret",
+			attrs: map[string][]string{"uid": {"eve"}, "mail": {"eve@example.com"}},
+		},
+		{
+			dn: "cn=npm-devs,ou=groups,dc=example,dc=com",
+			attrs: map[string][]string{
+				"cn":     {"npm-devs"},
+				"member": {"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"},
+			},
+		},
+	}
+}
+
+func testLDAPConfig(url string) *config.LDAPConfig {
+	cfg := config.Default().Auth.LDAP
+	cfg.URL = url
+	cfg.BindDN = "cn=service,dc=example,dc=com"
+	cfg.BindPassword = "service-secret"
+	cfg.BaseDN = "ou=people,dc=example,dc=com"
+	cfg.GroupBaseDN = "ou=groups,dc=example,dc=com"
+	cfg.RoleMapping = map[string]string{"npm-admins": "admin", "npm-devs": "developer"}
+	cfg.DefaultRole = ""
+	cfg.Timeout = 5 * time.Second
+	return &cfg
+}
+
+func TestLDAPUserStore_ValidateProvisionsUser(t *testing.T) {
+	setupTestDB(t)
+	store := NewLDAPUserStore(testLDAPConfig(startTestLDAP(t, testDirectory())))
+
+	user, err := store.Validate("alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if user.ID == 0 || user.Role != "developer" || user.Email != "alice@example.com" ||
+		user.FullName != "Alice Liddell" || user.AuthSource != AuthSourceLDAP {
+		t.Fatalf("Unexpected provisioned user: %+v", user)
+	}
+
+	if _, err := store.Validate("alice", "wrong"); err != ErrInvalidPassword {
+		t.Fatalf("Expected ErrInvalidPassword, got %v", err)
+	}
+	if _, err := store.Validate("alice", ""); err != ErrInvalidPassword {
+		t.Fatalf("Expected empty password to be rejected, got %v", err)
+	}
+
+	// 目录用户的密码不能在本地修改
+	user.Password = "new-password"
+	if err := store.Update(user); err != ErrExternalPassword {
+		t.Fatalf("Expected ErrExternalPassword, got %v", err)
+	}
+}
+
+func TestLDAPUserStore_MemberOfRoleMapping(t *testing.T) {
+	setupTestDB(t)
+	cfg := testLDAPConfig(startTestLDAP(t, testDirectory()))
+	cfg.GroupBaseDN = "" // Active Directory：读取 memberOf
+	store := NewLDAPUserStore(cfg)
+
+	user, err := store.Validate("bob", "bob-secret")
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if user.Role != "admin" {
+		t.Fatalf("Expected admin role from memberOf, got %s", user.Role)
+	}
+}
+
+func TestLDAPUserStore_GroupRequired(t *testing.T) {
+	setupTestDB(t)
+	store := NewLDAPUserStore(testLDAPConfig(startTestLDAP(t, testDirectory())))
+
+	if _, err := store.Validate("eve", "eve-secret"); err != ErrNoPermittedGroup {
+		t.Fatalf("Expected ErrNoPermittedGroup, got %v", err)
+	}
+
+	store.cfg.DefaultRole = "readonly"
+	user, err := store.Validate("eve", "eve-secret")
+	if err != nil || user.Role != "readonly" {
+		t.Fatalf("Expected readonly default role, got %+v, %v", user, err)
+	}
+}
+
+func TestLDAPUserStore_LocalFallbackAndProvisioning(t *testing.T) {
+	setupTestDB(t)
+	cfg := testLDAPConfig(startTestLDAP(t, testDirectory()))
+	cfg.AutoProvision = false
+	store := NewLDAPUserStore(cfg)
+
+	// 目录中不存在的本地账号（如默认管理员）使用本地密码
+	if err := store.Create(&User{Username: "root", Password: "root-secret", Role: "admin"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := store.Validate("root", "root-secret"); err != nil {
+		t.Fatalf("Expected local fallback, got %v", err)
+	}
+
+	// 关闭自动创建时，目录用户必须先由管理员创建本地账号
+	if _, err := store.Validate("alice", "alice-secret"); err != ErrUserNotFound {
+		t.Fatalf("Expected ErrUserNotFound without auto provisioning, got %v", err)
+	}
+
+	// 目录不可用时本地账号仍可登录
+	cfg.URL = "ldap://127.0.0.1:1"
+	if _, err := store.Validate("root", "root-secret"); err != nil {
+		t.Fatalf("Expected local login while directory is down, got %v", err)
+	}
+}
+
+func TestLDAPUserStore_DoesNotTakeOverLocalAccounts(t *testing.T) {
+	setupTestDB(t)
+	cfg := testLDAPConfig(startTestLDAP(t, testDirectory()))
+	store := NewLDAPUserStore(cfg)
+
+	// 与目录用户同名的本地管理员账号
+	if err := store.Create(&User{Username: "bob", Password: "local-secret", Role: "admin"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := store.Validate("bob", "bob-secret"); err != ErrInvalidPassword {
+		t.Fatalf("Expected directory password to be rejected for a local account, got %v", err)
+	}
+	user, err := store.Validate("bob", "local-secret")
+	if err != nil || user.AuthSource != AuthSourceLocal {
+		t.Fatalf("Expected local login, got %+v, %v", user, err)
+	}
+
+	// 管理员开启关联后由目录接管
+	cfg.LinkLocalAccounts = true
+	user, err = store.Validate("bob", "bob-secret")
+	if err != nil || user.AuthSource != AuthSourceLDAP {
+		t.Fatalf("Expected linked directory account, got %+v, %v", user, err)
+	}
+
+	// OIDC 账号始终不能被目录关联
+	if err := store.Create(&User{Username: "alice", Password: "x-secret", AuthSource: AuthSourceOIDC}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := store.Validate("alice", "alice-secret"); err != ErrAccountConflict {
+		t.Fatalf("Expected ErrAccountConflict for an OIDC account, got %v", err)
+	}
+}
+
+func TestLDAPUserStore_UnavailableDirectory(t *testing.T) {
+	setupTestDB(t)
+	cfg := testLDAPConfig("ldap://127.0.0.1:1")
+	store := NewLDAPUserStore(cfg)
+
+	// 目录不可用时不存在的本地账号不能报告为 ErrUserNotFound，否则会被当作新用户注册
+	if _, err := store.Validate("alice", "alice-secret"); err == nil || err == ErrUserNotFound {
+		t.Fatalf("Expected directory error, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockoutPolicy 当前生效的账号锁定策略（可热更新）
+var lockoutPolicy atomic.Pointer[config.LockoutConfig]
+
+// SetLockoutPolicy 更新账号锁定策略
+func SetLockoutPolicy(cfg config.LockoutConfig) {
+	lockoutPolicy.Store(&cfg)
+}
+
+// currentLockoutPolicy 返回当前策略，未设置时为默认值
+func currentLockoutPolicy() config.LockoutConfig {
+	if p := lockoutPolicy.Load(); p != nil {
+		return *p
+	}
+	return config.Default().Auth.Lockout
+}
+
+// AccountLockedUntil 返回账号的锁定截止时间，未锁定或未启用锁定时返回零值
+func AccountLockedUntil(userID uint) time.Time {
+	if !currentLockoutPolicy().Enabled {
+		return time.Time{}
+	}
+	var lockout db.AccountLockout
+	if err := db.DB.Where("user_id = ?", userID).First(&lockout).Error; err != nil {
+		return time.Time{}
+	}
+	if lockout.LockedUntil == nil || !time.Now().Before(*lockout.LockedUntil) {
+		return time.Time{}
+	}
+	return *lockout.LockedUntil
+}
+
+// LockedAccounts 返回当前处于锁定状态的账号及锁定截止时间
+func LockedAccounts() map[uint]time.Time {
+	result := make(map[uint]time.Time)
+	if !currentLockoutPolicy().Enabled {
+		return result
+	}
+	var lockouts []db.AccountLockout
+	db.DB.Where("locked_until > ?", time.Now()).Find(&lockouts)
+	for _, l := range lockouts {
+		result[l.UserID] = *l.LockedUntil
+	}
+	return result
+}
+
+// RecordLoginFailure 记录一次登录失败，连续失败达到 max_attempts 时锁定账号
+// 返回非零的锁定截止时间表示本次失败触发了锁定。计数使用原子更新，多个实例共享同一数据库时同样准确
+func RecordLoginFailure(userID uint) (time.Time, error) {
+	cfg := currentLockoutPolicy()
+	if !cfg.Enabled || cfg.MaxAttempts <= 0 {
+		return time.Time{}, nil
+	}
+
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&db.AccountLockout{UserID: userID}).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	// 距离上次失败超过 window 时重新计数
+	now := time.Now()
+	if err := db.DB.Model(&db.AccountLockout{}).Where("user_id = ?", userID).Updates(map[string]any{
+		"failed_count":   gorm.Expr("CASE WHEN last_failed_at IS NULL OR last_failed_at < ? THEN 1 ELSE failed_count + 1 END", now.Add(-cfg.Window)),
+		"last_failed_at": now,
+	}).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	var lockout db.AccountLockout
+	if err := db.DB.Where("user_id = ?", userID).First(&lockout).Error; err != nil {
+		return time.Time{}, err
+	}
+	if lockout.FailedCount < cfg.MaxAttempts {
+		return time.Time{}, nil
+	}
+
+	// 只有把计数清零的那个请求负责锁定，避免并发请求重复累加锁定次数
+	lockedUntil := now.Add(lockoutDuration(cfg, lockout.LockCount+1))
+	result := db.DB.Model(&db.AccountLockout{}).
+		Where("id = ? AND failed_count >= ?", lockout.ID, cfg.MaxAttempts).
+		Updates(map[string]any{
+			"failed_count": 0,
+			"lock_count":   gorm.Expr("lock_count + 1"),
+			"locked_until": lockedUntil,
+		})
+	if result.Error != nil {
+		return time.Time{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return time.Time{}, nil
+	}
+	return lockedUntil, nil
+}
+
+// UnlockAccount 清除账号的失败计数和锁定状态（登录成功、管理员解锁、重置密码时调用）
+func UnlockAccount(userID uint) error {
+	return db.DB.Where("user_id = ?", userID).Delete(&db.AccountLockout{}).Error
+}
+
+// lockoutDuration 第 n 次锁定的时长：duration * 2^(n-1)，不超过 max_duration
+func lockoutDuration(cfg config.LockoutConfig, n int) time.Duration {
+	d, limit := cfg.Duration, cfg.MaxDuration
+	if d <= 0 {
+		d = 5 * time.Minute
+	}
+	if limit <= 0 {
+		limit = 24 * time.Hour
+	}
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	return d
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+)
+
+func TestAccountLockout(t *testing.T) {
+	setupTestDB(t)
+	t.Cleanup(func() { SetLockoutPolicy(config.Default().Auth.Lockout) })
+
+	cfg := config.Default().Auth.Lockout
+	cfg.MaxAttempts = 3
+	cfg.Window = time.Hour
+	cfg.Duration = time.Minute
+	cfg.MaxDuration = 3 * time.Minute
+	SetLockoutPolicy(cfg)
+
+	user := createTestUser(t, "alice", "developer")
+
+	// failAll 连续失败 max_attempts 次，返回最后一次的锁定截止时间
+	failAll := func() time.Time {
+		var lockedUntil time.Time
+		for i := 0; i < cfg.MaxAttempts; i++ {
+			until, err := RecordLoginFailure(user.ID)
+			if err != nil {
+				t.Fatalf("RecordLoginFailure failed: %v", err)
+			}
+			if i < cfg.MaxAttempts-1 && !until.IsZero() {
+				t.Fatalf("Locked after %d failures", i+1)
+			}
+			lockedUntil = until
+		}
+		return lockedUntil
+	}
+	// expireLock 模拟锁定到期
+	expireLock := func() {
+		db.DB.Model(&db.AccountLockout{}).Where("user_id = ?", user.ID).Update("locked_until", time.Now().Add(-time.Second))
+	}
+
+	// 逐次翻倍，不超过 max_duration
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		until := failAll()
+		if got := time.Until(until).Round(time.Second); got != want {
+			t.Fatalf("Expected lockout of %v, got %v", want, got)
+		}
+		if AccountLockedUntil(user.ID).IsZero() {
+			t.Fatal("Expected account to be locked")
+		}
+		if _, ok := LockedAccounts()[user.ID]; !ok {
+			t.Error("Expected account in LockedAccounts")
+		}
+		expireLock()
+		if !AccountLockedUntil(user.ID).IsZero() {
+			t.Fatal("Expected expired lock to be ignored")
+		}
+	}
+
+	// 超过 window 没有失败时重新计数
+	RecordLoginFailure(user.ID)
+	RecordLoginFailure(user.ID)
+	db.DB.Model(&db.AccountLockout{}).Where("user_id = ?", user.ID).Update("last_failed_at", time.Now().Add(-2*time.Hour))
+	for i := 0; i < cfg.MaxAttempts-1; i++ {
+		if until, _ := RecordLoginFailure(user.ID); !until.IsZero() {
+			t.Fatal("Expected failure count to restart after the window")
+		}
+	}
+	if until, _ := RecordLoginFailure(user.ID); until.IsZero() {
+		t.Fatal("Expected account to be locked")
+	}
+
+	// 解锁后清除失败计数和锁定次数
+	if err := UnlockAccount(user.ID); err != nil {
+		t.Fatalf("UnlockAccount failed: %v", err)
+	}
+	if !AccountLockedUntil(user.ID).IsZero() {
+		t.Fatal("Expected account to be unlocked")
+	}
+	if until := failAll(); time.Until(until).Round(time.Second) != time.Minute {
+		t.Error("Expected lock count to be reset by unlock")
+	}
+
+	// 关闭锁定后不再拒绝登录
+	cfg.Enabled = false
+	SetLockoutPolicy(cfg)
+	if !AccountLockedUntil(user.ID).IsZero() {
+		t.Error("Expected lockout to be ignored when disabled")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -19,6 +20,7 @@ const (
 	UserKey       contextKey = "user"
 	TokenKey      contextKey = "token"
 	IsTokenAuth   contextKey = "isTokenAuth"
+	SessionKey    contextKey = "session" // JWT 会话的 jti
 )
 
 // TokenValidator Token 验证接口
@@ -28,9 +30,71 @@ type TokenValidator interface {
 
 // TokenInfo Token 信息（简化版，避免循环依赖）
 type TokenInfo struct {
-	ID       uint
-	Name     string
-	Readonly bool
+	ID              uint
+	Name            string
+	Readonly        bool
+	CIDRWhitelist   []string
+	Granular        bool     // 细粒度 token：只能访问 Packages 范围内的包，只能执行 Permissions 中的操作
+	Packages        []string // 包名或通配符规则，空表示所有包
+	Permissions     []string // 授权的操作，见 PackageAction
+	BypassTwoFactor bool     // 发布等写操作不需要 OTP（用于 CI）
+}
+
+// AllowsIP 检查客户端 IP 是否在 token 的 CIDR 白名单内，白名单为空表示不限制
+func (t *TokenInfo) AllowsIP(ip string) bool {
+	if len(t.CIDRWhitelist) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range t.CIDRWhitelist {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDRList 解析并校验逗号分隔或数组形式的 CIDR 列表
+func ParseCIDRList(cidrs []string) ([]string, error) {
+	result := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", cidr)
+		}
+		result = append(result, network.String())
+	}
+	return result, nil
+}
+
+// SplitCommaList 将数据库中逗号分隔的字段拆分为列表，忽略空项
+func SplitCommaList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// SplitCIDRWhitelist 将数据库中逗号分隔的 CIDR 白名单拆分为列表
+func SplitCIDRWhitelist(value string) []string {
+	return SplitCommaList(value)
 }
 
 // ValidateTokenByHash 通过 token 哈希验证 token，返回关联用户
@@ -44,6 +108,11 @@ func ValidateTokenByHash(tokenString string) (*User, *TokenInfo, error) {
 		return nil, nil, err
 	}
 
+	// 用户已被删除的 token 视为无效
+	if token.User == nil {
+		return nil, nil, fmt.Errorf("token owner not found")
+	}
+
 	// 检查是否过期
 	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
 		return nil, nil, fmt.Errorf("token expired")
@@ -64,9 +133,14 @@ func ValidateTokenByHash(tokenString string) (*User, *TokenInfo, error) {
 	}
 
 	tokenInfo := &TokenInfo{
-		ID:       token.ID,
-		Name:     token.Name,
-		Readonly: token.Readonly,
+		ID:              token.ID,
+		Name:            token.Name,
+		Readonly:        token.Readonly,
+		CIDRWhitelist:   SplitCIDRWhitelist(token.CIDRWhitelist),
+		Granular:        token.Granular,
+		Packages:        SplitCommaList(token.Packages),
+		Permissions:     SplitCommaList(token.Permissions),
+		BypassTwoFactor: token.BypassTwoFactor,
 	}
 
 	return user, tokenInfo, nil
@@ -76,48 +150,107 @@ func ValidateTokenByHash(tokenString string) (*User, *TokenInfo, error) {
 // 优先检查 JWT，再检查持久化 Token
 func AuthMiddleware(jwtService *JWTService, userStore UserStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		// 不再支持 query 参数传递 token，避免 token 泄露到日志
-
-		if authHeader == "" {
-			c.Next()
+		if !Authenticate(c, jwtService, userStore) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// 解析 Bearer token
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.Next()
-			return
-		}
+// Authenticate 解析请求中的凭证并写入 context（未携带凭证时不做处理）
+// 返回 false 表示请求已被中止；已认证过的请求不会重复校验
+func Authenticate(c *gin.Context, jwtService *JWTService, userStore UserStore) bool {
+	if IsAuthenticated(c) {
+		return true
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	// 不再支持 query 参数传递 token，避免 token 泄露到日志
+
+	if authHeader == "" {
+		return true
+	}
 
-		tokenString := parts[1]
+	// 解析 Bearer token
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return true
+	}
+
+	tokenString := parts[1]
 
-		// 1. 先尝试 JWT 验证
-		claims, err := jwtService.ValidateToken(tokenString)
+	// 1. 先尝试 JWT 验证
+	claims, err := jwtService.ValidateToken(tokenString)
+	if err == nil {
+		// JWT 签名有效，还需要会话未被撤销
+		if err := CheckSession(claims); err != nil {
+			logger.Debugf("JWT session rejected for %s: %v", claims.Username, err)
+			return true
+		}
+		user, err := userStore.Get(claims.Username)
 		if err == nil {
-			// JWT 有效
-			user, err := userStore.Get(claims.Username)
-			if err == nil {
-				c.Set(string(UserKey), user)
-				c.Set(string(IsTokenAuth), false)
-			}
+			c.Set(string(UserKey), user)
+			c.Set(string(SessionKey), claims.ID)
+			c.Set(string(IsTokenAuth), false)
+		}
+		return true
+	}
+
+	// 2. JWT 无效，尝试 Token 验证
+	user, tokenInfo, err := ValidateTokenByHash(tokenString)
+	if err != nil {
+		logger.Debugf("Token validation failed: %v", err)
+		return true
+	}
+
+	// 检查 CIDR 白名单
+	if !tokenInfo.AllowsIP(c.ClientIP()) {
+		logger.Warnf("Token '%s' used from disallowed IP: %s", tokenInfo.Name, c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "token is not allowed from this IP address",
+		})
+		c.Abort()
+		return false
+	}
+
+	// 细粒度 token 不能用于账号管理
+	if !tokenInfo.allowsPath(c.Request.URL.Path) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "granular access tokens cannot be used for account management",
+		})
+		c.Abort()
+		return false
+	}
+
+	// Token 有效
+	c.Set(string(UserKey), user)
+	c.Set(string(TokenKey), tokenInfo)
+	c.Set(string(IsTokenAuth), true)
+	return true
+}
+
+// RequireReadAuth 全局"读取需要认证"模式
+// enabled 返回 true 时，除 exempt 放行的请求外，未认证请求返回 npm 兼容的 401（只读 token 同样可以读取）
+func RequireReadAuth(jwtService *JWTService, userStore UserStore, enabled func() bool, exempt func(*gin.Context) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled() || exempt(c) {
 			c.Next()
 			return
 		}
 
-		// 2. JWT 无效，尝试 Token 验证
-		user, tokenInfo, err := ValidateTokenByHash(tokenString)
-		if err != nil {
-			logger.Debugf("Token validation failed: %v", err)
-			c.Next()
+		if !Authenticate(c, jwtService, userStore) {
+			return
+		}
+
+		if !IsAuthenticated(c) {
+			c.Header("WWW-Authenticate", `Bearer realm="grape"`)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "authentication required",
+			})
+			c.Abort()
 			return
 		}
 
-		// Token 有效
-		c.Set(string(UserKey), user)
-		c.Set(string(TokenKey), tokenInfo)
-		c.Set(string(IsTokenAuth), true)
 		c.Next()
 	}
 }
@@ -135,6 +268,11 @@ func GetTokenInfo(c *gin.Context) *TokenInfo {
 	return t
 }
 
+// GetSessionID 从 context 获取当前 JWT 会话的 jti，使用持久化 Token 认证时为空
+func GetSessionID(c *gin.Context) string {
+	return c.GetString(string(SessionKey))
+}
+
 // IsTokenAuth check if current auth is via persistent token
 func GetIsTokenAuth(c *gin.Context) bool {
 	isToken, exists := c.Get(string(IsTokenAuth))
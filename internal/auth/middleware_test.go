@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+)
+
+func TestTokenInfo_AllowsIP(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		ip    string
+		want  bool
+	}{
+		{name: "empty whitelist", cidrs: nil, ip: "192.168.1.1", want: true},
+		{name: "ip in range", cidrs: []string{"10.0.0.0/8"}, ip: "10.1.2.3", want: true},
+		{name: "ip out of range", cidrs: []string{"10.0.0.0/8"}, ip: "192.168.1.1", want: false},
+		{name: "second range matches", cidrs: []string{"10.0.0.0/8", "192.168.0.0/16"}, ip: "192.168.1.1", want: true},
+		{name: "ipv6", cidrs: []string{"fd00::/8"}, ip: "fd00::1", want: true},
+		{name: "invalid ip", cidrs: []string{"10.0.0.0/8"}, ip: "not-an-ip", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &TokenInfo{CIDRWhitelist: tt.cidrs}
+			if got := info.AllowsIP(tt.ip); got != tt.want {
+				t.Errorf("AllowsIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCIDRList(t *testing.T) {
+	cidrs, err := ParseCIDRList([]string{" 10.1.2.3/8 ", "", "192.168.0.0/16"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(cidrs) != 2 || cidrs[0] != "10.0.0.0/8" || cidrs[1] != "192.168.0.0/16" {
+		t.Fatalf("Unexpected CIDR list: %v", cidrs)
+	}
+
+	if _, err := ParseCIDRList([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("Expected error for invalid CIDR")
+	}
+}
+
+func TestValidateTokenByHash_DeletedUser(t *testing.T) {
+	setupTestDB(t)
+	store := NewDBUserStore()
+	createToken := func(username, plain string) {
+		t.Helper()
+		user := createTestUser(t, username, "developer")
+		hash := sha256.Sum256([]byte(plain))
+		if err := db.DB.Create(&db.Token{UserID: user.ID, Name: "ci", TokenHash: hex.EncodeToString(hash[:])}).Error; err != nil {
+			t.Fatalf("Failed to create token: %v", err)
+		}
+	}
+
+	createToken("alice", "alice-token")
+	if user, _, err := ValidateTokenByHash("alice-token"); err != nil || user.Username != "alice" {
+		t.Fatalf("Expected token to be valid, got %+v, %v", user, err)
+	}
+	if err := store.Delete("alice"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	var count int64
+	db.DB.Model(&db.Token{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected tokens of the deleted user to be removed, got %d", count)
+	}
+	if _, _, err := ValidateTokenByHash("alice-token"); err == nil {
+		t.Error("Expected token of a deleted user to be rejected")
+	}
+
+	// 早期版本删除用户时遗留的 token 同样拒绝，而不是 panic
+	createToken("bob", "bob-token")
+	db.DB.Where("username = ?", "bob").Delete(&db.User{})
+	if user, _, err := ValidateTokenByHash("bob-token"); err == nil || user != nil {
+		t.Errorf("Expected orphaned token to be rejected, got %+v, %v", user, err)
+	}
+}
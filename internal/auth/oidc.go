@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/graperegistry/grape/internal/config"
+)
+
+// oidcSigningMethods 允许的 id_token 签名算法（拒绝 none 与 HMAC）
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// OIDCProvider OpenID Connect 身份提供方客户端（授权码 + PKCE）
+// 首次使用时通过 discovery 获取端点，id_token 使用 IdP 的 JWKS 校验
+type OIDCProvider struct {
+	cfg    *config.OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      *KeySet
+}
+
+// oidcDiscovery /.well-known/openid-configuration 中用到的字段
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCIdentity 从 id_token 中读取的用户信息
+type OIDCIdentity struct {
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// NewOIDCProvider 创建 OIDCProvider
+func NewOIDCProvider(cfg *config.OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Enabled 是否启用并完成了必要配置
+func (p *OIDCProvider) Enabled() bool {
+	return p.cfg.Enabled && p.cfg.Issuer != "" && p.cfg.ClientID != ""
+}
+
+// DisplayName 登录按钮显示名称
+func (p *OIDCProvider) DisplayName() string {
+	return p.cfg.DisplayName
+}
+
+// discover 获取并缓存 IdP 端点
+func (p *OIDCProvider) discover() (*oidcDiscovery, *KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, p.keys, nil
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	resp, err := p.client.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("oidc discovery: unexpected status %d", resp.StatusCode)
+	}
+
+	var d oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, nil, fmt.Errorf("oidc discovery: issuer mismatch %q", d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, nil, fmt.Errorf("oidc discovery: incomplete provider metadata")
+	}
+
+	p.discovery = &d
+	p.keys = NewKeySet(d.JWKSURI, p.client)
+	return p.discovery, p.keys, nil
+}
+
+// AuthCodeURL 生成跳转到 IdP 的授权地址
+func (p *OIDCProvider) AuthCodeURL(redirectURL, state, nonce, verifier string) (string, error) {
+	d, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	hasOpenID := false
+	for _, scope := range scopes {
+		if scope == "openid" {
+			hasOpenID = true
+		}
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", PKCEChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange 用授权码换取 id_token 并校验，nonce 必须与发起登录时一致
+func (p *OIDCProvider) Exchange(ctx context.Context, redirectURL, code, verifier, nonce string) (*OIDCIdentity, error) {
+	d, keys, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc token request: %s %s", tok.Error, tok.ErrorDescription)
+	}
+	if tok.IDToken == "" {
+		return nil, fmt.Errorf("oidc token response has no id_token")
+	}
+
+	return p.verifyIDToken(d, keys, tok.IDToken, nonce)
+}
+
+// verifyIDToken 校验 id_token 的签名、issuer、audience、有效期与 nonce
+func (p *OIDCProvider) verifyIDToken(d *oidcDiscovery, keys *KeySet, raw, nonce string) (*OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, keys.Keyfunc,
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("invalid id_token: nonce mismatch")
+	}
+
+	identity := &OIDCIdentity{
+		Subject:  claimString(claims, "sub"),
+		Username: claimString(claims, p.cfg.UsernameClaim),
+		Email:    claimString(claims, "email"),
+		Name:     claimString(claims, "name"),
+		Groups:   claimStrings(claims, p.cfg.GroupsClaim),
+	}
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	if identity.Username == "" {
+		return nil, fmt.Errorf("id_token has no %s claim", p.cfg.UsernameClaim)
+	}
+	return identity, nil
+}
+
+// Provision 按组映射角色，同步（或自动创建）本地账号
+func (p *OIDCProvider) Provision(identity *OIDCIdentity) (*User, error) {
+	role := MapGroupsToRole(identity.Groups, p.cfg.RoleMapping, p.cfg.DefaultRole)
+	if role == "" {
+		return nil, ErrNoPermittedGroup
+	}
+	return syncExternalUser(&ExternalIdentity{
+		Source:   AuthSourceOIDC,
+		Username: identity.Username,
+		Email:    identity.Email,
+		FullName: identity.Name,
+		Role:     role,
+	}, externalSyncOptions{
+		AutoProvision: p.cfg.AutoProvision,
+		SyncRole:      len(p.cfg.RoleMapping) > 0,
+		// 只有 IdP 确认过的邮箱与已有账号一致时才接管同名账号，防止通过 IdP 中的同名用户冒用本地管理员
+		CanLink: func(existing *User) bool {
+			return identity.EmailVerified && identity.Email != "" && strings.EqualFold(existing.Email, identity.Email)
+		},
+	})
+}
+
+// NewOIDCRandom 生成 state、nonce 与 PKCE code_verifier 使用的随机串
+func NewOIDCRandom() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PKCEChallenge 计算 S256 code_challenge
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// claimString 读取字符串 claim
+func claimString(claims jwt.MapClaims, name string) string {
+	v, _ := claims[name].(string)
+	return v
+}
+
+// claimStrings 读取字符串数组 claim，兼容单个字符串
+func claimStrings(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/graperegistry/grape/internal/config"
+)
+
+// mockIdP 本地 OIDC 身份提供方：discovery、JWKS 和授权码换 token（校验 PKCE）
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]mockAuthorization // code -> 授权请求
+	claims jwt.MapClaims                // 下一次签发的 id_token 额外 claims
+}
+
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	idp := &mockIdP{key: key, codes: make(map[string]mockAuthorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.mu.Lock()
+		authz, ok := idp.codes[r.PostForm.Get("code")]
+		delete(idp.codes, r.PostForm.Get("code"))
+		claims := idp.claims
+		idp.mu.Unlock()
+
+		if !ok || PKCEChallenge(r.PostForm.Get("code_verifier")) != authz.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idp.sign(t, authz.nonce, claims),
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize 模拟用户在 IdP 登录并同意，返回回调中的授权码
+func (idp *mockIdP) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid auth URL: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("Missing PKCE parameters: %s", authURL)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.codes["code-"+q.Get("state")] = mockAuthorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	return "code-" + q.Get("state")
+}
+
+func (idp *mockIdP) sign(t *testing.T, nonce string, extra jwt.MapClaims) string {
+	claims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   "grape",
+		"sub":   "user-1",
+		"nonce": nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatalf("Failed to sign id_token: %v", err)
+	}
+	return signed
+}
+
+func testOIDCProvider(idp *mockIdP) *OIDCProvider {
+	cfg := config.Default().Auth.OIDC
+	cfg.Enabled = true
+	cfg.Issuer = idp.server.URL
+	cfg.ClientID = "grape"
+	cfg.RoleMapping = map[string]string{"npm-admins": "admin", "npm-readers": "readonly"}
+	return NewOIDCProvider(&cfg)
+}
+
+// login 走完整的授权码 + PKCE 流程
+func login(t *testing.T, p *OIDCProvider, idp *mockIdP, claims jwt.MapClaims) (*OIDCIdentity, error) {
+	t.Helper()
+	state, _ := NewOIDCRandom()
+	nonce, _ := NewOIDCRandom()
+	verifier, _ := NewOIDCRandom()
+
+	authURL, err := p.AuthCodeURL("http://grape.test/-/api/auth/oidc/callback", state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	idp.mu.Lock()
+	idp.claims = claims
+	idp.mu.Unlock()
+	code := idp.authorize(t, authURL)
+	return p.Exchange(context.Background(), "http://grape.test/-/api/auth/oidc/callback", code, verifier, nonce)
+}
+
+func TestOIDCProvider_LoginProvisionsUser(t *testing.T) {
+	setupTestDB(t)
+	idp := newMockIdP(t)
+	p := testOIDCProvider(idp)
+
+	identity, err := login(t, p, idp, jwt.MapClaims{
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"name":               "Alice",
+		"groups":             []string{"staff", "npm-admins"},
+	})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	user, err := p.Provision(identity)
+	if err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	if user.Role != "admin" || user.AuthSource != AuthSourceOIDC || user.Email != "alice@example.com" {
+		t.Fatalf("Unexpected user: %+v", user)
+	}
+
+	// SSO 用户没有可用的本地密码
+	if _, err := NewDBUserStore().Validate("alice", ""); err != ErrInvalidPassword {
+		t.Fatalf("Expected local password login to fail, got %v", err)
+	}
+}
+
+func TestOIDCProvider_RejectsTamperedFlow(t *testing.T) {
+	setupTestDB(t)
+	idp := newMockIdP(t)
+	p := testOIDCProvider(idp)
+	redirect := "http://grape.test/-/api/auth/oidc/callback"
+
+	// code_verifier 与 code_challenge 不匹配
+	authURL, _ := p.AuthCodeURL(redirect, "s1", "n1", "verifier-1")
+	code := idp.authorize(t, authURL)
+	if _, err := p.Exchange(context.Background(), redirect, code, "verifier-2", "n1"); err == nil {
+		t.Fatal("Expected PKCE mismatch to fail")
+	}
+
+	// nonce 不匹配
+	authURL, _ = p.AuthCodeURL(redirect, "s2", "n2", "verifier-3")
+	code = idp.authorize(t, authURL)
+	idp.claims = jwt.MapClaims{"preferred_username": "alice"}
+	if _, err := p.Exchange(context.Background(), redirect, code, "verifier-3", "other"); err == nil {
+		t.Fatal("Expected nonce mismatch to fail")
+	}
+
+	// audience 不是本客户端
+	if _, err := login(t, p, idp, jwt.MapClaims{"preferred_username": "alice", "aud": "someone-else"}); err == nil {
+		t.Fatal("Expected wrong audience to fail")
+	}
+}
+
+func TestOIDCProvider_ProvisionRules(t *testing.T) {
+	setupTestDB(t)
+	idp := newMockIdP(t)
+	p := testOIDCProvider(idp)
+
+	// 不在映射组中时使用默认角色；默认角色为空时拒绝
+	user, err := p.Provision(&OIDCIdentity{Username: "bob", Groups: []string{"staff"}})
+	if err != nil || user.Role != "developer" {
+		t.Fatalf("Expected default role, got %+v, %v", user, err)
+	}
+	p.cfg.DefaultRole = ""
+	if _, err := p.Provision(&OIDCIdentity{Username: "carol"}); err != ErrNoPermittedGroup {
+		t.Fatalf("Expected ErrNoPermittedGroup, got %v", err)
+	}
+
+	// 同名本地账号只有在邮箱已验证且一致时才会被接管
+	createTestUser(t, "root", "admin")
+	if _, err := p.Provision(&OIDCIdentity{Username: "root", Email: "root@example.com", Groups: []string{"npm-readers"}}); err != ErrAccountConflict {
+		t.Fatalf("Expected ErrAccountConflict, got %v", err)
+	}
+	user, err = p.Provision(&OIDCIdentity{Username: "root", Email: "root@example.com", EmailVerified: true, Groups: []string{"npm-admins"}})
+	if err != nil || user.AuthSource != AuthSourceOIDC || user.Role != "admin" {
+		t.Fatalf("Expected verified email to link account, got %+v, %v", user, err)
+	}
+}
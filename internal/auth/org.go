@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/graperegistry/grape/internal/db"
+)
+
+// 组织成员角色
+const (
+	OrgRoleOwner     = "owner"
+	OrgRoleAdmin     = "admin"
+	OrgRoleDeveloper = "developer"
+)
+
+// ValidOrgRole 检查组织角色是否合法
+func ValidOrgRole(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleDeveloper
+}
+
+// NormalizeOrgName 去掉组织名/scope 的 @ 前缀
+func NormalizeOrgName(name string) string {
+	return strings.TrimPrefix(name, "@")
+}
+
+// GetOrganization 按名称查找组织
+func GetOrganization(name string) (*db.Organization, bool) {
+	var org db.Organization
+	if err := db.DB.Where("name = ?", NormalizeOrgName(name)).First(&org).Error; err != nil {
+		return nil, false
+	}
+	return &org, true
+}
+
+// OrgForPackage 返回拥有包所在 scope 的组织
+func OrgForPackage(packageName string) (*db.Organization, bool) {
+	scope := PackageScope(packageName)
+	if scope == "" {
+		return nil, false
+	}
+	return GetOrganization(scope)
+}
+
+// OrgRole 返回用户在组织中的角色，非成员返回空字符串
+func OrgRole(orgID uint, user *User) string {
+	if user == nil {
+		return ""
+	}
+	var member db.OrgMember
+	if err := db.DB.Where("org_id = ? AND user_id = ?", orgID, user.ID).First(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// CanManageOrg 站点管理员或组织 owner/admin 可以管理组织成员、团队与授权
+func CanManageOrg(user *User, org *db.Organization) bool {
+	if user == nil {
+		return false
+	}
+	if user.Role == "admin" {
+		return true
+	}
+	role := OrgRole(org.ID, user)
+	return role == OrgRoleOwner || role == OrgRoleAdmin
+}
+
+// CanManageOrgOwners 只有站点管理员或组织 owner 可以任命、降级或移除 owner
+func CanManageOrgOwners(user *User, org *db.Organization) bool {
+	if user == nil {
+		return false
+	}
+	return user.Role == "admin" || OrgRole(org.ID, user) == OrgRoleOwner
+}
+
+// IsLastOrgOwner 检查用户是否为组织仅剩的 owner
+func IsLastOrgOwner(orgID, userID uint) bool {
+	if OrgRole(orgID, &User{ID: userID}) != OrgRoleOwner {
+		return false
+	}
+	var owners int64
+	db.DB.Model(&db.OrgMember{}).Where("org_id = ? AND role = ?", orgID, OrgRoleOwner).Count(&owners)
+	return owners <= 1
+}
+
+// HasTeamGrant 检查用户是否通过所在团队获得了包（或包所在 scope）的授权
+// permission 为 read-write 时只匹配读写授权
+func HasTeamGrant(user *User, packageName, permission string) bool {
+	if user == nil {
+		return false
+	}
+	names := []string{packageName}
+	if rule := scopeAccessRule(packageName); rule != "" {
+		names = append(names, rule)
+	}
+
+	query := db.DB.Model(&db.TeamGrant{}).
+		Joins("JOIN team_members ON team_members.team_id = team_grants.team_id").
+		Where("team_members.user_id = ? AND team_grants.package_name IN ?", user.ID, names)
+	if permission == PermissionReadWrite {
+		query = query.Where("team_grants.permission = ?", PermissionReadWrite)
+	}
+	var count int64
+	query.Count(&count)
+	return count > 0
+}
+
+// isOrgManagerForPackage 检查用户是否为包所属组织的 owner/admin
+func isOrgManagerForPackage(user *User, packageName string) bool {
+	org, ok := OrgForPackage(packageName)
+	if !ok || user == nil {
+		return false
+	}
+	role := OrgRole(org.ID, user)
+	return role == OrgRoleOwner || role == OrgRoleAdmin
+}
+
+// CanCreatePackage 检查用户是否可以创建新包：组织拥有的 scope 只有组织成员可以发布新包
+func CanCreatePackage(user *User, packageName string) bool {
+	if user == nil {
+		return false
+	}
+	if user.Role == "admin" {
+		return true
+	}
+	org, ok := OrgForPackage(packageName)
+	if !ok {
+		return true
+	}
+	return OrgRole(org.ID, user) != ""
+}
+
+// CanPublishViaOrg 检查用户是否通过组织角色或团队授权获得包的发布权限
+func CanPublishViaOrg(user *User, packageName string) bool {
+	return isOrgManagerForPackage(user, packageName) || HasTeamGrant(user, packageName, PermissionReadWrite)
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+)
+
+// createTestOrg 创建组织并按 用户 -> 角色 添加成员
+func createTestOrg(t *testing.T, name string, members map[*User]string) *db.Organization {
+	t.Helper()
+	org := &db.Organization{Name: name}
+	if err := db.DB.Create(org).Error; err != nil {
+		t.Fatalf("Failed to create org: %v", err)
+	}
+	for user, role := range members {
+		if err := db.DB.Create(&db.OrgMember{OrgID: org.ID, UserID: user.ID, Role: role}).Error; err != nil {
+			t.Fatalf("Failed to add member: %v", err)
+		}
+	}
+	return org
+}
+
+func TestOrgRoles(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner", "developer")
+	orgAdmin := createTestUser(t, "org-admin", "developer")
+	dev := createTestUser(t, "dev", "developer")
+	outsider := createTestUser(t, "outsider", "developer")
+	siteAdmin := createTestUser(t, "root", "admin")
+	org := createTestOrg(t, "acme", map[*User]string{owner: OrgRoleOwner, orgAdmin: OrgRoleAdmin, dev: OrgRoleDeveloper})
+
+	tests := []struct {
+		user         *User
+		role         string
+		manage       bool
+		manageOwners bool
+	}{
+		{owner, OrgRoleOwner, true, true},
+		{orgAdmin, OrgRoleAdmin, true, false},
+		{dev, OrgRoleDeveloper, false, false},
+		{outsider, "", false, false},
+		{siteAdmin, "", true, true},
+		{nil, "", false, false},
+	}
+	for _, tt := range tests {
+		name := "anonymous"
+		if tt.user != nil {
+			name = tt.user.Username
+		}
+		if got := OrgRole(org.ID, tt.user); got != tt.role {
+			t.Errorf("OrgRole(%s) = %q, expected %q", name, got, tt.role)
+		}
+		if got := CanManageOrg(tt.user, org); got != tt.manage {
+			t.Errorf("CanManageOrg(%s) = %v, expected %v", name, got, tt.manage)
+		}
+		if got := CanManageOrgOwners(tt.user, org); got != tt.manageOwners {
+			t.Errorf("CanManageOrgOwners(%s) = %v, expected %v", name, got, tt.manageOwners)
+		}
+	}
+}
+
+func TestIsLastOrgOwner(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice", "developer")
+	bob := createTestUser(t, "bob", "developer")
+	org := createTestOrg(t, "acme", map[*User]string{alice: OrgRoleOwner, bob: OrgRoleAdmin})
+
+	if !IsLastOrgOwner(org.ID, alice.ID) {
+		t.Error("Expected alice to be the last owner")
+	}
+	if IsLastOrgOwner(org.ID, bob.ID) {
+		t.Error("A non-owner cannot be the last owner")
+	}
+
+	db.DB.Model(&db.OrgMember{}).Where("org_id = ? AND user_id = ?", org.ID, bob.ID).Update("role", OrgRoleOwner)
+	if IsLastOrgOwner(org.ID, alice.ID) || IsLastOrgOwner(org.ID, bob.ID) {
+		t.Error("Expected neither owner to be the last one")
+	}
+}
+
+func TestOrgPackagePermissions(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner", "developer")
+	dev := createTestUser(t, "dev", "developer")
+	outsider := createTestUser(t, "outsider", "developer")
+	org := createTestOrg(t, "acme", map[*User]string{owner: OrgRoleOwner, dev: OrgRoleDeveloper})
+
+	if org, ok := OrgForPackage("@acme/lib"); !ok || org.Name != "acme" {
+		t.Fatalf("OrgForPackage(@acme/lib) = %v, %v", org, ok)
+	}
+	if _, ok := OrgForPackage("lib"); ok {
+		t.Error("Unscoped package must not belong to an organization")
+	}
+
+	if !CanCreatePackage(dev, "@acme/lib") || CanCreatePackage(outsider, "@acme/lib") {
+		t.Error("Only organization members may create packages in the organization scope")
+	}
+	if !CanCreatePackage(outsider, "@other/lib") {
+		t.Error("Scopes without an organization are open")
+	}
+
+	if !CanPublishViaOrg(owner, "@acme/lib") || CanPublishViaOrg(dev, "@acme/lib") {
+		t.Error("Only owners and admins publish via their organization role")
+	}
+
+	team := db.Team{OrgID: org.ID, Name: "devs"}
+	db.DB.Create(&team)
+	db.DB.Create(&db.TeamMember{TeamID: team.ID, UserID: dev.ID})
+	db.DB.Create(&db.TeamGrant{TeamID: team.ID, PackageName: "@acme/*", Permission: PermissionReadOnly})
+	if !HasTeamGrant(dev, "@acme/lib", PermissionReadOnly) || HasTeamGrant(dev, "@acme/lib", PermissionReadWrite) {
+		t.Error("Read-only scope grant should match read but not read-write")
+	}
+
+	db.DB.Model(&db.TeamGrant{}).Where("team_id = ?", team.ID).Update("permission", PermissionReadWrite)
+	if !CanPublishViaOrg(dev, "@acme/lib") {
+		t.Error("Read-write team grant should allow publishing")
+	}
+}
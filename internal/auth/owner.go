@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"path"
+	"strings"
+
+	"github.com/graperegistry/grape/internal/db"
+)
+
+// IsOwnerPattern 检查 owner 规则是否为通配符规则（如 @ui/*、eslint-config-acme-*）
+func IsOwnerPattern(name string) bool {
+	return strings.Contains(name, "*")
+}
+
+// ValidOwnerPattern 检查通配符规则语法是否合法
+func ValidOwnerPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// MatchOwnerPattern 检查包名是否匹配 owner 规则
+// 通配符 * 不跨越 /，因此 @ui/* 只匹配 @ui scope 下的包
+func MatchOwnerPattern(pattern, packageName string) bool {
+	if !IsOwnerPattern(pattern) {
+		return pattern == packageName
+	}
+	ok, err := path.Match(pattern, packageName)
+	return err == nil && ok
+}
+
+// EffectiveOwners 返回对包名生效的 owner 记录：精确匹配的记录和所有匹配的通配符规则
+// 返回记录的 PackageName 为命中的规则本身
+func EffectiveOwners(packageName string) ([]db.PackageOwner, error) {
+	var rules []db.PackageOwner
+	if err := db.DB.Where("package_name = ? OR package_name LIKE ?", packageName, "%*%").
+		Preload("User").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return filterOwnerRules(packageName, rules), nil
+}
+
+// filterOwnerRules 过滤出匹配包名的 owner 规则
+func filterOwnerRules(packageName string, rules []db.PackageOwner) []db.PackageOwner {
+	owners := make([]db.PackageOwner, 0, len(rules))
+	for _, rule := range rules {
+		if MatchOwnerPattern(rule.PackageName, packageName) {
+			owners = append(owners, rule)
+		}
+	}
+	return owners
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+)
+
+func TestMatchOwnerPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"lodash", "lodash", true},
+		{"lodash", "lodash-es", false},
+		{"@ui/*", "@ui/button", true},
+		{"@ui/*", "@uikit/button", false},
+		{"@ui/*", "ui-button", false},
+		{"eslint-config-acme-*", "eslint-config-acme-react", true},
+		{"eslint-config-acme-*", "eslint-config-acme", false},
+		{"*", "@ui/button", false},
+		{"acme-[*", "acme-[x", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchOwnerPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchOwnerPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterOwnerRules(t *testing.T) {
+	rules := []db.PackageOwner{
+		{PackageName: "@ui/button", UserID: 1},
+		{PackageName: "@ui/*", UserID: 2},
+		{PackageName: "@core/*", UserID: 3},
+	}
+
+	owners := filterOwnerRules("@ui/button", rules)
+	if len(owners) != 2 || owners[0].UserID != 1 || owners[1].UserID != 2 {
+		t.Fatalf("Unexpected owners: %+v", owners)
+	}
+
+	if owners := filterOwnerRules("@ui/input", rules); len(owners) != 1 || owners[0].UserID != 2 {
+		t.Fatalf("Unexpected owners for new package: %+v", owners)
+	}
+}
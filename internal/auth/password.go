@@ -0,0 +1,129 @@
+package auth
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/graperegistry/grape/internal/config"
+)
+
+// 密码验证错误
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordBreached = errors.New("password is too common and appears in breached password lists, choose a different one")
+)
+
+//go:embed breached_passwords.txt
+var builtinBreachedPasswords string
+
+var (
+	builtinBreachedOnce sync.Once
+	builtinBreachedSet  map[string]struct{}
+)
+
+// passwordPolicyState 当前生效的密码策略及额外的泄露密码列表
+type passwordPolicyState struct {
+	cfg      config.PasswordPolicyConfig
+	breached map[string]struct{}
+}
+
+// passwordPolicy 当前生效的密码策略（可热更新）
+var passwordPolicy atomic.Pointer[passwordPolicyState]
+
+// SetPasswordPolicy 更新密码策略，配置了 breached_list_file 时读取该文件
+// 文件读取失败时策略仍然生效（只使用内置列表），并返回错误供调用方记录
+func SetPasswordPolicy(cfg config.PasswordPolicyConfig) error {
+	state := &passwordPolicyState{cfg: cfg}
+	var err error
+	if cfg.BreachedListFile != "" {
+		var data []byte
+		if data, err = os.ReadFile(cfg.BreachedListFile); err == nil {
+			state.breached = parsePasswordList(string(data))
+		}
+	}
+	passwordPolicy.Store(state)
+	return err
+}
+
+// currentPasswordPolicy 返回当前策略，未设置时为默认值
+func currentPasswordPolicy() *passwordPolicyState {
+	if p := passwordPolicy.Load(); p != nil {
+		return p
+	}
+	return &passwordPolicyState{cfg: config.Default().Auth.PasswordPolicy}
+}
+
+// ValidatePassword 按密码策略验证密码强度
+func ValidatePassword(password string) error {
+	policy := currentPasswordPolicy()
+	cfg := policy.cfg
+
+	minLength := cfg.MinLength
+	if minLength <= 0 {
+		minLength = 8
+	}
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("%w: at least %d characters required", ErrPasswordTooShort, minLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	switch {
+	case cfg.RequireUppercase && !upper:
+		return errors.New("password must contain an uppercase letter")
+	case cfg.RequireLowercase && !lower:
+		return errors.New("password must contain a lowercase letter")
+	case cfg.RequireDigit && !digit:
+		return errors.New("password must contain a digit")
+	case cfg.RequireSymbol && !symbol:
+		return errors.New("password must contain a special character")
+	}
+
+	if cfg.CheckBreached && policy.isBreached(password) {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+// isBreached 密码是否在内置或额外的泄露密码列表中（忽略大小写）
+func (p *passwordPolicyState) isBreached(password string) bool {
+	builtinBreachedOnce.Do(func() {
+		builtinBreachedSet = parsePasswordList(builtinBreachedPasswords)
+	})
+	key := strings.ToLower(password)
+	if _, ok := builtinBreachedSet[key]; ok {
+		return true
+	}
+	_, ok := p.breached[key]
+	return ok
+}
+
+// parsePasswordList 解析每行一个的密码列表，忽略空行和 # 注释
+func parsePasswordList(data string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/graperegistry/grape/internal/db"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidResetCode = errors.New("invalid or expired password reset code")
+	ErrSamePassword     = errors.New("new password must be different from the current password")
+)
+
+// CreatePasswordReset 为本地账号签发一次性重置码，之前未使用的重置码同时作废
+// 返回的重置码形如 ABCD-EFGH-JKLM-NPQR，只在签发时返回一次，数据库只保存哈希
+func CreatePasswordReset(user *User, createdBy string) (string, time.Time, error) {
+	if user.IsExternal() {
+		return "", time.Time{}, ErrExternalPassword
+	}
+
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+
+	ttl := currentPasswordPolicy().cfg.ResetTTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	now := time.Now()
+	reset := &db.PasswordReset{
+		UserID:    user.ID,
+		CodeHash:  hashResetCode(code),
+		CreatedBy: createdBy,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&db.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(reset).Error
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return code, reset.ExpiresAt, nil
+}
+
+// RedeemPasswordReset 使用重置码设置新密码，成功后清除"必须修改密码"标记和账号锁定，并撤销该用户的所有会话
+func RedeemPasswordReset(store UserStore, code, newPassword string) (*User, error) {
+	var reset db.PasswordReset
+	if err := db.DB.Where("code_hash = ?", hashResetCode(code)).First(&reset).Error; err != nil {
+		return nil, ErrInvalidResetCode
+	}
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return nil, ErrInvalidResetCode
+	}
+	if err := ValidatePassword(newPassword); err != nil {
+		return nil, err
+	}
+
+	var dbUser db.User
+	if err := db.DB.First(&dbUser, reset.UserID).Error; err != nil {
+		return nil, ErrInvalidResetCode
+	}
+	user, err := store.Get(dbUser.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先占用重置码，防止并发请求重复使用
+	result := db.DB.Model(&db.PasswordReset{}).
+		Where("id = ? AND used_at IS NULL", reset.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidResetCode
+	}
+
+	user.Password = newPassword
+	user.MustChangePassword = false
+	if err := store.Update(user); err != nil {
+		return nil, err
+	}
+	if _, err := RevokeUserSessions(user.ID, ""); err != nil {
+		return nil, err
+	}
+	if err := UnlockAccount(user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// hashResetCode 忽略大小写、空格和连字符后计算哈希
+func hashResetCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+)
+
+func TestValidatePassword_Policy(t *testing.T) {
+	t.Cleanup(func() { SetPasswordPolicy(config.Default().Auth.PasswordPolicy) })
+
+	extra := filepath.Join(t.TempDir(), "breached.txt")
+	os.WriteFile(extra, []byte("# company list\nGrapeRegistry2024\n"), 0644)
+
+	cfg := config.Default().Auth.PasswordPolicy
+	cfg.MinLength = 10
+	cfg.RequireUppercase = true
+	cfg.RequireDigit = true
+	cfg.RequireSymbol = true
+	cfg.BreachedListFile = extra
+	if err := SetPasswordPolicy(cfg); err != nil {
+		t.Fatalf("SetPasswordPolicy failed: %v", err)
+	}
+
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"Sh0rt!", false},
+		{"longenough1!", false},    // 缺少大写字母
+		{"LongEnoughPass!", false}, // 缺少数字
+		{"LongEnough123", false},   // 缺少特殊字符
+		{"Correct-Horse-9", true},
+		{"Password1234!", true},       // 不在列表中
+		{"graperegistry2024", false},  // 额外列表（忽略大小写），同时缺少大写和特殊字符
+		{"GrapeRegistry2024!", true},  // 与列表中的条目不同
+		{"Pässwörter-Ünïcode1", true}, // 按字符而不是字节计算长度
+	}
+	for _, tt := range tests {
+		if err := ValidatePassword(tt.password); (err == nil) != tt.valid {
+			t.Errorf("ValidatePassword(%q) = %v, want valid %v", tt.password, err, tt.valid)
+		}
+	}
+
+	// 默认策略：8 位 + 泄露密码列表
+	SetPasswordPolicy(config.Default().Auth.PasswordPolicy)
+	if err := ValidatePassword("admin123"); err != ErrPasswordBreached {
+		t.Errorf("Expected admin123 to be rejected as breached, got %v", err)
+	}
+	if err := ValidatePassword("P@SSW0RD"); err != ErrPasswordBreached {
+		t.Errorf("Expected breached check to ignore case, got %v", err)
+	}
+	if err := ValidatePassword("short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Errorf("Expected ErrPasswordTooShort, got %v", err)
+	}
+	if err := ValidatePassword("tangerine-orbit"); err != nil {
+		t.Errorf("Expected password to be valid, got %v", err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	setupTestDB(t)
+	store := NewDBUserStore()
+	jwtService := NewJWTService("test-secret", time.Hour)
+	if err := store.Create(&User{Username: "alice", Password: "old-password-1", Role: "developer", MustChangePassword: true}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	alice, _ := store.Get("alice")
+	session, _ := jwtService.IssueSession(alice, SessionMethodPassword, "", "")
+
+	first, _, err := CreatePasswordReset(alice, "admin")
+	if err != nil {
+		t.Fatalf("CreatePasswordReset failed: %v", err)
+	}
+	code, expiresAt, _ := CreatePasswordReset(alice, "admin")
+	if time.Until(expiresAt) <= 0 {
+		t.Fatalf("Unexpected expiry: %v", expiresAt)
+	}
+
+	// 新的重置码签发后旧的作废
+	if _, err := RedeemPasswordReset(store, first, "new-password-2"); err != ErrInvalidResetCode {
+		t.Fatalf("Expected superseded code to be rejected, got %v", err)
+	}
+	// 不满足密码策略时不消耗重置码
+	if _, err := RedeemPasswordReset(store, code, "admin123"); err != ErrPasswordBreached {
+		t.Fatalf("Expected breached password to be rejected, got %v", err)
+	}
+
+	// 重置码忽略大小写和连字符
+	user, err := RedeemPasswordReset(store, " "+strings.ToLower(strings.ReplaceAll(code, "-", ""))+" ", "new-password-2")
+	if err != nil || user.Username != "alice" {
+		t.Fatalf("RedeemPasswordReset failed: %v", err)
+	}
+	if _, err := store.Validate("alice", "new-password-2"); err != nil {
+		t.Error("Expected new password to be set")
+	}
+	if updated, _ := store.Get("alice"); updated.MustChangePassword {
+		t.Error("Expected must-change flag to be cleared")
+	}
+	if current, _ := authenticateAs(t, jwtService, session); current != nil {
+		t.Error("Expected existing sessions to be revoked")
+	}
+
+	// 只能使用一次
+	if _, err := RedeemPasswordReset(store, code, "another-password-3"); err != ErrInvalidResetCode {
+		t.Fatalf("Expected used code to be rejected, got %v", err)
+	}
+
+	// 过期
+	expired, _, _ := CreatePasswordReset(alice, "admin")
+	db.DB.Model(&db.PasswordReset{}).Where("used_at IS NULL").Update("expires_at", time.Now().Add(-time.Minute))
+	if _, err := RedeemPasswordReset(store, expired, "another-password-3"); err != ErrInvalidResetCode {
+		t.Fatalf("Expected expired code to be rejected, got %v", err)
+	}
+
+	// 外部账号没有本地密码
+	if _, _, err := CreatePasswordReset(&User{ID: 99, Username: "bob", AuthSource: AuthSourceLDAP}, "admin"); err != ErrExternalPassword {
+		t.Fatalf("Expected ErrExternalPassword, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/graperegistry/grape/internal/db"
+)
+
+// 会话来源
+const (
+	SessionMethodPassword = "password"  // 用户名密码登录（Web UI / npm login）
+	SessionMethodOIDC     = "oidc"      // OIDC 单点登录
+	SessionMethodWebLogin = "web-login" // npm login --auth-type=web
+)
+
+// sessionTouchInterval 更新会话最后活跃时间的最小间隔，避免每个请求都写库
+const sessionTouchInterval = time.Minute
+
+var (
+	ErrSessionRevoked  = errors.New("session has been revoked")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// IssueSession 签发 JWT 并记录会话，之后可以通过 jti 撤销
+func (s *JWTService) IssueSession(user *User, method, ip, userAgent string) (string, error) {
+	jti := newJTI()
+	token, expiresAt, err := s.signToken(user, jti)
+	if err != nil {
+		return "", err
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	// 顺便清理已过期的会话
+	db.DB.Where("expires_at < ?", time.Now()).Delete(&db.UserSession{})
+
+	session := db.UserSession{
+		JTI:       jti,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Method:    method,
+		IP:        ip,
+		UserAgent: userAgent,
+		ExpiresAt: expiresAt,
+	}
+	if err := db.DB.Create(&session).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CheckSession 检查 JWT 对应的会话是否仍然有效（未撤销），并刷新最后活跃时间
+// 没有 jti 或会话记录不存在的 JWT 一律视为无效
+func CheckSession(claims *Claims) error {
+	if claims.ID == "" {
+		return ErrSessionRevoked
+	}
+	var session db.UserSession
+	if err := db.DB.Where("jti = ?", claims.ID).First(&session).Error; err != nil {
+		return ErrSessionRevoked
+	}
+	if session.RevokedAt != nil || session.Username != claims.Username {
+		return ErrSessionRevoked
+	}
+
+	now := time.Now()
+	if session.LastSeenAt == nil || now.Sub(*session.LastSeenAt) > sessionTouchInterval {
+		db.DB.Model(&session).Update("last_seen_at", &now)
+	}
+	return nil
+}
+
+// IsRecentSession 检查 jti 对应的会话是否未撤销、通过 method 登录且创建不超过 maxAge，
+// 没有本地密码的 SSO 账号以此代替重新输入密码
+func IsRecentSession(jti, method string, maxAge time.Duration) bool {
+	if jti == "" {
+		return false
+	}
+	var session db.UserSession
+	if err := db.DB.Where("jti = ? AND method = ? AND revoked_at IS NULL", jti, method).First(&session).Error; err != nil {
+		return false
+	}
+	return time.Since(session.CreatedAt) <= maxAge
+}
+
+// ListSessions 列出用户未撤销且未过期的会话，最新的在前
+func ListSessions(userID uint) ([]db.UserSession, error) {
+	var sessions []db.UserSession
+	err := db.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession 撤销用户的指定会话
+func RevokeSession(userID, sessionID uint) error {
+	result := db.DB.Model(&db.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeSessionByJTI 撤销 jti 对应的会话（登出）
+func RevokeSessionByJTI(jti string) error {
+	if jti == "" {
+		return nil
+	}
+	return db.DB.Model(&db.UserSession{}).
+		Where("jti = ? AND revoked_at IS NULL", jti).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions 撤销用户的所有会话，exceptJTI 非空时保留该会话（如修改密码的当前会话）
+// 返回撤销的会话数
+func RevokeUserSessions(userID uint, exceptJTI string) (int64, error) {
+	query := db.DB.Model(&db.UserSession{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptJTI != "" {
+		query = query.Where("jti <> ?", exceptJTI)
+	}
+	result := query.Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
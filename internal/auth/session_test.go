@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authenticateAs 用 Bearer token 走一遍 Authenticate，返回认证到的用户和会话
+func authenticateAs(t *testing.T, jwtService *JWTService, token string) (*User, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/-/whoami", nil)
+	c.Request.Header.Set("Authorization", "Bearer "+token)
+	if !Authenticate(c, jwtService, NewDBUserStore()) {
+		t.Fatal("Authenticate aborted the request")
+	}
+	return GetCurrentUser(c), GetSessionID(c)
+}
+
+func TestSession_IssueAndRevoke(t *testing.T) {
+	setupTestDB(t)
+	jwtService := NewJWTService("test-secret", time.Hour)
+	user := createTestUser(t, "alice", "developer")
+
+	token, err := jwtService.IssueSession(user, SessionMethodPassword, "10.0.0.1", "npm/10.0.0")
+	if err != nil {
+		t.Fatalf("IssueSession failed: %v", err)
+	}
+	current, jti := authenticateAs(t, jwtService, token)
+	if current == nil || current.Username != "alice" || jti == "" {
+		t.Fatalf("Expected session to authenticate, got %+v, %q", current, jti)
+	}
+
+	sessions, err := ListSessions(user.ID)
+	if err != nil || len(sessions) != 1 || sessions[0].IP != "10.0.0.1" || sessions[0].LastSeenAt == nil {
+		t.Fatalf("Unexpected sessions: %+v, %v", sessions, err)
+	}
+
+	// 登出后同一个 JWT 立即失效
+	if err := RevokeSessionByJTI(jti); err != nil {
+		t.Fatalf("RevokeSessionByJTI failed: %v", err)
+	}
+	if current, _ := authenticateAs(t, jwtService, token); current != nil {
+		t.Fatal("Expected revoked session to be rejected")
+	}
+	if sessions, _ := ListSessions(user.ID); len(sessions) != 0 {
+		t.Fatalf("Expected no active sessions, got %d", len(sessions))
+	}
+
+	// 没有会话记录的 JWT（签名正确）同样无效
+	unrecorded, _, _ := jwtService.signToken(user, newJTI())
+	if current, _ := authenticateAs(t, jwtService, unrecorded); current != nil {
+		t.Fatal("Expected JWT without a session record to be rejected")
+	}
+}
+
+func TestSession_RevokeUserSessions(t *testing.T) {
+	setupTestDB(t)
+	jwtService := NewJWTService("test-secret", time.Hour)
+	alice := createTestUser(t, "alice", "developer")
+	bob := createTestUser(t, "bob", "developer")
+
+	keep, _ := jwtService.IssueSession(alice, SessionMethodPassword, "", "")
+	other, _ := jwtService.IssueSession(alice, SessionMethodOIDC, "", "")
+	bobToken, _ := jwtService.IssueSession(bob, SessionMethodPassword, "", "")
+	_, keepJTI := authenticateAs(t, jwtService, keep)
+
+	// 修改密码：保留当前会话
+	count, err := RevokeUserSessions(alice.ID, keepJTI)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 revoked session, got %d, %v", count, err)
+	}
+	if current, _ := authenticateAs(t, jwtService, keep); current == nil {
+		t.Fatal("Expected current session to survive")
+	}
+	if current, _ := authenticateAs(t, jwtService, other); current != nil {
+		t.Fatal("Expected other session to be revoked")
+	}
+
+	// 单个会话只能由所属用户撤销
+	sessions, _ := ListSessions(alice.ID)
+	if err := RevokeSession(bob.ID, sessions[0].ID); err != ErrSessionNotFound {
+		t.Fatalf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	// 删除用户后会话全部失效
+	if err := NewDBUserStore().Delete("alice"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if sessions, _ := ListSessions(alice.ID); len(sessions) != 0 {
+		t.Fatalf("Expected deleted user to have no sessions, got %d", len(sessions))
+	}
+	if current, _ := authenticateAs(t, jwtService, bobToken); current == nil {
+		t.Fatal("Expected other users' sessions to be unaffected")
+	}
+}
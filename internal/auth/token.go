@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActionRead 读取包（仅用于 token 权限，授权给 token 的包都可以读取）
+const ActionRead PackageAction = "read"
+
+// tokenPermissions 细粒度 token 可以授予的操作
+var tokenPermissions = map[PackageAction]bool{
+	ActionRead:         true,
+	ActionPublish:      true,
+	ActionUnpublish:    true,
+	ActionDeprecate:    true,
+	ActionDistTag:      true,
+	ActionManageOwners: true,
+}
+
+// granularTokenDeniedPaths 细粒度 token 不能访问的账号管理 API（签发 token、profile、管理后台、组织等）
+var granularTokenDeniedPaths = []string{
+	"/-/npm/v1/tokens",
+	"/-/npm/v1/user",
+	"/-/api/",
+	"/-/org/",
+	"/-/team/",
+	"/-/v1/login",
+}
+
+// ParseTokenScope 校验并规范化细粒度 token 的包规则和操作
+// 包规则支持精确包名、@scope/* 和通配符（规则同包 owner）；操作至少一个
+func ParseTokenScope(packages, permissions []string) ([]string, []string, error) {
+	pkgs := make([]string, 0, len(packages))
+	for _, p := range packages {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if IsOwnerPattern(p) && !ValidOwnerPattern(p) {
+			return nil, nil, fmt.Errorf("invalid package pattern: %s", p)
+		}
+		pkgs = append(pkgs, p)
+	}
+
+	seen := make(map[string]bool)
+	perms := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		if !tokenPermissions[PackageAction(p)] {
+			return nil, nil, fmt.Errorf("invalid permission: %s", p)
+		}
+		seen[p] = true
+		perms = append(perms, p)
+	}
+	if len(perms) == 0 {
+		return nil, nil, fmt.Errorf("at least one permission is required")
+	}
+	return pkgs, perms, nil
+}
+
+// CanWrite token 是否有任何写权限
+func (t *TokenInfo) CanWrite() bool {
+	if t.Readonly {
+		return false
+	}
+	if !t.Granular {
+		return true
+	}
+	for _, p := range t.Permissions {
+		if PackageAction(p) != ActionRead {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsPackage 包是否在细粒度 token 的范围内，未限制包时匹配所有包
+func (t *TokenInfo) AllowsPackage(packageName string) bool {
+	if !t.Granular || len(t.Packages) == 0 {
+		return true
+	}
+	for _, pattern := range t.Packages {
+		if MatchOwnerPattern(pattern, packageName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows 检查 token 本身是否允许对包执行操作（不代替用户权限检查）
+//
+//   - 传统 token：只读 token 只能读取，否则不限制
+//   - 细粒度 token：包需在范围内，且操作在授权列表中；范围内的包都可以读取
+func (t *TokenInfo) Allows(action PackageAction, packageName string) bool {
+	if !t.Granular {
+		return action == ActionRead || !t.Readonly
+	}
+	if !t.AllowsPackage(packageName) {
+		return false
+	}
+	if action == ActionRead {
+		return true
+	}
+	for _, p := range t.Permissions {
+		if PackageAction(p) == action {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenAllows 使用持久化 token 认证时检查 token 的权限范围，JWT 会话不受限制
+func TokenAllows(c *gin.Context, action PackageAction, packageName string) bool {
+	t := GetTokenInfo(c)
+	return t == nil || t.Allows(action, packageName)
+}
+
+// allowsPath 细粒度 token 只能用于 registry 操作，不能访问账号管理 API
+func (t *TokenInfo) allowsPath(path string) bool {
+	if !t.Granular {
+		return true
+	}
+	for _, prefix := range granularTokenDeniedPaths {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/db"
+)
+
+func TestParseTokenScope(t *testing.T) {
+	pkgs, perms, err := ParseTokenScope([]string{" @acme/* ", "", "lodash"}, []string{"publish", "read", "publish"})
+	if err != nil {
+		t.Fatalf("ParseTokenScope failed: %v", err)
+	}
+	if len(pkgs) != 2 || pkgs[0] != "@acme/*" || pkgs[1] != "lodash" {
+		t.Errorf("Unexpected packages: %v", pkgs)
+	}
+	if len(perms) != 2 || perms[0] != "publish" || perms[1] != "read" {
+		t.Errorf("Unexpected permissions: %v", perms)
+	}
+
+	invalid := []struct {
+		packages    []string
+		permissions []string
+	}{
+		{nil, nil},
+		{nil, []string{"admin"}},
+		{[]string{"@acme/[*"}, []string{"read"}},
+	}
+	for _, tt := range invalid {
+		if _, _, err := ParseTokenScope(tt.packages, tt.permissions); err == nil {
+			t.Errorf("Expected error for %v %v", tt.packages, tt.permissions)
+		}
+	}
+}
+
+func TestTokenInfo_Allows(t *testing.T) {
+	classic := &TokenInfo{}
+	readonly := &TokenInfo{Readonly: true}
+	granular := &TokenInfo{Granular: true, Packages: []string{"@acme/ui-*"}, Permissions: []string{"publish", "dist-tag"}}
+	readOnlyGranular := &TokenInfo{Granular: true, Permissions: []string{"read"}}
+
+	tests := []struct {
+		name   string
+		token  *TokenInfo
+		action PackageAction
+		pkg    string
+		want   bool
+	}{
+		{"classic publish", classic, ActionPublish, "lodash", true},
+		{"readonly read", readonly, ActionRead, "lodash", true},
+		{"readonly publish", readonly, ActionPublish, "lodash", false},
+		{"granular publish in scope", granular, ActionPublish, "@acme/ui-button", true},
+		{"granular read implied", granular, ActionRead, "@acme/ui-button", true},
+		{"granular action not granted", granular, ActionUnpublish, "@acme/ui-button", false},
+		{"granular out of scope", granular, ActionPublish, "@acme/core", false},
+		{"granular read out of scope", granular, ActionRead, "lodash", false},
+		{"granular all packages", readOnlyGranular, ActionRead, "lodash", true},
+		{"granular read only", readOnlyGranular, ActionPublish, "lodash", false},
+	}
+	for _, tt := range tests {
+		if got := tt.token.Allows(tt.action, tt.pkg); got != tt.want {
+			t.Errorf("%s: Allows(%s, %s) = %v, want %v", tt.name, tt.action, tt.pkg, got, tt.want)
+		}
+	}
+
+	if !granular.CanWrite() || readOnlyGranular.CanWrite() || readonly.CanWrite() || !classic.CanWrite() {
+		t.Error("Unexpected CanWrite result")
+	}
+}
+
+func TestAuthenticate_GranularToken(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "ci", "developer")
+	raw := "grape_granular_test_token"
+	hash := sha256.Sum256([]byte(raw))
+	record := &db.Token{
+		UserID:      user.ID,
+		Name:        "ci",
+		TokenHash:   hex.EncodeToString(hash[:]),
+		TokenPrefix: raw[:8],
+		Granular:    true,
+		Packages:    "@acme/*",
+		Permissions: "publish",
+	}
+	if err := db.DB.Create(record).Error; err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	jwtService := NewJWTService("test-secret", time.Hour)
+	request := func(path string) (*gin.Context, *httptest.ResponseRecorder, bool) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, path, nil)
+		c.Request.Header.Set("Authorization", "Bearer "+raw)
+		return c, w, Authenticate(c, jwtService, NewDBUserStore())
+	}
+
+	c, _, ok := request("/@acme%2fui")
+	if !ok || GetCurrentUser(c) == nil {
+		t.Fatal("Expected granular token to authenticate registry requests")
+	}
+	if !TokenAllows(c, ActionPublish, "@acme/ui") || TokenAllows(c, ActionPublish, "lodash") {
+		t.Error("Expected token scope to be enforced")
+	}
+
+	// 账号管理 API 拒绝细粒度 token
+	for _, path := range []string{"/-/npm/v1/tokens", "/-/npm/v1/user", "/-/api/admin/users"} {
+		if _, w, ok := request(path); ok || w.Code != http.StatusForbidden {
+			t.Errorf("Expected %s to be forbidden, got %d", path, w.Code)
+		}
+	}
+}
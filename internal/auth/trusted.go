@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+)
+
+// TrustedTokenPrefix trusted publishing 签发的短期 token 名称前缀
+const TrustedTokenPrefix = "trusted-publishing:"
+
+var (
+	ErrUntrustedIssuer = errors.New("oidc token issuer is not trusted")
+	ErrNoTrustPolicy   = errors.New("no trusted publisher policy matches this token")
+)
+
+// TrustedIdentity 校验通过的 CI 身份
+type TrustedIdentity struct {
+	Issuer string // TrustedIssuerConfig.Name
+	Claims jwt.MapClaims
+}
+
+// Subject 用于审计日志的简短描述，如 acme/ui@refs/heads/main
+func (i *TrustedIdentity) Subject() string {
+	if repo := claimString(i.Claims, "repository"); repo != "" {
+		if ref := claimString(i.Claims, "ref"); ref != "" {
+			return repo + "@" + ref
+		}
+		return repo
+	}
+	return claimString(i.Claims, "sub")
+}
+
+// TrustedPublisher 校验 CI 的 OIDC ID token 并匹配信任策略
+// 每个签发方的 JWKS 按需获取并缓存（复用 KeySet 的 kid 轮换逻辑）
+type TrustedPublisher struct {
+	cfg    *config.TrustedPublishingConfig
+	client *http.Client
+
+	mu   sync.Mutex
+	keys map[string]*KeySet
+}
+
+// NewTrustedPublisher 创建 TrustedPublisher
+func NewTrustedPublisher(cfg *config.TrustedPublishingConfig) *TrustedPublisher {
+	return &TrustedPublisher{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]*KeySet),
+	}
+}
+
+// Enabled 是否启用并配置了签发方
+func (p *TrustedPublisher) Enabled() bool {
+	return p.cfg.Enabled && p.cfg.Audience != "" && len(p.cfg.Issuers) > 0
+}
+
+// TokenTTL 发布凭证有效期
+func (p *TrustedPublisher) TokenTTL() time.Duration {
+	if p.cfg.TokenTTL <= 0 {
+		return 15 * time.Minute
+	}
+	return p.cfg.TokenTTL
+}
+
+// Verify 校验 ID token 的签名、issuer、audience 与有效期
+func (p *TrustedPublisher) Verify(raw string) (*TrustedIdentity, error) {
+	// 先读取未校验的 iss 以选择签发方，签名和 issuer 随后由 ParseWithClaims 校验
+	unverified := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(raw, unverified); err != nil {
+		return nil, fmt.Errorf("invalid oidc token: %w", err)
+	}
+	issuer := p.findIssuer(claimString(unverified, "iss"))
+	if issuer == nil {
+		return nil, ErrUntrustedIssuer
+	}
+
+	keys, err := p.keySet(issuer)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, keys.Keyfunc,
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(issuer.Issuer),
+		jwt.WithAudience(p.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oidc token: %w", err)
+	}
+	return &TrustedIdentity{Issuer: issuer.Name, Claims: claims}, nil
+}
+
+// Match 返回允许该身份发布包的第一条策略
+func (p *TrustedPublisher) Match(identity *TrustedIdentity, packageName string) (*config.TrustPolicyConfig, error) {
+	for i := range p.cfg.Policies {
+		policy := &p.cfg.Policies[i]
+		if policy.Issuer == identity.Issuer && policyMatchesPackage(policy, packageName) && policyMatchesClaims(policy, identity.Claims) {
+			return policy, nil
+		}
+	}
+	return nil, ErrNoTrustPolicy
+}
+
+func (p *TrustedPublisher) findIssuer(iss string) *config.TrustedIssuerConfig {
+	if iss == "" {
+		return nil
+	}
+	for i := range p.cfg.Issuers {
+		if strings.TrimSuffix(p.cfg.Issuers[i].Issuer, "/") == strings.TrimSuffix(iss, "/") {
+			return &p.cfg.Issuers[i]
+		}
+	}
+	return nil
+}
+
+// keySet 返回签发方的 KeySet，未配置 jwks_url 时通过 discovery 获取
+func (p *TrustedPublisher) keySet(issuer *config.TrustedIssuerConfig) (*KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if keys, ok := p.keys[issuer.Name]; ok {
+		return keys, nil
+	}
+
+	jwksURL := issuer.JWKSURL
+	if jwksURL == "" {
+		resp, err := p.client.Get(strings.TrimSuffix(issuer.Issuer, "/") + "/.well-known/openid-configuration")
+		if err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("oidc discovery: unexpected status %d", resp.StatusCode)
+		}
+		var d oidcDiscovery
+		if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		if d.JWKSURI == "" {
+			return nil, fmt.Errorf("oidc discovery: no jwks_uri")
+		}
+		jwksURL = d.JWKSURI
+	}
+
+	keys := NewKeySet(jwksURL, p.client)
+	p.keys[issuer.Name] = keys
+	return keys, nil
+}
+
+// policyMatchesPackage 包名是否在策略范围内
+func policyMatchesPackage(policy *config.TrustPolicyConfig, packageName string) bool {
+	for _, pattern := range policy.Packages {
+		if MatchOwnerPattern(pattern, packageName) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyMatchesClaims 策略的所有条件都要满足；没有 repository 和 claims 条件的策略不生效，
+// 否则同一签发方（如 GitHub）下的任何仓库都能发布
+func policyMatchesClaims(policy *config.TrustPolicyConfig, claims jwt.MapClaims) bool {
+	if policy.Repository == "" && len(policy.Claims) == 0 {
+		return false
+	}
+	if policy.Repository != "" && claimString(claims, "repository") != policy.Repository {
+		return false
+	}
+	if policy.Workflow != "" && !matchClaim(policy.Workflow, workflowFile(claimString(claims, "workflow_ref"))) {
+		return false
+	}
+	if policy.Branch != "" && !matchClaim("refs/heads/"+policy.Branch, claimString(claims, "ref")) {
+		return false
+	}
+	if policy.Environment != "" && claimString(claims, "environment") != policy.Environment {
+		return false
+	}
+	for name, want := range policy.Claims {
+		v, ok := claims[name]
+		if !ok || !matchClaim(want, fmt.Sprint(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchClaim 比较 claim 值，支持 * 通配符（不跨越 /）
+func matchClaim(pattern, value string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == value
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// workflowFile 从 workflow_ref 中取出 workflow 文件名
+// 例如 acme/ui/.github/workflows/release.yml@refs/heads/main -> release.yml
+func workflowFile(workflowRef string) string {
+	if i := strings.Index(workflowRef, "@"); i >= 0 {
+		workflowRef = workflowRef[:i]
+	}
+	if i := strings.Index(workflowRef, "/.github/workflows/"); i >= 0 {
+		return workflowRef[i+len("/.github/workflows/"):]
+	}
+	return workflowRef
+}
+
+// IssueTrustedToken 为 trusted publishing 签发只能发布单个包的短期 token
+// token 记录在 tokens 表中，可以像普通 token 一样被撤销；过期的记录在下次签发时清理
+func IssueTrustedToken(user *User, packageName, subject string, ttl time.Duration) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(b)
+	hash := sha256.Sum256([]byte(token))
+
+	db.DB.Where("name LIKE ? AND expires_at < ?", TrustedTokenPrefix+"%", time.Now()).Delete(&db.Token{})
+
+	name := TrustedTokenPrefix + " " + subject
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	expiresAt := time.Now().Add(ttl)
+	record := &db.Token{
+		UserID:          user.ID,
+		Name:            name,
+		TokenHash:       hex.EncodeToString(hash[:]),
+		TokenPrefix:     token[:6],
+		Granular:        true,
+		Packages:        packageName,
+		Permissions:     string(ActionPublish),
+		BypassTwoFactor: true, // CI 无法输入 OTP，OIDC 身份本身已经是强认证
+		ExpiresAt:       &expiresAt,
+	}
+	if err := db.DB.Create(record).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/graperegistry/grape/internal/config"
+)
+
+// githubClaims GitHub Actions ID token 的常用 claims
+func githubClaims(repo, workflow, ref string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"aud":          "npm:registry.example.com",
+		"sub":          "repo:" + repo + ":ref:" + ref,
+		"repository":   repo,
+		"ref":          ref,
+		"workflow_ref": repo + "/.github/workflows/" + workflow + "@" + ref,
+	}
+}
+
+func testTrustedPublisher(idp *mockIdP) *TrustedPublisher {
+	cfg := config.Default().Auth.TrustedPublishing
+	cfg.Enabled = true
+	cfg.Audience = "npm:registry.example.com"
+	cfg.Issuers = []config.TrustedIssuerConfig{{Name: "github", Issuer: idp.server.URL}}
+	cfg.Policies = []config.TrustPolicyConfig{
+		{Issuer: "github", Packages: []string{"@acme/ui-*"}, User: "ci", Repository: "acme/ui", Workflow: "release.yml", Branch: "main"},
+		{Issuer: "github", Packages: []string{"@acme/*"}, User: "ci", Claims: map[string]string{"repository": "acme/*", "environment": "npm"}},
+		{Issuer: "github", Packages: []string{"*"}, User: "ci"}, // 没有 repository/claims 条件，不生效
+	}
+	return NewTrustedPublisher(&cfg)
+}
+
+func TestTrustedPublisher_Verify(t *testing.T) {
+	idp := newMockIdP(t)
+	p := testTrustedPublisher(idp)
+
+	identity, err := p.Verify(idp.sign(t, "", githubClaims("acme/ui", "release.yml", "refs/heads/main")))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if identity.Issuer != "github" || identity.Subject() != "acme/ui@refs/heads/main" {
+		t.Errorf("Unexpected identity: %s %s", identity.Issuer, identity.Subject())
+	}
+
+	rejected := map[string]jwt.MapClaims{
+		"wrong audience":   {"aud": "npm:registry.npmjs.org"},
+		"untrusted issuer": {"iss": "https://evil.example.com"},
+		"expired":          {"exp": time.Now().Add(-time.Hour).Unix()},
+	}
+	for name, claims := range rejected {
+		if _, err := p.Verify(idp.sign(t, "", claims)); err == nil {
+			t.Errorf("%s: expected token to be rejected", name)
+		}
+	}
+
+	// 签名密钥不在 JWKS 中
+	other := newMockIdP(t)
+	forged := other.sign(t, "", jwt.MapClaims{"iss": idp.server.URL, "aud": "npm:registry.example.com"})
+	if _, err := p.Verify(forged); err == nil {
+		t.Error("Expected token signed by an unknown key to be rejected")
+	}
+}
+
+func TestTrustedPublisher_Match(t *testing.T) {
+	idp := newMockIdP(t)
+	p := testTrustedPublisher(idp)
+
+	envClaims := githubClaims("acme/core", "ci.yml", "refs/tags/v1.0.0")
+	envClaims["environment"] = "npm"
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		pkg     string
+		matched bool
+	}{
+		{"release workflow on main", githubClaims("acme/ui", "release.yml", "refs/heads/main"), "@acme/ui-button", true},
+		{"other branch", githubClaims("acme/ui", "release.yml", "refs/heads/dev"), "@acme/ui-button", false},
+		{"other workflow", githubClaims("acme/ui", "test.yml", "refs/heads/main"), "@acme/ui-button", false},
+		{"other repository", githubClaims("evil/ui", "release.yml", "refs/heads/main"), "@acme/ui-button", false},
+		{"package outside policy", githubClaims("acme/ui", "release.yml", "refs/heads/main"), "lodash", false},
+		{"generic claims policy", envClaims, "@acme/core", true},
+	}
+
+	for _, tt := range tests {
+		identity := &TrustedIdentity{Issuer: "github", Claims: tt.claims}
+		_, err := p.Match(identity, tt.pkg)
+		if (err == nil) != tt.matched {
+			t.Errorf("%s: Match() error = %v, want matched %v", tt.name, err, tt.matched)
+		}
+	}
+}
+
+func TestIssueTrustedToken(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "ci", "developer")
+
+	token, expiresAt, err := IssueTrustedToken(user, "@acme/ui", "acme/ui@refs/heads/main", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("IssueTrustedToken failed: %v", err)
+	}
+	if time.Until(expiresAt) > 15*time.Minute {
+		t.Errorf("Unexpected expiry: %v", expiresAt)
+	}
+
+	got, info, err := ValidateTokenByHash(token)
+	if err != nil || got.Username != "ci" {
+		t.Fatalf("Expected issued token to authenticate, got %v", err)
+	}
+	if !info.Allows(ActionPublish, "@acme/ui") || info.Allows(ActionPublish, "@acme/other") || info.Allows(ActionUnpublish, "@acme/ui") {
+		t.Error("Expected token to only allow publishing the exchanged package")
+	}
+	if !info.BypassTwoFactor {
+		t.Error("Expected trusted publishing token to bypass OTP")
+	}
+}
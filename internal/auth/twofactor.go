@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"gorm.io/gorm"
+)
+
+// 两步验证模式（与 npm profile enable-2fa 一致）
+const (
+	TwoFactorAuthOnly      = "auth-only"       // 仅登录时需要 OTP
+	TwoFactorAuthAndWrites = "auth-and-writes" // 登录和写操作都需要 OTP
+)
+
+// OTPScope 需要 OTP 的操作类型
+type OTPScope int
+
+const (
+	OTPLogin   OTPScope = iota // 登录（PUT /-/user）
+	OTPWrite                   // 发布、删除包，修改 dist-tag 和 owner
+	OTPAccount                 // 修改两步验证设置
+)
+
+// recoveryCodeCount 启用两步验证时生成的恢复码数量
+const recoveryCodeCount = 10
+
+var (
+	ErrOTPRequired          = errors.New("one-time password required")
+	ErrInvalidOTP           = errors.New("invalid one-time password")
+	ErrTwoFactorRequired    = errors.New("two-factor authentication is required by the registry policy")
+	ErrTwoFactorNotPending  = errors.New("two-factor authentication setup has not been started")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrInvalidTwoFactorMode = errors.New("invalid two-factor mode, must be auth-only or auth-and-writes")
+)
+
+// twoFactorPolicy 当前生效的两步验证策略（可热更新）
+var twoFactorPolicy atomic.Pointer[config.TwoFactorConfig]
+
+// SetTwoFactorPolicy 更新两步验证策略
+func SetTwoFactorPolicy(cfg config.TwoFactorConfig) {
+	twoFactorPolicy.Store(&cfg)
+}
+
+// currentTwoFactorPolicy 返回当前策略，未设置时为默认值（不强制）
+func currentTwoFactorPolicy() config.TwoFactorConfig {
+	if p := twoFactorPolicy.Load(); p != nil {
+		return *p
+	}
+	return config.Default().Auth.TwoFactor
+}
+
+// TwoFactorStatus 用户的两步验证状态
+type TwoFactorStatus struct {
+	Enabled bool   `json:"enabled"`
+	Pending bool   `json:"pending"`
+	Mode    string `json:"mode,omitempty"`
+}
+
+// ValidTwoFactorMode 检查模式是否合法
+func ValidTwoFactorMode(mode string) bool {
+	return mode == TwoFactorAuthOnly || mode == TwoFactorAuthAndWrites
+}
+
+// GetTwoFactorStatus 查询用户的两步验证状态
+func GetTwoFactorStatus(userID uint) TwoFactorStatus {
+	var tfa db.UserTwoFactor
+	if err := db.DB.Where("user_id = ?", userID).First(&tfa).Error; err != nil {
+		return TwoFactorStatus{}
+	}
+	return TwoFactorStatus{Enabled: !tfa.Pending, Pending: tfa.Pending, Mode: tfa.Mode}
+}
+
+// BeginTwoFactor 生成新的 TOTP 密钥（待确认），返回认证器 App 使用的 otpauth:// 地址
+func BeginTwoFactor(user *User, mode string) (string, error) {
+	if !ValidTwoFactorMode(mode) {
+		return "", ErrInvalidTwoFactorMode
+	}
+	if GetTwoFactorStatus(user.ID).Enabled {
+		return "", ErrTwoFactorEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	var tfa db.UserTwoFactor
+	err = db.DB.Where("user_id = ?", user.ID).
+		Assign(map[string]interface{}{"secret": secret, "mode": mode, "pending": true, "recovery_codes": "", "last_step": 0}).
+		FirstOrCreate(&tfa, db.UserTwoFactor{UserID: user.ID}).Error
+	if err != nil {
+		return "", err
+	}
+	return TOTPURL(currentTwoFactorPolicy().Issuer, user.Username, secret), nil
+}
+
+// ConfirmTwoFactor 用认证器 App 生成的 OTP 确认启用，返回一次性恢复码（只展示这一次）
+func ConfirmTwoFactor(userID uint, otp string) ([]string, error) {
+	var tfa db.UserTwoFactor
+	if err := db.DB.Where("user_id = ? AND pending = ?", userID, true).First(&tfa).Error; err != nil {
+		return nil, ErrTwoFactorNotPending
+	}
+	step, ok := ValidateTOTP(tfa.Secret, otp, time.Now())
+	if !ok {
+		return nil, ErrInvalidOTP
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.DB.Model(&tfa).Updates(map[string]interface{}{
+		"pending":        false,
+		"recovery_codes": hashes,
+		"last_step":      step,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// SetTwoFactorMode 修改已启用的两步验证模式
+func SetTwoFactorMode(userID uint, mode string) error {
+	if !ValidTwoFactorMode(mode) {
+		return ErrInvalidTwoFactorMode
+	}
+	return db.DB.Model(&db.UserTwoFactor{}).Where("user_id = ?", userID).Update("mode", mode).Error
+}
+
+// DisableTwoFactor 关闭两步验证（包括未确认的设置）
+func DisableTwoFactor(userID uint) error {
+	return db.DB.Where("user_id = ?", userID).Delete(&db.UserTwoFactor{}).Error
+}
+
+// VerifyOTP 校验 OTP 或恢复码：OTP 不能重复使用，恢复码使用后作废
+func VerifyOTP(userID uint, otp string) error {
+	otp = strings.TrimSpace(otp)
+	if otp == "" {
+		return ErrOTPRequired
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var tfa db.UserTwoFactor
+		if err := tx.Where("user_id = ? AND pending = ?", userID, false).First(&tfa).Error; err != nil {
+			return ErrInvalidOTP
+		}
+
+		if step, ok := ValidateTOTP(tfa.Secret, otp, time.Now()); ok {
+			if step <= tfa.LastStep {
+				return ErrInvalidOTP
+			}
+			return tx.Model(&tfa).Update("last_step", step).Error
+		}
+
+		// 恢复码
+		var hashes []string
+		json.Unmarshal([]byte(tfa.RecoveryCodes), &hashes)
+		hash := hashRecoveryCode(otp)
+		for i, h := range hashes {
+			if h == hash {
+				remaining := append(hashes[:i:i], hashes[i+1:]...)
+				data, _ := json.Marshal(remaining)
+				return tx.Model(&tfa).Update("recovery_codes", string(data)).Error
+			}
+		}
+		return ErrInvalidOTP
+	})
+}
+
+// CheckOTP 按用户的两步验证模式和全局策略检查操作是否需要 OTP，需要时校验 otp
+//
+//   - 登录、修改两步验证设置：启用了两步验证的用户都需要 OTP
+//   - 发布/删除等写操作：auth-and-writes 模式需要 OTP；开启 require_for_publishers 时，
+//     未启用两步验证的用户被拒绝，已启用的用户无论模式都需要 OTP
+func CheckOTP(user *User, otp string, scope OTPScope) error {
+	status := GetTwoFactorStatus(user.ID)
+	policy := currentTwoFactorPolicy()
+
+	if !status.Enabled {
+		if scope == OTPWrite && policy.RequireForPublishers {
+			return ErrTwoFactorRequired
+		}
+		return nil
+	}
+
+	required := scope != OTPWrite ||
+		status.Mode == TwoFactorAuthAndWrites ||
+		policy.RequireForPublishers
+	if !required {
+		return nil
+	}
+	return VerifyOTP(user.ID, otp)
+}
+
+// RequireAdminTwoFactor 开启 require_for_admins 时，未启用两步验证的管理员不能使用管理 API
+// 需放在 RequireAdmin 之后
+func RequireAdminTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := GetCurrentUser(c)
+		if user != nil && currentTwoFactorPolicy().RequireForAdmins && !GetTwoFactorStatus(user.ID).Enabled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "two-factor authentication is required for administrators, enable it with `npm profile enable-2fa`",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// generateRecoveryCodes 生成恢复码，返回明文和 JSON 编码的哈希列表
+func generateRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, "", err
+	}
+	return codes, string(data), nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(sum[:])
+}
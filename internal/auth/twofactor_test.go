@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/graperegistry/grape/internal/config"
+)
+
+func TestValidateTOTP_RFC6238(t *testing.T) {
+	// RFC 6238 附录 B 的 SHA1 测试向量（密钥 "12345678901234567890"，取后 6 位）
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range cases {
+		step, ok := ValidateTOTP(secret, tc.code, time.Unix(tc.unix, 0))
+		if !ok || step != tc.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%d, %s) = %d, %v", tc.unix, tc.code, step, ok)
+		}
+	}
+
+	// 超出允许的时钟偏差
+	if _, ok := ValidateTOTP(secret, "287082", time.Unix(59+3*totpPeriod, 0)); ok {
+		t.Error("Expected code outside the skew window to be rejected")
+	}
+}
+
+// currentOTP 计算密钥在 now 之后 offset 个时间片的 OTP
+func currentOTP(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totpCode(secret, time.Now().Unix()/totpPeriod+offset)
+	if err != nil {
+		t.Fatalf("totpCode failed: %v", err)
+	}
+	return code
+}
+
+// enrollTwoFactor 完成开启两步验证的两个步骤，返回密钥和恢复码
+func enrollTwoFactor(t *testing.T, user *User, mode string) (string, []string) {
+	t.Helper()
+	otpauthURL, err := BeginTwoFactor(user, mode)
+	if err != nil {
+		t.Fatalf("BeginTwoFactor failed: %v", err)
+	}
+	u, err := url.Parse(otpauthURL)
+	if err != nil {
+		t.Fatalf("Invalid otpauth URL: %v", err)
+	}
+	secret := u.Query().Get("secret")
+
+	if !GetTwoFactorStatus(user.ID).Pending {
+		t.Fatal("Expected two-factor setup to be pending")
+	}
+	codes, err := ConfirmTwoFactor(user.ID, currentOTP(t, secret, 0))
+	if err != nil {
+		t.Fatalf("ConfirmTwoFactor failed: %v", err)
+	}
+	return secret, codes
+}
+
+func TestTwoFactor_EnrollAndVerify(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "developer")
+
+	if _, err := ConfirmTwoFactor(user.ID, "123456"); err != ErrTwoFactorNotPending {
+		t.Fatalf("Expected ErrTwoFactorNotPending, got %v", err)
+	}
+
+	secret, codes := enrollTwoFactor(t, user, TwoFactorAuthOnly)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+	if status := GetTwoFactorStatus(user.ID); !status.Enabled || status.Mode != TwoFactorAuthOnly {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+	if _, err := BeginTwoFactor(user, TwoFactorAuthOnly); err != ErrTwoFactorEnabled {
+		t.Fatalf("Expected ErrTwoFactorEnabled, got %v", err)
+	}
+
+	// 同一个 OTP 不能重复使用
+	next := currentOTP(t, secret, 1)
+	if err := VerifyOTP(user.ID, next); err != nil {
+		t.Fatalf("Expected next OTP to be accepted, got %v", err)
+	}
+	if err := VerifyOTP(user.ID, next); err != ErrInvalidOTP {
+		t.Fatalf("Expected replayed OTP to be rejected, got %v", err)
+	}
+
+	// 恢复码只能使用一次
+	if err := VerifyOTP(user.ID, codes[3]); err != nil {
+		t.Fatalf("Expected recovery code to be accepted, got %v", err)
+	}
+	if err := VerifyOTP(user.ID, codes[3]); err != ErrInvalidOTP {
+		t.Fatalf("Expected used recovery code to be rejected, got %v", err)
+	}
+
+	if err := DisableTwoFactor(user.ID); err != nil {
+		t.Fatalf("DisableTwoFactor failed: %v", err)
+	}
+	if GetTwoFactorStatus(user.ID).Enabled {
+		t.Fatal("Expected two-factor to be disabled")
+	}
+}
+
+func TestCheckOTP_ModesAndPolicy(t *testing.T) {
+	setupTestDB(t)
+	t.Cleanup(func() { SetTwoFactorPolicy(config.Default().Auth.TwoFactor) })
+
+	plain := createTestUser(t, "plain", "developer")
+	authOnly := createTestUser(t, "authonly", "developer")
+	writes := createTestUser(t, "writes", "developer")
+	enrollTwoFactor(t, authOnly, TwoFactorAuthOnly)
+	enrollTwoFactor(t, writes, TwoFactorAuthAndWrites)
+
+	SetTwoFactorPolicy(config.TwoFactorConfig{})
+	cases := []struct {
+		user  *User
+		scope OTPScope
+		want  error
+	}{
+		{plain, OTPLogin, nil},
+		{plain, OTPWrite, nil},
+		{authOnly, OTPLogin, ErrOTPRequired},
+		{authOnly, OTPWrite, nil},
+		{writes, OTPLogin, ErrOTPRequired},
+		{writes, OTPWrite, ErrOTPRequired},
+	}
+	for _, tc := range cases {
+		if err := CheckOTP(tc.user, "", tc.scope); err != tc.want {
+			t.Errorf("CheckOTP(%s, %d) = %v, want %v", tc.user.Username, tc.scope, err, tc.want)
+		}
+	}
+
+	// require_for_publishers：未启用两步验证的用户不能发布，auth-only 用户发布时也需要 OTP
+	SetTwoFactorPolicy(config.TwoFactorConfig{RequireForPublishers: true})
+	if err := CheckOTP(plain, "", OTPWrite); err != ErrTwoFactorRequired {
+		t.Errorf("Expected ErrTwoFactorRequired, got %v", err)
+	}
+	if err := CheckOTP(plain, "", OTPLogin); err != nil {
+		t.Errorf("Expected login without 2FA to be allowed, got %v", err)
+	}
+	if err := CheckOTP(authOnly, "", OTPWrite); err != ErrOTPRequired {
+		t.Errorf("Expected ErrOTPRequired, got %v", err)
+	}
+}
@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -9,11 +8,6 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// 密码验证错误
-var (
-	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
-)
-
 // ValidRoles 有效的角色列表
 var ValidRoles = map[string]bool{
 	"admin":     true,
@@ -21,14 +15,6 @@ var ValidRoles = map[string]bool{
 	"readonly":  true,
 }
 
-// ValidatePassword 验证密码强度
-func ValidatePassword(password string) error {
-	if len(password) < 8 {
-		return ErrPasswordTooShort
-	}
-	return nil
-}
-
 // ValidateRole 验证角色是否有效
 func ValidateRole(role string) error {
 	if role == "" {
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
+	"regexp"
 	"time"
 )
 
@@ -12,6 +15,9 @@ type Config struct {
 	Auth     AuthConfig     `mapstructure:"auth"`
 	Database DatabaseConfig `mapstructure:"database"`
 	Security SecurityConfig `mapstructure:"security"`
+	Sync     SyncConfig     `mapstructure:"sync"`
+
+	Replication ReplicationConfig `mapstructure:"replication"`
 }
 
 type ServerConfig struct {
@@ -55,16 +61,213 @@ type LogConfig struct {
 }
 
 type AuthConfig struct {
-	JWTSecret         string        `mapstructure:"jwt_secret"`
-	JWTExpiry         time.Duration `mapstructure:"jwt_expiry"`
-	AllowRegistration bool          `mapstructure:"allow_registration"` // 是否允许自助注册，默认 false
+	JWTSecret         string                  `mapstructure:"jwt_secret"`
+	JWTExpiry         time.Duration           `mapstructure:"jwt_expiry"`
+	AllowRegistration bool                    `mapstructure:"allow_registration"` // 是否允许自助注册，默认 false
+	RequireReadAuth   bool                    `mapstructure:"require_read_auth"`  // 读取包（元数据/tarball/搜索）是否需要认证，默认 false
+	Backend           string                  `mapstructure:"backend"`            // 用户认证后端：db | ldap，默认 db
+	LDAP              LDAPConfig              `mapstructure:"ldap"`
+	OIDC              OIDCConfig              `mapstructure:"oidc"`
+	TwoFactor         TwoFactorConfig         `mapstructure:"two_factor"`
+	TrustedPublishing TrustedPublishingConfig `mapstructure:"trusted_publishing"`
+	PasswordPolicy    PasswordPolicyConfig    `mapstructure:"password_policy"`
+	Lockout           LockoutConfig           `mapstructure:"lockout"`
+}
+
+// LDAPConfig LDAP / Active Directory 认证配置
+type LDAPConfig struct {
+	URL                string            `mapstructure:"url"`                  // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool              `mapstructure:"start_tls"`            // ldap:// 连接后升级为 TLS
+	InsecureSkipVerify bool              `mapstructure:"insecure_skip_verify"` // 跳过证书校验（仅测试环境）
+	BindDN             string            `mapstructure:"bind_dn"`              // 用于搜索用户的服务账号
+	BindPassword       string            `mapstructure:"bind_password"`
+	BaseDN             string            `mapstructure:"base_dn"`            // 用户搜索根
+	UserFilter         string            `mapstructure:"user_filter"`        // 用户搜索过滤器，%s 替换为用户名
+	UsernameAttribute  string            `mapstructure:"username_attribute"` // uid（AD 为 sAMAccountName）
+	EmailAttribute     string            `mapstructure:"email_attribute"`
+	NameAttribute      string            `mapstructure:"name_attribute"`
+	GroupBaseDN        string            `mapstructure:"group_base_dn"`       // 组搜索根，为空时读取用户的 memberOf 属性
+	GroupFilter        string            `mapstructure:"group_filter"`        // 组搜索过滤器，%s 替换为用户 DN
+	GroupAttribute     string            `mapstructure:"group_attribute"`     // 组名属性
+	RoleMapping        map[string]string `mapstructure:"role_mapping"`        // 组名 -> 角色（admin/developer/readonly）
+	DefaultRole        string            `mapstructure:"default_role"`        // 不属于任何映射组时的角色，为空则拒绝登录
+	AutoProvision      bool              `mapstructure:"auto_provision"`      // 首次登录时自动创建本地用户
+	LinkLocalAccounts  bool              `mapstructure:"link_local_accounts"` // 允许目录用户接管同名的本地账号（默认不允许）
+	Timeout            time.Duration     `mapstructure:"timeout"`
+}
+
+// OIDCConfig OpenID Connect 单点登录配置（Web UI，授权码 + PKCE）
+type OIDCConfig struct {
+	Enabled       bool              `mapstructure:"enabled"`
+	DisplayName   string            `mapstructure:"display_name"` // 登录页按钮上显示的名称
+	Issuer        string            `mapstructure:"issuer"`       // 通过 {issuer}/.well-known/openid-configuration 发现端点
+	ClientID      string            `mapstructure:"client_id"`
+	ClientSecret  string            `mapstructure:"client_secret"` // 公共客户端可留空（仅 PKCE）
+	RedirectURL   string            `mapstructure:"redirect_url"`  // 回调地址，为空时按请求推断 {Web UI}/-/api/auth/oidc/callback
+	Scopes        []string          `mapstructure:"scopes"`
+	UsernameClaim string            `mapstructure:"username_claim"` // 用作本地用户名的 claim
+	GroupsClaim   string            `mapstructure:"groups_claim"`   // 组列表 claim
+	RoleMapping   map[string]string `mapstructure:"role_mapping"`   // 组名 -> 角色（admin/developer/readonly）
+	DefaultRole   string            `mapstructure:"default_role"`   // 不属于任何映射组时的角色，为空则拒绝登录
+	AutoProvision bool              `mapstructure:"auto_provision"` // 首次登录时自动创建本地用户
+}
+
+// TwoFactorConfig 两步验证（TOTP）策略
+type TwoFactorConfig struct {
+	Issuer               string `mapstructure:"issuer"`                 // 认证器 App 中显示的名称
+	RequireForAdmins     bool   `mapstructure:"require_for_admins"`     // 管理员必须启用两步验证才能使用管理 API
+	RequireForPublishers bool   `mapstructure:"require_for_publishers"` // 发布/删除包必须启用两步验证并提供 OTP
+}
+
+// PasswordPolicyConfig 本地账号的密码策略与密码重置
+type PasswordPolicyConfig struct {
+	MinLength        int           `mapstructure:"min_length"`         // 最小长度
+	RequireUppercase bool          `mapstructure:"require_uppercase"`  // 需要大写字母
+	RequireLowercase bool          `mapstructure:"require_lowercase"`  // 需要小写字母
+	RequireDigit     bool          `mapstructure:"require_digit"`      // 需要数字
+	RequireSymbol    bool          `mapstructure:"require_symbol"`     // 需要特殊字符
+	CheckBreached    bool          `mapstructure:"check_breached"`     // 拒绝内置泄露密码列表中的密码
+	BreachedListFile string        `mapstructure:"breached_list_file"` // 额外的泄露密码列表文件，每行一个
+	ResetTTL         time.Duration `mapstructure:"reset_ttl"`          // 管理员签发的重置码有效期
+}
+
+// LockoutConfig 账号连续登录失败后的锁定策略，计数保存在数据库中，重启和多实例部署下同样生效
+type LockoutConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	MaxAttempts int           `mapstructure:"max_attempts"` // 连续失败多少次后锁定
+	Window      time.Duration `mapstructure:"window"`       // 超过该时间没有失败时重新计数
+	Duration    time.Duration `mapstructure:"duration"`     // 首次锁定时长，之后每次锁定翻倍
+	MaxDuration time.Duration `mapstructure:"max_duration"` // 锁定时长上限
+}
+
+// TrustedPublishingConfig CI 使用 OIDC ID token 换取短期发布凭证（trusted publishing），不需要在 CI 中保存 token
+type TrustedPublishingConfig struct {
+	Enabled  bool                  `mapstructure:"enabled"`
+	Audience string                `mapstructure:"audience"`  // ID token 必须包含的 aud，npm CLI 申请的是 npm:<registry host>
+	TokenTTL time.Duration         `mapstructure:"token_ttl"` // 发布凭证有效期
+	Issuers  []TrustedIssuerConfig `mapstructure:"issuers"`
+	Policies []TrustPolicyConfig   `mapstructure:"policies"`
+}
+
+// TrustedIssuerConfig 受信任的 CI 身份签发方
+type TrustedIssuerConfig struct {
+	Name    string `mapstructure:"name"`     // 策略中引用的名称，如 github
+	Issuer  string `mapstructure:"issuer"`   // iss claim，如 https://token.actions.githubusercontent.com
+	JWKSURL string `mapstructure:"jwks_url"` // 为空时通过 {issuer}/.well-known/openid-configuration 发现
+}
+
+// TrustPolicyConfig 信任策略：满足条件的 CI 身份可以以 User 的身份发布 Packages
+// 除 Issuer/Packages/User 外的条件都是可选的，但至少需要 Repository 或 Claims 之一
+type TrustPolicyConfig struct {
+	Issuer      string            `mapstructure:"issuer"`      // TrustedIssuerConfig.Name
+	Packages    []string          `mapstructure:"packages"`    // 包名或通配符规则（规则同包 owner）
+	User        string            `mapstructure:"user"`        // 发布使用的本地账号，需要有包的发布权限
+	Repository  string            `mapstructure:"repository"`  // repository claim，如 acme/ui
+	Workflow    string            `mapstructure:"workflow"`    // workflow 文件名（取自 workflow_ref），如 release.yml
+	Branch      string            `mapstructure:"branch"`      // 分支（取自 ref），支持通配符，如 release/*
+	Environment string            `mapstructure:"environment"` // environment claim
+	Claims      map[string]string `mapstructure:"claims"`      // 其他必须匹配的 claim，值支持通配符（用于其他 CI）
+}
+
+// SyncConfig 定时从其他 Grape 或 npm registry 同步包
+type SyncConfig struct {
+	Jobs []SyncJobConfig `mapstructure:"jobs"`
+}
+
+// SyncJobConfig 同步任务，Scope 和 Packages 至少需要一个
+type SyncJobConfig struct {
+	Name     string        `mapstructure:"name"`
+	From     string        `mapstructure:"from"`     // 源 registry 地址
+	Token    string        `mapstructure:"token"`    // 源 registry 的 token，可选
+	Scope    string        `mapstructure:"scope"`    // 同步该 scope 下的所有包，如 @acme
+	Packages []string      `mapstructure:"packages"` // 额外同步的包
+	Interval time.Duration `mapstructure:"interval"` // 同步间隔，为 0 时只能由管理员手动触发
+	Enabled  bool          `mapstructure:"enabled"`
+}
+
+// 复制模式
+const (
+	ReplicationPrimary = "primary"
+	ReplicationReplica = "replica"
+)
+
+// ReplicationConfig 主从复制配置：副本跟随主节点的 /-/_changes 复制公开包并在本地提供读取，
+// 写请求、登录和其他 API 转发或重定向到主节点
+type ReplicationConfig struct {
+	Mode      string `mapstructure:"mode"`       // primary（默认）| replica
+	Primary   string `mapstructure:"primary"`    // 副本模式下主节点的 npm API 地址
+	Token     string `mapstructure:"token"`      // 读取主节点变更和包的 token，主节点开启 require_read_auth 时需要
+	WriteMode string `mapstructure:"write_mode"` // 转发给主节点的请求：proxy（默认）| redirect
+}
+
+// IsReplica 是否以只读副本模式运行
+func (c ReplicationConfig) IsReplica() bool {
+	return c.Mode == ReplicationReplica
+}
+
+// Validate 检查复制配置
+func (c ReplicationConfig) Validate() error {
+	switch c.Mode {
+	case "", ReplicationPrimary:
+		return nil
+	case ReplicationReplica:
+	default:
+		return fmt.Errorf("invalid replication.mode %q, must be primary or replica", c.Mode)
+	}
+	u, err := url.Parse(c.Primary)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("replication.primary must be the http(s) URL of the primary registry API, got %q", c.Primary)
+	}
+	if c.WriteMode != "" && c.WriteMode != "proxy" && c.WriteMode != "redirect" {
+		return fmt.Errorf("invalid replication.write_mode %q, must be proxy or redirect", c.WriteMode)
+	}
+	return nil
 }
 
 type DatabaseConfig struct {
 	Type string `mapstructure:"type"` // sqlite | postgres
 	DSN  string `mapstructure:"dsn"`  // 数据库连接字符串
+
+	// 连接池，多个实例共享 PostgreSQL 时按数据库的 max_connections 分配
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`     // 最大连接数，0 表示不限制
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`     // 最大空闲连接数
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`  // 连接最长使用时间，0 表示不限制
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // 连接最长空闲时间，0 表示不限制
+
+	AutoMigrate bool `mapstructure:"auto_migrate"` // 启动时自动执行待执行的 SQL 迁移，关闭时需要先执行 grape migrate up
 }
 
+// Validate 检查数据库类型和连接池配置
+func (c DatabaseConfig) Validate() error {
+	switch c.Type {
+	case "sqlite", "postgres":
+	default:
+		return fmt.Errorf("database.type must be sqlite or postgres, got %q", c.Type)
+	}
+	if c.DSN == "" {
+		return fmt.Errorf("database.dsn is required")
+	}
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 || c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("database connection pool settings must not be negative")
+	}
+	return nil
+}
+
+// RedactedDSN 返回隐藏密码后的连接字符串，用于日志和系统信息
+func (c DatabaseConfig) RedactedDSN() string {
+	if u, err := url.Parse(c.DSN); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return u.String()
+		}
+		return c.DSN
+	}
+	return dsnPasswordPattern.ReplaceAllString(c.DSN, "${1}xxxxx")
+}
+
+// dsnPasswordPattern 匹配 key=value 形式连接字符串中的密码
+var dsnPasswordPattern = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
 type SecurityConfig struct {
 	AllowedOrigins []string `mapstructure:"allowed_origins"`
 	ContentPolicy  string   `mapstructure:"content_policy"`
@@ -101,10 +304,58 @@ func Default() *Config {
 			JWTSecret:         "grape-secret-key-change-in-production",
 			JWTExpiry:         24 * time.Hour,
 			AllowRegistration: false,
+			RequireReadAuth:   false,
+			Backend:           "db",
+			LDAP: LDAPConfig{
+				UserFilter:        "(uid=%s)",
+				UsernameAttribute: "uid",
+				EmailAttribute:    "mail",
+				NameAttribute:     "cn",
+				GroupFilter:       "(member=%s)",
+				GroupAttribute:    "cn",
+				DefaultRole:       "developer",
+				AutoProvision:     true,
+				Timeout:           10 * time.Second,
+			},
+			OIDC: OIDCConfig{
+				DisplayName:   "SSO",
+				Scopes:        []string{"openid", "profile", "email"},
+				UsernameClaim: "preferred_username",
+				GroupsClaim:   "groups",
+				DefaultRole:   "developer",
+				AutoProvision: true,
+			},
+			TwoFactor: TwoFactorConfig{
+				Issuer: "Grape",
+			},
+			TrustedPublishing: TrustedPublishingConfig{
+				TokenTTL: 15 * time.Minute,
+			},
+			PasswordPolicy: PasswordPolicyConfig{
+				MinLength:     8,
+				CheckBreached: true,
+				ResetTTL:      24 * time.Hour,
+			},
+			Lockout: LockoutConfig{
+				Enabled:     true,
+				MaxAttempts: 5,
+				Window:      15 * time.Minute,
+				Duration:    5 * time.Minute,
+				MaxDuration: 24 * time.Hour,
+			},
 		},
 		Database: DatabaseConfig{
-			Type: "sqlite",
-			DSN:  "./data/grape.db",
+			Type:            "sqlite",
+			DSN:             "./data/grape.db",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			AutoMigrate:     true,
+		},
+		Replication: ReplicationConfig{
+			Mode:      ReplicationPrimary,
+			WriteMode: "proxy",
 		},
 		Security: SecurityConfig{
 			AllowedOrigins: []string{}, // 空表示允许 4873 端口的任何地址
@@ -79,6 +79,9 @@ func Save(cfg *Config) error {
 	globalViper.Set("auth.jwt_secret", cfg.Auth.JWTSecret)
 	globalViper.Set("auth.jwt_expiry", cfg.Auth.JWTExpiry.String())
 	globalViper.Set("auth.allow_registration", cfg.Auth.AllowRegistration)
+	globalViper.Set("auth.require_read_auth", cfg.Auth.RequireReadAuth)
+	globalViper.Set("auth.two_factor.require_for_admins", cfg.Auth.TwoFactor.RequireForAdmins)
+	globalViper.Set("auth.two_factor.require_for_publishers", cfg.Auth.TwoFactor.RequireForPublishers)
 	globalViper.Set("log.level", cfg.Log.Level)
 
 	if globalCfgPath == "" {
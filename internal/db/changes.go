@@ -0,0 +1,68 @@
+package db
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 包变更类型
+const (
+	ChangePublish   = "publish"
+	ChangeUnpublish = "unpublish"
+	ChangeDistTag   = "dist-tag"
+	ChangeDeprecate = "deprecate"
+)
+
+var (
+	changesMu     sync.Mutex
+	changesNotify = make(chan struct{})
+)
+
+// RecordChange 同步写入一条包变更并唤醒等待中的 longpoll 请求。
+// 跟随者按 seq 增量读取，seq 必须按提交顺序分配：PostgreSQL 上多个实例并发写入时，
+// 较小的 seq 可能晚于较大的 seq 提交而被跟随者跳过，因此写入时对表加锁串行分配；
+// SQLite 的写事务本身是串行的
+func RecordChange(change *PackageChange) error {
+	if DB == nil {
+		return nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if Dialect(tx) == "postgres" {
+			// EXCLUSIVE 锁只阻塞写入，不影响 _changes 读取
+			if err := tx.Exec("LOCK TABLE package_changes IN EXCLUSIVE MODE").Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(change).Error
+	})
+	if err != nil {
+		return err
+	}
+	changesMu.Lock()
+	close(changesNotify)
+	changesNotify = make(chan struct{})
+	changesMu.Unlock()
+	return nil
+}
+
+// ChangesNotify 返回在下一条变更写入时关闭的 channel；只能感知本进程写入的变更
+func ChangesNotify() <-chan struct{} {
+	changesMu.Lock()
+	defer changesMu.Unlock()
+	return changesNotify
+}
+
+// LastChangeSeq 返回最新的变更序号，没有变更时为 0
+func LastChangeSeq() (uint64, error) {
+	var seq uint64
+	err := DB.Model(&PackageChange{}).Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error
+	return seq, err
+}
+
+// ListChanges 按序号升序返回 since 之后的最多 limit 条变更
+func ListChanges(since uint64, limit int) ([]PackageChange, error) {
+	var changes []PackageChange
+	err := DB.Where("seq > ?", since).Order("seq").Limit(limit).Find(&changes).Error
+	return changes, err
+}
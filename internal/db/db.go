@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -15,12 +17,17 @@ var DB *gorm.DB
 type Config struct {
 	Type string // sqlite | postgres
 	DSN  string // 数据库连接字符串
+
+	// 连接池设置，0 表示使用 database/sql 的默认值（不限制）
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
 // Init 初始化数据库连接
 func Init(cfg *Config) error {
-	var err error
-	var gormDB *gorm.DB
+	var dialector gorm.Dialector
 
 	switch cfg.Type {
 	case "sqlite":
@@ -31,25 +38,46 @@ func Init(cfg *Config) error {
 				return fmt.Errorf("failed to create database directory: %w", err)
 			}
 		}
-
-		gormDB, err = gorm.Open(sqlite.Open(cfg.DSN), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Silent),
-		})
+		dialector = sqlite.Open(cfg.DSN)
 	case "postgres":
-		// TODO: PostgreSQL 支持
-		return fmt.Errorf("postgres not implemented yet")
+		dialector = postgres.Open(cfg.DSN)
 	default:
 		return fmt.Errorf("unsupported database type: %s", cfg.Type)
 	}
 
+	gormDB, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect database: %w", err)
+	}
+
+	sqlDB, err := gormDB.DB()
 	if err != nil {
 		return fmt.Errorf("failed to connect database: %w", err)
 	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return fmt.Errorf("failed to connect database: %w", err)
+	}
 
 	DB = gormDB
 	return nil
 }
 
+// Dialect 返回数据库方言名称：sqlite 或 postgres
+func Dialect(db *gorm.DB) string {
+	return db.Dialector.Name()
+}
+
 // Migrate 自动迁移数据库表结构
 func Migrate(models ...interface{}) error {
 	return DB.AutoMigrate(models...)
@@ -0,0 +1,76 @@
+// Package dbtest 为测试准备数据库：默认使用临时目录中的 SQLite，
+// 设置 GRAPE_TEST_POSTGRES_DSN 时使用 PostgreSQL，每个测试使用独立的 schema。
+package dbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// PostgresDSNEnv 指定测试用 PostgreSQL 的环境变量
+const PostgresDSNEnv = "GRAPE_TEST_POSTGRES_DSN"
+
+// Open 初始化全局 db.DB 并迁移 models，测试结束时关闭连接（PostgreSQL 还会删除 schema）
+func Open(t testing.TB, models ...interface{}) {
+	t.Helper()
+	logger.Init("error")
+
+	cfg := &db.Config{Type: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")}
+	var schema string
+	if dsn := os.Getenv(PostgresDSNEnv); dsn != "" {
+		schema = createSchema(t, dsn)
+		cfg = &db.Config{Type: "postgres", DSN: withSearchPath(dsn, schema)}
+	}
+
+	if err := db.Init(cfg); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(models...); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+}
+
+// createSchema 创建随机命名的 schema，测试结束后删除
+func createSchema(t testing.TB, dsn string) string {
+	t.Helper()
+	b := make([]byte, 6)
+	rand.Read(b)
+	schema := "grape_test_" + hex.EncodeToString(b)
+
+	if err := db.Init(&db.Config{Type: "postgres", DSN: dsn}); err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	admin := db.DB
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return schema
+}
+
+// withSearchPath 在 URL 或 key=value 形式的连接字符串中设置 search_path
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
@@ -1,13 +1,18 @@
 package db
 
 import (
+	"crypto/sha256"
+	"database/sql"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/fs"
-	"path/filepath"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -19,64 +24,271 @@ var migrationsFS embed.FS
 type Migration struct {
 	Version     int
 	Description string
-	SQL         string
+	SQL         string // up
+	DownSQL     string
+	Checksum    string // up SQL 的 SHA256，记录在 schema_version 中用于发现被修改的迁移
+}
+
+// MigrationStep 迁移计划中的一步
+type MigrationStep struct {
+	Migration
+	Down bool
+}
+
+// SQLText 返回这一步要执行的 SQL
+func (s MigrationStep) SQLText() string {
+	if s.Down {
+		return s.DownSQL
+	}
+	return s.SQL
+}
+
+// MigrationStatus 单个迁移的状态
+type MigrationStatus struct {
+	Version     int        `json:"version"`
+	Description string     `json:"description"`
+	Applied     bool       `json:"applied"`
+	AppliedAt   *time.Time `json:"appliedAt,omitempty"`
+	Modified    bool       `json:"modified"` // 已执行的迁移文件内容被修改
+	Unknown     bool       `json:"unknown"`  // 数据库中记录了当前版本没有的迁移
+}
+
+// ErrSchemaAhead 数据库中已执行了当前版本没有的迁移（由更新的版本执行）
+var ErrSchemaAhead = errors.New("database schema is newer than this binary")
+
+// appliedMigration schema_version 中的一条记录
+type appliedMigration struct {
+	Version   int
+	AppliedAt *time.Time
+	Checksum  string
 }
 
 // RunMigrations runs all pending migrations
 func RunMigrations(db *gorm.DB) error {
-	// Ensure schema_version table exists
-	if err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS schema_version (
-			version INTEGER PRIMARY KEY,
-			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			description VARCHAR(255)
-		)
-	`).Error; err != nil {
-		return fmt.Errorf("failed to create schema_version table: %w", err)
+	steps, err := PlanMigrations(db, -1)
+	if err != nil {
+		return err
 	}
+	return ApplyMigrations(db, steps)
+}
 
-	// Get current version
-	var currentVersion int
-	row := db.Raw("SELECT COALESCE(MAX(version), 0) FROM schema_version").Row()
-	row.Scan(&currentVersion)
+// PlanMigrations 计算迁移到 target 版本需要执行的步骤，target 为 -1 表示最新版本。
+// 已执行迁移的校验和不一致或数据库版本比当前程序新时返回错误；只读取数据库，不做任何修改
+func PlanMigrations(db *gorm.DB, target int) ([]MigrationStep, error) {
+	migrations, applied, err := loadState(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyApplied(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if target < 0 {
+		target = latest
+	}
+	if target > latest {
+		return nil, fmt.Errorf("unknown migration version %d, latest is %d", target, latest)
+	}
+
+	var steps []MigrationStep
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok && m.Version <= target {
+			steps = append(steps, MigrationStep{Migration: m})
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; ok && m.Version > target {
+			steps = append(steps, MigrationStep{Migration: m, Down: true})
+		}
+	}
+	return steps, nil
+}
+
+// ApplyMigrations 按顺序执行迁移步骤，每一步在单独的事务中执行并更新 schema_version。
+// 执行前会创建 schema_version 表并为旧记录补写校验和，steps 为空时只做这一步
+func ApplyMigrations(db *gorm.DB, steps []MigrationStep) error {
+	if err := prepareSchemaVersion(db); err != nil {
+		return err
+	}
+	for _, step := range steps {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// 只有注释的迁移（如 001）只记录版本
+			if sqlText := step.SQLText(); hasStatements(sqlText) {
+				if err := tx.Exec(sqlText).Error; err != nil {
+					return err
+				}
+			}
+			if step.Down {
+				return tx.Exec("DELETE FROM schema_version WHERE version = ?", step.Version).Error
+			}
+			return tx.Exec(
+				"INSERT INTO schema_version (version, description, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				step.Version, step.Description, step.Checksum, time.Now().UTC(),
+			).Error
+		})
+		if err != nil {
+			direction := "up"
+			if step.Down {
+				direction = "down"
+			}
+			return fmt.Errorf("migration %d (%s) %s failed: %w", step.Version, step.Description, direction, err)
+		}
+	}
+	return nil
+}
 
-	// Load and sort migrations
-	migrations, err := loadMigrations()
+// MigrationStatuses 返回所有迁移（包括数据库中记录的未知迁移）的状态，按版本排序；只读取数据库
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, applied, err := loadState(db)
 	if err != nil {
-		return fmt.Errorf("failed to load migrations: %w", err)
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		status := MigrationStatus{Version: m.Version, Description: m.Description}
+		if a, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.AppliedAt
+			status.Modified = a.Checksum != "" && a.Checksum != m.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	for version, a := range applied {
+		if !known[version] {
+			statuses = append(statuses, MigrationStatus{Version: version, Applied: true, AppliedAt: a.AppliedAt, Unknown: true})
+		}
 	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
 
-	// Run pending migrations
+// verifyApplied 检查已执行的迁移：数据库中有未知版本时返回 ErrSchemaAhead，文件被修改时返回错误
+func verifyApplied(migrations []Migration, applied map[int]appliedMigration) error {
+	byVersion := make(map[int]Migration, len(migrations))
 	for _, m := range migrations {
-		if m.Version <= currentVersion {
+		byVersion[m.Version] = m
+	}
+
+	var unknown []int
+	for version, a := range applied {
+		m, ok := byVersion[version]
+		if !ok {
+			unknown = append(unknown, version)
 			continue
 		}
+		if a.Checksum != "" && a.Checksum != m.Checksum {
+			return fmt.Errorf("migration %d (%s) has been modified since it was applied: file checksum %s, recorded %s",
+				version, m.Description, m.Checksum, a.Checksum)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Ints(unknown)
+		return fmt.Errorf("%w: applied migrations %v are unknown, upgrade grape or roll back with the newer binary", ErrSchemaAhead, unknown)
+	}
+	return nil
+}
+
+// loadState 读取当前方言的迁移文件和 schema_version 中的记录，不修改数据库
+func loadState(db *gorm.DB) ([]Migration, map[int]appliedMigration, error) {
+	migrations, err := loadMigrations(Dialect(db))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	applied, err := loadApplied(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrations, applied, nil
+}
 
-		// Run migration in transaction
-		tx := db.Begin()
-		if err := tx.Exec(m.SQL).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("migration %d failed: %w", m.Version, err)
+// prepareSchemaVersion 创建 schema_version 表，旧版本创建的表补充 checksum 列，
+// 没有校验和的旧记录按当前迁移文件补写
+func prepareSchemaVersion(db *gorm.DB) error {
+	if err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			description VARCHAR(255),
+			checksum VARCHAR(64)
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	if !db.Migrator().HasColumn("schema_version", "checksum") {
+		if err := db.Exec("ALTER TABLE schema_version ADD COLUMN checksum VARCHAR(64)").Error; err != nil {
+			return fmt.Errorf("failed to add checksum to schema_version: %w", err)
 		}
+	}
 
-		// Record migration
-		if err := tx.Exec(
-			"INSERT INTO schema_version (version, description) VALUES (?, ?)",
-			m.Version, m.Description,
+	migrations, err := loadMigrations(Dialect(db))
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	for _, m := range migrations {
+		if err := db.Exec(
+			"UPDATE schema_version SET checksum = ? WHERE version = ? AND (checksum IS NULL OR checksum = '')",
+			m.Checksum, m.Version,
 		).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+			return fmt.Errorf("failed to record checksum of migration %d: %w", m.Version, err)
 		}
+	}
+	return nil
+}
 
-		tx.Commit()
+// loadApplied 读取已执行的迁移；表不存在时视为没有执行过迁移，没有校验和的旧记录不做校验
+func loadApplied(db *gorm.DB) (map[int]appliedMigration, error) {
+	applied := make(map[int]appliedMigration)
+	if !db.Migrator().HasTable("schema_version") {
+		return applied, nil
 	}
+	query := "SELECT version, applied_at, checksum FROM schema_version"
+	if !db.Migrator().HasColumn("schema_version", "checksum") {
+		query = "SELECT version, applied_at, NULL FROM schema_version"
+	}
+	rows, err := db.Raw(query).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_version: %w", err)
+	}
+	defer rows.Close()
 
-	return nil
+	for rows.Next() {
+		var a appliedMigration
+		var appliedAt sql.NullTime
+		var checksum sql.NullString
+		if err := rows.Scan(&a.Version, &appliedAt, &checksum); err != nil {
+			return nil, fmt.Errorf("failed to read schema_version: %w", err)
+		}
+		if appliedAt.Valid {
+			a.AppliedAt = &appliedAt.Time
+		}
+		a.Checksum = checksum.String
+		applied[a.Version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_version: %w", err)
+	}
+	return applied, nil
 }
 
-// loadMigrations loads all migration files
-func loadMigrations() ([]Migration, error) {
-	var migrations []Migration
+// loadMigrations loads all migration files for the given dialect.
+// 文件名格式为 NNN_name[.dialect].up.sql 和 NNN_name[.dialect].down.sql，
+// 带方言的文件覆盖同版本的通用文件，用于方言不兼容的 DDL
+func loadMigrations(dialect string) ([]Migration, error) {
+	type source struct {
+		description string
+		sql         string
+		specific    bool
+	}
+	ups := make(map[int]source)
+	downs := make(map[int]source)
 
 	entries, err := fs.ReadDir(migrationsFS, "migrations")
 	if err != nil {
@@ -84,38 +296,73 @@ func loadMigrations() ([]Migration, error) {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
 			continue
 		}
 
-		// Parse version from filename (e.g., 002_gc_metadata.sql -> 2)
-		name := entry.Name()
-		parts := strings.SplitN(name, "_", 2)
+		// Parse version from filename (e.g., 002_gc_metadata.up.sql -> 2)
+		parts := strings.SplitN(strings.TrimSuffix(name, ".sql"), "_", 2)
 		if len(parts) != 2 {
 			continue
 		}
-
 		version, err := strconv.Atoi(parts[0])
 		if err != nil {
 			continue
 		}
 
+		base, direction, ok := cutLast(parts[1])
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s: expected NNN_name.up.sql or NNN_name.down.sql", name)
+		}
+		fileDialect := ""
+		if b, d, ok := cutLast(base); ok {
+			base, fileDialect = b, d
+		}
+		if fileDialect != "" && fileDialect != dialect {
+			continue
+		}
+
+		target := ups
+		if direction == "down" {
+			target = downs
+		}
+		if existing, ok := target[version]; ok && existing.specific && fileDialect == "" {
+			continue
+		}
+
 		// Read migration content
-		content, err := migrationsFS.ReadFile(filepath.Join("migrations", name))
+		content, err := migrationsFS.ReadFile(path.Join("migrations", name))
 		if err != nil {
 			return nil, err
 		}
+		target[version] = source{
+			description: strings.ReplaceAll(base, "_", " "),
+			sql:         string(content),
+			specific:    fileDialect != "",
+		}
+	}
 
-		// Extract description from filename
-		description := strings.TrimSuffix(parts[1], ".sql")
-		description = strings.ReplaceAll(description, "_", " ")
-
+	migrations := make([]Migration, 0, len(ups))
+	for version, up := range ups {
+		down, ok := downs[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d (%s) has no down migration", version, up.description)
+		}
+		sum := sha256.Sum256([]byte(up.sql))
 		migrations = append(migrations, Migration{
 			Version:     version,
-			Description: description,
-			SQL:         string(content),
+			Description: up.description,
+			SQL:         up.sql,
+			DownSQL:     down.sql,
+			Checksum:    hex.EncodeToString(sum[:]),
 		})
 	}
+	for version := range downs {
+		if _, ok := ups[version]; !ok {
+			return nil, fmt.Errorf("migration %d has no up migration", version)
+		}
+	}
 
 	// Sort by version
 	sort.Slice(migrations, func(i, j int) bool {
@@ -125,10 +372,66 @@ func loadMigrations() ([]Migration, error) {
 	return migrations, nil
 }
 
+// cutLast 按最后一个 "." 拆分
+func cutLast(s string) (string, string, bool) {
+	idx := strings.LastIndex(s, ".")
+	if idx < 0 {
+		return s, "", false
+	}
+	return s[:idx], s[idx+1:], true
+}
+
+// hasStatements 判断迁移 SQL 中除注释和空行外是否还有语句
+func hasStatements(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
 // GetSchemaVersion returns the current schema version
 func GetSchemaVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable("schema_version") {
+		return 0, nil
+	}
 	var version int
 	row := db.Raw("SELECT COALESCE(MAX(version), 0) FROM schema_version").Row()
-	err := row.Scan(&version)
-	return version, err
+	if err := row.Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// DataMigration 记录已执行的数据迁移（需要读取存储文件、无法用 SQL 表达的迁移）
+type DataMigration struct {
+	Name      string    `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName 指定表名
+func (DataMigration) TableName() string {
+	return "data_migrations"
+}
+
+// RunDataMigration 只执行一次 fn，fn 成功后才记录该数据迁移
+func RunDataMigration(db *gorm.DB, name string, fn func() error) error {
+	if err := db.AutoMigrate(&DataMigration{}); err != nil {
+		return fmt.Errorf("failed to create data_migrations table: %w", err)
+	}
+
+	var count int64
+	if err := db.Model(&DataMigration{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check data migration %s: %w", name, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if err := fn(); err != nil {
+		return fmt.Errorf("data migration %s failed: %w", name, err)
+	}
+	return db.Create(&DataMigration{Name: name, AppliedAt: time.Now()}).Error
 }
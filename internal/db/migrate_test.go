@@ -0,0 +1,161 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+func TestRunMigrations(t *testing.T) {
+	dbtest.Open(t)
+
+	// 第二次运行没有待执行的迁移，也不能因重复记录版本而失败
+	for i := 0; i < 2; i++ {
+		if err := db.RunMigrations(db.DB); err != nil {
+			t.Fatalf("RunMigrations #%d failed: %v", i+1, err)
+		}
+	}
+	version, err := db.GetSchemaVersion(db.DB)
+	if err != nil || version != 3 {
+		t.Fatalf("Expected schema version 3, got %d, %v", version, err)
+	}
+
+	var count int64
+	db.DB.Raw("SELECT COUNT(*) FROM schema_version").Scan(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 recorded migrations, got %d", count)
+	}
+	if err := db.DB.Create(&db.PackageGCMetadata{PackageName: "foo"}).Error; err != nil {
+		t.Errorf("Failed to use migrated table: %v", err)
+	}
+
+	statuses, err := db.MigrationStatuses(db.DB)
+	if err != nil {
+		t.Fatalf("MigrationStatuses failed: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || s.Modified || s.Unknown || s.AppliedAt == nil {
+			t.Errorf("Unexpected status for migration %d: %+v", s.Version, s)
+		}
+	}
+}
+
+func TestMigrateDown(t *testing.T) {
+	dbtest.Open(t)
+	if err := db.RunMigrations(db.DB); err != nil {
+		t.Fatalf("RunMigrations failed: %v", err)
+	}
+
+	steps, err := db.PlanMigrations(db.DB, 1)
+	if err != nil {
+		t.Fatalf("PlanMigrations failed: %v", err)
+	}
+	if len(steps) != 2 || !steps[0].Down || steps[0].Version != 3 || steps[1].Version != 2 {
+		t.Fatalf("Expected down steps 3, 2, got %+v", steps)
+	}
+	if err := db.ApplyMigrations(db.DB, steps); err != nil {
+		t.Fatalf("ApplyMigrations failed: %v", err)
+	}
+
+	version, _ := db.GetSchemaVersion(db.DB)
+	if version != 1 {
+		t.Errorf("Expected schema version 1 after down, got %d", version)
+	}
+	for _, table := range []string{"package_gc_metadata", "orphaned_files", "package_deprecations"} {
+		if db.DB.Migrator().HasTable(table) {
+			t.Errorf("Expected table %s to be dropped", table)
+		}
+	}
+
+	// 再次升级到最新版本
+	if err := db.RunMigrations(db.DB); err != nil {
+		t.Fatalf("RunMigrations after down failed: %v", err)
+	}
+	if !db.DB.Migrator().HasTable("package_deprecations") {
+		t.Error("Expected package_deprecations to be recreated")
+	}
+
+	if _, err := db.PlanMigrations(db.DB, 42); err == nil {
+		t.Error("Expected error for unknown target version")
+	}
+}
+
+func TestMigrationVerification(t *testing.T) {
+	dbtest.Open(t)
+	if err := db.RunMigrations(db.DB); err != nil {
+		t.Fatalf("RunMigrations failed: %v", err)
+	}
+
+	db.DB.Exec("UPDATE schema_version SET checksum = ? WHERE version = 2", "0000")
+	if _, err := db.PlanMigrations(db.DB, -1); err == nil || errors.Is(err, db.ErrSchemaAhead) {
+		t.Errorf("Expected checksum mismatch error, got %v", err)
+	}
+	statuses, _ := db.MigrationStatuses(db.DB)
+	if len(statuses) != 3 || !statuses[1].Modified {
+		t.Errorf("Expected migration 2 to be reported as modified, got %+v", statuses)
+	}
+
+	// 旧版本没有校验和的记录不做校验，PlanMigrations 只读，由 ApplyMigrations 补写
+	db.DB.Exec("UPDATE schema_version SET checksum = NULL")
+	if _, err := db.PlanMigrations(db.DB, -1); err != nil {
+		t.Fatalf("Expected records without checksums to pass, got %v", err)
+	}
+	var empty int64
+	db.DB.Raw("SELECT COUNT(*) FROM schema_version WHERE checksum IS NULL OR checksum = ''").Scan(&empty)
+	if empty != 3 {
+		t.Errorf("PlanMigrations must not write checksums, %d of 3 missing", empty)
+	}
+	if err := db.ApplyMigrations(db.DB, nil); err != nil {
+		t.Fatalf("ApplyMigrations failed: %v", err)
+	}
+	db.DB.Raw("SELECT COUNT(*) FROM schema_version WHERE checksum IS NULL OR checksum = ''").Scan(&empty)
+	if empty != 0 {
+		t.Errorf("Expected all checksums to be backfilled, %d missing", empty)
+	}
+
+	db.DB.Exec("INSERT INTO schema_version (version, description) VALUES (99, 'future')")
+	if _, err := db.PlanMigrations(db.DB, -1); !errors.Is(err, db.ErrSchemaAhead) {
+		t.Errorf("Expected ErrSchemaAhead, got %v", err)
+	}
+	statuses, _ = db.MigrationStatuses(db.DB)
+	if last := statuses[len(statuses)-1]; last.Version != 99 || !last.Unknown {
+		t.Errorf("Expected migration 99 to be reported as unknown, got %+v", last)
+	}
+}
+
+func TestMigrationPlanIsReadOnly(t *testing.T) {
+	dbtest.Open(t)
+
+	// 全新数据库上查看状态和计划不能创建 schema_version
+	statuses, err := db.MigrationStatuses(db.DB)
+	if err != nil {
+		t.Fatalf("MigrationStatuses failed: %v", err)
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Errorf("Expected migration %d to be pending, got %+v", s.Version, s)
+		}
+	}
+	steps, err := db.PlanMigrations(db.DB, -1)
+	if err != nil || len(steps) != 3 {
+		t.Fatalf("Expected 3 pending steps, got %d, %v", len(steps), err)
+	}
+	if version, err := db.GetSchemaVersion(db.DB); err != nil || version != 0 {
+		t.Errorf("Expected schema version 0, got %d, %v", version, err)
+	}
+	if db.DB.Migrator().HasTable("schema_version") {
+		t.Error("Planning migrations created schema_version")
+	}
+
+	// 旧版本创建的没有 checksum 列的表也不能被修改
+	db.DB.Exec("CREATE TABLE schema_version (version INTEGER PRIMARY KEY, applied_at TIMESTAMP, description VARCHAR(255))")
+	db.DB.Exec("INSERT INTO schema_version (version, description) VALUES (1, 'init')")
+	if steps, err := db.PlanMigrations(db.DB, -1); err != nil || len(steps) != 2 {
+		t.Fatalf("Expected 2 pending steps, got %d, %v", len(steps), err)
+	}
+	if db.DB.Migrator().HasColumn("schema_version", "checksum") {
+		t.Error("Planning migrations added the checksum column")
+	}
+}
@@ -0,0 +1,3 @@
+-- Migration: Initial schema
+-- Version: 1
+-- Description: Nothing to roll back, the initial tables are managed by GORM AutoMigrate
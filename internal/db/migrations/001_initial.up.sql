@@ -13,5 +13,3 @@
 -- - tokens
 -- - audit_logs
 -- - webhooks
-
-INSERT INTO schema_version (version, description) VALUES (1, 'Initial schema (GORM AutoMigrate)');
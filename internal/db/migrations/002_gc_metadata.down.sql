@@ -0,0 +1,6 @@
+-- Migration: Add package_gc_metadata table
+-- Version: 2
+-- Description: Drop garbage collection metadata
+
+DROP TABLE IF EXISTS orphaned_files;
+DROP TABLE IF EXISTS package_gc_metadata;
@@ -0,0 +1,31 @@
+-- Migration: Add package_gc_metadata table
+-- Version: 2
+-- Description: Add metadata for garbage collection (PostgreSQL)
+
+-- Track package download/access for GC decisions
+CREATE TABLE IF NOT EXISTS package_gc_metadata (
+    id BIGSERIAL PRIMARY KEY,
+    package_name VARCHAR(255) NOT NULL UNIQUE,
+    version VARCHAR(50),
+    last_accessed_at TIMESTAMPTZ,  -- Last time package/version was accessed
+    access_count BIGINT DEFAULT 0,
+    marked_for_gc BOOLEAN DEFAULT FALSE,  -- Flagged for cleanup
+    marked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_gc_package ON package_gc_metadata(package_name);
+CREATE INDEX IF NOT EXISTS idx_gc_last_accessed ON package_gc_metadata(last_accessed_at);
+CREATE INDEX IF NOT EXISTS idx_gc_marked ON package_gc_metadata(marked_for_gc);
+
+-- Track orphaned files (files not referenced by any metadata)
+CREATE TABLE IF NOT EXISTS orphaned_files (
+    id BIGSERIAL PRIMARY KEY,
+    file_path VARCHAR(500) NOT NULL,
+    file_size BIGINT,
+    discovered_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    cleaned_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_orphaned_cleaned ON orphaned_files(cleaned_at);
@@ -29,5 +29,3 @@ CREATE TABLE IF NOT EXISTS orphaned_files (
 );
 
 CREATE INDEX IF NOT EXISTS idx_orphaned_cleaned ON orphaned_files(cleaned_at);
-
-INSERT INTO schema_version (version, description) VALUES (2, 'Add GC metadata tables');
@@ -0,0 +1,5 @@
+-- Migration: Add package_deprecation table
+-- Version: 3
+-- Description: Drop package deprecation records
+
+DROP TABLE IF EXISTS package_deprecations;
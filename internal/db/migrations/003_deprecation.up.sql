@@ -14,5 +14,3 @@ CREATE TABLE IF NOT EXISTS package_deprecations (
 );
 
 CREATE INDEX IF NOT EXISTS idx_deprecations_package ON package_deprecations(package_name);
-
-INSERT INTO schema_version (version, description) VALUES (3, 'Add package deprecation support');
@@ -8,13 +8,18 @@ import (
 
 // User 用户模型
 type User struct {
-	ID        uint       `gorm:"primaryKey" json:"id"`
-	Username  string     `gorm:"uniqueIndex;size:100;not null" json:"username"`
-	Email     string     `gorm:"size:255" json:"email"`
-	Password  string     `gorm:"size:255;not null" json:"-"`
-	Role      string     `gorm:"size:50;default:developer" json:"role"`
-	CreatedAt time.Time  `json:"createdAt"`
-	LastLogin *time.Time `json:"lastLogin,omitempty"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Username   string     `gorm:"uniqueIndex;size:100;not null" json:"username"`
+	Email      string     `gorm:"size:255" json:"email"`
+	FullName   string     `gorm:"size:255" json:"fullname"`
+	Password   string     `gorm:"size:255;not null" json:"-"`
+	Role       string     `gorm:"size:50;default:developer" json:"role"`
+	AuthSource string     `gorm:"size:20;default:local" json:"authSource"` // local | ldap
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	LastLogin  *time.Time `json:"lastLogin,omitempty"`
+
+	MustChangePassword bool `gorm:"default:false" json:"mustChangePassword"` // 下次登录必须修改密码
 }
 
 // TableName 指定表名
@@ -62,6 +67,7 @@ type PackageVersion struct {
 	Tarball     string    `gorm:"size:500" json:"tarball"` // tarball 文件名
 	Shasum      string    `gorm:"size:100" json:"shasum"`
 	Publisher   string    `gorm:"size:100" json:"publisher"` // 发布者
+	Size        int64     `gorm:"default:0" json:"size"`     // tarball 大小，未缓存时为 0
 	CreatedAt   time.Time `json:"createdAt"`
 }
 
@@ -73,7 +79,7 @@ func (PackageVersion) TableName() string {
 // AuditLog 审计日志
 type AuditLog struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	Action    string    `gorm:"size:50;index" json:"action"`  // login/publish/unpublish/user_create/user_delete/config_update
+	Action    string    `gorm:"size:50;index" json:"action"` // login/publish/unpublish/user_create/user_delete/config_update
 	Username  string    `gorm:"size:100;index" json:"username"`
 	IP        string    `gorm:"size:50" json:"ip"`
 	Detail    string    `gorm:"size:500" json:"detail"`
@@ -90,7 +96,7 @@ type Webhook struct {
 	ID             uint       `gorm:"primaryKey" json:"id"`
 	Name           string     `gorm:"size:100;not null" json:"name"`
 	URL            string     `gorm:"size:500;not null" json:"url"`
-	Secret         string     `gorm:"size:255" json:"-"` // HMAC 密钥，不对外暴露
+	Secret         string     `gorm:"size:255" json:"-"`      // HMAC 密钥，不对外暴露
 	Events         string     `gorm:"size:500" json:"events"` // 逗号分隔的事件类型，空表示订阅所有
 	Enabled        bool       `gorm:"default:true" json:"enabled"`
 	CreatedAt      time.Time  `json:"createdAt"`
@@ -104,15 +110,22 @@ func (Webhook) TableName() string {
 
 // Token CI/CD 持久化 Token（用于自动化发布）
 type Token struct {
-	ID        uint       `gorm:"primaryKey" json:"id"`
-	UserID    uint       `gorm:"index;not null" json:"userId"`
-	User      *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Name      string     `gorm:"size:100;not null" json:"name"`              // 描述，如 "github-ci"
-	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`      // sha256 哈希存储
-	Readonly  bool       `gorm:"default:false" json:"readonly"`              // 只读 token 不能发布
-	ExpiresAt *time.Time `json:"expiresAt,omitempty"`                        // nil = 永不过期
-	LastUsed  *time.Time `json:"lastUsed,omitempty"`
-	CreatedAt time.Time  `json:"createdAt"`
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"index;not null" json:"userId"`
+	User            *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Name            string     `gorm:"size:100;not null" json:"name"`         // 描述，如 "github-ci"
+	TokenHash       string     `gorm:"size:64;uniqueIndex;not null" json:"-"` // sha256 哈希存储
+	TokenPrefix     string     `gorm:"size:16" json:"tokenPrefix"`            // token 明文前缀，用于列表中辨认
+	Readonly        bool       `gorm:"default:false" json:"readonly"`         // 只读 token 不能发布
+	CIDRWhitelist   string     `gorm:"size:500" json:"cidrWhitelist"`         // 逗号分隔的 CIDR，空表示不限制
+	Granular        bool       `gorm:"default:false" json:"granular"`         // 细粒度 token：限定包范围和可执行的操作
+	Packages        string     `gorm:"size:1000" json:"packages"`             // 逗号分隔的包名或通配符规则，空表示所有包
+	Permissions     string     `gorm:"size:200" json:"permissions"`           // 逗号分隔的操作：read,publish,unpublish,deprecate,dist-tag,owner
+	BypassTwoFactor bool       `gorm:"default:false" json:"bypassTwoFactor"`  // 写操作不需要 OTP
+	ExpiresAt       *time.Time `json:"expiresAt,omitempty"`                   // nil = 永不过期
+	LastUsed        *time.Time `json:"lastUsed,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
 }
 
 // TableName 指定表名
@@ -134,3 +147,179 @@ type PackageOwner struct {
 func (PackageOwner) TableName() string {
 	return "package_owners"
 }
+
+// WebLoginSession npm web 登录会话（npm login --auth-type=web）
+type WebLoginSession struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	SessionID   string     `gorm:"size:64;uniqueIndex;not null" json:"sessionId"` // 随机会话 ID，出现在 loginUrl/doneUrl 中
+	Hostname    string     `gorm:"size:255" json:"hostname"`                      // CLI 上报的主机名
+	IP          string     `gorm:"size:50" json:"ip"`                             // 发起登录的 CLI IP
+	Username    string     `gorm:"size:100" json:"username,omitempty"`            // 确认登录的用户，CLI 轮询时以该用户签发 token
+	ConfirmedAt *time.Time `json:"confirmedAt,omitempty"`
+	ExpiresAt   time.Time  `gorm:"index" json:"expiresAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// TableName 指定表名
+func (WebLoginSession) TableName() string {
+	return "web_login_sessions"
+}
+
+// UserTwoFactor 用户的两步验证（TOTP）设置
+type UserTwoFactor struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"uniqueIndex;not null" json:"userId"`
+	Secret        string    `gorm:"size:64;not null" json:"-"`            // base32 TOTP 密钥
+	Mode          string    `gorm:"size:20;not null" json:"mode"`         // auth-only | auth-and-writes
+	Pending       bool      `gorm:"not null;default:true" json:"pending"` // 已生成密钥但尚未用 OTP 确认
+	RecoveryCodes string    `gorm:"type:text" json:"-"`                   // 未使用的恢复码 SHA256，JSON 数组
+	LastStep      int64     `gorm:"not null;default:0" json:"-"`          // 最近一次使用的 TOTP 时间片，防止重放
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (UserTwoFactor) TableName() string {
+	return "user_two_factors"
+}
+
+// UserSession 已签发的 JWT 登录会话，按 jti 记录，撤销后 JWT 立即失效
+type UserSession struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	JTI        string     `gorm:"column:jti;size:64;uniqueIndex;not null" json:"-"` // JWT ID
+	UserID     uint       `gorm:"index;not null" json:"userId"`
+	Username   string     `gorm:"size:100;not null" json:"username"`
+	Method     string     `gorm:"size:20" json:"method"` // password | oidc | web-login
+	IP         string     `gorm:"size:50" json:"ip"`
+	UserAgent  string     `gorm:"size:255" json:"userAgent"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// TableName 指定表名
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
+
+// PasswordReset 管理员签发的一次性密码重置码
+type PasswordReset struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"userId"`
+	CodeHash  string     `gorm:"uniqueIndex;size:64;not null" json:"-"` // 重置码的 SHA256
+	CreatedBy string     `gorm:"size:100" json:"createdBy"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TableName 指定表名
+func (PasswordReset) TableName() string {
+	return "password_resets"
+}
+
+// AccountLockout 账号的连续登录失败计数与锁定状态
+type AccountLockout struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"uniqueIndex;not null" json:"userId"`
+	FailedCount  int        `gorm:"default:0" json:"failedCount"` // 当前窗口内的连续失败次数
+	LastFailedAt *time.Time `json:"lastFailedAt,omitempty"`
+	LockCount    int        `gorm:"default:0" json:"lockCount"` // 已连续锁定的次数，决定下次锁定时长
+	LockedUntil  *time.Time `json:"lockedUntil,omitempty"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (AccountLockout) TableName() string {
+	return "account_lockouts"
+}
+
+// OIDCLoginState Web UI OIDC 登录进行中的状态（授权码 + PKCE）
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	State        string    `gorm:"size:64;uniqueIndex;not null" json:"-"` // 回调时校验的 state 参数
+	Nonce        string    `gorm:"size:64;not null" json:"-"`             // 写入 id_token 的 nonce
+	CodeVerifier string    `gorm:"size:128;not null" json:"-"`            // PKCE code_verifier
+	Redirect     string    `gorm:"size:500" json:"redirect"`              // 登录完成后返回的前端路径
+	ExpiresAt    time.Time `gorm:"index" json:"expiresAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TableName 指定表名
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+// PackageAccess 包或 scope 的读取访问级别
+type PackageAccess struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PackageName string    `gorm:"uniqueIndex;size:255;not null" json:"packageName"` // 包名，或 @scope/* 表示整个 scope
+	Access      string    `gorm:"size:20;not null;default:public" json:"access"`    // public | restricted
+	UpdatedBy   string    `gorm:"size:100" json:"updatedBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (PackageAccess) TableName() string {
+	return "package_access"
+}
+
+// PackageGrant 用户对包的授权（npm access grant）
+type PackageGrant struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PackageName string    `gorm:"uniqueIndex:idx_pkg_grant;size:255;not null" json:"packageName"`
+	UserID      uint      `gorm:"uniqueIndex:idx_pkg_grant;not null" json:"userId"`
+	User        *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Permission  string    `gorm:"size:20;not null;default:read-only" json:"permission"` // read-only | read-write
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// TableName 指定表名
+func (PackageGrant) TableName() string {
+	return "package_grants"
+}
+
+// SyncState 从其他 registry 同步的包的进度，用于增量同步
+type SyncState struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Source      string    `gorm:"uniqueIndex:idx_sync_pkg;size:255;not null" json:"source"` // 源 registry 地址
+	PackageName string    `gorm:"uniqueIndex:idx_sync_pkg;size:255;not null" json:"packageName"`
+	Modified    string    `gorm:"size:50" json:"modified"` // 上次同步完成时源 metadata 的 time.modified
+	Versions    int       `json:"versions"`
+	SyncedAt    time.Time `json:"syncedAt"`
+}
+
+// TableName 指定表名
+func (SyncState) TableName() string {
+	return "sync_states"
+}
+
+// PackageChange 包变更日志，Seq 单调递增，供 /-/_changes 增量跟随
+type PackageChange struct {
+	Seq         uint64    `gorm:"primaryKey;autoIncrement" json:"seq"`
+	PackageName string    `gorm:"index;size:255;not null" json:"id"`
+	Type        string    `gorm:"size:20;not null" json:"type"` // publish | unpublish | dist-tag | deprecate
+	Version     string    `gorm:"size:50" json:"version,omitempty"`
+	Tag         string    `gorm:"size:100" json:"tag,omitempty"` // dist-tag 变更的 tag
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// TableName 指定表名
+func (PackageChange) TableName() string {
+	return "package_changes"
+}
+
+// ReplicationState 只读副本跟随主节点变更流的进度
+type ReplicationState struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Source    string    `gorm:"uniqueIndex;size:255;not null" json:"source"` // 主节点地址
+	Seq       uint64    `json:"seq"`                                         // 已应用的主节点变更序号
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (ReplicationState) TableName() string {
+	return "replication_states"
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"time"
+)
+
+// Organization 组织，拥有同名 scope（如组织 acme 拥有 @acme）
+type Organization struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:100;not null" json:"name"` // 不含 @ 前缀
+	Description string    `gorm:"size:500" json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrgMember 组织成员
+type OrgMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	OrgID     uint      `gorm:"uniqueIndex:idx_org_member;not null" json:"orgId"`
+	UserID    uint      `gorm:"uniqueIndex:idx_org_member;not null" json:"userId"`
+	User      *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Role      string    `gorm:"size:20;not null;default:developer" json:"role"` // owner | admin | developer
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (OrgMember) TableName() string {
+	return "org_members"
+}
+
+// Team 组织下的团队
+type Team struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OrgID       uint      `gorm:"uniqueIndex:idx_org_team;not null" json:"orgId"`
+	Name        string    `gorm:"uniqueIndex:idx_org_team;size:100;not null" json:"name"`
+	Description string    `gorm:"size:500" json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (Team) TableName() string {
+	return "teams"
+}
+
+// TeamMember 团队成员
+type TeamMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TeamID    uint      `gorm:"uniqueIndex:idx_team_member;not null" json:"teamId"`
+	UserID    uint      `gorm:"uniqueIndex:idx_team_member;not null" json:"userId"`
+	User      *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (TeamMember) TableName() string {
+	return "team_members"
+}
+
+// TeamGrant 团队对包或 scope 的授权
+type TeamGrant struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TeamID      uint      `gorm:"uniqueIndex:idx_team_grant;not null" json:"teamId"`
+	PackageName string    `gorm:"uniqueIndex:idx_team_grant;size:255;not null" json:"packageName"` // 包名，或 @scope/* 表示整个 scope
+	Permission  string    `gorm:"size:20;not null;default:read-only" json:"permission"`            // read-only | read-write
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (TeamGrant) TableName() string {
+	return "team_grants"
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IsPrivatePackage 判断 metadata 是否属于私有包：代理缓存和导入的缓存包带有 _upstream 字段
+func IsPrivatePackage(meta map[string]interface{}) bool {
+	_, cached := meta["_upstream"]
+	return !cached
+}
+
+// IndexPackage 按 metadata 更新 packages 和 package_versions，metadata 中已不存在的版本会被删除。
+// tarballSize 返回 tarball 文件的大小，未缓存的 tarball 返回 0
+func IndexPackage(name string, meta map[string]interface{}, tarballSize func(filename string) int64) error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		record := Package{Name: name, Private: IsPrivatePackage(meta)}
+		record.Description, _ = meta["description"].(string)
+		if len(record.Description) > 500 {
+			record.Description = strings.ToValidUTF8(record.Description[:500], "")
+		}
+		if tags, ok := meta["dist-tags"].(map[string]interface{}); ok {
+			record.Latest, _ = tags["latest"].(string)
+		}
+		times, _ := meta["time"].(map[string]interface{})
+		record.CreatedAt = parseMetaTime(times, "created")
+		record.UpdatedAt = parseMetaTime(times, "modified")
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description", "private", "latest", "updated_at"}),
+		}).Create(&record).Error; err != nil {
+			return err
+		}
+
+		versions, _ := meta["versions"].(map[string]interface{})
+		names := make([]string, 0, len(versions))
+		for version := range versions {
+			names = append(names, version)
+		}
+		sort.Strings(names)
+		for _, version := range names {
+			v, _ := versions[version].(map[string]interface{})
+			row := PackageVersion{PackageName: name, Version: version, CreatedAt: parseMetaTime(times, version)}
+			if dist, ok := v["dist"].(map[string]interface{}); ok {
+				if tarball, _ := dist["tarball"].(string); tarball != "" {
+					row.Tarball = path.Base(tarball)
+				}
+				row.Shasum, _ = dist["shasum"].(string)
+			}
+			if npmUser, ok := v["_npmUser"].(map[string]interface{}); ok {
+				row.Publisher, _ = npmUser["name"].(string)
+			}
+			if row.Tarball != "" && tarballSize != nil {
+				row.Size = tarballSize(row.Tarball)
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "package_name"}, {Name: "version"}},
+				DoUpdates: clause.AssignmentColumns([]string{"tarball", "shasum", "publisher", "size"}),
+			}).Create(&row).Error; err != nil {
+				return err
+			}
+		}
+
+		stale := tx.Where("package_name = ?", name)
+		if len(names) > 0 {
+			stale = stale.Where("version NOT IN ?", names)
+		}
+		return stale.Delete(&PackageVersion{}).Error
+	})
+}
+
+// parseMetaTime 解析 metadata time 字段中的时间，无法解析时返回零值（由 GORM 填充当前时间）
+func parseMetaTime(times map[string]interface{}, key string) time.Time {
+	if s, ok := times[key].(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// SetTarballSize 更新 tarball 的大小，用于上游 tarball 被缓存或删除之后
+func SetTarballSize(name, filename string, size int64) error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Model(&PackageVersion{}).
+		Where("package_name = ? AND tarball = ?", name, filename).
+		Update("size", size).Error
+}
+
+// DeletePackageIndex 删除包及其所有版本的索引记录
+func DeletePackageIndex(name string) error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("package_name = ?", name).Delete(&PackageVersion{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("name = ?", name).Delete(&Package{}).Error
+	})
+}
+
+// PackageSize 返回包已缓存的 tarball 总大小
+func PackageSize(name string) int64 {
+	var size int64
+	DB.Model(&PackageVersion{}).Where("package_name = ?", name).Select("COALESCE(SUM(size), 0)").Scan(&size)
+	return size
+}
+
+// PackageIndexStats 包索引统计
+type PackageIndexStats struct {
+	TotalPackages int64 `json:"totalPackages"`
+	PrivateCount  int64 `json:"privatePackages"`
+	CachedCount   int64 `json:"cachedPackages"`
+	TotalVersions int64 `json:"totalVersions"`
+	TotalSize     int64 `json:"totalSize"` // tarball 总大小（字节）
+}
+
+// GetPackageIndexStats 从 packages 和 package_versions 汇总统计信息
+func GetPackageIndexStats() (*PackageIndexStats, error) {
+	stats := &PackageIndexStats{}
+	if err := DB.Model(&Package{}).Count(&stats.TotalPackages).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&Package{}).Where("private = ?", true).Count(&stats.PrivateCount).Error; err != nil {
+		return nil, err
+	}
+	stats.CachedCount = stats.TotalPackages - stats.PrivateCount
+	if err := DB.Model(&PackageVersion{}).Count(&stats.TotalVersions).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Model(&PackageVersion{}).Select("COALESCE(SUM(size), 0)").Scan(&stats.TotalSize).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// SearchPackageIndex 按名称或描述搜索包（不区分大小写），query 为空时返回全部，按名称排序
+func SearchPackageIndex(query string) ([]Package, error) {
+	var packages []Package
+	q := DB.Order("name")
+	if query = strings.ToLower(strings.TrimSpace(query)); query != "" {
+		pattern := "%" + escapeLike(query) + "%"
+		q = q.Where("LOWER(name) LIKE ? ESCAPE '\\' OR LOWER(description) LIKE ? ESCAPE '\\'", pattern, pattern)
+	}
+	if err := q.Find(&packages).Error; err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
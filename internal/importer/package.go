@@ -0,0 +1,46 @@
+package importer
+
+import (
+	"path"
+	"sort"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/storage"
+)
+
+// recordPackage 按 metadata 更新包索引，已存在的记录会被更新
+func recordPackage(store storage.Storage, name string, meta map[string]interface{}) error {
+	return db.IndexPackage(name, meta, func(filename string) int64 {
+		return store.TarballSize(name, filename)
+	})
+}
+
+// recordPublishes 为导入的版本写入 publish 变更，供 /-/_changes 的跟随者感知
+func recordPublishes(name string, versions []string) error {
+	for _, version := range versions {
+		if err := db.RecordChange(&db.PackageChange{PackageName: name, Type: db.ChangePublish, Version: version}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// versionDist 返回版本的 tarball 文件名和 shasum
+func versionDist(version map[string]interface{}) (string, string) {
+	dist, _ := version["dist"].(map[string]interface{})
+	tarball, _ := dist["tarball"].(string)
+	shasum, _ := dist["shasum"].(string)
+	if tarball == "" {
+		return "", shasum
+	}
+	return path.Base(tarball), shasum
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
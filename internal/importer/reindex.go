@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/storage"
+)
+
+// ReindexResult 重建包索引的结果
+type ReindexResult struct {
+	Packages int      `json:"packages"` // 写入索引的包数量
+	Versions int      `json:"versions"` // 写入索引的版本数量
+	Removed  int      `json:"removed"`  // 磁盘上已不存在而被删除的包记录
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Reindex 按本地存储中的 metadata 重建 packages 和 package_versions，
+// 用于升级、恢复备份或手动修改存储目录之后
+func Reindex(store storage.Storage) (*ReindexResult, error) {
+	names, err := store.ListPackageNames()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReindexResult{}
+	onDisk := make(map[string]bool, len(names))
+	for _, name := range names {
+		onDisk[name] = true
+		data, err := store.GetMetadata(name)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		var meta map[string]interface{}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: invalid metadata: %v", name, err))
+			continue
+		}
+		if err := recordPackage(store, name, meta); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		versions, _ := meta["versions"].(map[string]interface{})
+		result.Packages++
+		result.Versions += len(versions)
+	}
+
+	var indexed []string
+	if err := db.DB.Model(&db.Package{}).Pluck("name", &indexed).Error; err != nil {
+		return nil, err
+	}
+	for _, name := range indexed {
+		if onDisk[name] {
+			continue
+		}
+		if err := db.DeletePackageIndex(name); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		result.Removed++
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package importer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+func saveTestPackage(t *testing.T, store *local.Storage, name string, meta map[string]interface{}) {
+	t.Helper()
+	data, _ := json.Marshal(meta)
+	if err := store.SaveMetadata(name, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	setupTestDB(t)
+	store := local.New(t.TempDir())
+
+	saveTestPackage(t, store, "@acme/ui", map[string]interface{}{
+		"name":        "@acme/ui",
+		"description": "UI 100% components",
+		"dist-tags":   map[string]string{"latest": "1.1.0"},
+		"time":        map[string]string{"modified": "2024-01-15T08:00:00Z", "1.0.0": "2024-01-10T08:00:00Z"},
+		"versions": map[string]interface{}{
+			"1.0.0": map[string]interface{}{"_npmUser": map[string]string{"name": "alice"}, "dist": map[string]string{"tarball": "http://x/@acme/ui/-/ui-1.0.0.tgz", "shasum": "a"}},
+			"1.1.0": map[string]interface{}{"dist": map[string]string{"tarball": "http://x/@acme/ui/-/ui-1.1.0.tgz"}},
+		},
+	})
+	store.SaveTarball("@acme/ui", "ui-1.0.0.tgz", []byte("12345"))
+	saveTestPackage(t, store, "lodash", map[string]interface{}{
+		"name":      "lodash",
+		"_upstream": "npmjs",
+		"dist-tags": map[string]string{"latest": "4.17.21"},
+		"versions":  map[string]interface{}{"4.17.21": map[string]interface{}{}},
+	})
+	// 磁盘上已不存在的包和版本会被删除
+	db.DB.Create(&db.Package{Name: "gone"})
+	db.DB.Create(&db.PackageVersion{PackageName: "@acme/ui", Version: "0.9.0"})
+
+	result, err := Reindex(store)
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if result.Packages != 2 || result.Versions != 3 || result.Removed != 1 || len(result.Errors) != 0 {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+
+	stats, err := db.GetPackageIndexStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalPackages != 2 || stats.PrivateCount != 1 || stats.CachedCount != 1 || stats.TotalVersions != 3 || stats.TotalSize != 5 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	var version db.PackageVersion
+	db.DB.Where("package_name = ? AND version = ?", "@acme/ui", "1.0.0").First(&version)
+	if version.Tarball != "ui-1.0.0.tgz" || version.Publisher != "alice" || version.Size != 5 || version.CreatedAt.Year() != 2024 {
+		t.Errorf("Unexpected version record: %+v", version)
+	}
+
+	// LIKE 通配符按字面匹配
+	for query, want := range map[string]int{"ui": 1, "100%": 1, "%": 1, "_": 0, "LODASH": 1, "": 2} {
+		packages, err := db.SearchPackageIndex(query)
+		if err != nil || len(packages) != want {
+			t.Errorf("SearchPackageIndex(%q) = %d packages, %v; want %d", query, len(packages), err, want)
+		}
+	}
+}
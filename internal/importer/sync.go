@@ -0,0 +1,469 @@
+package importer
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/storage"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxSyncMetadataSize = 50 * 1024 * 1024  // 与代理上游的限制一致
+	maxSyncTarballSize  = 500 * 1024 * 1024 // 与代理上游的限制一致
+	syncSearchPageSize  = 250               // npm search 单页上限
+)
+
+var (
+	ErrSyncNoPackages     = errors.New("scope or packages required")
+	ErrIntegrityMismatch  = errors.New("tarball integrity check failed")
+	ErrVersionConflict    = errors.New("local tarball differs from source, keeping local version")
+	ErrSearchNotSupported = errors.New("source registry does not support /-/v1/search, list packages explicitly")
+)
+
+// SyncOptions 同步选项
+type SyncOptions struct {
+	From     string   // 源 registry 地址
+	Token    string   // 源 registry 的 token，可选
+	Scope    string   // 同步该 scope 下的所有包，如 @acme
+	Packages []string // 额外同步的包
+	Full     bool     // 忽略上次同步记录，逐个版本检查
+	DryRun   bool     // 只检查需要下载的版本，不写入
+	Mirror   bool     // 本地 metadata 与源保持一致，去掉源中已不存在的版本和 dist-tag（只读副本使用）
+	Client   *http.Client
+
+	// Progress 每个包处理完后调用
+	Progress func(SyncProgress)
+}
+
+// SyncProgress 同步进度
+type SyncProgress struct {
+	Index   int               `json:"index"` // 从 1 开始
+	Total   int               `json:"total"`
+	Package SyncPackageResult `json:"package"`
+}
+
+// SyncPackageResult 单个包的同步结果
+type SyncPackageResult struct {
+	Name       string   `json:"name"`
+	Unchanged  bool     `json:"unchanged,omitempty"` // 源 metadata 自上次同步后没有变化
+	Downloaded []string `json:"downloaded,omitempty"`
+	UpToDate   int      `json:"upToDate"` // 本地已有的版本数
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// SyncResult 同步结果
+type SyncResult struct {
+	Source     string              `json:"source"`
+	Packages   []SyncPackageResult `json:"packages"`
+	Downloaded int                 `json:"downloaded"` // 下载的版本总数
+	Failed     int                 `json:"failed"`     // 有错误的包数量
+	StartedAt  time.Time           `json:"startedAt"`
+	FinishedAt time.Time           `json:"finishedAt"`
+}
+
+// Syncer 从其他 Grape 或 npm registry 复制包到本地存储，作为私有包保存
+//
+// 同步是增量的：源 metadata 的 time.modified 与上次同步完成时相同的包直接跳过，
+// 本地已有 tarball 的版本不会重复下载。tarball 先于 metadata 写入，
+// 中断后再次运行会从未完成的版本继续。
+type Syncer struct {
+	store storage.Storage
+	authz *auth.PackageAuthorizer
+	opts  SyncOptions
+	from  *url.URL
+}
+
+// NewSyncer 创建 Syncer
+func NewSyncer(store storage.Storage, authz *auth.PackageAuthorizer, opts SyncOptions) (*Syncer, error) {
+	from, err := url.Parse(strings.TrimSuffix(opts.From, "/"))
+	if err != nil || (from.Scheme != "http" && from.Scheme != "https") || from.Host == "" {
+		return nil, fmt.Errorf("invalid source registry URL: %q", opts.From)
+	}
+	if opts.Scope != "" && !strings.HasPrefix(opts.Scope, "@") {
+		opts.Scope = "@" + opts.Scope
+	}
+	if opts.Scope == "" && len(opts.Packages) == 0 {
+		return nil, ErrSyncNoPackages
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Minute}
+	}
+	return &Syncer{store: store, authz: authz, opts: opts, from: from}, nil
+}
+
+// Run 执行一次同步，单个包的错误记录在结果中，不会中断其他包
+func (s *Syncer) Run(ctx context.Context) (*SyncResult, error) {
+	result := &SyncResult{Source: s.from.String(), Packages: []SyncPackageResult{}, StartedAt: time.Now()}
+
+	names, err := s.listPackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		pkg := s.syncPackage(ctx, name)
+		result.Packages = append(result.Packages, pkg)
+		result.Downloaded += len(pkg.Downloaded)
+		if len(pkg.Errors) > 0 {
+			result.Failed++
+		}
+		if s.opts.Progress != nil {
+			s.opts.Progress(SyncProgress{Index: i + 1, Total: len(names), Package: pkg})
+		}
+	}
+	result.FinishedAt = time.Now()
+	return result, nil
+}
+
+// listPackages 合并 scope 搜索结果和显式指定的包
+func (s *Syncer) listPackages(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range s.opts.Packages {
+		add(name)
+	}
+
+	if s.opts.Scope != "" {
+		// npmjs 支持 scope: 限定符；不支持的 registry 再按 scope 名搜索，结果都按前缀过滤
+		found, err := s.searchScope(ctx, "scope:"+strings.TrimPrefix(s.opts.Scope, "@"))
+		if err == nil && len(found) == 0 {
+			found, err = s.searchScope(ctx, s.opts.Scope)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range found {
+			add(name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Syncer) searchScope(ctx context.Context, text string) ([]string, error) {
+	prefix := s.opts.Scope + "/"
+	var names []string
+	for from := 0; ; from += syncSearchPageSize {
+		query := url.Values{}
+		query.Set("text", text)
+		query.Set("size", fmt.Sprint(syncSearchPageSize))
+		query.Set("from", fmt.Sprint(from))
+
+		var page struct {
+			Objects []struct {
+				Package struct {
+					Name string `json:"name"`
+				} `json:"package"`
+			} `json:"objects"`
+			Total int `json:"total"`
+		}
+		data, status, err := s.get(ctx, s.from.String()+"/-/v1/search?"+query.Encode(), maxSyncMetadataSize)
+		if err != nil {
+			return nil, err
+		}
+		if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+			return nil, ErrSearchNotSupported
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("search returned status %d", status)
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("invalid search response: %w", err)
+		}
+		for _, obj := range page.Objects {
+			if strings.HasPrefix(obj.Package.Name, prefix) {
+				names = append(names, obj.Package.Name)
+			}
+		}
+		if len(page.Objects) < syncSearchPageSize || from+len(page.Objects) >= page.Total {
+			return names, nil
+		}
+	}
+}
+
+// syncPackage 同步一个包：下载缺失的版本，合并 metadata，写入数据库
+func (s *Syncer) syncPackage(ctx context.Context, name string) SyncPackageResult {
+	result := SyncPackageResult{Name: name}
+	fail := func(format string, args ...interface{}) SyncPackageResult {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+		return result
+	}
+
+	data, status, err := s.get(ctx, s.from.String()+"/"+url.PathEscape(name), maxSyncMetadataSize)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if status != http.StatusOK {
+		return fail("metadata returned status %d", status)
+	}
+	var remote map[string]interface{}
+	if err := json.Unmarshal(data, &remote); err != nil {
+		return fail("invalid metadata: %v", err)
+	}
+
+	modified := ""
+	if times, ok := remote["time"].(map[string]interface{}); ok {
+		modified, _ = times["modified"].(string)
+	}
+	var state db.SyncState
+	db.DB.Where("source = ? AND package_name = ?", s.from.String(), name).Limit(1).Find(&state)
+	if !s.opts.Full && modified != "" && state.Modified == modified && s.store.HasPackage(name) {
+		result.Unchanged = true
+		return result
+	}
+
+	var local map[string]interface{}
+	if existing, err := s.store.GetMetadata(name); err == nil {
+		json.Unmarshal(existing, &local)
+	}
+	localVersions, _ := local["versions"].(map[string]interface{})
+
+	remoteVersions, _ := remote["versions"].(map[string]interface{})
+	synced := make(map[string]interface{}, len(remoteVersions))
+	for _, version := range sortedKeys(remoteVersions) {
+		v, _ := remoteVersions[version].(map[string]interface{})
+		tarballURL, filename := tarballLocation(v)
+		if filename == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: missing dist.tarball", version))
+			continue
+		}
+		if lv, ok := localVersions[version]; ok {
+			if existing, err := s.store.GetTarball(name, filename); err == nil {
+				// 同一版本号内容不同时保留本地版本，不用源 metadata 覆盖
+				if err := verifyIntegrity(v, existing); err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", version, ErrVersionConflict))
+					synced[version] = lv
+					continue
+				}
+				result.UpToDate++
+				synced[version] = v
+				continue
+			}
+		}
+		// 上次中断时已下载但 metadata 还未写入的 tarball
+		if existing, err := s.store.GetTarball(name, filename); err == nil && verifyIntegrity(v, existing) == nil {
+			result.UpToDate++
+			synced[version] = v
+			continue
+		}
+		if s.opts.DryRun {
+			result.Downloaded = append(result.Downloaded, version)
+			continue
+		}
+
+		tarball, err := s.fetchTarball(ctx, tarballURL)
+		if err == nil {
+			err = verifyIntegrity(v, tarball)
+		}
+		if err == nil {
+			err = s.store.SaveTarball(name, filename, tarball)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", version, err))
+			continue
+		}
+		result.Downloaded = append(result.Downloaded, version)
+		synced[version] = v
+	}
+	if s.opts.DryRun || (len(synced) == 0 && local == nil) {
+		return result
+	}
+
+	base := local
+	if s.opts.Mirror {
+		base = nil
+	}
+	meta := mergeSyncedMetadata(name, base, remote, synced)
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if err := s.store.SaveMetadata(name, metaJSON); err != nil {
+		return fail("%v", err)
+	}
+	if err := recordPackage(s.store, name, meta); err != nil {
+		return fail("%v", err)
+	}
+	if err := recordPublishes(name, result.Downloaded); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to record changes: %v", err))
+	}
+	if s.authz != nil {
+		if _, err := s.authz.Reconcile([]string{name}); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("failed to add owners: %v", err))
+		}
+	}
+
+	// 有版本失败时不记录 modified，下次继续重试
+	if len(result.Errors) == 0 {
+		state := db.SyncState{
+			Source:      s.from.String(),
+			PackageName: name,
+			Modified:    modified,
+			Versions:    len(meta["versions"].(map[string]interface{})),
+			SyncedAt:    time.Now(),
+		}
+		err := db.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "source"}, {Name: "package_name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"modified", "versions", "synced_at"}),
+		}).Create(&state).Error
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("failed to record sync state: %v", err))
+		}
+	}
+	return result
+}
+
+// mergeSyncedMetadata 以源 metadata 为准合并本地 metadata：
+// 本地独有的版本保留，源中下载失败的版本不写入，dist-tags 只保留指向已有版本的 tag
+func mergeSyncedMetadata(name string, local, remote, synced map[string]interface{}) map[string]interface{} {
+	meta := make(map[string]interface{}, len(remote))
+	for k, v := range remote {
+		meta[k] = v
+	}
+	for _, key := range []string{"_rev", "_attachments", "_uplinks", "_distfiles", "_upstream"} {
+		delete(meta, key)
+	}
+	meta["_id"] = name
+	meta["name"] = name
+
+	versions := make(map[string]interface{})
+	times := make(map[string]interface{})
+	if local != nil {
+		if lv, ok := local["versions"].(map[string]interface{}); ok {
+			for k, v := range lv {
+				versions[k] = v
+			}
+		}
+		if lt, ok := local["time"].(map[string]interface{}); ok {
+			for k, v := range lt {
+				times[k] = v
+			}
+		}
+	}
+	for k, v := range synced {
+		versions[k] = v
+	}
+	if rt, ok := remote["time"].(map[string]interface{}); ok {
+		for k, v := range rt {
+			if versions[k] != nil || k == "created" || k == "modified" {
+				times[k] = v
+			}
+		}
+	}
+	meta["versions"] = versions
+	meta["time"] = times
+
+	tags := make(map[string]interface{})
+	if local != nil {
+		if lt, ok := local["dist-tags"].(map[string]interface{}); ok {
+			for k, v := range lt {
+				tags[k] = v
+			}
+		}
+	}
+	if rt, ok := remote["dist-tags"].(map[string]interface{}); ok {
+		for tag, v := range rt {
+			if version, _ := v.(string); versions[version] != nil {
+				tags[tag] = version
+			}
+		}
+	}
+	meta["dist-tags"] = tags
+	return meta
+}
+
+// tarballLocation 返回版本的 tarball 地址和文件名
+func tarballLocation(version map[string]interface{}) (string, string) {
+	dist, _ := version["dist"].(map[string]interface{})
+	tarball, _ := dist["tarball"].(string)
+	u, err := url.Parse(tarball)
+	if tarball == "" || err != nil {
+		return "", ""
+	}
+	return tarball, path.Base(u.Path)
+}
+
+// verifyIntegrity 优先用 dist.integrity 中的 sha512 校验，没有时用 dist.shasum
+func verifyIntegrity(version map[string]interface{}, data []byte) error {
+	dist, _ := version["dist"].(map[string]interface{})
+	if integrity, _ := dist["integrity"].(string); integrity != "" {
+		sum := sha512.Sum512(data)
+		actual := base64.StdEncoding.EncodeToString(sum[:])
+		for _, entry := range strings.Fields(integrity) {
+			if expected, ok := strings.CutPrefix(entry, "sha512-"); ok {
+				if subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1 {
+					return nil
+				}
+				return ErrIntegrityMismatch
+			}
+		}
+	}
+	if shasum, _ := dist["shasum"].(string); shasum != "" {
+		sum := sha1.Sum(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), shasum) {
+			return ErrIntegrityMismatch
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) fetchTarball(ctx context.Context, tarballURL string) ([]byte, error) {
+	data, status, err := s.get(ctx, tarballURL, maxSyncTarballSize)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("tarball returned status %d", status)
+	}
+	return data, nil
+}
+
+// get 请求源 registry；token 只发送给源 registry 所在的主机
+func (s *Syncer) get(ctx context.Context, rawURL string, limit int64) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.opts.Token != "" && req.URL.Host == s.from.Host {
+		req.Header.Set("Authorization", "Bearer "+s.opts.Token)
+	}
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if int64(len(data)) > limit {
+		return nil, resp.StatusCode, fmt.Errorf("response from %s exceeds %d bytes", req.URL.Host, limit)
+	}
+	return data, resp.StatusCode, nil
+}
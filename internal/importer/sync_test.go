@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+// fakeRegistry 模拟支持 /-/v1/search 的 npm registry
+type fakeRegistry struct {
+	mu        sync.Mutex
+	server    *httptest.Server
+	packages  map[string]map[string]string // 包名 -> 版本 -> tarball 内容
+	modified  string
+	corrupt   map[string]bool // 返回错误内容的 tarball
+	downloads int
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	r := &fakeRegistry{
+		packages: map[string]map[string]string{},
+		modified: "2024-01-01T00:00:00.000Z",
+		corrupt:  map[string]bool{},
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *fakeRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if req.URL.Path == "/-/v1/search" {
+		var objects []map[string]interface{}
+		for name := range r.packages {
+			objects = append(objects, map[string]interface{}{"package": map[string]string{"name": name}})
+		}
+		objects = append(objects, map[string]interface{}{"package": map[string]string{"name": "acme-unscoped"}})
+		json.NewEncoder(w).Encode(map[string]interface{}{"objects": objects, "total": len(objects)})
+		return
+	}
+	if name, file, ok := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/-/"); ok {
+		r.downloads++
+		for version, content := range r.packages[name] {
+			if file == tarballName(name, version) {
+				if r.corrupt[file] {
+					content = "corrupted"
+				}
+				w.Write([]byte(content))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	name := strings.TrimPrefix(req.URL.Path, "/")
+	versions, ok := r.packages[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	meta := map[string]interface{}{
+		"_id":         name,
+		"_rev":        "5-abc",
+		"name":        name,
+		"maintainers": []map[string]string{{"name": "alice"}},
+		"time":        map[string]string{"modified": r.modified},
+		"dist-tags":   map[string]string{},
+	}
+	vs := map[string]interface{}{}
+	for version, content := range versions {
+		sum := sha512.Sum512([]byte(content))
+		vs[version] = map[string]interface{}{
+			"name":    name,
+			"version": version,
+			"dist": map[string]string{
+				"tarball":   r.server.URL + "/" + name + "/-/" + tarballName(name, version),
+				"integrity": "sha512-" + base64.StdEncoding.EncodeToString(sum[:]),
+			},
+		}
+		meta["dist-tags"].(map[string]string)["latest"] = version
+	}
+	meta["versions"] = vs
+	json.NewEncoder(w).Encode(meta)
+}
+
+func tarballName(name, version string) string {
+	return name[strings.LastIndex(name, "/")+1:] + "-" + version + ".tgz"
+}
+
+func TestSync(t *testing.T) {
+	setupTestDB(t)
+	db.DB.Create(&db.User{Username: "alice", Password: "x", Role: "developer"})
+
+	src := newFakeRegistry(t)
+	src.packages["@acme/ui"] = map[string]string{"1.0.0": "ui-1", "1.1.0": "ui-2"}
+	src.packages["@acme/utils"] = map[string]string{"2.0.0": "utils-1"}
+	src.corrupt["utils-2.0.0.tgz"] = true
+
+	store := local.New(t.TempDir())
+	authz := auth.NewPackageAuthorizer(store)
+	run := func(opts SyncOptions) *SyncResult {
+		t.Helper()
+		opts.From, opts.Token = src.server.URL, "secret"
+		syncer, err := NewSyncer(store, authz, opts)
+		if err != nil {
+			t.Fatalf("NewSyncer failed: %v", err)
+		}
+		result, err := syncer.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		return result
+	}
+
+	// dry run 只列出需要下载的版本
+	result := run(SyncOptions{Scope: "acme", DryRun: true})
+	if len(result.Packages) != 2 || result.Downloaded != 3 || store.HasPackage("@acme/ui") {
+		t.Fatalf("Unexpected dry run result: %+v", result)
+	}
+
+	var progress []int
+	result = run(SyncOptions{Scope: "@acme", Progress: func(p SyncProgress) { progress = append(progress, p.Index) }})
+	if len(progress) != 2 || result.Downloaded != 2 || result.Failed != 1 {
+		t.Fatalf("Unexpected sync result: %+v", result)
+	}
+	if !strings.Contains(result.Packages[1].Errors[0], ErrIntegrityMismatch.Error()) {
+		t.Errorf("Expected integrity error, got %v", result.Packages[1].Errors)
+	}
+	if store.HasPackage("@acme/utils") {
+		t.Error("Expected package without any verified version not to be saved")
+	}
+
+	data, _ := store.GetMetadata("@acme/ui")
+	var meta map[string]interface{}
+	json.Unmarshal(data, &meta)
+	if _, ok := meta["_rev"]; ok {
+		t.Error("Expected source _rev to be removed")
+	}
+	if len(meta["versions"].(map[string]interface{})) != 2 {
+		t.Errorf("Expected 2 versions, got %v", meta["versions"])
+	}
+	var owners int64
+	db.DB.Model(&db.PackageOwner{}).Where("package_name = ?", "@acme/ui").Count(&owners)
+	var pkg db.Package
+	db.DB.Where("name = ?", "@acme/ui").First(&pkg)
+	if owners != 1 || !pkg.Private || pkg.Latest == "" {
+		t.Errorf("Expected owner and private package record, got %d owners, %+v", owners, pkg)
+	}
+
+	// 再次同步：没有变化的包跳过，之前失败的包重试
+	src.corrupt = map[string]bool{}
+	src.downloads = 0
+	result = run(SyncOptions{Scope: "@acme"})
+	if !result.Packages[0].Unchanged || result.Downloaded != 1 || result.Failed != 0 || src.downloads != 1 {
+		t.Fatalf("Expected incremental sync, got %+v (%d downloads)", result, src.downloads)
+	}
+
+	// 源发布新版本后只下载新版本
+	src.packages["@acme/ui"]["1.2.0"] = "ui-3"
+	src.modified = "2024-02-01T00:00:00.000Z"
+	src.downloads = 0
+	result = run(SyncOptions{Scope: "@acme"})
+	if result.Downloaded != 1 || src.downloads != 1 || result.Packages[0].UpToDate != 2 {
+		t.Fatalf("Expected only the new version to be copied, got %+v", result)
+	}
+	if !store.HasTarball("@acme/ui", "ui-1.2.0.tgz") {
+		t.Error("Expected new tarball to be saved")
+	}
+
+	// 源中同一版本内容变化时报告冲突，保留本地 metadata 与 tarball
+	data, _ = store.GetMetadata("@acme/ui")
+	json.Unmarshal(data, &meta)
+	localDist := meta["versions"].(map[string]interface{})["1.0.0"].(map[string]interface{})["dist"].(map[string]interface{})["integrity"]
+	src.packages["@acme/ui"]["1.0.0"] = "ui-1-republished"
+	src.modified = "2024-03-01T00:00:00.000Z"
+	result = run(SyncOptions{Scope: "@acme"})
+	if result.Failed != 1 || len(result.Packages[0].Errors) != 1 || !strings.Contains(result.Packages[0].Errors[0], ErrVersionConflict.Error()) {
+		t.Fatalf("Expected version conflict, got %+v", result)
+	}
+	data, _ = store.GetMetadata("@acme/ui")
+	meta = nil
+	json.Unmarshal(data, &meta)
+	if dist := meta["versions"].(map[string]interface{})["1.0.0"].(map[string]interface{})["dist"].(map[string]interface{}); dist["integrity"] != localDist {
+		t.Errorf("Expected local metadata to be kept, got %v", dist)
+	}
+	if tarball, _ := store.GetTarball("@acme/ui", "ui-1.0.0.tgz"); string(tarball) != "ui-1" {
+		t.Errorf("Expected local tarball to be kept, got %q", tarball)
+	}
+}
@@ -0,0 +1,358 @@
+package importer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/storage"
+)
+
+// verdaccioDBFile Verdaccio 记录本地发布（私有）包的文件
+const verdaccioDBFile = ".verdaccio-db.json"
+
+// 导入时对每个包采取的动作
+const (
+	ActionImport       = "import"        // 导入
+	ActionSkipExisting = "skip-existing" // Grape 中已存在同名包
+	ActionSkipCache    = "skip-cache"    // 上游缓存，未指定 IncludeCache
+)
+
+// VerdaccioOptions Verdaccio 导入选项
+type VerdaccioOptions struct {
+	StorageDir   string // Verdaccio 的 storage 目录
+	IncludeCache bool   // 同时导入从上游缓存的包
+	Overwrite    bool   // 覆盖 Grape 中已存在的同名包
+	DryRun       bool   // 只生成报告，不写入存储和数据库
+}
+
+// VerdaccioPackage Verdaccio storage 中的一个包
+type VerdaccioPackage struct {
+	Name               string   `json:"name"`
+	Private            bool     `json:"private"`
+	Uplink             string   `json:"uplink,omitempty"` // 缓存包来自的上游
+	Versions           int      `json:"versions"`
+	Tarballs           int      `json:"tarballs"`
+	MissingTarballs    []string `json:"missingTarballs,omitempty"`
+	CorruptTarballs    []string `json:"corruptTarballs,omitempty"` // shasum 不匹配，不会导入
+	Maintainers        []string `json:"maintainers,omitempty"`
+	UnknownMaintainers []string `json:"unknownMaintainers,omitempty"` // 在 Grape 中没有账号，不会成为 owner
+	Action             string   `json:"action"`
+
+	dir  string
+	meta map[string]interface{}
+}
+
+// VerdaccioReport 导入报告，DryRun 时 Imported 为空
+type VerdaccioReport struct {
+	Private  []*VerdaccioPackage `json:"private"`
+	Cached   []*VerdaccioPackage `json:"cached"`
+	Imported []string            `json:"imported"`
+	Errors   []string            `json:"errors"`
+}
+
+// ImportVerdaccio 把 Verdaccio storage 目录中的包导入到本地存储，并写入 packages / package_versions / package_owners
+//
+// .verdaccio-db.json 中列出的包视为私有包，其余为上游缓存；没有该文件时以 _uplinks 是否为空判断。
+// 私有包的 maintainers 中在 Grape 有账号的用户成为 owner。
+func ImportVerdaccio(store storage.Storage, authz *auth.PackageAuthorizer, opts VerdaccioOptions) (*VerdaccioReport, error) {
+	privateList, err := readVerdaccioDB(opts.StorageDir)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := scanVerdaccioStorage(opts.StorageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerdaccioReport{
+		Private:  []*VerdaccioPackage{},
+		Cached:   []*VerdaccioPackage{},
+		Imported: []string{},
+		Errors:   []string{},
+	}
+	for _, name := range dirs {
+		pkg, problems, err := inspectVerdaccioPackage(opts.StorageDir, name, privateList)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		report.Errors = append(report.Errors, problems...)
+
+		switch {
+		case store.HasPackage(pkg.Name) && !opts.Overwrite:
+			pkg.Action = ActionSkipExisting
+		case !pkg.Private && !opts.IncludeCache:
+			pkg.Action = ActionSkipCache
+		default:
+			pkg.Action = ActionImport
+		}
+		if pkg.Private {
+			report.Private = append(report.Private, pkg)
+		} else {
+			report.Cached = append(report.Cached, pkg)
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, group := range [][]*VerdaccioPackage{report.Private, report.Cached} {
+		for _, pkg := range group {
+			if pkg.Action != ActionImport {
+				continue
+			}
+			if err := importVerdaccioPackage(store, pkg); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", pkg.Name, err))
+				continue
+			}
+			if pkg.Private {
+				if _, err := authz.Reconcile([]string{pkg.Name}); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: failed to add owners: %v", pkg.Name, err))
+				}
+			}
+			report.Imported = append(report.Imported, pkg.Name)
+		}
+	}
+	return report, nil
+}
+
+// readVerdaccioDB 读取 .verdaccio-db.json 中的私有包列表，文件不存在时返回 nil
+func readVerdaccioDB(dir string) (map[string]bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, verdaccioDBFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		List []string `json:"list"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", verdaccioDBFile, err)
+	}
+	list := make(map[string]bool, len(file.List))
+	for _, name := range file.List {
+		list[name] = true
+	}
+	return list, nil
+}
+
+// scanVerdaccioStorage 列出 storage 目录中包含 package.json 的包（<pkg>/ 和 @scope/<pkg>/）
+func scanVerdaccioStorage(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !strings.HasPrefix(entry.Name(), "@") {
+			if isVerdaccioPackageDir(filepath.Join(dir, entry.Name())) {
+				names = append(names, entry.Name())
+			}
+			continue
+		}
+		scoped, err := os.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range scoped {
+			if sub.IsDir() && isVerdaccioPackageDir(filepath.Join(dir, entry.Name(), sub.Name())) {
+				names = append(names, entry.Name()+"/"+sub.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func isVerdaccioPackageDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "package.json"))
+	return err == nil
+}
+
+// inspectVerdaccioPackage 读取包的 package.json，检查 tarball 和 maintainers
+// 返回的 problems 是不影响导入其他版本的问题（如 tarball 校验和不匹配）
+func inspectVerdaccioPackage(storageDir, name string, privateList map[string]bool) (*VerdaccioPackage, []string, error) {
+	dir := filepath.Join(storageDir, filepath.FromSlash(name))
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, nil, fmt.Errorf("invalid package.json: %w", err)
+	}
+	if metaName, _ := meta["name"].(string); metaName != "" && metaName != name {
+		return nil, nil, fmt.Errorf("package.json name %q does not match directory", metaName)
+	}
+
+	pkg := &VerdaccioPackage{Name: name, dir: dir, meta: meta}
+	if uplinks, ok := meta["_uplinks"].(map[string]interface{}); ok {
+		var names []string
+		for uplink := range uplinks {
+			names = append(names, uplink)
+		}
+		sort.Strings(names)
+		pkg.Uplink = strings.Join(names, ",")
+	}
+	if privateList != nil {
+		pkg.Private = privateList[name]
+	} else {
+		pkg.Private = pkg.Uplink == ""
+	}
+
+	var problems []string
+	seen := make(map[string]bool)
+	addMaintainers := func(list []interface{}) {
+		for _, m := range auth.MaintainerNames(list) {
+			if !seen[m] {
+				seen[m] = true
+				pkg.Maintainers = append(pkg.Maintainers, m)
+			}
+		}
+	}
+	list, _ := meta["maintainers"].([]interface{})
+	addMaintainers(list)
+
+	versions, _ := meta["versions"].(map[string]interface{})
+	for _, version := range sortedKeys(versions) {
+		v, _ := versions[version].(map[string]interface{})
+		pkg.Versions++
+		list, _ := v["maintainers"].([]interface{})
+		addMaintainers(list)
+		if npmUser, ok := v["_npmUser"]; ok {
+			addMaintainers([]interface{}{npmUser})
+		}
+
+		filename, shasum := versionDist(v)
+		if filename == "" {
+			continue
+		}
+		sum, err := fileSHA1(filepath.Join(dir, filename))
+		if err != nil {
+			pkg.MissingTarballs = append(pkg.MissingTarballs, filename)
+			continue
+		}
+		if shasum != "" && sum != shasum {
+			pkg.CorruptTarballs = append(pkg.CorruptTarballs, filename)
+			problems = append(problems, fmt.Sprintf("%s: %s: shasum mismatch, skipped", name, filename))
+			continue
+		}
+		pkg.Tarballs++
+	}
+
+	// 缓存包只需要 maintainers 做展示，不关心账号
+	if pkg.Private {
+		for _, m := range pkg.Maintainers {
+			var user db.User
+			if err := db.DB.Where("username = ?", m).First(&user).Error; err != nil {
+				pkg.UnknownMaintainers = append(pkg.UnknownMaintainers, m)
+			}
+		}
+	}
+	return pkg, problems, nil
+}
+
+// importVerdaccioPackage 先复制 tarball 再写入 metadata，最后更新数据库
+//
+// 缺失或校验和不匹配的 tarball 不会复制。私有包没有其他来源，这些版本和指向它们的 dist-tag
+// 不写入 metadata；缓存包保留这些版本，由代理按需从上游获取 tarball。
+func importVerdaccioPackage(store storage.Storage, pkg *VerdaccioPackage) error {
+	meta := pkg.meta
+	versions, _ := meta["versions"].(map[string]interface{})
+	skipped := make(map[string]bool)
+	for _, filename := range append(pkg.MissingTarballs, pkg.CorruptTarballs...) {
+		skipped[filename] = true
+	}
+	var dropped []string
+	for _, version := range sortedKeys(versions) {
+		v, _ := versions[version].(map[string]interface{})
+		filename, _ := versionDist(v)
+		if filename == "" || skipped[filename] {
+			dropped = append(dropped, version)
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(pkg.dir, filename))
+		if err != nil {
+			return err
+		}
+		if err := store.SaveTarball(pkg.Name, filename, data); err != nil {
+			return err
+		}
+	}
+	if pkg.Private {
+		dropVersions(meta, dropped)
+		if len(versions) == 0 {
+			return errors.New("no version with a valid tarball")
+		}
+	}
+
+	// 去掉 Verdaccio 内部字段
+	for _, key := range []string{"_uplinks", "_distfiles", "_rev", "_attachments"} {
+		delete(meta, key)
+	}
+	meta["_id"] = pkg.Name
+	meta["name"] = pkg.Name
+	if pkg.Private {
+		maintainers := make([]map[string]string, 0, len(pkg.Maintainers))
+		for _, m := range pkg.Maintainers {
+			maintainers = append(maintainers, map[string]string{"name": m})
+		}
+		meta["maintainers"] = maintainers
+	} else {
+		meta["_upstream"] = pkg.Uplink
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := store.SaveMetadata(pkg.Name, data); err != nil {
+		return err
+	}
+
+	if err := recordPackage(store, pkg.Name, meta); err != nil {
+		return err
+	}
+	return recordPublishes(pkg.Name, sortedKeys(versions))
+}
+
+// dropVersions 从 metadata 中去掉版本及其发布时间和指向它们的 dist-tag
+func dropVersions(meta map[string]interface{}, dropped []string) {
+	versions, _ := meta["versions"].(map[string]interface{})
+	times, _ := meta["time"].(map[string]interface{})
+	gone := make(map[string]bool, len(dropped))
+	for _, version := range dropped {
+		gone[version] = true
+		delete(versions, version)
+		delete(times, version)
+	}
+	tags, _ := meta["dist-tags"].(map[string]interface{})
+	for tag, v := range tags {
+		if version, _ := v.(string); gone[version] {
+			delete(tags, tag)
+		}
+	}
+}
+
+func fileSHA1(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,169 @@
+package importer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	dbtest.Open(t, &db.User{}, &db.Package{}, &db.PackageVersion{}, &db.PackageOwner{}, &db.SyncState{}, &db.PackageChange{})
+}
+
+// writeVerdaccioPackage 按 Verdaccio 的布局写入 package.json 和 tarball
+func writeVerdaccioPackage(t *testing.T, dir, name string, uplinks map[string]interface{}, versions map[string]string) {
+	t.Helper()
+	pkgDir := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	meta := map[string]interface{}{
+		"name":         name,
+		"_uplinks":     uplinks,
+		"_distfiles":   map[string]interface{}{},
+		"_attachments": map[string]interface{}{},
+		"_rev":         "3-abc",
+		"dist-tags":    map[string]string{},
+		"time":         map[string]string{},
+	}
+	vs := map[string]interface{}{}
+	for version, publisher := range versions {
+		filename := filepath.Base(name) + "-" + version + ".tgz"
+		content := []byte(name + "@" + version)
+		sum := sha1.Sum(content)
+		vs[version] = map[string]interface{}{
+			"name":        name,
+			"version":     version,
+			"maintainers": []map[string]string{{"name": publisher, "email": publisher + "@example.com"}},
+			"_npmUser":    map[string]string{"name": publisher},
+			"dist": map[string]string{
+				"tarball": "http://localhost:4873/" + name + "/-/" + filename,
+				"shasum":  hex.EncodeToString(sum[:]),
+			},
+		}
+		if tags := meta["dist-tags"].(map[string]string); version > tags["latest"] {
+			tags["latest"] = version
+		}
+		meta["time"].(map[string]string)[version] = "2024-01-15T08:00:00.000Z"
+		if version != "9.9.9" { // 9.9.9 模拟缺失的 tarball
+			os.WriteFile(filepath.Join(pkgDir, filename), content, 0644)
+		}
+	}
+	meta["versions"] = vs
+	data, _ := json.Marshal(meta)
+	os.WriteFile(filepath.Join(pkgDir, "package.json"), data, 0644)
+}
+
+func TestImportVerdaccio(t *testing.T) {
+	setupTestDB(t)
+	db.DB.Create(&db.User{Username: "alice", Password: "x", Role: "developer"})
+
+	src := t.TempDir()
+	writeVerdaccioPackage(t, src, "@acme/ui", map[string]interface{}{}, map[string]string{"1.0.0": "alice", "1.1.0": "bob"})
+	writeVerdaccioPackage(t, src, "acme-utils", map[string]interface{}{}, map[string]string{"1.0.0": "alice", "2.0.0": "alice", "9.9.9": "alice"})
+	os.WriteFile(filepath.Join(src, "acme-utils", "acme-utils-2.0.0.tgz"), []byte("corrupted"), 0644)
+	writeVerdaccioPackage(t, src, "lodash", map[string]interface{}{"npmjs": map[string]interface{}{"etag": "x"}}, map[string]string{"4.17.21": "jdalton"})
+	os.WriteFile(filepath.Join(src, ".verdaccio-db.json"), []byte(`{"list":["@acme/ui","acme-utils"],"secret":"s"}`), 0644)
+
+	store := local.New(t.TempDir())
+	authz := auth.NewPackageAuthorizer(store)
+
+	// dry run 只生成报告
+	report, err := ImportVerdaccio(store, authz, VerdaccioOptions{StorageDir: src, DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportVerdaccio failed: %v", err)
+	}
+	if len(report.Private) != 2 || len(report.Cached) != 1 || len(report.Imported) != 0 {
+		t.Fatalf("Unexpected dry run report: %+v", report)
+	}
+	ui := report.Private[0]
+	if ui.Name != "@acme/ui" || ui.Versions != 2 || ui.Tarballs != 2 || ui.Action != ActionImport {
+		t.Errorf("Unexpected report for @acme/ui: %+v", ui)
+	}
+	if len(ui.UnknownMaintainers) != 1 || ui.UnknownMaintainers[0] != "bob" {
+		t.Errorf("Expected bob to be reported as unknown maintainer, got %v", ui.UnknownMaintainers)
+	}
+	if utils := report.Private[1]; len(utils.MissingTarballs) != 1 || len(utils.CorruptTarballs) != 1 || utils.Tarballs != 1 {
+		t.Errorf("Expected a missing and a corrupt tarball for acme-utils, got %+v", utils)
+	}
+	if report.Cached[0].Action != ActionSkipCache || report.Cached[0].Uplink != "npmjs" {
+		t.Errorf("Expected lodash to be skipped as cache, got %+v", report.Cached[0])
+	}
+	if store.HasPackage("@acme/ui") {
+		t.Fatal("Expected dry run not to write packages")
+	}
+
+	report, err = ImportVerdaccio(store, authz, VerdaccioOptions{StorageDir: src})
+	if err != nil {
+		t.Fatalf("ImportVerdaccio failed: %v", err)
+	}
+	if len(report.Imported) != 2 || len(report.Errors) != 1 {
+		t.Fatalf("Unexpected import report: %+v", report)
+	}
+	if !store.HasTarball("@acme/ui", "ui-1.1.0.tgz") || store.HasPackage("lodash") {
+		t.Error("Expected private packages to be copied and caches to be skipped")
+	}
+
+	data, _ := store.GetMetadata("@acme/ui")
+	var meta map[string]interface{}
+	json.Unmarshal(data, &meta)
+	if _, ok := meta["_uplinks"]; ok {
+		t.Error("Expected Verdaccio internal fields to be removed")
+	}
+	if names := auth.MaintainerNames(meta["maintainers"].([]interface{})); len(names) != 1 || names[0] != "alice" {
+		t.Errorf("Expected maintainers to be synced with owners, got %v", names)
+	}
+
+	// 私有包中没有有效 tarball 的版本和指向它们的 dist-tag 不写入
+	data, _ = store.GetMetadata("acme-utils")
+	meta = nil
+	json.Unmarshal(data, &meta)
+	if versions := meta["versions"].(map[string]interface{}); len(versions) != 1 || versions["1.0.0"] == nil {
+		t.Errorf("Expected only the version with a valid tarball, got %v", versions)
+	}
+	if tags := meta["dist-tags"].(map[string]interface{}); len(tags) != 0 {
+		t.Errorf("Expected dist-tags of dropped versions to be removed, got %v", tags)
+	}
+	if store.HasTarball("acme-utils", "acme-utils-2.0.0.tgz") {
+		t.Error("Expected tarball with shasum mismatch not to be imported")
+	}
+
+	var pkg db.Package
+	if err := db.DB.Where("name = ?", "@acme/ui").First(&pkg).Error; err != nil || !pkg.Private || pkg.Latest != "1.1.0" {
+		t.Errorf("Unexpected package record: %+v, %v", pkg, err)
+	}
+	var versions int64
+	db.DB.Model(&db.PackageVersion{}).Where("package_name = ?", "@acme/ui").Count(&versions)
+	if versions != 2 {
+		t.Errorf("Expected 2 version records, got %d", versions)
+	}
+	var owners int64
+	db.DB.Model(&db.PackageOwner{}).Where("package_name IN ?", []string{"@acme/ui", "acme-utils"}).Count(&owners)
+	if owners != 2 {
+		t.Errorf("Expected alice to own both private packages, got %d owners", owners)
+	}
+
+	// 再次导入时跳过已存在的包，缓存包按需导入
+	report, err = ImportVerdaccio(store, authz, VerdaccioOptions{StorageDir: src, IncludeCache: true})
+	if err != nil {
+		t.Fatalf("ImportVerdaccio failed: %v", err)
+	}
+	if len(report.Imported) != 1 || report.Imported[0] != "lodash" || report.Private[0].Action != ActionSkipExisting {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	packages, _ := store.ListPackages()
+	for _, p := range packages {
+		if p.Name == "lodash" && p.Private {
+			t.Error("Expected cached package not to be listed as private")
+		}
+	}
+}
@@ -75,13 +75,31 @@ var (
 		},
 	)
 
-	// 登录尝试次数
+	// 登录尝试次数，status 为 success | failure，reason 为具体结果
 	LoginAttemptsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "grape",
 			Name:      "login_attempts_total",
 			Help:      "Total number of login attempts",
 		},
-		[]string{"status"},
+		[]string{"status", "reason"},
+	)
+
+	// 只读副本落后主节点的时间（秒），追上主节点时为 0
+	ReplicationLagSeconds = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "grape",
+			Name:      "replication_lag_seconds",
+			Help:      "Seconds since the replica was last caught up with the primary change feed",
+		},
+	)
+
+	// 只读副本已应用的主节点变更序号
+	ReplicationLastSeq = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "grape",
+			Name:      "replication_last_seq",
+			Help:      "Last primary change sequence applied by the replica",
+		},
 	)
 )
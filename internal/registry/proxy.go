@@ -277,6 +277,14 @@ func (p *Proxy) Upstream() string {
 	return ""
 }
 
+// UpstreamName 返回包所使用的上游名称，没有可用上游时返回空字符串
+func (p *Proxy) UpstreamName(packageName string) string {
+	if up := p.selectUpstream(packageName); up != nil {
+		return up.Name
+	}
+	return ""
+}
+
 // Upstreams 返回所有上游配置
 func (p *Proxy) Upstreams() []UpstreamInfo {
 	p.mu.RLock()
@@ -0,0 +1,436 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/importer"
+	"github.com/graperegistry/grape/internal/logger"
+	"github.com/graperegistry/grape/internal/metrics"
+	"github.com/graperegistry/grape/internal/storage"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	changesBatchSize  = 100
+	longpollTimeout   = 25 * time.Second // 小于默认的 write_timeout，主节点也会自行截断
+	searchPageSize    = 250
+	maxRetryDelay     = time.Minute
+	maxResponseSize   = 50 * 1024 * 1024
+	remoteUserTTL     = time.Minute
+	maxRemoteUserKeys = 1024
+)
+
+// ErrNotFound 主节点上不存在（或不可读）的包
+var ErrNotFound = errors.New("not found on primary")
+
+// Status 副本的复制状态
+type Status struct {
+	Primary    string     `json:"primary"`
+	Seq        uint64     `json:"seq"`                  // 已应用的主节点变更序号
+	CaughtUp   bool       `json:"caughtUp"`             // 最近一次拉取后没有待应用的变更
+	CaughtUpAt *time.Time `json:"caughtUpAt,omitempty"` // 最近一次追上主节点的时间
+	LagSeconds float64    `json:"lagSeconds"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+// RemoteUser 由主节点验证的用户
+type RemoteUser struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type cachedUser struct {
+	user    *RemoteUser
+	expires time.Time
+}
+
+// Replica 跟随主节点的 /-/_changes，把主节点上公开可读的包复制到本地存储
+//
+// 受限包不会复制，本地已有的副本会被删除，这些包的读取由调用方转发给主节点。
+// 首次启动时先记录主节点当前的序号，再通过 /-/v1/search 复制全部包。
+type Replica struct {
+	store   storage.Storage
+	primary *url.URL
+	token   string
+	client  *http.Client
+
+	mu        sync.Mutex
+	status    Status
+	startedAt time.Time
+	users     map[string]cachedUser
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 创建 Replica，调用 Start 后开始复制
+func New(store storage.Storage, cfg config.ReplicationConfig) (*Replica, error) {
+	primary, err := url.Parse(strings.TrimSuffix(cfg.Primary, "/"))
+	if err != nil || (primary.Scheme != "http" && primary.Scheme != "https") || primary.Host == "" {
+		return nil, fmt.Errorf("invalid primary registry URL: %q", cfg.Primary)
+	}
+	r := &Replica{
+		store:     store,
+		primary:   primary,
+		token:     cfg.Token,
+		client:    &http.Client{Timeout: 10 * time.Minute},
+		status:    Status{Primary: primary.String()},
+		startedAt: time.Now(),
+		users:     make(map[string]cachedUser),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r, nil
+}
+
+// Primary 返回主节点地址
+func (r *Replica) Primary() *url.URL {
+	return r.primary
+}
+
+// Start 启动复制和延迟指标上报
+func (r *Replica) Start() {
+	r.wg.Add(2)
+	go r.follow()
+	go r.reportLag()
+}
+
+// Stop 停止复制并等待 goroutine 退出
+func (r *Replica) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// Status 返回当前复制状态
+func (r *Replica) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.status
+	status.LagSeconds = r.lagLocked().Seconds()
+	return status
+}
+
+// lagLocked 追上主节点时为 0，否则为距离上次追上（或启动）的时间
+func (r *Replica) lagLocked() time.Duration {
+	if r.status.CaughtUp {
+		return 0
+	}
+	if r.status.CaughtUpAt != nil {
+		return time.Since(*r.status.CaughtUpAt)
+	}
+	return time.Since(r.startedAt)
+}
+
+func (r *Replica) reportLag() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		metrics.ReplicationLagSeconds.Set(r.lagLocked().Seconds())
+		r.mu.Unlock()
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Replica) follow() {
+	defer r.wg.Done()
+
+	var state db.ReplicationState
+	found := false
+	if err := db.DB.Where("source = ?", r.primary.String()).Limit(1).Find(&state).Error; err != nil {
+		logger.Warnf("Failed to load replication state: %v", err)
+	} else {
+		found = state.ID != 0
+	}
+	r.mu.Lock()
+	r.status.Seq = state.Seq
+	r.mu.Unlock()
+	metrics.ReplicationLastSeq.Set(float64(state.Seq))
+
+	delay := time.Second
+	for r.ctx.Err() == nil {
+		var err error
+		if !found {
+			if err = r.bootstrap(); err == nil {
+				found = true
+			}
+		} else {
+			err = r.poll()
+		}
+		if err == nil {
+			delay = time.Second
+			continue
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+		logger.Warnf("Replication from %s failed: %v", r.primary, err)
+		r.mu.Lock()
+		r.status.CaughtUp = false
+		r.status.LastError = err.Error()
+		r.mu.Unlock()
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// changesPage /-/_changes 的响应
+type changesPage struct {
+	Results []struct {
+		Seq     uint64 `json:"seq"`
+		ID      string `json:"id"`
+		Deleted bool   `json:"deleted"`
+	} `json:"results"`
+	LastSeq uint64 `json:"last_seq"`
+}
+
+// bootstrap 记录主节点当前序号后复制主节点上的全部包，之后从该序号开始跟随
+func (r *Replica) bootstrap() error {
+	var head changesPage
+	if err := r.getJSON("/-/_changes?since=now", &head); err != nil {
+		return fmt.Errorf("failed to read primary change feed: %w", err)
+	}
+
+	var names []string
+	for from := 0; ; from += searchPageSize {
+		var page struct {
+			Objects []struct {
+				Package struct {
+					Name string `json:"name"`
+				} `json:"package"`
+			} `json:"objects"`
+			Total int `json:"total"`
+		}
+		if err := r.getJSON(fmt.Sprintf("/-/v1/search?text=&size=%d&from=%d", searchPageSize, from), &page); err != nil {
+			return fmt.Errorf("failed to list primary packages: %w", err)
+		}
+		for _, obj := range page.Objects {
+			names = append(names, obj.Package.Name)
+		}
+		if len(page.Objects) < searchPageSize || from+len(page.Objects) >= page.Total {
+			break
+		}
+	}
+
+	logger.Infof("🔁 Replicating %d packages from %s", len(names), r.primary)
+	for _, name := range names {
+		if err := r.apply(name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return r.commit(head.LastSeq, true)
+}
+
+// poll 用 longpoll 拉取一批变更并应用：同一批中的包只处理一次，
+// 最后一条变更是删除时直接移除本地副本，否则按主节点的当前状态同步
+func (r *Replica) poll() error {
+	r.mu.Lock()
+	since := r.status.Seq
+	r.mu.Unlock()
+
+	query := url.Values{}
+	query.Set("since", fmt.Sprint(since))
+	query.Set("limit", fmt.Sprint(changesBatchSize))
+	query.Set("feed", "longpoll")
+	query.Set("timeout", fmt.Sprint(longpollTimeout.Milliseconds()))
+	var page changesPage
+	if err := r.getJSON("/-/_changes?"+query.Encode(), &page); err != nil {
+		return fmt.Errorf("failed to read primary change feed: %w", err)
+	}
+
+	var names []string
+	deleted := make(map[string]bool)
+	for _, change := range page.Results {
+		if _, ok := deleted[change.ID]; !ok {
+			names = append(names, change.ID)
+		}
+		deleted[change.ID] = change.Deleted
+	}
+	for _, name := range names {
+		var err error
+		if deleted[name] {
+			err = r.remove(name)
+		} else {
+			err = r.apply(name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if page.LastSeq < since {
+		page.LastSeq = since
+	}
+	return r.commit(page.LastSeq, len(page.Results) < changesBatchSize)
+}
+
+// commit 保存已应用的序号并更新状态
+func (r *Replica) commit(seq uint64, caughtUp bool) error {
+	state := db.ReplicationState{Source: r.primary.String(), Seq: seq, UpdatedAt: time.Now()}
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source"}},
+		DoUpdates: clause.AssignmentColumns([]string{"seq", "updated_at"}),
+	}).Create(&state).Error
+	if err != nil {
+		return fmt.Errorf("failed to save replication state: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Seq = seq
+	r.status.CaughtUp = caughtUp
+	r.status.LastError = ""
+	if caughtUp {
+		now := time.Now()
+		r.status.CaughtUpAt = &now
+	}
+	metrics.ReplicationLastSeq.Set(float64(seq))
+	return nil
+}
+
+// apply 让本地副本与主节点上包的当前状态一致：公开包完整复制，已删除或受限的包从本地移除
+func (r *Replica) apply(name string) error {
+	var visibility struct {
+		Public bool `json:"public"`
+	}
+	err := r.getJSON("/-/package/"+url.PathEscape(name)+"/visibility", &visibility)
+	if errors.Is(err, ErrNotFound) || (err == nil && !visibility.Public) {
+		return r.remove(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	syncer, err := importer.NewSyncer(r.store, nil, importer.SyncOptions{
+		From:     r.primary.String(),
+		Token:    r.token,
+		Packages: []string{name},
+		Full:     true,
+		Mirror:   true,
+		Client:   r.client,
+	})
+	if err != nil {
+		return err
+	}
+	result, err := syncer.Run(r.ctx)
+	if err != nil {
+		return err
+	}
+	if errs := result.Packages[0].Errors; len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// remove 删除本地副本中的包
+func (r *Replica) remove(name string) error {
+	if !r.store.HasPackage(name) {
+		return nil
+	}
+	if err := r.store.DeletePackage(name); err != nil {
+		return err
+	}
+	if err := db.DeletePackageIndex(name); err != nil {
+		return err
+	}
+	logger.Infof("Removed replicated package %s", name)
+	return nil
+}
+
+// getJSON 请求主节点并解析 JSON，404 返回 ErrNotFound
+func (r *Replica) getJSON(path string, out interface{}) error {
+	data, status, err := r.get(path, http.Header{})
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", strings.SplitN(path, "?", 2)[0], status)
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (r *Replica) get(path string, header http.Header) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.primary.String()+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header = header
+	req.Header.Set("Accept", "application/json")
+	if r.token != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	return data, resp.StatusCode, err
+}
+
+// Authenticate 用请求的 Authorization 头向主节点验证身份，结果缓存一分钟；
+// 没有 Authorization 或主节点不认可时返回 nil
+func (r *Replica) Authenticate(authorization string) (*RemoteUser, error) {
+	if authorization == "" {
+		return nil, nil
+	}
+	r.mu.Lock()
+	if cached, ok := r.users[authorization]; ok && time.Now().Before(cached.expires) {
+		r.mu.Unlock()
+		return cached.user, nil
+	}
+	r.mu.Unlock()
+
+	data, status, err := r.get("/-/api/user", http.Header{"Authorization": {authorization}})
+	if err != nil {
+		return nil, err
+	}
+	var user *RemoteUser
+	switch status {
+	case http.StatusOK:
+		user = &RemoteUser{}
+		if err := json.Unmarshal(data, user); err != nil {
+			return nil, err
+		}
+	case http.StatusUnauthorized, http.StatusForbidden:
+	default:
+		return nil, fmt.Errorf("/-/api/user returned status %d", status)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if len(r.users) >= maxRemoteUserKeys {
+		for key, cached := range r.users {
+			if now.After(cached.expires) {
+				delete(r.users, key)
+			}
+		}
+	}
+	if len(r.users) < maxRemoteUserKeys {
+		r.users[authorization] = cachedUser{user: user, expires: now.Add(remoteUserTTL)}
+	}
+	return user, nil
+}
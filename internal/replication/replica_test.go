@@ -0,0 +1,169 @@
+package replication
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+// fakePrimary 模拟主节点的 _changes、search、visibility、metadata 和 tarball 接口
+type fakePrimary struct {
+	mu         sync.Mutex
+	server     *httptest.Server
+	packages   map[string][]string // 包名 -> 版本
+	tags       map[string]map[string]string
+	restricted map[string]bool
+	changes    []string // 按序号排列的包名，序号从 1 开始
+	deleted    map[int]bool
+}
+
+func newFakePrimary(t *testing.T) *fakePrimary {
+	p := &fakePrimary{
+		packages:   map[string][]string{},
+		tags:       map[string]map[string]string{},
+		restricted: map[string]bool{},
+		deleted:    map[int]bool{},
+	}
+	p.server = httptest.NewServer(http.HandlerFunc(p.serve))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *fakePrimary) publish(name string, versions ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.packages[name] = append(p.packages[name], versions...)
+	p.tags[name] = map[string]string{"latest": versions[len(versions)-1]}
+	p.changes = append(p.changes, name)
+}
+
+func (p *fakePrimary) unpublish(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.packages, name)
+	p.changes = append(p.changes, name)
+	p.deleted[len(p.changes)] = true
+}
+
+func (p *fakePrimary) serve(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case path == "/-/_changes":
+		since := 0
+		if s := r.URL.Query().Get("since"); s != "now" {
+			json.Unmarshal([]byte(s), &since)
+		} else {
+			since = len(p.changes)
+		}
+		var results []map[string]interface{}
+		for i := since; i < len(p.changes); i++ {
+			results = append(results, map[string]interface{}{"seq": i + 1, "id": p.changes[i], "deleted": p.deleted[i+1]})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results, "last_seq": len(p.changes)})
+	case path == "/-/v1/search":
+		var objects []map[string]interface{}
+		for name := range p.packages {
+			objects = append(objects, map[string]interface{}{"package": map[string]string{"name": name}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"objects": objects, "total": len(objects)})
+	case strings.HasPrefix(path, "/-/package/"):
+		// 与真实主节点一致：只检查读权限，不检查包是否存在
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/-/package/"), "/visibility")
+		json.NewEncoder(w).Encode(map[string]bool{"public": !p.restricted[name]})
+	case strings.Contains(path, "/-/"):
+		w.Write([]byte("tarball " + path))
+	default:
+		name := strings.TrimPrefix(path, "/")
+		versions, ok := p.packages[name]
+		if !ok {
+			w.WriteHeader(http.StatusBadGateway) // 主节点本地没有的包会转到上游
+			return
+		}
+		vs := map[string]interface{}{}
+		for _, version := range versions {
+			tarballPath := "/" + name + "/-/" + name + "-" + version + ".tgz"
+			sum := sha1.Sum([]byte("tarball " + tarballPath))
+			vs[version] = map[string]interface{}{
+				"name":    name,
+				"version": version,
+				"dist":    map[string]string{"tarball": p.server.URL + tarballPath, "shasum": hex.EncodeToString(sum[:])},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "versions": vs, "dist-tags": p.tags[name]})
+	}
+}
+
+func TestReplica(t *testing.T) {
+	dbtest.Open(t, &db.Package{}, &db.PackageVersion{}, &db.SyncState{}, &db.PackageChange{}, &db.ReplicationState{})
+
+	primary := newFakePrimary(t)
+	primary.publish("app", "1.0.0")
+	primary.publish("secret", "1.0.0")
+	primary.restricted["secret"] = true
+	primary.tags["app"]["beta"] = "1.0.0"
+
+	store := local.New(t.TempDir())
+	r, err := New(store, config.ReplicationConfig{Mode: config.ReplicationReplica, Primary: primary.server.URL})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if err := r.bootstrap(); err != nil {
+		t.Fatalf("bootstrap failed: %v", err)
+	}
+	if !store.HasTarball("app", "app-1.0.0.tgz") || store.HasPackage("secret") {
+		t.Fatal("Expected public packages to be replicated and restricted ones to be skipped")
+	}
+	if status := r.Status(); status.Seq != 2 || !status.CaughtUp || status.LagSeconds != 0 {
+		t.Fatalf("Unexpected status after bootstrap: %+v", status)
+	}
+
+	// 新版本和 dist-tag 按主节点的当前状态覆盖本地 metadata，主节点删除的 beta tag 也会删除
+	primary.publish("app", "1.1.0")
+	primary.publish("lib", "0.1.0")
+	if err := r.poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	data, _ := store.GetMetadata("app")
+	var meta map[string]interface{}
+	json.Unmarshal(data, &meta)
+	if tags := meta["dist-tags"].(map[string]interface{}); len(tags) != 1 || tags["latest"] != "1.1.0" {
+		t.Errorf("Expected dist-tags to mirror the primary, got %v", tags)
+	}
+	if !store.HasPackage("lib") || r.Status().Seq != 4 {
+		t.Fatalf("Expected new package to be replicated, status %+v", r.Status())
+	}
+
+	primary.unpublish("lib")
+	if err := r.poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if store.HasPackage("lib") {
+		t.Error("Expected unpublished package to be removed from the replica")
+	}
+
+	var state db.ReplicationState
+	db.DB.Where("source = ?", primary.server.URL).First(&state)
+	if state.Seq != 5 {
+		t.Errorf("Expected persisted seq 5, got %d", state.Seq)
+	}
+
+	// 主节点不可达时返回错误，由 follow 记录并重试
+	primary.server.Close()
+	if err := r.poll(); err == nil {
+		t.Error("Expected poll to fail when the primary is down")
+	}
+}
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// AccessHandler 包访问级别与授权管理 Handler（npm access）
+type AccessHandler struct{}
+
+// NewAccessHandler 创建 AccessHandler
+func NewAccessHandler() *AccessHandler {
+	return &AccessHandler{}
+}
+
+// GetVisibility 获取包的可见性
+// GET /-/package/:name/visibility
+func (h *AccessHandler) GetVisibility(c *gin.Context) {
+	packageName := decodePackageName(c.Param("name"))
+	if !auth.AuthorizeRead(c, packageName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "package not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"public": auth.PackageAccessLevel(packageName) == auth.AccessPublic,
+	})
+}
+
+// SetAccess 设置包的访问级别
+// POST /-/package/:name/access
+func (h *AccessHandler) SetAccess(c *gin.Context) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	packageName := decodePackageName(c.Param("name"))
+	var req struct {
+		Access string `json:"access"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	// npm access set status=private 发送的是 private
+	if req.Access == "private" {
+		req.Access = auth.AccessRestricted
+	}
+	if !auth.ValidAccessLevel(req.Access) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "access must be public or restricted"})
+		return
+	}
+
+	if !canManagePackageAccess(c, user, packageName) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only package owners can change access"})
+		return
+	}
+
+	if err := auth.SetPackageAccess(packageName, req.Access, user.Username); err != nil {
+		logger.Errorf("Failed to set package access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set access"})
+		return
+	}
+
+	db.RecordAudit("package_access", user.Username, c.ClientIP(), fmt.Sprintf("设置 %s 访问级别: %s", packageName, req.Access))
+	c.JSON(http.StatusOK, gin.H{"ok": true, "access": req.Access})
+}
+
+// ListGrants 列出包的授权
+// GET /-/package/:name/grants
+func (h *AccessHandler) ListGrants(c *gin.Context) {
+	packageName := decodePackageName(c.Param("name"))
+	if !auth.AuthorizeRead(c, packageName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "package not found"})
+		return
+	}
+
+	var grants []db.PackageGrant
+	if err := db.DB.Where("package_name = ?", packageName).Preload("User").Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list grants"})
+		return
+	}
+
+	// npm 格式：{ "username": "read-only" }
+	result := make(map[string]string)
+	for _, g := range grants {
+		if g.User != nil {
+			result[g.User.Username] = g.Permission
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GrantAccess 授予用户包的读/写权限
+// PUT /-/package/:name/grants/:username
+func (h *AccessHandler) GrantAccess(c *gin.Context) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	packageName := decodePackageName(c.Param("name"))
+	username := c.Param("username")
+
+	var req struct {
+		Permissions string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if req.Permissions == "" {
+		req.Permissions = auth.PermissionReadOnly
+	}
+	if !auth.ValidPermission(req.Permissions) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "permissions must be read-only or read-write"})
+		return
+	}
+
+	if !canManagePackageAccess(c, user, packageName) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only package owners can grant access"})
+		return
+	}
+
+	var target db.User
+	if err := db.DB.Where("username = ?", username).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var grant db.PackageGrant
+	if err := db.DB.Where("package_name = ? AND user_id = ?", packageName, target.ID).
+		Assign(db.PackageGrant{Permission: req.Permissions}).
+		FirstOrCreate(&grant, db.PackageGrant{PackageName: packageName, UserID: target.ID}).Error; err != nil {
+		logger.Errorf("Failed to grant access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to grant access"})
+		return
+	}
+
+	db.RecordAudit("package_grant", user.Username, c.ClientIP(), fmt.Sprintf("授予 %s 对 %s 的 %s 权限", username, packageName, req.Permissions))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// RevokeAccess 撤销用户对包的授权
+// DELETE /-/package/:name/grants/:username
+func (h *AccessHandler) RevokeAccess(c *gin.Context) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	packageName := decodePackageName(c.Param("name"))
+	username := c.Param("username")
+
+	if !canManagePackageAccess(c, user, packageName) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only package owners can revoke access"})
+		return
+	}
+
+	var target db.User
+	if err := db.DB.Where("username = ?", username).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := db.DB.Where("package_name = ? AND user_id = ?", packageName, target.ID).Delete(&db.PackageGrant{}).Error; err != nil {
+		logger.Errorf("Failed to revoke access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke access"})
+		return
+	}
+
+	db.RecordAudit("package_revoke", user.Username, c.ClientIP(), fmt.Sprintf("撤销 %s 对 %s 的权限", username, packageName))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// ListAccessRules 管理员列出所有包/scope 访问规则
+// GET /-/api/admin/access
+func (h *AccessHandler) ListAccessRules(c *gin.Context) {
+	var rules []db.PackageAccess
+	if err := db.DB.Order("package_name").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list access rules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// SetAccessRule 管理员设置包或 scope 的访问级别
+// PUT /-/api/admin/access
+func (h *AccessHandler) SetAccessRule(c *gin.Context) {
+	var req struct {
+		Name   string `json:"name" binding:"required"` // 包名或 @scope/*
+		Access string `json:"access" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if !auth.ValidAccessLevel(req.Access) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "access must be public or restricted"})
+		return
+	}
+	if strings.Contains(req.Name, "*") && !isScopeRule(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope rules must look like @scope/*"})
+		return
+	}
+
+	username := ""
+	if user := auth.GetCurrentUser(c); user != nil {
+		username = user.Username
+	}
+	if err := auth.SetPackageAccess(req.Name, req.Access, username); err != nil {
+		logger.Errorf("Failed to set access rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set access rule"})
+		return
+	}
+
+	db.RecordAudit("package_access", username, c.ClientIP(), fmt.Sprintf("设置 %s 访问级别: %s", req.Name, req.Access))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// DeleteAccessRule 管理员删除访问规则（恢复为默认）
+// DELETE /-/api/admin/access?name=xxx
+func (h *AccessHandler) DeleteAccessRule(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+
+	db.DB.Where("package_name = ?", name).Delete(&db.PackageAccess{})
+
+	username := ""
+	if user := auth.GetCurrentUser(c); user != nil {
+		username = user.Username
+	}
+	db.RecordAudit("package_access", username, c.ClientIP(), "删除访问规则: "+name)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// canManagePackageAccess 只有 admin 或包 owner 可以修改访问设置，使用 token 时需要 owner 权限
+func canManagePackageAccess(c *gin.Context, user *auth.User, packageName string) bool {
+	if !auth.TokenAllows(c, auth.ActionManageOwners, packageName) {
+		return false
+	}
+	return user.Role == "admin" || auth.IsPackageOwner(user, packageName)
+}
+
+// isScopeRule 检查名称是否为 @scope/* 形式
+func isScopeRule(name string) bool {
+	return strings.HasPrefix(name, "@") && strings.HasSuffix(name, "/*") && strings.Count(name, "/") == 1
+}
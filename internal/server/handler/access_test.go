@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+// setupRestrictedPackageTest 发布受限包 @acme/secret（owner 为 alice）和公开包 @acme/open
+func setupRestrictedPackageTest(t *testing.T) *gin.Engine {
+	t.Helper()
+	dbtest.Open(t, &db.User{}, &db.Package{}, &db.PackageVersion{}, &db.PackageAccess{}, &db.PackageOwner{}, &db.PackageGrant{},
+		&db.Organization{}, &db.OrgMember{}, &db.Team{}, &db.TeamMember{}, &db.TeamGrant{})
+
+	users := make(map[string]*auth.User)
+	for _, name := range []string{"alice", "bob"} {
+		u := &db.User{Username: name, Password: "x", Email: name + "@example.com", Role: "developer"}
+		if err := db.DB.Create(u).Error; err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		users[name] = &auth.User{ID: u.ID, Username: u.Username, Role: u.Role}
+	}
+
+	store := local.New(t.TempDir())
+	for _, name := range []string{"@acme/secret", "@acme/open"} {
+		meta := map[string]interface{}{
+			"name":        name,
+			"description": "acme package",
+			"dist-tags":   map[string]interface{}{"latest": "1.0.0"},
+			"versions":    map[string]interface{}{"1.0.0": map[string]interface{}{"name": name, "version": "1.0.0"}},
+		}
+		data, _ := json.Marshal(meta)
+		store.SaveMetadata(name, data)
+		store.SaveTarball(name, name[len("@acme/"):]+"-1.0.0.tgz", []byte("tarball"))
+		if err := db.IndexPackage(name, meta, func(string) int64 { return 7 }); err != nil {
+			t.Fatalf("IndexPackage failed: %v", err)
+		}
+	}
+	db.DB.Create(&db.PackageOwner{PackageName: "@acme/secret", UserID: users["alice"].ID})
+	if err := auth.SetPackageAccess("@acme/secret", auth.AccessRestricted, "alice"); err != nil {
+		t.Fatalf("SetPackageAccess failed: %v", err)
+	}
+
+	registry := NewRegistryHandler(nil, store, "http://localhost:4874", 30*time.Second)
+	api := NewAPIHandler(store, nil, config.Default(), "test", nil, nil)
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		if user, ok := users[c.GetHeader("X-Test-User")]; ok {
+			c.Set(string(auth.UserKey), user)
+		}
+		// X-Test-Token 模拟只能访问指定包的细粒度 token
+		if scope := c.GetHeader("X-Test-Token"); scope != "" {
+			c.Set(string(auth.TokenKey), &auth.TokenInfo{Granular: true, Packages: []string{scope}})
+		}
+	})
+	router.GET("/-/v1/search", api.NpmSearch)
+	router.GET("/-/api/search", api.SearchPackages)
+	router.GET("/-/api/packages", api.ListPackages)
+	router.GET("/:scope/:name", func(c *gin.Context) {
+		c.Params = append(c.Params, gin.Param{Key: "package", Value: c.Param("scope") + "/" + c.Param("name")})
+		registry.GetPackage(c)
+	})
+	router.GET("/:scope/:name/-/:filename", func(c *gin.Context) {
+		c.Params = append(c.Params, gin.Param{Key: "package", Value: c.Param("scope") + "/" + c.Param("name")})
+		registry.GetTarball(c)
+	})
+	return router
+}
+
+func accessRequest(router *gin.Engine, target, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRestrictedPackage_HiddenFromNonGrantees(t *testing.T) {
+	router := setupRestrictedPackageTest(t)
+
+	for _, user := range []string{"", "bob"} {
+		if w := accessRequest(router, "/@acme/secret", user); w.Code != http.StatusNotFound {
+			t.Errorf("Metadata for %q: expected 404, got %d", user, w.Code)
+		}
+		if w := accessRequest(router, "/@acme/secret/-/secret-1.0.0.tgz", user); w.Code != http.StatusNotFound {
+			t.Errorf("Tarball for %q: expected 404, got %d", user, w.Code)
+		}
+		for _, target := range []string{"/-/v1/search?text=acme", "/-/api/search?q=acme", "/-/api/packages"} {
+			w := accessRequest(router, target, user)
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s for %q: expected 200, got %d", target, user, w.Code)
+			}
+			if strings.Contains(w.Body.String(), "@acme/secret") || !strings.Contains(w.Body.String(), "@acme/open") {
+				t.Errorf("%s for %q: unexpected result %s", target, user, w.Body.String())
+			}
+		}
+		if w := accessRequest(router, "/@acme/open", user); w.Code != http.StatusOK {
+			t.Errorf("Public metadata for %q: expected 200, got %d", user, w.Code)
+		}
+	}
+}
+
+func TestRestrictedPackage_VisibleToOwner(t *testing.T) {
+	router := setupRestrictedPackageTest(t)
+
+	if w := accessRequest(router, "/@acme/secret", "alice"); w.Code != http.StatusOK {
+		t.Errorf("Metadata for owner: expected 200, got %d", w.Code)
+	}
+	if w := accessRequest(router, "/@acme/secret/-/secret-1.0.0.tgz", "alice"); w.Code != http.StatusOK {
+		t.Errorf("Tarball for owner: expected 200, got %d", w.Code)
+	}
+	if w := accessRequest(router, "/-/v1/search?text=acme", "alice"); !strings.Contains(w.Body.String(), "@acme/secret") {
+		t.Errorf("Search for owner: expected the restricted package, got %s", w.Body.String())
+	}
+}
+
+func TestRestrictedPackage_HiddenOutsideTokenScope(t *testing.T) {
+	router := setupRestrictedPackageTest(t)
+
+	for _, target := range []string{"/-/v1/search?text=acme", "/-/api/search?q=acme", "/-/api/packages"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Test-User", "alice")
+		req.Header.Set("X-Test-Token", "@acme/open")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", target, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "@acme/secret") || !strings.Contains(w.Body.String(), "@acme/open") {
+			t.Errorf("%s: token scope not applied, got %s", target, w.Body.String())
+		}
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,31 +13,29 @@ import (
 	"github.com/graperegistry/grape/internal/db"
 	"github.com/graperegistry/grape/internal/logger"
 	"github.com/graperegistry/grape/internal/registry"
+	"github.com/graperegistry/grape/internal/replication"
 	"github.com/graperegistry/grape/internal/storage"
-	"github.com/graperegistry/grape/internal/storage/local"
 )
 
 type APIHandler struct {
 	storage    storage.Storage
-	dataPath   string
-	local      *local.Storage
 	proxy      *registry.Proxy
 	cfg        *config.Config
 	version    string
 	startTime  time.Time
 	applyFn    func(*config.Config)
+	replica    *replication.Replica // 只读副本模式下非 nil
 }
 
-func NewAPIHandler(localStorage *local.Storage, dataPath string, proxy *registry.Proxy, cfg *config.Config, version string, applyFn func(*config.Config)) *APIHandler {
+func NewAPIHandler(storage storage.Storage, proxy *registry.Proxy, cfg *config.Config, version string, applyFn func(*config.Config), replica *replication.Replica) *APIHandler {
 	return &APIHandler{
-		storage:   localStorage,
-		dataPath:  dataPath,
-		local:     localStorage,
+		storage:   storage,
 		proxy:     proxy,
 		cfg:       cfg,
 		version:   version,
 		startTime: time.Now(),
 		applyFn:   applyFn,
+		replica:   replica,
 	}
 }
 
@@ -52,32 +51,41 @@ type PackageInfo struct {
 // ListPackages 列出所有包
 // GET /-/api/packages
 func (h *APIHandler) ListPackages(c *gin.Context) {
-	packages, err := h.storage.ListPackages()
+	packages, err := db.SearchPackageIndex("")
 	if err != nil {
+		logger.Errorf("Failed to list packages: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list packages"})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"packages": readablePackages(c, packages)})
+}
+
+// readablePackages 过滤掉当前用户无权读取的包
+func readablePackages(c *gin.Context, packages []db.Package) []PackageInfo {
+	filter := auth.NewRequestReadFilter(c)
 	result := make([]PackageInfo, 0, len(packages))
 	for _, pkg := range packages {
-		info := PackageInfo{
+		if !filter.CanRead(pkg.Name) {
+			continue
+		}
+		result = append(result, PackageInfo{
 			Name:        pkg.Name,
 			Description: pkg.Description,
-			Version:     pkg.Version,
+			Version:     pkg.Latest,
 			Private:     pkg.Private,
 			UpdatedAt:   pkg.UpdatedAt.Format("2006-01-02 15:04:05"),
-		}
-		result = append(result, info)
+		})
 	}
-
-	c.JSON(http.StatusOK, gin.H{"packages": result})
+	return result
 }
 
 // GetStats 获取统计信息
 // GET /-/api/stats
 func (h *APIHandler) GetStats(c *gin.Context) {
-	stats, err := h.storage.GetStats()
+	stats, err := db.GetPackageIndexStats()
 	if err != nil {
+		logger.Errorf("Failed to get package stats: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get stats"})
 		return
 	}
@@ -86,9 +94,12 @@ func (h *APIHandler) GetStats(c *gin.Context) {
 	storageSizeMB := stats.TotalSize / (1024 * 1024)
 
 	result := gin.H{
-		"totalPackages": stats.TotalPackages,
-		"storageSize":   storageSizeMB,
-		"upstreams":     h.proxy.Upstreams(),
+		"totalPackages":   stats.TotalPackages,
+		"privatePackages": stats.PrivateCount,
+		"cachedPackages":  stats.CachedCount,
+		"totalVersions":   stats.TotalVersions,
+		"storageSize":     storageSizeMB,
+		"upstreams":       h.proxy.Upstreams(),
 	}
 
 	c.JSON(http.StatusOK, result)
@@ -111,29 +122,83 @@ func (h *APIHandler) SearchPackages(c *gin.Context) {
 		return
 	}
 
-	packages, err := h.storage.ListPackages()
+	packages, err := db.SearchPackageIndex(query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list packages"})
+		logger.Errorf("Failed to search packages: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search packages"})
+		return
+	}
+
+	result := readablePackages(c, packages)
+	c.JSON(http.StatusOK, gin.H{
+		"packages": result,
+		"total":    len(result),
+	})
+}
+
+// NpmSearch npm search 兼容接口，支持 scope:<name> 限定符
+// GET /-/v1/search?text=keyword&size=20&from=0
+func (h *APIHandler) NpmSearch(c *gin.Context) {
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if size <= 0 || size > 250 {
+		size = 20
+	}
+	from, _ := strconv.Atoi(c.DefaultQuery("from", "0"))
+	if from < 0 {
+		from = 0
+	}
+
+	scope := ""
+	var terms []string
+	for _, field := range strings.Fields(strings.ToLower(c.Query("text"))) {
+		if name, ok := strings.CutPrefix(field, "scope:"); ok {
+			scope = "@" + strings.TrimPrefix(name, "@") + "/"
+			continue
+		}
+		terms = append(terms, field)
+	}
+	query := strings.Join(terms, " ")
+
+	packages, err := db.SearchPackageIndex(query)
+	if err != nil {
+		logger.Errorf("Failed to search packages: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search packages"})
 		return
 	}
 
-	result := make([]PackageInfo, 0)
+	filter := auth.NewRequestReadFilter(c)
+	objects := make([]gin.H, 0)
 	for _, pkg := range packages {
-		if strings.Contains(strings.ToLower(pkg.Name), query) ||
-			strings.Contains(strings.ToLower(pkg.Description), query) {
-			info := PackageInfo{
-				Name:        pkg.Name,
-				Description: pkg.Description,
-				Version:     pkg.Version,
-				Private:     pkg.Private,
-			}
-			result = append(result, info)
+		if scope != "" && !strings.HasPrefix(strings.ToLower(pkg.Name), scope) {
+			continue
 		}
+		if !filter.CanRead(pkg.Name) {
+			continue
+		}
+		objects = append(objects, gin.H{
+			"package": gin.H{
+				"name":        pkg.Name,
+				"version":     pkg.Latest,
+				"description": pkg.Description,
+				"date":        pkg.UpdatedAt.UTC().Format(time.RFC3339),
+			},
+			"score":       gin.H{"final": 1, "detail": gin.H{"quality": 1, "popularity": 1, "maintenance": 1}},
+			"searchScore": 1,
+		})
 	}
 
+	total := len(objects)
+	if from > total {
+		from = total
+	}
+	end := from + size
+	if end > total {
+		end = total
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"packages": result,
-		"total":    len(result),
+		"objects": objects[from:end],
+		"total":   total,
+		"time":    time.Now().UTC().Format(time.RFC3339),
 	})
 }
 
@@ -148,13 +213,28 @@ func (h *APIHandler) GetSystemInfo(c *gin.Context) {
 		"version":      h.version,
 		"startTime":    h.startTime.UTC().Format(time.RFC3339),
 		"uptime":       uptimeStr,
+		"storageType":  h.cfg.Storage.Type,
 		"storagePath":  h.cfg.Storage.Path,
-		"databasePath": h.cfg.Database.DSN,
+		"databaseType": h.cfg.Database.Type,
+		"databasePath": h.cfg.Database.RedactedDSN(),
 		"host":         fmt.Sprintf("%s:%d", h.cfg.Server.Host, h.cfg.Server.Port),
 		"upstreams":    h.proxy.Upstreams(),
+		"replication":  h.replicationInfo(),
 	})
 }
 
+// replicationInfo 复制模式；副本还包含跟随主节点的进度
+func (h *APIHandler) replicationInfo() gin.H {
+	if h.replica == nil {
+		return gin.H{"mode": config.ReplicationPrimary}
+	}
+	return gin.H{
+		"mode":      config.ReplicationReplica,
+		"writeMode": h.cfg.Replication.WriteMode,
+		"status":    h.replica.Status(),
+	}
+}
+
 // GetConfig 获取可编辑配置
 // GET /-/api/admin/config
 func (h *APIHandler) GetConfig(c *gin.Context) {
@@ -184,6 +264,11 @@ func (h *APIHandler) GetConfig(c *gin.Context) {
 			"jwtSecret":         jwtSecretMasked,
 			"jwtExpiry":         int(h.cfg.Auth.JWTExpiry.Hours()),
 			"allowRegistration": h.cfg.Auth.AllowRegistration,
+			"requireReadAuth":   h.cfg.Auth.RequireReadAuth,
+			"twoFactor": gin.H{
+				"requireForAdmins":     h.cfg.Auth.TwoFactor.RequireForAdmins,
+				"requireForPublishers": h.cfg.Auth.TwoFactor.RequireForPublishers,
+			},
 		},
 		"log": gin.H{
 			"level": h.cfg.Log.Level,
@@ -201,6 +286,11 @@ type UpdateConfigRequest struct {
 		JWTSecret         string `json:"jwtSecret"`
 		JWTExpiry         int    `json:"jwtExpiry"` // 小时
 		AllowRegistration *bool  `json:"allowRegistration"`
+		RequireReadAuth   *bool  `json:"requireReadAuth"`
+		TwoFactor         *struct {
+			RequireForAdmins     *bool `json:"requireForAdmins"`
+			RequireForPublishers *bool `json:"requireForPublishers"`
+		} `json:"twoFactor"`
 	} `json:"auth"`
 	Log *struct {
 		Level string `json:"level"`
@@ -261,6 +351,17 @@ func (h *APIHandler) UpdateConfig(c *gin.Context) {
 		if req.Auth.AllowRegistration != nil {
 			h.cfg.Auth.AllowRegistration = *req.Auth.AllowRegistration
 		}
+		if req.Auth.RequireReadAuth != nil {
+			h.cfg.Auth.RequireReadAuth = *req.Auth.RequireReadAuth
+		}
+		if tf := req.Auth.TwoFactor; tf != nil {
+			if tf.RequireForAdmins != nil {
+				h.cfg.Auth.TwoFactor.RequireForAdmins = *tf.RequireForAdmins
+			}
+			if tf.RequireForPublishers != nil {
+				h.cfg.Auth.TwoFactor.RequireForPublishers = *tf.RequireForPublishers
+			}
+		}
 	}
 
 	// 更新 log 配置
@@ -142,6 +142,8 @@ type LoginRequest struct {
 	Password string `json:"password"`
 	Email    string `json:"email"`
 	Type     string `json:"type"`
+
+	NewPassword string `json:"new_password,omitempty"` // 账号被要求修改密码时，Web UI 随登录请求提交新密码
 }
 
 // LoginResponse npm login 响应格式
@@ -159,6 +161,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	clientIP := c.ClientIP()
 	if !getLoginLimiter().checkLimit(clientIP) {
 		logger.Warnf("Login rate limited for IP: %s", clientIP)
+		recordLoginAttempt(loginResultRateLimited)
 		c.JSON(http.StatusTooManyRequests, gin.H{
 			"error": "too many login attempts, please try again later",
 		})
@@ -190,10 +193,26 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// 检查是登录还是注册
 	existingUser, err := h.userStore.Get(username)
+	if err == auth.ErrUserNotFound && req.Password != "" {
+		// 外部目录（LDAP）用户首次登录时由 Validate 自动创建本地账号
+		validatedUser, verr := h.userStore.Validate(username, req.Password)
+		if verr == nil {
+			existingUser, err = validatedUser, nil
+		} else if verr != auth.ErrUserNotFound {
+			// 目录中存在该用户（密码错误、不在允许的组等）或目录不可用，不能当作新用户注册
+			logger.Warnf("Failed to validate directory user %s: %v", username, verr)
+			db.RecordAudit("login_failed", username, c.ClientIP(), "目录认证失败")
+			recordLoginAttempt(loginResultInvalidCredentials)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+			return
+		}
+	}
 	if err == auth.ErrUserNotFound {
 		// 用户不存在，检查是否允许自助注册
 		if !h.allowRegistration {
 			logger.Warnf("Registration attempted for non-existent user: %s (registration disabled)", username)
+			db.RecordAudit("login_failed", username, c.ClientIP(), "用户不存在")
+			recordLoginAttempt(loginResultUnknownUser)
 			c.JSON(http.StatusForbidden, gin.H{
 				"error": "registration is disabled, contact your administrator",
 			})
@@ -233,18 +252,45 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 验证密码
-	if req.Password != "" {
-		validatedUser, err := h.userStore.Validate(username, req.Password)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	// 锁定期间即使密码正确也拒绝登录
+	if lockedUntil := auth.AccountLockedUntil(existingUser.ID); !lockedUntil.IsZero() {
+		recordLoginAttempt(loginResultAccountLocked)
+		rejectLockedAccount(c, lockedUntil)
+		return
+	}
+
+	// 验证密码（空密码同样需要校验，不能跳过）
+	validatedUser, err := h.userStore.Validate(username, req.Password)
+	if err != nil {
+		// 只有密码错误计入失败次数，LDAP 不可用等错误不应导致账号被锁定
+		if err == auth.ErrInvalidPassword {
+			recordPasswordFailure(c, existingUser)
 			return
 		}
-		existingUser = validatedUser
+		logger.Warnf("Failed to validate password of %s: %v", username, err)
+		recordLoginAttempt(loginResultInvalidCredentials)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return
 	}
+	existingUser = validatedUser
 
-	// 登录成功，重置限流计数
+	// 启用了两步验证的用户需要在 npm-otp 头中提供 OTP
+	if !requireOTP(c, existingUser, auth.OTPLogin) {
+		recordLoginAttempt(loginResultOTP)
+		return
+	}
+
+	// 被要求修改密码的账号必须先设置新密码才能拿到 token
+	if existingUser.MustChangePassword && !h.changeRequiredPassword(c, existingUser, req.Password, req.NewPassword) {
+		recordLoginAttempt(loginResultPasswordChange)
+		return
+	}
+
+	// 登录成功，重置限流计数和账号的失败计数
 	getLoginLimiter().reset(clientIP)
+	if err := auth.UnlockAccount(existingUser.ID); err != nil {
+		logger.Errorf("Failed to reset login failures of %s: %v", username, err)
+	}
 
 	// 更新最后登录时间
 	now := time.Now()
@@ -252,7 +298,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	_ = h.userStore.Update(existingUser)
 
 	// 生成 JWT token
-	token, err := h.jwtService.GenerateToken(existingUser)
+	token, err := h.jwtService.IssueSession(existingUser, auth.SessionMethodPassword, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		logger.Errorf("Failed to generate token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
@@ -261,6 +307,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	logger.Infof("User logged in: %s", username)
 	db.RecordAudit("login", username, c.ClientIP(), "登录成功")
+	recordLoginAttempt(loginResultSuccess)
 
 	c.JSON(http.StatusOK, LoginResponse{
 		OK:    true,
@@ -282,15 +329,43 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 		"username":   user.Username,
 		"email":      user.Email,
 		"role":       user.Role,
+		"authSource": user.AuthSource,
 		"createdAt":  user.CreatedAt,
 		"lastLogin":  user.LastLogin,
 	})
 }
 
-// Logout 用户登出
+// Logout 用户登出，撤销当前 JWT 会话；使用持久化 token 认证时撤销该 token
 // DELETE /-/api/session
+// DELETE /-/user/token/:token (npm logout)
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// JWT 是无状态的，登出只需客户端删除 token
+	// 使用持久化 token 执行 npm logout 时撤销该 token
+	if tokenInfo := auth.GetTokenInfo(c); tokenInfo != nil && tokenInfo.ID != 0 {
+		user := auth.GetCurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		if err := db.DB.Where("id = ? AND user_id = ?", tokenInfo.ID, user.ID).Delete(&db.Token{}).Error; err != nil {
+			logger.Errorf("Failed to revoke token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+			return
+		}
+		db.RecordAudit("token_delete", user.Username, c.ClientIP(), fmt.Sprintf("npm logout 撤销 token '%s' (id: %d)", tokenInfo.Name, tokenInfo.ID))
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+		return
+	}
+
+	if jti := auth.GetSessionID(c); jti != "" {
+		if err := auth.RevokeSessionByJTI(jti); err != nil {
+			logger.Errorf("Failed to revoke session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+			return
+		}
+		if user := auth.GetCurrentUser(c); user != nil {
+			db.RecordAudit("logout", user.Username, c.ClientIP(), "登出")
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
@@ -298,16 +373,23 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 // GET /-/api/admin/users
 func (h *AuthHandler) ListUsers(c *gin.Context) {
 	users := h.userStore.List()
+	locked := auth.LockedAccounts()
 	
 	result := make([]gin.H, 0, len(users))
 	for _, u := range users {
-		result = append(result, gin.H{
-			"username":  u.Username,
-			"email":     u.Email,
-			"role":      u.Role,
-			"createdAt": u.CreatedAt,
-			"lastLogin": u.LastLogin,
-		})
+		item := gin.H{
+			"username":           u.Username,
+			"email":              u.Email,
+			"role":               u.Role,
+			"authSource":         u.AuthSource,
+			"mustChangePassword": u.MustChangePassword,
+			"createdAt":          u.CreatedAt,
+			"lastLogin":          u.LastLogin,
+		}
+		if until, ok := locked[u.ID]; ok {
+			item["lockedUntil"] = until
+		}
+		result = append(result, item)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"users": result})
@@ -321,6 +403,8 @@ func (h *AuthHandler) CreateUser(c *gin.Context) {
 		Password string `json:"password" binding:"required"`
 		Email    string `json:"email"`
 		Role     string `json:"role"`
+
+		MustChangePassword bool `json:"mustChangePassword"` // 首次登录时必须修改密码
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -348,6 +432,8 @@ func (h *AuthHandler) CreateUser(c *gin.Context) {
 		Password: req.Password,
 		Email:    req.Email,
 		Role:     req.Role,
+
+		MustChangePassword: req.MustChangePassword,
 	}
 
 	if err := h.userStore.Create(user); err != nil {
@@ -383,6 +469,8 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 		Email    string `json:"email"`
 		Password string `json:"password"` // 留空则不修改密码
 		Role     string `json:"role"`
+
+		MustChangePassword *bool `json:"mustChangePassword"` // 留空则不修改
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -416,6 +504,10 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// 角色变化、重置密码或要求修改密码后，旧会话全部失效
+	revokeSessions := req.Password != "" || (req.Role != "" && req.Role != existingUser.Role) ||
+		(req.MustChangePassword != nil && *req.MustChangePassword && !existingUser.MustChangePassword)
+
 	// 只更新非空字段
 	if req.Email != "" {
 		existingUser.Email = req.Email
@@ -426,13 +518,35 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 	if req.Password != "" {
 		existingUser.Password = req.Password
 	}
+	if req.MustChangePassword != nil {
+		if *req.MustChangePassword && existingUser.IsExternal() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": auth.ErrExternalPassword.Error()})
+			return
+		}
+		existingUser.MustChangePassword = *req.MustChangePassword
+	}
 
 	if err := h.userStore.Update(existingUser); err != nil {
+		if err == auth.ErrExternalPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Failed to update user: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
 		return
 	}
 
+	if revokeSessions {
+		// 管理员修改自己的账号时保留当前会话
+		exceptJTI := ""
+		if current := auth.GetCurrentUser(c); current != nil && current.ID == existingUser.ID {
+			exceptJTI = auth.GetSessionID(c)
+		}
+		if _, err := auth.RevokeUserSessions(existingUser.ID, exceptJTI); err != nil {
+			logger.Errorf("Failed to revoke sessions of %s: %v", username, err)
+		}
+	}
+
 	logger.Infof("Admin updated user: %s", username)
 	c.JSON(http.StatusOK, gin.H{
 		"ok":       true,
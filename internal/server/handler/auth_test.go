@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
 )
 
 func setupTestRouter() *gin.Engine {
@@ -18,31 +21,16 @@ func setupTestRouter() *gin.Engine {
 }
 
 func TestLoginHandler(t *testing.T) {
-	router := setupTestRouter()
-	
-	// 初始化 JWT 服务
+	dbtest.Open(t, &db.User{}, &db.UserSession{}, &db.AuditLog{}, &db.AccountLockout{}, &db.UserTwoFactor{})
+	store := auth.NewDBUserStore()
+	if err := store.Create(&auth.User{Username: "admin", Password: "Admin-Pass-123", Role: "admin"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
 	jwtService := auth.NewJWTService("test-secret", time.Hour)
-	
-	// 注册路由
-	router.PUT("/-/user/:username", func(c *gin.Context) {
-		// 简化的登录处理
-		var req struct {
-			Name     string `json:"name"`
-			Password string `json:"password"`
-		}
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": "invalid request"})
-			return
-		}
-		
-		// 测试用户验证
-		if req.Name == "admin" && req.Password == "admin" {
-			token, _ := jwtService.GenerateToken(&auth.User{Username: req.Name, Role: "admin"})
-			c.JSON(201, gin.H{"token": token})
-		} else {
-			c.JSON(401, gin.H{"error": "invalid credentials"})
-		}
-	})
+	h := NewAuthHandler(store, jwtService, false)
+
+	router := setupTestRouter()
+	router.PUT("/-/user/:username", h.Login)
 
 	tests := []struct {
 		name       string
@@ -54,8 +42,8 @@ func TestLoginHandler(t *testing.T) {
 		{
 			name:       "valid credentials",
 			username:   "admin",
-			password:   "admin",
-			wantStatus: 201,
+			password:   "Admin-Pass-123",
+			wantStatus: 200,
 			wantToken:  true,
 		},
 		{
@@ -80,7 +68,7 @@ func TestLoginHandler(t *testing.T) {
 				"name":     tt.username,
 				"password": tt.password,
 			})
-			req := httptest.NewRequest("PUT", "/-/user/"+tt.username, bytes.NewBuffer(body))
+			req := httptest.NewRequest("PUT", "/-/user/org.couchdb.user:"+tt.username, bytes.NewBuffer(body))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 			
@@ -158,4 +146,88 @@ func TestAuthMiddleware(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// directoryUserStore 模拟 LDAP：目录中的用户没有本地账号，密码在目录中校验
+type directoryUserStore struct {
+	*auth.DBUserStore
+	directory map[string]string
+}
+
+func (s *directoryUserStore) Validate(username, password string) (*auth.User, error) {
+	expected, ok := s.directory[username]
+	if !ok {
+		return s.DBUserStore.Validate(username, password)
+	}
+	if password != expected {
+		return nil, auth.ErrInvalidPassword
+	}
+	if user, err := s.Get(username); err == nil {
+		return user, nil
+	}
+	user := &auth.User{Username: username, Password: "Random-Pass-123", Role: "developer", AuthSource: auth.AuthSourceLDAP}
+	if err := s.Create(user); err != nil {
+		return nil, err
+	}
+	return s.Get(username)
+}
+
+func TestLogin_DirectoryUserIsNotRegistered(t *testing.T) {
+	dbtest.Open(t, &db.User{}, &db.UserSession{}, &db.AuditLog{}, &db.AccountLockout{})
+	store := &directoryUserStore{DBUserStore: auth.NewDBUserStore(), directory: map[string]string{"carol": "Directory-Pass-1"}}
+	h := NewAuthHandler(store, auth.NewJWTService("test-secret", time.Hour), true)
+	router := setupTestRouter()
+	router.PUT("/-/user/:username", h.Login)
+
+	login := func(name, password string) int {
+		body, _ := json.Marshal(map[string]string{"name": name, "password": password})
+		req := httptest.NewRequest("PUT", "/-/user/org.couchdb.user:"+name, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 目录用户首次登录时密码错误不能走自助注册
+	if code := login("carol", "Wrong-Pass-123"); code != 401 {
+		t.Fatalf("Wrong directory password: expected 401, got %d", code)
+	}
+	if _, err := store.Get("carol"); err != auth.ErrUserNotFound {
+		t.Fatalf("Directory user was registered with a wrong password: %v", err)
+	}
+
+	if code := login("carol", "Directory-Pass-1"); code != 200 {
+		t.Fatalf("Directory login: expected 200, got %d", code)
+	}
+	// 目录中不存在的用户仍可自助注册
+	if code := login("dave", "Local-Pass-123"); code != 200 {
+		t.Fatalf("Self registration: expected 200, got %d", code)
+	}
+}
+
+func TestLogout_RevokesPersistentToken(t *testing.T) {
+	dbtest.Open(t, &db.User{}, &db.Token{}, &db.UserSession{}, &db.AuditLog{})
+	alice := &db.User{Username: "alice", Password: "x", Role: "developer"}
+	db.DB.Create(alice)
+	token := &db.Token{UserID: alice.ID, Name: "ci", TokenHash: strings.Repeat("a", 64)}
+	db.DB.Create(token)
+
+	h := NewAuthHandler(auth.NewDBUserStore(), auth.NewJWTService("test-secret", time.Hour), false)
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set(string(auth.UserKey), &auth.User{ID: alice.ID, Username: alice.Username, Role: alice.Role})
+		c.Set(string(auth.TokenKey), &auth.TokenInfo{ID: token.ID, Name: token.Name})
+		c.Set(string(auth.IsTokenAuth), true)
+	})
+	router.DELETE("/-/user/token/*token", h.Logout)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/-/user/token/secret", nil))
+	if w.Code != 200 {
+		t.Fatalf("npm logout: expected 200, got %d", w.Code)
+	}
+	var count int64
+	db.DB.Model(&db.Token{}).Where("id = ?", token.ID).Count(&count)
+	if count != 0 {
+		t.Error("Expected npm logout to revoke the persistent token")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -14,16 +15,21 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/graperegistry/grape/internal/db"
 	"github.com/graperegistry/grape/internal/logger"
+	"github.com/graperegistry/grape/internal/storage"
 )
 
+// backupPackagesDir 备份文件中包文件的目录，与本地存储的目录结构一致
+const backupPackagesDir = "data/packages/"
+
 // BackupHandler 备份恢复 Handler
 type BackupHandler struct {
-	dataDir string
+	storage storage.Storage
+	dataDir string // 数据库文件和恢复前自动备份所在的目录
 }
 
 // NewBackupHandler 创建 BackupHandler
-func NewBackupHandler(dataDir string) *BackupHandler {
-	return &BackupHandler{dataDir: dataDir}
+func NewBackupHandler(storage storage.Storage, dataDir string) *BackupHandler {
+	return &BackupHandler{storage: storage, dataDir: dataDir}
 }
 
 // BackupInfo 备份信息
@@ -32,23 +38,27 @@ type BackupInfo struct {
 	StorageSize   int64  `json:"storageSize"`
 	DatabaseSize  int64  `json:"databaseSize"`
 	DataDir       string `json:"dataDir"`
+	Database      string `json:"database"`  // sqlite | postgres
+	Supported     bool   `json:"supported"` // 当前数据库是否支持在线备份恢复
+}
+
+// errBackupUnsupported PostgreSQL 的数据不在数据目录中，在线备份无法包含数据库
+const errBackupUnsupported = "backup and restore only support SQLite, back up PostgreSQL with pg_dump and the storage directory separately"
+
+// backupSupported 备份只包含数据目录中的 SQLite 数据库文件
+func backupSupported() bool {
+	return db.Dialect(db.DB) == "sqlite"
 }
 
 // GetBackupInfo 获取备份信息
 // GET /-/api/admin/backup/info
 func (h *BackupHandler) GetBackupInfo(c *gin.Context) {
-	// 统计包数量
-	var totalPackages int64
-	db.DB.Model(&db.Package{}).Count(&totalPackages)
-
-	// 计算存储大小
-	var storageSize int64
-	filepath.Walk(filepath.Join(h.dataDir, "packages"), func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() {
-			storageSize += info.Size()
-		}
-		return nil
-	})
+	// 包数量和 tarball 大小来自包索引
+	stats, err := db.GetPackageIndexStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get package stats"})
+		return
+	}
 
 	// 数据库大小
 	var dbSize int64
@@ -58,16 +68,23 @@ func (h *BackupHandler) GetBackupInfo(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, BackupInfo{
-		TotalPackages: int(totalPackages),
-		StorageSize:   storageSize,
+		TotalPackages: int(stats.TotalPackages),
+		StorageSize:   stats.TotalSize,
 		DatabaseSize:  dbSize,
 		DataDir:       h.dataDir,
+		Database:      db.Dialect(db.DB),
+		Supported:     backupSupported(),
 	})
 }
 
 // CreateBackup 创建备份并下载
 // GET /-/api/admin/backup/download
 func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	if !backupSupported() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errBackupUnsupported})
+		return
+	}
+
 	// 创建临时文件
 	tmpFile, err := os.CreateTemp("", "grape-backup-*.tar.gz")
 	if err != nil {
@@ -81,12 +98,9 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 	gzWriter := gzip.NewWriter(tmpFile)
 	tarWriter := tar.NewWriter(gzWriter)
 
-	// 添加数据目录
-	dataPath := filepath.Join(h.dataDir, "packages")
-	if _, err := os.Stat(dataPath); err == nil {
-		if err := h.addDirectoryToTar(tarWriter, dataPath, "data/packages"); err != nil {
-			logger.Warnf("Failed to backup packages: %v", err)
-		}
+	// 添加包文件
+	if err := h.addPackagesToTar(tarWriter); err != nil {
+		logger.Warnf("Failed to backup packages: %v", err)
 	}
 
 	// 添加数据库
@@ -136,6 +150,11 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 // RestoreBackup 从上传的文件恢复
 // POST /-/api/admin/backup/restore
 func (h *BackupHandler) RestoreBackup(c *gin.Context) {
+	if !backupSupported() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errBackupUnsupported})
+		return
+	}
+
 	// 获取上传的文件
 	file, _, err := c.Request.FormFile("file")
 	if err != nil {
@@ -177,8 +196,25 @@ func (h *BackupHandler) RestoreBackup(c *gin.Context) {
 			continue
 		}
 
-		// 去掉 data/ 前缀
+		// 包文件通过存储写入
+		if strings.HasPrefix(header.Name, backupPackagesDir) {
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := h.restorePackageFile(strings.TrimPrefix(header.Name, backupPackagesDir), tarReader); err != nil {
+				logger.Warnf("Failed to restore %s: %v", header.Name, err)
+				continue
+			}
+			restored++
+			continue
+		}
+
+		// 去掉 data/ 前缀，不允许写到数据目录之外
 		targetPath := filepath.Join(h.dataDir, strings.TrimPrefix(header.Name, "data/"))
+		if !strings.HasPrefix(targetPath, filepath.Clean(h.dataDir)+string(filepath.Separator)) {
+			logger.Warnf("Skipping backup entry outside the data directory: %s", header.Name)
+			continue
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -248,43 +284,55 @@ func (h *BackupHandler) ListBackups(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"backups": backups})
 }
 
-func (h *BackupHandler) addDirectoryToTar(tw *tar.Writer, srcPath, destPath string) error {
-	return filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+// addPackagesToTar 把存储中的全部包写入备份，目录结构为 data/packages/<name>/metadata.json
+// 和 data/packages/<name>/tarballs/<filename>
+func (h *BackupHandler) addPackagesToTar(tw *tar.Writer) error {
+	names, err := h.storage.ListPackageNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		metadata, err := h.storage.GetMetadata(name)
 		if err != nil {
-			return err
-		}
-
-		if strings.HasSuffix(path, ".tmp") {
-			return nil
+			logger.Warnf("Failed to backup metadata of %s: %v", name, err)
+			continue
 		}
+		h.addBytesToTar(tw, path.Join(backupPackagesDir, name, "metadata.json"), metadata)
 
-		relPath, err := filepath.Rel(srcPath, path)
+		filenames, err := h.storage.ListTarballs(name)
 		if err != nil {
-			return err
+			logger.Warnf("Failed to list tarballs of %s: %v", name, err)
+			continue
+		}
+		for _, filename := range filenames {
+			data, err := h.storage.GetTarball(name, filename)
+			if err != nil {
+				logger.Warnf("Failed to backup tarball %s/%s: %v", name, filename, err)
+				continue
+			}
+			h.addBytesToTar(tw, path.Join(backupPackagesDir, name, "tarballs", filename), data)
 		}
-		tarPath := filepath.Join(destPath, relPath)
+	}
+	return nil
+}
 
-		header, err := tar.FileInfoHeader(info, "")
+// restorePackageFile 把备份中 data/packages/ 下的一个文件写入存储，其他文件（如 .tmp）被忽略
+func (h *BackupHandler) restorePackageFile(rel string, r io.Reader) error {
+	if name, ok := strings.CutSuffix(rel, "/metadata.json"); ok {
+		data, err := io.ReadAll(r)
 		if err != nil {
 			return err
 		}
-		header.Name = tarPath
-
-		if err := tw.WriteHeader(header); err != nil {
+		return h.storage.SaveMetadata(name, data)
+	}
+	if i := strings.LastIndex(rel, "/tarballs/"); i > 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
 			return err
 		}
-
-		if !info.IsDir() {
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-			io.Copy(tw, file)
-		}
-
-		return nil
-	})
+		return h.storage.SaveTarball(rel[:i], rel[i+len("/tarballs/"):], data)
+	}
+	return nil
 }
 
 func (h *BackupHandler) addFileToTar(tw *tar.Writer, srcPath, destPath string) error {
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+// PostgreSQL 的数据不在数据目录中，备份恢复只在 SQLite 下可用
+func TestBackup_OnlySupportsSQLite(t *testing.T) {
+	dbtest.Open(t, &db.Package{}, &db.PackageVersion{}, &db.AuditLog{})
+	dataDir := t.TempDir()
+	h := NewBackupHandler(local.New(dataDir), dataDir)
+	router := setupTestRouter()
+	router.GET("/-/api/admin/backup/info", h.GetBackupInfo)
+	router.GET("/-/api/admin/backup/download", h.CreateBackup)
+	router.POST("/-/api/admin/backup/restore", h.RestoreBackup)
+
+	sqlite := db.Dialect(db.DB) == "sqlite"
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/api/admin/backup/info", nil))
+	var info BackupInfo
+	json.Unmarshal(w.Body.Bytes(), &info)
+	if w.Code != http.StatusOK || info.Supported != sqlite || info.Database != db.Dialect(db.DB) {
+		t.Fatalf("Unexpected backup info: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/api/admin/backup/download", nil))
+	if sqlite && w.Code != http.StatusOK {
+		t.Errorf("SQLite backup: expected 200, got %d", w.Code)
+	}
+	if !sqlite && w.Code != http.StatusBadRequest {
+		t.Errorf("PostgreSQL backup: expected 400, got %d", w.Code)
+	}
+
+	if !sqlite {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/-/api/admin/backup/restore", nil))
+		if w.Code != http.StatusBadRequest || !json.Valid(w.Body.Bytes()) {
+			t.Errorf("PostgreSQL restore: expected 400, got %d", w.Code)
+		}
+	}
+}
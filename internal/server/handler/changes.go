@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+const (
+	defaultChangesLimit = 1000
+	maxChangesLimit     = 10000
+
+	// changesRecheckInterval longpoll 期间重新查询数据库的间隔：ChangesNotify 只能唤醒本进程写入的变更，
+	// 多个实例共用数据库时依靠定期查询发现其他实例写入的变更
+	changesRecheckInterval = 5 * time.Second
+)
+
+// changeResult CouchDB _changes 格式的一条结果，附带 Grape 的变更类型
+type changeResult struct {
+	Seq     uint64              `json:"seq"`
+	ID      string              `json:"id"`
+	Changes []map[string]string `json:"changes"`
+	Deleted bool                `json:"deleted,omitempty"`
+	Type    string              `json:"type"`
+	Version string              `json:"version,omitempty"`
+	Tag     string              `json:"tag,omitempty"`
+	Doc     json.RawMessage     `json:"doc,omitempty"`
+}
+
+// recordChange 写入包变更日志，失败不影响请求本身
+func recordChange(change *db.PackageChange) {
+	if err := db.RecordChange(change); err != nil {
+		logger.Warnf("Failed to record change for %s: %v", change.PackageName, err)
+	}
+}
+
+// sortedVersions 返回排序后的版本号，使同一次发布的变更顺序稳定
+func sortedVersions(versions map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(versions))
+	for v := range versions {
+		keys = append(keys, v)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Changes 返回包变更日志，兼容 CouchDB 的 _changes 接口
+// GET /-/_changes?since=&limit=&feed=normal|longpoll&timeout=&include_docs=
+func (h *RegistryHandler) Changes(c *gin.Context) {
+	var since uint64
+	switch s := c.Query("since"); s {
+	case "", "0":
+	case "now":
+		seq, err := db.LastChangeSeq()
+		if err != nil {
+			logger.Errorf("Failed to query last change: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query changes"})
+			return
+		}
+		since = seq
+	default:
+		seq, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+		since = seq
+	}
+
+	limit := defaultChangesLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = min(n, maxChangesLimit)
+	}
+
+	feed := c.DefaultQuery("feed", "normal")
+	if feed != "normal" && feed != "longpoll" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported feed: %s", feed)})
+		return
+	}
+
+	timeout := h.longpollTimeout
+	if s := c.Query("timeout"); s != "" {
+		ms, err := strconv.Atoi(s)
+		if err != nil || ms < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timeout"})
+			return
+		}
+		timeout = min(time.Duration(ms)*time.Millisecond, h.longpollTimeout)
+	}
+	includeDocs := c.Query("include_docs") == "true"
+
+	filter := auth.NewRequestReadFilter(c)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	recheck := time.NewTicker(changesRecheckInterval)
+	defer recheck.Stop()
+
+	results := []changeResult{}
+	for {
+		// 先取通知 channel 再查询，避免漏掉两者之间写入的变更
+		notify := db.ChangesNotify()
+		changes, err := db.ListChanges(since, limit)
+		if err != nil {
+			logger.Errorf("Failed to query changes: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query changes"})
+			return
+		}
+		for _, change := range changes {
+			// 不可读的包也推进 last_seq，跟随者不会反复拉取同一段
+			since = change.Seq
+			if !filter.CanRead(change.PackageName) {
+				continue
+			}
+			results = append(results, h.changeResult(c, change, includeDocs))
+		}
+		if len(results) > 0 || feed != "longpoll" {
+			break
+		}
+		if len(changes) == limit {
+			continue
+		}
+		select {
+		case <-notify:
+			continue
+		case <-recheck.C:
+			continue
+		case <-deadline.C:
+		case <-c.Request.Context().Done():
+			return
+		}
+		break
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results, "last_seq": since})
+}
+
+func (h *RegistryHandler) changeResult(c *gin.Context, change db.PackageChange, includeDocs bool) changeResult {
+	result := changeResult{
+		Seq:     change.Seq,
+		ID:      change.PackageName,
+		Changes: []map[string]string{{"rev": fmt.Sprintf("%d-%s", change.Seq, change.Type)}},
+		Deleted: change.Type == db.ChangeUnpublish && change.Version == "", // 删除单个版本时包仍然存在
+		Type:    change.Type,
+		Version: change.Version,
+		Tag:     change.Tag,
+	}
+	if !includeDocs || result.Deleted || !h.storage.HasPackage(change.PackageName) {
+		return result
+	}
+	data, err := h.storage.GetMetadata(change.PackageName)
+	if err != nil {
+		logger.Warnf("Failed to read metadata for %s: %v", change.PackageName, err)
+		return result
+	}
+	if rewritten, err := h.rewriteTarballURLs(data, change.PackageName, requestBaseURL(c)); err == nil {
+		data = rewritten
+	}
+	result.Doc = data
+	return result
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+type changesResponse struct {
+	Results []changeResult `json:"results"`
+	LastSeq uint64         `json:"last_seq"`
+}
+
+func TestChanges(t *testing.T) {
+	dbtest.Open(t, &db.PackageChange{}, &db.PackageAccess{})
+
+	store := local.New(t.TempDir())
+	store.SaveMetadata("foo", []byte(`{"name":"foo","versions":{"1.0.0":{"dist":{"tarball":"http://old/foo/-/foo-1.0.0.tgz"}}}}`))
+	h := NewRegistryHandler(nil, store, "http://localhost:4874", 30*time.Second)
+	router := setupTestRouter()
+	router.GET("/-/_changes", h.Changes)
+
+	get := func(query string) changesResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/_changes"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp changesResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	recordChange(&db.PackageChange{PackageName: "foo", Type: db.ChangePublish, Version: "1.0.0"})
+	recordChange(&db.PackageChange{PackageName: "foo", Type: db.ChangeDistTag, Version: "1.0.0", Tag: "beta"})
+	recordChange(&db.PackageChange{PackageName: "bar", Type: db.ChangeUnpublish})
+
+	resp := get("")
+	if len(resp.Results) != 3 || resp.LastSeq != 3 || !resp.Results[2].Deleted || resp.Results[1].Tag != "beta" {
+		t.Fatalf("Unexpected changes: %+v", resp)
+	}
+	resp = get("?since=1&limit=1&include_docs=true")
+	if len(resp.Results) != 1 || resp.LastSeq != 2 || len(resp.Results[0].Doc) == 0 {
+		t.Fatalf("Unexpected paged changes: %+v", resp)
+	}
+	if resp = get("?since=now"); len(resp.Results) != 0 || resp.LastSeq != 3 {
+		t.Fatalf("Expected no changes since now, got %+v", resp)
+	}
+
+	// longpoll 在新变更写入时返回
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		recordChange(&db.PackageChange{PackageName: "foo", Type: db.ChangeDeprecate, Version: "1.0.0"})
+	}()
+	started := time.Now()
+	resp = get("?since=3&feed=longpoll&timeout=5000")
+	if len(resp.Results) != 1 || resp.LastSeq != 4 || time.Since(started) > 4*time.Second {
+		t.Fatalf("Expected longpoll to return the new change, got %+v", resp)
+	}
+	if resp = get("?since=4&feed=longpoll&timeout=10"); len(resp.Results) != 0 || resp.LastSeq != 4 {
+		t.Fatalf("Expected empty longpoll result on timeout, got %+v", resp)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/_changes?feed=continuous", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unsupported feed, got %d", w.Code)
+	}
+}
@@ -0,0 +1,402 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+	"github.com/graperegistry/grape/internal/webhook"
+)
+
+// loadMetadata 读取并解析包元数据
+func (h *PublishHandler) loadMetadata(packageName string) (map[string]interface{}, error) {
+	data, err := h.storage.GetMetadata(packageName)
+	if err != nil {
+		return nil, err
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// saveMetadata 更新 modified 时间并保存包元数据
+func (h *PublishHandler) saveMetadata(packageName string, meta map[string]interface{}) error {
+	if timeMap, ok := meta["time"].(map[string]interface{}); ok {
+		timeMap["modified"] = time.Now().UTC().Format(time.RFC3339)
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := h.storage.SaveMetadata(packageName, data); err != nil {
+		return err
+	}
+	indexPackage(h.storage, packageName, data)
+	return nil
+}
+
+// updateMetadata 处理不带附件的元数据更新：已有版本的 deprecated 字段（npm deprecate）、
+// maintainers（npm owner add/rm）以及请求中去掉的版本（npm unpublish <pkg>@<version>），调用方需持有包锁
+func (h *PublishHandler) updateMetadata(c *gin.Context, user *auth.User, packageName string, req *PublishRequest) {
+	meta, err := h.loadMetadata(packageName)
+	if err != nil {
+		logger.Errorf("Failed to load metadata: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read package metadata"})
+		return
+	}
+	versions, _ := meta["versions"].(map[string]interface{})
+
+	deprecations := make(map[string]string)
+	for ver, info := range req.Versions {
+		existing, ok := versions[ver].(map[string]interface{})
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("version %s has no tarball attached", ver),
+			})
+			return
+		}
+		message, _ := info["deprecated"].(string)
+		if old, _ := existing["deprecated"].(string); message != old {
+			deprecations[ver] = message
+		}
+	}
+
+	// npm unpublish <pkg>@<version> 先提交去掉该版本的完整元数据，再删除 tarball；
+	// 请求中没有任何版本时不是 unpublish（删除最后一个版本时 npm 会删除整个包）
+	var unpublished []string
+	if len(req.Versions) > 0 {
+		for ver := range versions {
+			if _, ok := req.Versions[ver]; !ok {
+				unpublished = append(unpublished, ver)
+			}
+		}
+		sort.Strings(unpublished)
+	}
+
+	// 只有 maintainers 与当前元数据不一致时才视为 owner 变更（npm deprecate 会原样带回 maintainers）
+	var added, removed []string
+	stored, _ := meta["maintainers"].([]interface{})
+	if req.Maintainers != nil && !sameNames(auth.MaintainerNames(req.Maintainers), auth.MaintainerNames(stored)) {
+		added, removed, err = h.diffMaintainers(packageName, auth.MaintainerNames(req.Maintainers))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if len(deprecations) > 0 && !h.authz.Authorize(c, packageName, auth.ActionDeprecate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not an owner of this package"})
+		return
+	}
+	if len(added)+len(removed) > 0 && !h.authz.Authorize(c, packageName, auth.ActionManageOwners) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only package owners can change maintainers"})
+		return
+	}
+	if len(unpublished) > 0 && !h.authz.Authorize(c, packageName, auth.ActionUnpublish) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions to unpublish this package"})
+		return
+	}
+
+	if len(deprecations)+len(unpublished) > 0 {
+		for ver, message := range deprecations {
+			existing := versions[ver].(map[string]interface{})
+			if message == "" {
+				delete(existing, "deprecated")
+			} else {
+				existing["deprecated"] = message
+			}
+		}
+		removeVersions(meta, unpublished)
+		if err := h.saveMetadata(packageName, meta); err != nil {
+			logger.Errorf("Failed to save metadata: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save metadata"})
+			return
+		}
+		if len(deprecations) > 0 {
+			db.RecordAudit("package_deprecate", user.Username, c.ClientIP(),
+				fmt.Sprintf("修改 %s 的 %d 个版本的废弃信息", packageName, len(deprecations)))
+		}
+		for ver := range deprecations {
+			recordChange(&db.PackageChange{PackageName: packageName, Type: db.ChangeDeprecate, Version: ver})
+		}
+		h.recordVersionUnpublish(c, user, packageName, unpublished)
+	}
+
+	for _, username := range added {
+		var target db.User
+		db.DB.Where("username = ?", username).First(&target)
+		if err := h.authz.AddOwner(packageName, target.ID); err != nil {
+			logger.Errorf("Failed to add owner: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add owner"})
+			return
+		}
+		db.RecordAudit("package_owner_add", user.Username, c.ClientIP(), fmt.Sprintf("Added %s as owner of %s", username, packageName))
+	}
+	for _, username := range removed {
+		var target db.User
+		db.DB.Where("username = ?", username).First(&target)
+		if err := h.authz.RemoveOwner(packageName, target.ID); err != nil {
+			logger.Errorf("Failed to remove owner: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove owner"})
+			return
+		}
+		db.RecordAudit("package_owner_remove", user.Username, c.ClientIP(), fmt.Sprintf("Removed %s as owner of %s", username, packageName))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true, "success": true})
+}
+
+// removeVersions 从元数据中删除版本及其发布时间；指向被删版本的 dist-tag 一并删除，
+// latest 被删除时改为剩余版本中最近发布的版本
+func removeVersions(meta map[string]interface{}, removed []string) {
+	if len(removed) == 0 {
+		return
+	}
+	versions, _ := meta["versions"].(map[string]interface{})
+	times, _ := meta["time"].(map[string]interface{})
+	gone := make(map[string]bool, len(removed))
+	for _, ver := range removed {
+		gone[ver] = true
+		delete(versions, ver)
+		delete(times, ver)
+	}
+
+	tags, _ := meta["dist-tags"].(map[string]interface{})
+	latestRemoved := false
+	for tag, ver := range tags {
+		if v, _ := ver.(string); gone[v] {
+			delete(tags, tag)
+			latestRemoved = latestRemoved || tag == "latest"
+		}
+	}
+	if !latestRemoved || len(versions) == 0 {
+		return
+	}
+	newest, newestTime := "", ""
+	for ver := range versions {
+		published, _ := times[ver].(string)
+		if newest == "" || published > newestTime || (published == newestTime && ver > newest) {
+			newest, newestTime = ver, published
+		}
+	}
+	if tags == nil {
+		tags = make(map[string]interface{})
+		meta["dist-tags"] = tags
+	}
+	tags["latest"] = newest
+}
+
+// recordVersionUnpublish 记录删除单个版本的审计日志、变更和 webhook
+func (h *PublishHandler) recordVersionUnpublish(c *gin.Context, user *auth.User, packageName string, versions []string) {
+	for _, ver := range versions {
+		logger.Infof("Unpublished version: %s@%s by user: %s", packageName, ver, user.Username)
+		db.RecordAudit("package_unpublish", user.Username, c.ClientIP(), fmt.Sprintf("删除版本: %s@%s", packageName, ver))
+		recordChange(&db.PackageChange{PackageName: packageName, Type: db.ChangeUnpublish, Version: ver})
+	}
+	if len(versions) > 0 {
+		h.dispatcher.Dispatch(webhook.EventPackageUnpublished, gin.H{
+			"package":  packageName,
+			"versions": versions,
+			"operator": user.Username,
+		})
+	}
+}
+
+// diffMaintainers 比较期望的 maintainers 与 package_owners 中的精确 owner，返回需要添加和移除的用户名
+func (h *PublishHandler) diffMaintainers(packageName string, desired []string) (added, removed []string, err error) {
+	if len(desired) == 0 {
+		return nil, nil, fmt.Errorf("a package must have at least one maintainer")
+	}
+
+	var owners []db.PackageOwner
+	if err := db.DB.Where("package_name = ?", packageName).Preload("User").Find(&owners).Error; err != nil {
+		return nil, nil, err
+	}
+	current := make(map[string]bool)
+	for _, owner := range owners {
+		if owner.User != nil {
+			current[owner.User.Username] = true
+		}
+	}
+
+	want := make(map[string]bool)
+	for _, name := range desired {
+		if want[name] {
+			continue
+		}
+		want[name] = true
+		if !current[name] {
+			var count int64
+			db.DB.Model(&db.User{}).Where("username = ?", name).Count(&count)
+			if count == 0 {
+				return nil, nil, fmt.Errorf("user %s not found", name)
+			}
+			added = append(added, name)
+		}
+	}
+	for name := range current {
+		if !want[name] {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed, nil
+}
+
+// sameNames 比较两个用户名列表是否包含相同的用户（忽略顺序与重复）
+func sameNames(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, name := range a {
+		set[name] = true
+	}
+	other := make(map[string]bool, len(b))
+	for _, name := range b {
+		if !set[name] {
+			return false
+		}
+		other[name] = true
+	}
+	return len(set) == len(other)
+}
+
+// ListDistTags 列出包的 dist-tags
+// GET /-/package/:name/dist-tags
+func (h *PublishHandler) ListDistTags(c *gin.Context) {
+	packageName := decodePackageName(c.Param("name"))
+	if !h.storage.HasPackage(packageName) || !auth.AuthorizeRead(c, packageName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "package not found"})
+		return
+	}
+
+	meta, err := h.loadMetadata(packageName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read package metadata"})
+		return
+	}
+	tags, ok := meta["dist-tags"].(map[string]interface{})
+	if !ok {
+		tags = map[string]interface{}{}
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// SetDistTag 设置 dist-tag，请求体为 JSON 字符串形式的版本号
+// PUT /-/package/:name/dist-tags/:tag
+func (h *PublishHandler) SetDistTag(c *gin.Context) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if tokenInfo := auth.GetTokenInfo(c); tokenInfo != nil && tokenInfo.Readonly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "read-only token cannot change dist-tags"})
+		return
+	}
+	if !requireOTP(c, user, auth.OTPWrite) {
+		return
+	}
+
+	packageName := decodePackageName(c.Param("name"))
+	tag := c.Param("tag")
+
+	var version string
+	if err := c.ShouldBindJSON(&version); err != nil || version == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version required"})
+		return
+	}
+
+	h.changeDistTag(c, user, packageName, tag, version)
+}
+
+// DeleteDistTag 删除 dist-tag（latest 不可删除）
+// DELETE /-/package/:name/dist-tags/:tag
+func (h *PublishHandler) DeleteDistTag(c *gin.Context) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if tokenInfo := auth.GetTokenInfo(c); tokenInfo != nil && tokenInfo.Readonly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "read-only token cannot change dist-tags"})
+		return
+	}
+	if !requireOTP(c, user, auth.OTPWrite) {
+		return
+	}
+
+	tag := c.Param("tag")
+	if tag == "latest" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot delete the latest tag"})
+		return
+	}
+
+	h.changeDistTag(c, user, decodePackageName(c.Param("name")), tag, "")
+}
+
+// changeDistTag 设置或删除（version 为空）dist-tag
+func (h *PublishHandler) changeDistTag(c *gin.Context, user *auth.User, packageName, tag, version string) {
+	if !h.storage.HasPackage(packageName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "package not found"})
+		return
+	}
+	if !h.authz.Authorize(c, packageName, auth.ActionDistTag) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not an owner of this package"})
+		return
+	}
+
+	lock := h.getPackageLock(packageName)
+	lock.Lock()
+	defer func() {
+		lock.Unlock()
+		h.releasePackageLock(packageName)
+	}()
+
+	meta, err := h.loadMetadata(packageName)
+	if err != nil {
+		logger.Errorf("Failed to load metadata: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read package metadata"})
+		return
+	}
+
+	tags, ok := meta["dist-tags"].(map[string]interface{})
+	if !ok {
+		tags = map[string]interface{}{}
+		meta["dist-tags"] = tags
+	}
+
+	if version == "" {
+		if _, exists := tags[tag]; !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+			return
+		}
+		delete(tags, tag)
+	} else {
+		versions, _ := meta["versions"].(map[string]interface{})
+		if _, exists := versions[version]; !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("version %s not found", version)})
+			return
+		}
+		tags[tag] = version
+	}
+
+	if err := h.saveMetadata(packageName, meta); err != nil {
+		logger.Errorf("Failed to save metadata: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save metadata"})
+		return
+	}
+
+	if version == "" {
+		db.RecordAudit("package_dist_tag", user.Username, c.ClientIP(), fmt.Sprintf("删除 %s 的 dist-tag %s", packageName, tag))
+	} else {
+		db.RecordAudit("package_dist_tag", user.Username, c.ClientIP(), fmt.Sprintf("设置 %s 的 dist-tag %s -> %s", packageName, tag, version))
+	}
+	recordChange(&db.PackageChange{PackageName: packageName, Type: db.ChangeDistTag, Version: version, Tag: tag})
+	c.JSON(http.StatusOK, tags)
+}
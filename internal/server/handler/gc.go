@@ -3,30 +3,23 @@ package handler
 import (
 	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/graperegistry/grape/internal/db"
-	"github.com/graperegistry/grape/internal/storage/local"
+	"github.com/graperegistry/grape/internal/storage"
 	"gorm.io/gorm"
 )
 
 // GCHandler Garbage Collection Handler
 type GCHandler struct {
-	storage  *local.Storage
-	dataPath string
+	storage storage.Storage
 }
 
 // NewGCHandler creates a new GC handler
-func NewGCHandler(storage *local.Storage, dataPath string) *GCHandler {
-	return &GCHandler{
-		storage:  storage,
-		dataPath: dataPath,
-	}
+func NewGCHandler(storage storage.Storage) *GCHandler {
+	return &GCHandler{storage: storage}
 }
 
 // GCStats GC statistics
@@ -64,13 +57,7 @@ func (h *GCHandler) GetGCStats(c *gin.Context) {
 	db.DB.Model(&db.PackageVersion{}).Count(&stats.TotalVersions)
 
 	// Total size
-	packagesPath := filepath.Join(h.dataPath, "packages")
-	filepath.Walk(packagesPath, func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() {
-			stats.TotalSize += info.Size()
-		}
-		return nil
-	})
+	db.DB.Model(&db.PackageVersion{}).Select("COALESCE(SUM(size), 0)").Scan(&stats.TotalSize)
 
 	// Deprecated packages
 	db.DB.Model(&db.PackageDeprecation{}).Count(&stats.DeprecatedPackages)
@@ -86,19 +73,7 @@ func (h *GCHandler) GetGCStats(c *gin.Context) {
 	db.DB.Where("last_accessed_at < ? OR last_accessed_at IS NULL", cutoff).Find(&oldPackages)
 
 	for _, pkg := range oldPackages {
-		// Get package size
-		packagePath := filepath.Join(packagesPath, strings.ReplaceAll(pkg.PackageName, "@", ""), "metadata.json")
-		if info, err := os.Stat(packagePath); err == nil {
-			stats.OldPackagesSize += info.Size()
-		}
-		// Also count tarballs
-		tarballPath := filepath.Join(packagesPath, strings.ReplaceAll(pkg.PackageName, "@", ""), "tgz")
-		filepath.Walk(tarballPath, func(path string, info os.FileInfo, err error) error {
-			if err == nil && !info.IsDir() {
-				stats.OldPackagesSize += info.Size()
-			}
-			return nil
-		})
+		stats.OldPackagesSize += db.PackageSize(pkg.PackageName)
 	}
 
 	// Orphaned files count (approximate)
@@ -155,15 +130,7 @@ func (h *GCHandler) AnalyzeGC(c *gin.Context) {
 		isDeprecated = result.Error == nil
 
 		// Calculate size
-		var size int64
-		packageNameSafe := strings.ReplaceAll(pkg.PackageName, "@", "")
-		packagePath := filepath.Join(h.dataPath, "packages", packageNameSafe)
-		filepath.Walk(packagePath, func(path string, info os.FileInfo, err error) error {
-			if err == nil && !info.IsDir() {
-				size += info.Size()
-			}
-			return nil
-		})
+		size := db.PackageSize(pkg.PackageName)
 
 		lastAccessed := "never"
 		if pkg.LastAccessedAt != nil {
@@ -239,16 +206,7 @@ func (h *GCHandler) RunGC(c *gin.Context) {
 		}
 
 		// Calculate size
-		packageNameSafe := strings.ReplaceAll(pkg.PackageName, "@", "")
-		packagePath := filepath.Join(h.dataPath, "packages", packageNameSafe)
-
-		var size int64
-		filepath.Walk(packagePath, func(path string, info os.FileInfo, err error) error {
-			if err == nil && !info.IsDir() {
-				size += info.Size()
-			}
-			return nil
-		})
+		size := db.PackageSize(pkg.PackageName)
 
 		if req.DryRun {
 			deleted = append(deleted, pkg.PackageName+" (dry run)")
@@ -257,16 +215,16 @@ func (h *GCHandler) RunGC(c *gin.Context) {
 		}
 
 		// Actually delete
-		if err := os.RemoveAll(packagePath); err != nil {
+		if err := h.storage.DeletePackage(pkg.PackageName); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: %v", pkg.PackageName, err))
 			continue
 		}
 
 		// Delete from database
-		db.DB.Where("package_name = ?", pkg.PackageName).Delete(&db.PackageVersion{})
-		db.DB.Where("package_name = ?", pkg.PackageName).Delete(&db.Package{})
+		removePackageIndex(pkg.PackageName)
 		db.DB.Where("package_name = ?", pkg.PackageName).Delete(&db.PackageGCMetadata{})
 		db.DB.Where("package_name = ?", pkg.PackageName).Delete(&db.PackageOwner{})
+		recordChange(&db.PackageChange{PackageName: pkg.PackageName, Type: db.ChangeUnpublish})
 
 		deleted = append(deleted, pkg.PackageName)
 		deletedSize += size
@@ -363,6 +321,8 @@ func (h *GCHandler) DeprecatePackage(c *gin.Context) {
 		Detail:   fmt.Sprintf("Deprecated %s (version: %s): %s", packageName, req.Version, req.Reason),
 	})
 
+	recordChange(&db.PackageChange{PackageName: packageName, Type: db.ChangeDeprecate, Version: req.Version})
+
 	c.JSON(http.StatusOK, gin.H{"ok": true, "message": "Package deprecated"})
 }
 
@@ -386,6 +346,7 @@ func (h *GCHandler) UndeprecatePackage(c *gin.Context) {
 		IP:       c.ClientIP(),
 		Detail:   fmt.Sprintf("Undeprecated %s (version: %s)", packageName, version),
 	})
+	recordChange(&db.PackageChange{PackageName: packageName, Type: db.ChangeDeprecate, Version: version})
 
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
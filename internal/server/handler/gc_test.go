@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+func TestRunGCDeletesThroughStorage(t *testing.T) {
+	dbtest.Open(t, &db.Package{}, &db.PackageVersion{}, &db.PackageGCMetadata{}, &db.PackageDeprecation{},
+		&db.PackageOwner{}, &db.PackageChange{}, &db.AuditLog{})
+
+	store := local.New(t.TempDir())
+	now := time.Now()
+	for _, name := range []string{"@scope/old", "recent"} {
+		store.SaveMetadata(name, []byte(`{"name":"`+name+`"}`))
+		store.SaveTarball(name, "pkg-1.0.0.tgz", []byte("data"))
+		db.DB.Create(&db.PackageVersion{PackageName: name, Version: "1.0.0"})
+		db.DB.Create(&db.PackageVersion{PackageName: name, Version: "2.0.0"})
+	}
+	db.DB.Create(&db.PackageGCMetadata{PackageName: "@scope/old"})
+	db.DB.Create(&db.PackageGCMetadata{PackageName: "recent", LastAccessedAt: &now})
+
+	h := NewGCHandler(store)
+	router := setupTestRouter()
+	router.POST("/gc/run", h.RunGC)
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"maxInactiveDays":30,"minVersionsToKeep":1}`)
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/gc/run", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// scoped 包的目录是 packages/@scope/old，不能按去掉 @ 的路径删除
+	if store.HasPackage("@scope/old") || store.HasTarball("@scope/old", "pkg-1.0.0.tgz") {
+		t.Error("Expected @scope/old to be deleted from storage")
+	}
+	if !store.HasPackage("recent") {
+		t.Error("Expected recently accessed package to be kept")
+	}
+	var versions int64
+	db.DB.Model(&db.PackageVersion{}).Where("package_name = ?", "@scope/old").Count(&versions)
+	if versions != 0 {
+		t.Errorf("Expected index rows of @scope/old to be removed, got %d versions", versions)
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"encoding/json"
+
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+	"github.com/graperegistry/grape/internal/storage"
+)
+
+// indexPackage 按刚写入的 metadata 更新包索引，失败只记录日志（可通过 grape reindex 修复）
+func indexPackage(store storage.Storage, name string, data []byte) {
+	var meta map[string]interface{}
+	err := json.Unmarshal(data, &meta)
+	if err == nil {
+		err = db.IndexPackage(name, meta, func(filename string) int64 {
+			return store.TarballSize(name, filename)
+		})
+	}
+	if err != nil {
+		logger.Warnf("Failed to index package %s: %v", name, err)
+	}
+}
+
+// removePackageIndex 删除包的索引记录
+func removePackageIndex(name string) {
+	if err := db.DeletePackageIndex(name); err != nil {
+		logger.Warnf("Failed to remove package index %s: %v", name, err)
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+	"github.com/graperegistry/grape/internal/metrics"
+)
+
+// 登录结果，用作 grape_login_attempts_total 的 reason 标签
+const (
+	loginResultSuccess            = "success"
+	loginResultRateLimited        = "rate_limited"
+	loginResultUnknownUser        = "unknown_user"
+	loginResultInvalidCredentials = "invalid_credentials"
+	loginResultAccountLocked      = "account_locked"
+	loginResultOTP                = "otp"
+	loginResultPasswordChange     = "password_change_required"
+)
+
+// recordLoginAttempt 记录登录尝试指标
+func recordLoginAttempt(reason string) {
+	status := "failure"
+	if reason == loginResultSuccess {
+		status = "success"
+	}
+	metrics.LoginAttemptsTotal.WithLabelValues(status, reason).Inc()
+}
+
+// rejectLockedAccount 账号处于锁定状态时返回 429 和 Retry-After
+func rejectLockedAccount(c *gin.Context, lockedUntil time.Time) {
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "account is temporarily locked after too many failed login attempts, try again later or contact your administrator",
+		"locked":      true,
+		"lockedUntil": lockedUntil,
+	})
+}
+
+// recordPasswordFailure 记录密码错误，连续失败达到阈值时锁定账号并写入响应
+func recordPasswordFailure(c *gin.Context, user *auth.User) {
+	lockedUntil, err := auth.RecordLoginFailure(user.ID)
+	if err != nil {
+		logger.Errorf("Failed to record login failure of %s: %v", user.Username, err)
+	}
+	if !lockedUntil.IsZero() {
+		logger.Warnf("Account locked after repeated login failures: %s (until %s)", user.Username, lockedUntil.Format(time.RFC3339))
+		db.RecordAudit("account_locked", user.Username, c.ClientIP(), "连续登录失败，锁定至 "+lockedUntil.Format(time.RFC3339))
+		recordLoginAttempt(loginResultAccountLocked)
+		rejectLockedAccount(c, lockedUntil)
+		return
+	}
+
+	db.RecordAudit("login_failed", user.Username, c.ClientIP(), "密码错误")
+	recordLoginAttempt(loginResultInvalidCredentials)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+}
+
+// UnlockUserAdmin 解除账号锁定并清除失败计数 (管理员)
+// DELETE /-/api/admin/users/:username/lockout
+func (h *AuthHandler) UnlockUserAdmin(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := auth.UnlockAccount(user.ID); err != nil {
+		logger.Errorf("Failed to unlock account %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock account"})
+		return
+	}
+
+	adminName := ""
+	if admin := auth.GetCurrentUser(c); admin != nil {
+		adminName = admin.Username
+	}
+	db.RecordAudit("account_unlock", adminName, c.ClientIP(), "解除账号锁定: "+user.Username)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+const (
+	// oidcLoginTTL 从跳转 IdP 到回调的最长时间
+	oidcLoginTTL = 10 * time.Minute
+	// oidcCallbackPath Web UI 上的回调路径
+	oidcCallbackPath = "/-/api/auth/oidc/callback"
+	// oidcStateCookie 绑定发起登录的浏览器，防止登录 CSRF
+	oidcStateCookie = "grape_oidc_state"
+)
+
+// OIDCHandler Web UI 的 OpenID Connect 单点登录
+// 登录成功后签发与密码登录相同的 JWT，用户可以继续在 Web UI 中通过 TokenHandler 创建 CLI token
+type OIDCHandler struct {
+	provider    *auth.OIDCProvider
+	jwtService  *auth.JWTService
+	redirectURL string
+}
+
+// NewOIDCHandler 创建 OIDCHandler，redirectURL 为空时按请求推断回调地址
+func NewOIDCHandler(provider *auth.OIDCProvider, jwtService *auth.JWTService, redirectURL string) *OIDCHandler {
+	return &OIDCHandler{
+		provider:    provider,
+		jwtService:  jwtService,
+		redirectURL: redirectURL,
+	}
+}
+
+// GetConfig 登录页查询是否显示 SSO 按钮
+// GET /-/api/auth/oidc
+func (h *OIDCHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.provider.Enabled(),
+		"name":    h.provider.DisplayName(),
+	})
+}
+
+// StartLogin 生成 state/nonce/PKCE 并跳转到 IdP
+// GET /-/api/auth/oidc/login?redirect=/packages
+func (h *OIDCHandler) StartLogin(c *gin.Context) {
+	if !h.provider.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "single sign-on is not enabled"})
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := auth.NewOIDCRandom()
+		if err != nil {
+			logger.Errorf("Failed to generate OIDC state: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+			return
+		}
+		values[i] = v
+	}
+	state := &db.OIDCLoginState{
+		State:        values[0],
+		Nonce:        values[1],
+		CodeVerifier: values[2],
+		Redirect:     safeRedirectPath(c.Query("redirect")),
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}
+
+	authURL, err := h.provider.AuthCodeURL(h.callbackURL(c), state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		logger.Errorf("Failed to build OIDC authorization URL: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+
+	if err := db.DB.Create(state).Error; err != nil {
+		logger.Errorf("Failed to save OIDC login state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+	// 顺便清理过期状态
+	db.DB.Where("expires_at < ?", time.Now()).Delete(&db.OIDCLoginState{})
+
+	h.setStateCookie(c, state.State, int(oidcLoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback IdP 回调：校验 state，用授权码换取 id_token，同步本地账号并签发 JWT
+// token 放在 URL fragment 中交给前端，不会出现在服务端日志和 Referer 中
+// GET /-/api/auth/oidc/callback?code=xxx&state=xxx
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		logger.Warnf("OIDC login rejected by provider: %s %s", errCode, c.Query("error_description"))
+		h.redirectError(c, "access_denied")
+		return
+	}
+
+	// state 必须与发起登录时写入浏览器的 cookie 一致，否则可能是诱导用户登录攻击者账号
+	cookie, err := c.Cookie(oidcStateCookie)
+	h.setStateCookie(c, "", -1)
+	if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(c.Query("state"))) != 1 {
+		h.redirectError(c, "invalid_state")
+		return
+	}
+
+	var state db.OIDCLoginState
+	if err := db.DB.Where("state = ? AND expires_at > ?", c.Query("state"), time.Now()).First(&state).Error; err != nil {
+		h.redirectError(c, "invalid_state")
+		return
+	}
+	// state 只能使用一次
+	db.DB.Delete(&state)
+
+	identity, err := h.provider.Exchange(c.Request.Context(), h.callbackURL(c), c.Query("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		logger.Warnf("OIDC login failed: %v", err)
+		h.redirectError(c, "login_failed")
+		return
+	}
+
+	user, err := h.provider.Provision(identity)
+	if err != nil {
+		logger.Warnf("OIDC login for %s rejected: %v", identity.Username, err)
+		switch err {
+		case auth.ErrNoPermittedGroup:
+			h.redirectError(c, "no_permitted_group")
+		case auth.ErrUserNotFound:
+			h.redirectError(c, "user_not_found")
+		case auth.ErrAccountConflict:
+			h.redirectError(c, "account_conflict")
+		default:
+			h.redirectError(c, "login_failed")
+		}
+		return
+	}
+
+	token, err := h.jwtService.IssueSession(user, auth.SessionMethodOIDC, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		logger.Errorf("Failed to generate token: %v", err)
+		h.redirectError(c, "login_failed")
+		return
+	}
+
+	now := time.Now()
+	db.DB.Model(&db.User{}).Where("id = ?", user.ID).Update("last_login", &now)
+	logger.Infof("User logged in via OIDC: %s", user.Username)
+	db.RecordAudit("login", user.Username, c.ClientIP(), "OIDC 单点登录成功")
+
+	fragment := url.Values{}
+	fragment.Set("token", token)
+	fragment.Set("username", user.Username)
+	fragment.Set("role", user.Role)
+	fragment.Set("redirect", state.Redirect)
+	c.Redirect(http.StatusFound, "/login#"+fragment.Encode())
+}
+
+// callbackURL 回调地址：优先使用配置，否则按请求推断（需与 IdP 中登记的一致）
+func (h *OIDCHandler) callbackURL(c *gin.Context) string {
+	if h.redirectURL != "" {
+		return h.redirectURL
+	}
+	return requestBaseURL(c) + oidcCallbackPath
+}
+
+// setStateCookie 写入（maxAge < 0 时清除）state cookie
+// 回调地址可能经过反向代理改写路径，cookie 不限定 Path
+func (h *OIDCHandler) setStateCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(requestBaseURL(c), "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectError 返回登录页并携带错误码
+func (h *OIDCHandler) redirectError(c *gin.Context, code string) {
+	c.Redirect(http.StatusFound, fmt.Sprintf("/login?sso_error=%s", url.QueryEscape(code)))
+}
+
+// safeRedirectPath 只允许站内相对路径，防止开放重定向
+func safeRedirectPath(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+	return redirect
+}
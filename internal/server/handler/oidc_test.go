@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+func TestOIDCCallback_RequiresStateCookie(t *testing.T) {
+	dbtest.Open(t, &db.OIDCLoginState{})
+
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	}))
+	defer idp.Close()
+
+	cfg := config.Default().Auth.OIDC
+	cfg.Enabled = true
+	cfg.Issuer = idp.URL
+	cfg.ClientID = "grape"
+	h := NewOIDCHandler(auth.NewOIDCProvider(&cfg), nil, "")
+	router := setupTestRouter()
+	router.GET("/-/api/auth/oidc/login", h.StartLogin)
+	router.GET(oidcCallbackPath, h.Callback)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/-/api/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected redirect to IdP, got %d: %s", w.Code, w.Body.String())
+	}
+	location, _ := url.Parse(w.Header().Get("Location"))
+	state := location.Query().Get("state")
+	var cookie *http.Cookie
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == oidcStateCookie {
+			cookie = ck
+		}
+	}
+	if cookie == nil || cookie.Value != state || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Expected HttpOnly SameSite=Lax state cookie, got %+v", cookie)
+	}
+
+	callback := func(cookieValue string) string {
+		req := httptest.NewRequest(http.MethodGet, oidcCallbackPath+"?code=abc&state="+url.QueryEscape(state), nil)
+		if cookieValue != "" {
+			req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: cookieValue})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header().Get("Location")
+	}
+
+	// 另一个浏览器（没有 cookie 或 cookie 不匹配）不能完成登录，state 也不会被消耗
+	for _, value := range []string{"", "other-state"} {
+		if location := callback(value); !strings.Contains(location, "sso_error=invalid_state") {
+			t.Errorf("Cookie %q: expected invalid_state, got %s", value, location)
+		}
+	}
+	// 发起登录的浏览器通过 state 校验，后续在换取授权码时失败
+	if location := callback(state); !strings.Contains(location, "sso_error=login_failed") {
+		t.Errorf("Expected state check to pass with matching cookie, got %s", location)
+	}
+}
@@ -0,0 +1,657 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+	"gorm.io/gorm"
+)
+
+// orgNamePattern 组织/团队名称规则（与 npm scope 一致）
+var orgNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// OrgHandler 组织与团队管理 Handler（npm org / npm team）
+type OrgHandler struct{}
+
+// NewOrgHandler 创建 OrgHandler
+func NewOrgHandler() *OrgHandler {
+	return &OrgHandler{}
+}
+
+// loadManagedOrg 加载组织并检查当前用户的管理权限，失败时已写入响应
+// 只用于写操作，只读 token 一律拒绝
+func (h *OrgHandler) loadManagedOrg(c *gin.Context, name string) (*auth.User, *db.Organization, bool) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return nil, nil, false
+	}
+	if tokenInfo := auth.GetTokenInfo(c); tokenInfo != nil && tokenInfo.Readonly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "read-only token cannot manage organizations"})
+		return nil, nil, false
+	}
+	org, ok := auth.GetOrganization(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+		return nil, nil, false
+	}
+	if !auth.CanManageOrg(user, org) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only organization owners and admins can do this"})
+		return nil, nil, false
+	}
+	return user, org, true
+}
+
+// loadMemberOrg 加载组织并检查当前用户是否为成员（或站点管理员），失败时已写入响应
+func (h *OrgHandler) loadMemberOrg(c *gin.Context, name string) (*db.Organization, bool) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return nil, false
+	}
+	org, ok := auth.GetOrganization(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+		return nil, false
+	}
+	if user.Role != "admin" && auth.OrgRole(org.ID, user) == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+		return nil, false
+	}
+	return org, true
+}
+
+// loadTeam 按 scope:team 加载团队
+func (h *OrgHandler) loadTeam(org *db.Organization, teamName string) (*db.Team, bool) {
+	var team db.Team
+	if err := db.DB.Where("org_id = ? AND name = ?", org.ID, teamName).First(&team).Error; err != nil {
+		return nil, false
+	}
+	return &team, true
+}
+
+// ListMembers 列出组织成员
+// GET /-/org/:org/user
+func (h *OrgHandler) ListMembers(c *gin.Context) {
+	org, ok := h.loadMemberOrg(c, c.Param("org"))
+	if !ok {
+		return
+	}
+
+	var members []db.OrgMember
+	if err := db.DB.Where("org_id = ?", org.ID).Preload("User").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list members"})
+		return
+	}
+
+	// npm 格式：{ "username": "role" }
+	result := make(map[string]string)
+	for _, m := range members {
+		if m.User != nil {
+			result[m.User.Username] = m.Role
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// SetMember 添加组织成员或修改其角色
+// PUT /-/org/:org/user
+func (h *OrgHandler) SetMember(c *gin.Context) {
+	user, org, ok := h.loadManagedOrg(c, c.Param("org"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		User string `json:"user" binding:"required"`
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = auth.OrgRoleDeveloper
+	}
+	if !auth.ValidOrgRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be owner, admin or developer"})
+		return
+	}
+
+	var target db.User
+	if err := db.DB.Where("username = ?", req.User).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// 只有组织 owner（或站点管理员）可以任命 owner 或修改现有 owner 的角色
+	currentRole := auth.OrgRole(org.ID, &auth.User{ID: target.ID})
+	if (req.Role == auth.OrgRoleOwner || currentRole == auth.OrgRoleOwner) && !auth.CanManageOrgOwners(user, org) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only organization owners can add or change owners"})
+		return
+	}
+	// 不允许降级最后一个 owner
+	if req.Role != auth.OrgRoleOwner && auth.IsLastOrgOwner(org.ID, target.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot demote the last owner of an organization"})
+		return
+	}
+
+	var member db.OrgMember
+	if err := db.DB.Where("org_id = ? AND user_id = ?", org.ID, target.ID).
+		Assign(db.OrgMember{Role: req.Role}).
+		FirstOrCreate(&member, db.OrgMember{OrgID: org.ID, UserID: target.ID}).Error; err != nil {
+		logger.Errorf("Failed to set org member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set member"})
+		return
+	}
+
+	var size int64
+	db.DB.Model(&db.OrgMember{}).Where("org_id = ?", org.ID).Count(&size)
+
+	db.RecordAudit("org_member", user.Username, c.ClientIP(), fmt.Sprintf("设置 %s 在组织 %s 的角色: %s", req.User, org.Name, req.Role))
+	c.JSON(http.StatusOK, gin.H{
+		"org":  gin.H{"name": org.Name, "size": size},
+		"user": target.Username,
+		"role": req.Role,
+	})
+}
+
+// RemoveMember 移除组织成员（同时移出该组织下的所有团队）
+// DELETE /-/org/:org/user
+func (h *OrgHandler) RemoveMember(c *gin.Context) {
+	user, org, ok := h.loadManagedOrg(c, c.Param("org"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		User string `json:"user" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	var target db.User
+	if err := db.DB.Where("username = ?", req.User).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// 组织 admin 不能移除 owner，也不允许移除最后一个 owner
+	if auth.OrgRole(org.ID, &auth.User{ID: target.ID}) == auth.OrgRoleOwner {
+		if !auth.CanManageOrgOwners(user, org) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only organization owners can remove owners"})
+			return
+		}
+		if auth.IsLastOrgOwner(org.ID, target.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot remove the last owner of an organization"})
+			return
+		}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		teamIDs := tx.Model(&db.Team{}).Select("id").Where("org_id = ?", org.ID)
+		if err := tx.Where("user_id = ? AND team_id IN (?)", target.ID, teamIDs).Delete(&db.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("org_id = ? AND user_id = ?", org.ID, target.ID).Delete(&db.OrgMember{}).Error
+	})
+	if err != nil {
+		logger.Errorf("Failed to remove org member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove member"})
+		return
+	}
+
+	db.RecordAudit("org_member", user.Username, c.ClientIP(), fmt.Sprintf("将 %s 移出组织 %s", req.User, org.Name))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// ListTeams 列出组织下的团队
+// GET /-/org/:org/team
+func (h *OrgHandler) ListTeams(c *gin.Context) {
+	org, ok := h.loadMemberOrg(c, c.Param("org"))
+	if !ok {
+		return
+	}
+
+	var teams []db.Team
+	if err := db.DB.Where("org_id = ?", org.ID).Order("name").Find(&teams).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list teams"})
+		return
+	}
+
+	// npm 格式：["org:team"]
+	result := make([]string, 0, len(teams))
+	for _, t := range teams {
+		result = append(result, org.Name+":"+t.Name)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateTeam 创建团队
+// PUT /-/org/:org/team
+func (h *OrgHandler) CreateTeam(c *gin.Context) {
+	user, org, ok := h.loadManagedOrg(c, c.Param("org"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if !orgNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid team name"})
+		return
+	}
+	if _, exists := h.loadTeam(org, req.Name); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "team already exists"})
+		return
+	}
+
+	team := db.Team{OrgID: org.ID, Name: req.Name, Description: req.Description}
+	if err := db.DB.Create(&team).Error; err != nil {
+		logger.Errorf("Failed to create team: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create team"})
+		return
+	}
+
+	db.RecordAudit("team_create", user.Username, c.ClientIP(), fmt.Sprintf("创建团队 %s:%s", org.Name, team.Name))
+	c.JSON(http.StatusCreated, gin.H{"name": team.Name, "description": team.Description})
+}
+
+// ListOrgPackages 列出组织 scope 下的包
+// GET /-/org/:org/package
+func (h *OrgHandler) ListOrgPackages(c *gin.Context) {
+	org, ok := h.loadMemberOrg(c, c.Param("org"))
+	if !ok {
+		return
+	}
+
+	var names []string
+	if err := db.DB.Model(&db.PackageOwner{}).
+		Where("package_name LIKE ?", "@"+org.Name+"/%").
+		Distinct().Pluck("package_name", &names).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list packages"})
+		return
+	}
+
+	// npm 格式：{ "@org/pkg": "read-write" }
+	result := make(map[string]string)
+	for _, name := range names {
+		result[name] = auth.PermissionReadWrite
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteTeam 删除团队及其成员、授权
+// DELETE /-/team/:scope/:team
+func (h *OrgHandler) DeleteTeam(c *gin.Context) {
+	user, org, ok := h.loadManagedOrg(c, c.Param("scope"))
+	if !ok {
+		return
+	}
+	team, ok := h.loadTeam(org, c.Param("team"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("team_id = ?", team.ID).Delete(&db.TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", team.ID).Delete(&db.TeamGrant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(team).Error
+	})
+	if err != nil {
+		logger.Errorf("Failed to delete team: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete team"})
+		return
+	}
+
+	db.RecordAudit("team_delete", user.Username, c.ClientIP(), fmt.Sprintf("删除团队 %s:%s", org.Name, team.Name))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// ListTeamMembers 列出团队成员
+// GET /-/team/:scope/:team/user
+func (h *OrgHandler) ListTeamMembers(c *gin.Context) {
+	org, ok := h.loadMemberOrg(c, c.Param("scope"))
+	if !ok {
+		return
+	}
+	team, ok := h.loadTeam(org, c.Param("team"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+		return
+	}
+
+	var members []db.TeamMember
+	if err := db.DB.Where("team_id = ?", team.ID).Preload("User").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list team members"})
+		return
+	}
+
+	result := make([]string, 0, len(members))
+	for _, m := range members {
+		if m.User != nil {
+			result = append(result, m.User.Username)
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// AddTeamMember 将组织成员加入团队
+// PUT /-/team/:scope/:team/user
+func (h *OrgHandler) AddTeamMember(c *gin.Context) {
+	user, org, ok := h.loadManagedOrg(c, c.Param("scope"))
+	if !ok {
+		return
+	}
+	team, ok := h.loadTeam(org, c.Param("team"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+		return
+	}
+
+	var req struct {
+		User string `json:"user" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	var target db.User
+	if err := db.DB.Where("username = ?", req.User).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if auth.OrgRole(org.ID, &auth.User{ID: target.ID}) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user is not a member of the organization"})
+		return
+	}
+
+	var member db.TeamMember
+	if err := db.DB.FirstOrCreate(&member, db.TeamMember{TeamID: team.ID, UserID: target.ID}).Error; err != nil {
+		logger.Errorf("Failed to add team member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add team member"})
+		return
+	}
+
+	db.RecordAudit("team_member", user.Username, c.ClientIP(), fmt.Sprintf("将 %s 加入团队 %s:%s", req.User, org.Name, team.Name))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// RemoveTeamMember 将用户移出团队
+// DELETE /-/team/:scope/:team/user
+func (h *OrgHandler) RemoveTeamMember(c *gin.Context) {
+	user, org, ok := h.loadManagedOrg(c, c.Param("scope"))
+	if !ok {
+		return
+	}
+	team, ok := h.loadTeam(org, c.Param("team"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+		return
+	}
+
+	var req struct {
+		User string `json:"user" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	var target db.User
+	if err := db.DB.Where("username = ?", req.User).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := db.DB.Where("team_id = ? AND user_id = ?", team.ID, target.ID).Delete(&db.TeamMember{}).Error; err != nil {
+		logger.Errorf("Failed to remove team member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove team member"})
+		return
+	}
+
+	db.RecordAudit("team_member", user.Username, c.ClientIP(), fmt.Sprintf("将 %s 移出团队 %s:%s", req.User, org.Name, team.Name))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// ListTeamPackages 列出团队的包授权
+// GET /-/team/:scope/:team/package
+func (h *OrgHandler) ListTeamPackages(c *gin.Context) {
+	org, ok := h.loadMemberOrg(c, c.Param("scope"))
+	if !ok {
+		return
+	}
+	team, ok := h.loadTeam(org, c.Param("team"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+		return
+	}
+
+	var grants []db.TeamGrant
+	if err := db.DB.Where("team_id = ?", team.ID).Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list team packages"})
+		return
+	}
+
+	result := make(map[string]string)
+	for _, g := range grants {
+		result[g.PackageName] = g.Permission
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GrantTeamPackage 授予团队对包（或 @scope/*）的权限
+// PUT /-/team/:scope/:team/package
+func (h *OrgHandler) GrantTeamPackage(c *gin.Context) {
+	user, org, ok := h.loadManagedOrg(c, c.Param("scope"))
+	if !ok {
+		return
+	}
+	team, ok := h.loadTeam(org, c.Param("team"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+		return
+	}
+
+	var req struct {
+		Package     string `json:"package" binding:"required"`
+		Permissions string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if req.Permissions == "" {
+		req.Permissions = auth.PermissionReadOnly
+	}
+	if !auth.ValidPermission(req.Permissions) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "permissions must be read-only or read-write"})
+		return
+	}
+	// 组织只能授权自己 scope 下的包，站点管理员不受限制
+	if user.Role != "admin" && !orgOwnsPackage(org, req.Package) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "package is not in the organization scope"})
+		return
+	}
+
+	var grant db.TeamGrant
+	if err := db.DB.Where("team_id = ? AND package_name = ?", team.ID, req.Package).
+		Assign(db.TeamGrant{Permission: req.Permissions}).
+		FirstOrCreate(&grant, db.TeamGrant{TeamID: team.ID, PackageName: req.Package}).Error; err != nil {
+		logger.Errorf("Failed to grant team access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to grant access"})
+		return
+	}
+
+	db.RecordAudit("team_grant", user.Username, c.ClientIP(), fmt.Sprintf("授予团队 %s:%s 对 %s 的 %s 权限", org.Name, team.Name, req.Package, req.Permissions))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// RevokeTeamPackage 撤销团队对包的权限
+// DELETE /-/team/:scope/:team/package
+func (h *OrgHandler) RevokeTeamPackage(c *gin.Context) {
+	user, org, ok := h.loadManagedOrg(c, c.Param("scope"))
+	if !ok {
+		return
+	}
+	team, ok := h.loadTeam(org, c.Param("team"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+		return
+	}
+
+	var req struct {
+		Package string `json:"package" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := db.DB.Where("team_id = ? AND package_name = ?", team.ID, req.Package).Delete(&db.TeamGrant{}).Error; err != nil {
+		logger.Errorf("Failed to revoke team access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke access"})
+		return
+	}
+
+	db.RecordAudit("team_revoke", user.Username, c.ClientIP(), fmt.Sprintf("撤销团队 %s:%s 对 %s 的权限", org.Name, team.Name, req.Package))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// ListOrgsAdmin 管理员列出所有组织
+// GET /-/api/admin/orgs
+func (h *OrgHandler) ListOrgsAdmin(c *gin.Context) {
+	var orgs []db.Organization
+	if err := db.DB.Order("name").Find(&orgs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list organizations"})
+		return
+	}
+
+	type orgInfo struct {
+		db.Organization
+		Members int64 `json:"members"`
+		Teams   int64 `json:"teams"`
+	}
+	result := make([]orgInfo, 0, len(orgs))
+	for _, org := range orgs {
+		info := orgInfo{Organization: org}
+		db.DB.Model(&db.OrgMember{}).Where("org_id = ?", org.ID).Count(&info.Members)
+		db.DB.Model(&db.Team{}).Where("org_id = ?", org.ID).Count(&info.Teams)
+		result = append(result, info)
+	}
+	c.JSON(http.StatusOK, gin.H{"orgs": result})
+}
+
+// CreateOrgAdmin 管理员创建组织并指定初始 owner
+// POST /-/api/admin/orgs
+func (h *OrgHandler) CreateOrgAdmin(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Owner       string `json:"owner" binding:"required"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	name := auth.NormalizeOrgName(strings.ToLower(req.Name))
+	if !orgNamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid organization name"})
+		return
+	}
+	if _, exists := auth.GetOrganization(name); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "organization already exists"})
+		return
+	}
+
+	var owner db.User
+	if err := db.DB.Where("username = ?", req.Owner).First(&owner).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "owner not found"})
+		return
+	}
+
+	org := db.Organization{Name: name, Description: req.Description}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&db.OrgMember{OrgID: org.ID, UserID: owner.ID, Role: auth.OrgRoleOwner}).Error
+	})
+	if err != nil {
+		logger.Errorf("Failed to create organization: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create organization"})
+		return
+	}
+
+	username := ""
+	if user := auth.GetCurrentUser(c); user != nil {
+		username = user.Username
+	}
+	db.RecordAudit("org_create", username, c.ClientIP(), fmt.Sprintf("创建组织 @%s，owner: %s", org.Name, owner.Username))
+	c.JSON(http.StatusCreated, org)
+}
+
+// DeleteOrgAdmin 管理员删除组织及其成员、团队
+// DELETE /-/api/admin/orgs/:org
+func (h *OrgHandler) DeleteOrgAdmin(c *gin.Context) {
+	org, ok := auth.GetOrganization(c.Param("org"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		teamIDs := tx.Model(&db.Team{}).Select("id").Where("org_id = ?", org.ID)
+		if err := tx.Where("team_id IN (?)", teamIDs).Delete(&db.TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id IN (?)", teamIDs).Delete(&db.TeamGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", org.ID).Delete(&db.Team{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", org.ID).Delete(&db.OrgMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(org).Error
+	})
+	if err != nil {
+		logger.Errorf("Failed to delete organization: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete organization"})
+		return
+	}
+
+	username := ""
+	if user := auth.GetCurrentUser(c); user != nil {
+		username = user.Username
+	}
+	db.RecordAudit("org_delete", username, c.ClientIP(), "删除组织 @"+org.Name)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// orgOwnsPackage 检查包（或 @scope/* 规则）是否属于组织的 scope
+func orgOwnsPackage(org *db.Organization, packageName string) bool {
+	return strings.HasPrefix(packageName, "@"+org.Name+"/")
+}
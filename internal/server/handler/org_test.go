@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+// setupOrgTest 创建组织 acme：owner 为 alice，admin 为 bob，developer 为 carol；dave 不是成员
+// X-Test-Readonly 表示当前请求使用只读 token
+func setupOrgTest(t *testing.T) (*gin.Engine, map[string]*auth.User) {
+	t.Helper()
+	dbtest.Open(t, &db.User{}, &db.AuditLog{}, &db.Organization{}, &db.OrgMember{}, &db.Team{}, &db.TeamMember{}, &db.TeamGrant{})
+
+	users := make(map[string]*auth.User)
+	for _, name := range []string{"alice", "bob", "carol", "dave", "root"} {
+		role := "developer"
+		if name == "root" {
+			role = "admin"
+		}
+		u := &db.User{Username: name, Password: "x", Email: name + "@example.com", Role: role}
+		if err := db.DB.Create(u).Error; err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		users[name] = &auth.User{ID: u.ID, Username: u.Username, Role: u.Role}
+	}
+
+	org := db.Organization{Name: "acme"}
+	db.DB.Create(&org)
+	for name, role := range map[string]string{"alice": auth.OrgRoleOwner, "bob": auth.OrgRoleAdmin, "carol": auth.OrgRoleDeveloper} {
+		db.DB.Create(&db.OrgMember{OrgID: org.ID, UserID: users[name].ID, Role: role})
+	}
+
+	h := NewOrgHandler()
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		if user, ok := users[c.GetHeader("X-Test-User")]; ok {
+			c.Set(string(auth.UserKey), user)
+		}
+		if c.GetHeader("X-Test-Readonly") != "" {
+			c.Set(string(auth.TokenKey), &auth.TokenInfo{ID: 1, Name: "ro", Readonly: true})
+		}
+	})
+	router.GET("/-/org/:org/user", h.ListMembers)
+	router.PUT("/-/org/:org/user", h.SetMember)
+	router.DELETE("/-/org/:org/user", h.RemoveMember)
+	return router, users
+}
+
+func orgRequest(router *gin.Engine, method, user string, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, "/-/org/acme/user", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func orgMemberRole(t *testing.T, user *auth.User) string {
+	t.Helper()
+	org, _ := auth.GetOrganization("acme")
+	return auth.OrgRole(org.ID, user)
+}
+
+func TestOrgHandler_ListMembers(t *testing.T) {
+	router, _ := setupOrgTest(t)
+
+	w := orgRequest(router, http.MethodGet, "carol", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Member list: expected 200, got %d", w.Code)
+	}
+	var members map[string]string
+	json.Unmarshal(w.Body.Bytes(), &members)
+	if len(members) != 3 || members["alice"] != auth.OrgRoleOwner || members["carol"] != auth.OrgRoleDeveloper {
+		t.Errorf("Unexpected members: %v", members)
+	}
+
+	if w := orgRequest(router, http.MethodGet, "dave", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Non-member list: expected 404, got %d", w.Code)
+	}
+	if w := orgRequest(router, http.MethodGet, "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Anonymous list: expected 401, got %d", w.Code)
+	}
+}
+
+func TestOrgHandler_SetMember(t *testing.T) {
+	router, users := setupOrgTest(t)
+
+	tests := []struct {
+		name     string
+		actor    string
+		user     string
+		role     string
+		wantCode int
+	}{
+		{"developer cannot manage members", "carol", "dave", "developer", http.StatusForbidden},
+		{"admin adds a developer", "bob", "dave", "developer", http.StatusOK},
+		{"admin cannot appoint an owner", "bob", "dave", "owner", http.StatusForbidden},
+		{"admin cannot demote an owner", "bob", "alice", "developer", http.StatusForbidden},
+		{"owner cannot demote the last owner", "alice", "alice", "admin", http.StatusBadRequest},
+		{"invalid role", "alice", "dave", "superuser", http.StatusBadRequest},
+		{"unknown user", "alice", "nobody", "developer", http.StatusNotFound},
+		{"owner appoints another owner", "alice", "carol", "owner", http.StatusOK},
+		{"owner demotes an owner that is not the last", "alice", "carol", "admin", http.StatusOK},
+		{"site admin appoints an owner", "root", "dave", "owner", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := orgRequest(router, http.MethodPut, tt.actor, gin.H{"user": tt.user, "role": tt.role})
+			if w.Code != tt.wantCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if role := orgMemberRole(t, users["alice"]); role != auth.OrgRoleOwner {
+		t.Errorf("alice: expected owner, got %q", role)
+	}
+	if role := orgMemberRole(t, users["carol"]); role != auth.OrgRoleAdmin {
+		t.Errorf("carol: expected admin, got %q", role)
+	}
+	if role := orgMemberRole(t, users["dave"]); role != auth.OrgRoleOwner {
+		t.Errorf("dave: expected owner, got %q", role)
+	}
+}
+
+func TestOrgHandler_RejectsReadonlyToken(t *testing.T) {
+	router, users := setupOrgTest(t)
+
+	request := func(method string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, "/-/org/acme/user", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", "alice")
+		req.Header.Set("X-Test-Readonly", "1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 组织 owner 的只读 token 可以查看成员，但不能修改
+	if w := request(http.MethodGet, nil); w.Code != http.StatusOK {
+		t.Fatalf("List members: expected 200, got %d", w.Code)
+	}
+	if w := request(http.MethodPut, gin.H{"user": "dave", "role": "developer"}); w.Code != http.StatusForbidden {
+		t.Errorf("Set member: expected 403, got %d", w.Code)
+	}
+	if role := orgMemberRole(t, users["dave"]); role != "" {
+		t.Errorf("Expected dave not to be added, got role %q", role)
+	}
+	if w := request(http.MethodDelete, gin.H{"user": "carol"}); w.Code != http.StatusForbidden {
+		t.Errorf("Remove member: expected 403, got %d", w.Code)
+	}
+	if role := orgMemberRole(t, users["carol"]); role != auth.OrgRoleDeveloper {
+		t.Errorf("Expected carol to stay a developer, got %q", role)
+	}
+}
+
+func TestOrgHandler_RemoveMember(t *testing.T) {
+	router, users := setupOrgTest(t)
+
+	if w := orgRequest(router, http.MethodDelete, "bob", gin.H{"user": "alice"}); w.Code != http.StatusForbidden {
+		t.Errorf("Admin removing an owner: expected 403, got %d", w.Code)
+	}
+	if w := orgRequest(router, http.MethodDelete, "alice", gin.H{"user": "alice"}); w.Code != http.StatusBadRequest {
+		t.Errorf("Removing the last owner: expected 400, got %d", w.Code)
+	}
+	if w := orgRequest(router, http.MethodDelete, "bob", gin.H{"user": "carol"}); w.Code != http.StatusOK {
+		t.Errorf("Admin removing a developer: expected 200, got %d", w.Code)
+	}
+	if role := orgMemberRole(t, users["carol"]); role != "" {
+		t.Errorf("carol still has role %q after removal", role)
+	}
+	if role := orgMemberRole(t, users["alice"]); role != auth.OrgRoleOwner {
+		t.Errorf("alice: expected owner, got %q", role)
+	}
+}
@@ -11,11 +11,13 @@ import (
 )
 
 // OwnerHandler 包 Owner 管理 Handler
-type OwnerHandler struct{}
+type OwnerHandler struct {
+	authz *auth.PackageAuthorizer
+}
 
 // NewOwnerHandler 创建 OwnerHandler
-func NewOwnerHandler() *OwnerHandler {
-	return &OwnerHandler{}
+func NewOwnerHandler(authz *auth.PackageAuthorizer) *OwnerHandler {
+	return &OwnerHandler{authz: authz}
 }
 
 // OwnerInfo owner 信息响应
@@ -57,7 +59,8 @@ func (h *OwnerHandler) ListOwners(c *gin.Context) {
 
 // AddOwnerRequest 添加 owner 请求
 type AddOwnerRequest struct {
-	Name string `json:"name"` // 用户名
+	Name    string `json:"name"`              // 用户名
+	Package string `json:"package,omitempty"` // 包名或通配符规则（管理后台 /owners 使用）
 }
 
 // AddOwner 添加包 owner
@@ -68,6 +71,9 @@ func (h *OwnerHandler) AddOwner(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
+	if !requireOTP(c, currentUser, auth.OTPWrite) {
+		return
+	}
 
 	packageName := c.Param("name")
 	username := c.Param("username")
@@ -78,13 +84,9 @@ func (h *OwnerHandler) AddOwner(c *gin.Context) {
 	}
 
 	// 检查权限：只有 admin 或包的 owner 可以添加 owner
-	if currentUser.Role != "admin" {
-		var existingOwner db.PackageOwner
-		result := db.DB.Where("package_name = ? AND user_id = ?", packageName, currentUser.ID).First(&existingOwner)
-		if result.Error != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "only package owners can add collaborators"})
-			return
-		}
+	if !h.authz.Authorize(c, packageName, auth.ActionManageOwners) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only package owners can add collaborators"})
+		return
 	}
 
 	// 查找要添加的用户
@@ -94,19 +96,11 @@ func (h *OwnerHandler) AddOwner(c *gin.Context) {
 		return
 	}
 
-	// 添加 owner
-	owner := &db.PackageOwner{
-		PackageName: packageName,
-		UserID:      targetUser.ID,
-		CanPublish:  true,
-	}
-
-	if err := db.DB.Create(owner).Error; err != nil {
-		// 检查是否已存在
-		if err.Error() != "" {
-			logger.Warnf("Owner already exists or error: %v", err)
-		}
-		// 幂等操作，已存在也返回成功
+	// 添加 owner（幂等操作，已存在也返回成功），并同步 maintainers
+	if err := h.authz.AddOwner(packageName, targetUser.ID); err != nil {
+		logger.Errorf("Failed to add owner: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add owner"})
+		return
 	}
 
 	// 记录审计日志
@@ -134,6 +128,9 @@ func (h *OwnerHandler) RemoveOwner(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
+	if !requireOTP(c, currentUser, auth.OTPWrite) {
+		return
+	}
 
 	packageName := c.Param("name")
 	username := c.Param("username")
@@ -144,13 +141,9 @@ func (h *OwnerHandler) RemoveOwner(c *gin.Context) {
 	}
 
 	// 检查权限：只有 admin 或包的 owner 可以移除 owner
-	if currentUser.Role != "admin" {
-		var existingOwner db.PackageOwner
-		result := db.DB.Where("package_name = ? AND user_id = ?", packageName, currentUser.ID).First(&existingOwner)
-		if result.Error != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "only package owners can remove collaborators"})
-			return
-		}
+	if !h.authz.Authorize(c, packageName, auth.ActionManageOwners) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only package owners can remove collaborators"})
+		return
 	}
 
 	// 查找要移除的用户
@@ -166,10 +159,9 @@ func (h *OwnerHandler) RemoveOwner(c *gin.Context) {
 		return
 	}
 
-	// 移除 owner
-	result := db.DB.Where("package_name = ? AND user_id = ?", packageName, targetUser.ID).Delete(&db.PackageOwner{})
-	if result.Error != nil {
-		logger.Errorf("Failed to remove owner: %v", result.Error)
+	// 移除 owner 并同步 maintainers
+	if err := h.authz.RemoveOwner(packageName, targetUser.ID); err != nil {
+		logger.Errorf("Failed to remove owner: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove owner"})
 		return
 	}
@@ -218,6 +210,7 @@ func (h *OwnerHandler) ListPackageOwnersAdmin(c *gin.Context) {
 
 // SetPackageOwnerAdmin 管理员设置包 owner
 // POST /-/api/admin/packages/:name/owners
+// POST /-/api/admin/owners（body 中的 package 可以是 @ui/* 等包含 / 的通配符规则）
 func (h *OwnerHandler) SetPackageOwnerAdmin(c *gin.Context) {
 	packageName := c.Param("name")
 	var req AddOwnerRequest
@@ -225,6 +218,19 @@ func (h *OwnerHandler) SetPackageOwnerAdmin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
 		return
 	}
+	if packageName == "" {
+		packageName = req.Package
+	}
+	if packageName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "package name required"})
+		return
+	}
+
+	// 通配符规则（如 @ui/*、eslint-config-acme-*）需要语法合法
+	if auth.IsOwnerPattern(packageName) && !auth.ValidOwnerPattern(packageName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid owner pattern"})
+		return
+	}
 
 	// 查找用户
 	var targetUser db.User
@@ -233,15 +239,11 @@ func (h *OwnerHandler) SetPackageOwnerAdmin(c *gin.Context) {
 		return
 	}
 
-	// 添加 owner
-	owner := &db.PackageOwner{
-		PackageName: packageName,
-		UserID:      targetUser.ID,
-		CanPublish:  true,
-	}
-
-	if err := db.DB.Create(owner).Error; err != nil {
-		logger.Warnf("Owner already exists or error: %v", err)
+	// 添加 owner 并同步 maintainers
+	if err := h.authz.AddOwner(packageName, targetUser.ID); err != nil {
+		logger.Errorf("Failed to add owner: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add owner"})
+		return
 	}
 
 	currentUser := auth.GetCurrentUser(c)
@@ -267,7 +269,11 @@ func (h *OwnerHandler) RemovePackageOwnerAdmin(c *gin.Context) {
 		return
 	}
 
-	db.DB.Where("package_name = ? AND user_id = ?", packageName, targetUser.ID).Delete(&db.PackageOwner{})
+	if err := h.authz.RemoveOwner(packageName, targetUser.ID); err != nil {
+		logger.Errorf("Failed to remove owner: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove owner"})
+		return
+	}
 
 	currentUser := auth.GetCurrentUser(c)
 	db.DB.Create(&db.AuditLog{
@@ -279,3 +285,73 @@ func (h *OwnerHandler) RemovePackageOwnerAdmin(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
+
+
+// EffectiveOwnerInfo 生效 owner 信息
+type EffectiveOwnerInfo struct {
+	Username   string `json:"username"`
+	Email      string `json:"email,omitempty"`
+	Rule       string `json:"rule"`       // 命中的 owner 规则（包名或通配符）
+	Source     string `json:"source"`     // package | pattern | org
+	CanPublish bool   `json:"canPublish"`
+}
+
+// GetEffectiveOwnersAdmin 管理员查看任意包名的生效 owners（包括通配符规则和组织 owner/admin）
+// GET /-/api/admin/owners/effective?name=xxx
+func (h *OwnerHandler) GetEffectiveOwnersAdmin(c *gin.Context) {
+	packageName := c.Query("name")
+	if packageName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+
+	owners, err := auth.EffectiveOwners(packageName)
+	if err != nil {
+		logger.Errorf("Failed to resolve effective owners: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list owners"})
+		return
+	}
+
+	result := make([]EffectiveOwnerInfo, 0, len(owners))
+	for _, owner := range owners {
+		if owner.User == nil {
+			continue
+		}
+		source := "package"
+		if auth.IsOwnerPattern(owner.PackageName) {
+			source = "pattern"
+		}
+		result = append(result, EffectiveOwnerInfo{
+			Username:   owner.User.Username,
+			Email:      owner.User.Email,
+			Rule:       owner.PackageName,
+			Source:     source,
+			CanPublish: owner.CanPublish,
+		})
+	}
+
+	// 组织 owner/admin 对组织 scope 下的包同样拥有发布权限
+	if org, ok := auth.OrgForPackage(packageName); ok {
+		var members []db.OrgMember
+		db.DB.Where("org_id = ? AND role IN ?", org.ID, []string{auth.OrgRoleOwner, auth.OrgRoleAdmin}).
+			Preload("User").Find(&members)
+		for _, m := range members {
+			if m.User == nil {
+				continue
+			}
+			result = append(result, EffectiveOwnerInfo{
+				Username:   m.User.Username,
+				Email:      m.User.Email,
+				Rule:       "@" + org.Name + ":" + m.Role,
+				Source:     "org",
+				CanPublish: true,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"package": packageName,
+		"exists":  len(result) > 0,
+		"owners":  result,
+	})
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// resetPasswordPath Web UI 上使用重置码设置新密码的页面
+const resetPasswordPath = "/reset-password"
+
+// changeRequiredPassword 处理被要求修改密码的账号登录，写入响应并返回 false 表示不能继续登录
+// 没有提交新密码时返回 403 + mustChangePassword，Web UI 据此显示设置新密码的表单
+func (h *AuthHandler) changeRequiredPassword(c *gin.Context, user *auth.User, oldPassword, newPassword string) bool {
+	if newPassword == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":              "password change required, log in to the web UI to set a new password",
+			"mustChangePassword": true,
+		})
+		return false
+	}
+	if newPassword == oldPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": auth.ErrSamePassword.Error(), "mustChangePassword": true})
+		return false
+	}
+	if err := auth.ValidatePassword(newPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "mustChangePassword": true})
+		return false
+	}
+
+	user.Password = newPassword
+	user.MustChangePassword = false
+	if err := h.userStore.Update(user); err != nil {
+		logger.Errorf("Failed to change password of %s: %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return false
+	}
+	if _, err := auth.RevokeUserSessions(user.ID, ""); err != nil {
+		logger.Errorf("Failed to revoke sessions of %s: %v", user.Username, err)
+	}
+	db.RecordAudit("password_change", user.Username, c.ClientIP(), "登录时修改密码")
+	return true
+}
+
+// CreatePasswordResetAdmin 为用户签发一次性密码重置码和链接 (管理员)
+// POST /-/api/admin/users/:username/password-reset
+func (h *AuthHandler) CreatePasswordResetAdmin(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	adminName := ""
+	if admin := auth.GetCurrentUser(c); admin != nil {
+		adminName = admin.Username
+	}
+
+	code, expiresAt, err := auth.CreatePasswordReset(user, adminName)
+	if err != nil {
+		if err == auth.ErrExternalPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Failed to create password reset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create password reset"})
+		return
+	}
+
+	db.RecordAudit("password_reset_issue", adminName, c.ClientIP(), "签发密码重置码: "+user.Username)
+	c.JSON(http.StatusCreated, gin.H{
+		"code":      code,
+		"url":       requestBaseURL(c) + resetPasswordPath + "?code=" + url.QueryEscape(code),
+		"expiresAt": expiresAt,
+	})
+}
+
+// ResetPassword 使用重置码设置新密码，成功后该用户的所有会话失效
+// POST /-/api/auth/password-reset
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	limitKey := "reset:" + c.ClientIP()
+	if !getLoginLimiter().checkLimit(limitKey) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many attempts, please try again later"})
+		return
+	}
+
+	var req struct {
+		Code     string `json:"code" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := auth.RedeemPasswordReset(h.userStore, req.Code, req.Password)
+	if err != nil {
+		if err == auth.ErrInvalidResetCode || err == auth.ErrExternalPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Failed to reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	getLoginLimiter().reset(limitKey)
+	db.RecordAudit("password_reset", user.Username, c.ClientIP(), "使用重置码修改密码")
+	c.JSON(http.StatusOK, gin.H{"ok": true, "username": user.Username})
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// ProfileResponse npm profile 响应格式
+type ProfileResponse struct {
+	Name          string      `json:"name"`
+	Email         string      `json:"email"`
+	EmailVerified bool        `json:"email_verified"`
+	FullName      string      `json:"fullname"`
+	Created       string      `json:"created"`
+	Updated       string      `json:"updated"`
+	TFA           interface{} `json:"tfa"` // 未启用时为 false，否则为 {pending, mode}
+}
+
+// UpdateProfileRequest npm profile set 请求格式
+type UpdateProfileRequest struct {
+	Email    *string `json:"email"`
+	FullName *string `json:"fullname"`
+	Password *struct {
+		Old string `json:"old"`
+		New string `json:"new"`
+	} `json:"password"`
+	// TFA npm profile enable-2fa / disable-2fa：
+	// {"password","mode"} 开启、切换模式或关闭（mode 为 disable），["<otp>"] 确认开启
+	TFA json.RawMessage `json:"tfa"`
+}
+
+// Ping 处理 npm ping 请求
+// GET /-/ping
+func Ping(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// Whoami 返回当前登录用户名
+// GET /-/whoami
+func (h *AuthHandler) Whoami(c *gin.Context) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"username": user.Username})
+}
+
+// GetProfile 获取当前用户的 profile
+// GET /-/npm/v1/user
+func (h *AuthHandler) GetProfile(c *gin.Context) {
+	current := auth.GetCurrentUser(c)
+	if current == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	user, err := h.userStore.Get(current.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toProfileResponse(user))
+}
+
+// UpdateProfile 更新当前用户的 profile（email、fullname、密码）
+// POST /-/npm/v1/user
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	current := auth.GetCurrentUser(c)
+	if current == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	// 只读 Token 不允许修改 profile
+	tokenInfo := auth.GetTokenInfo(c)
+	if tokenInfo != nil && tokenInfo.Readonly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "read-only token cannot update profile"})
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	user, err := h.userStore.Get(current.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if len(req.TFA) > 0 && string(req.TFA) != "null" {
+		h.updateTwoFactor(c, user, req.TFA)
+		return
+	}
+
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" || !strings.Contains(email, "@") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+			return
+		}
+		user.Email = email
+	}
+	if req.FullName != nil {
+		user.FullName = strings.TrimSpace(*req.FullName)
+	}
+
+	passwordChanged := false
+	if req.Password != nil {
+		if user.IsExternal() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": auth.ErrExternalPassword.Error()})
+			return
+		}
+		// 修改密码需要校验旧密码
+		if _, err := h.userStore.Validate(user.Username, req.Password.Old); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "old password is incorrect"})
+			return
+		}
+		if err := auth.ValidatePassword(req.Password.New); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Password.New == req.Password.Old {
+			c.JSON(http.StatusBadRequest, gin.H{"error": auth.ErrSamePassword.Error()})
+			return
+		}
+		user.Password = req.Password.New
+		user.MustChangePassword = false
+		passwordChanged = true
+	}
+
+	if err := h.userStore.Update(user); err != nil {
+		logger.Errorf("Failed to update profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update profile"})
+		return
+	}
+
+	if passwordChanged {
+		// 保留当前会话，其他会话全部失效
+		if _, err := auth.RevokeUserSessions(user.ID, auth.GetSessionID(c)); err != nil {
+			logger.Errorf("Failed to revoke sessions of %s: %v", user.Username, err)
+		}
+		db.RecordAudit("password_change", user.Username, c.ClientIP(), "修改密码")
+	}
+	db.RecordAudit("profile_update", user.Username, c.ClientIP(), "更新 profile")
+
+	updated, err := h.userStore.Get(user.Username)
+	if err != nil {
+		updated = user
+	}
+	c.JSON(http.StatusOK, toProfileResponse(updated))
+}
+
+// toProfileResponse 将 auth.User 转换为 npm profile 格式
+func toProfileResponse(user *auth.User) ProfileResponse {
+	updated := user.UpdatedAt
+	if updated.IsZero() {
+		updated = user.CreatedAt
+	}
+	var tfa interface{} = false
+	if status := auth.GetTwoFactorStatus(user.ID); status.Enabled || status.Pending {
+		tfa = gin.H{"pending": status.Pending, "mode": status.Mode}
+	}
+	return ProfileResponse{
+		Name:     user.Username,
+		Email:    user.Email,
+		FullName: user.FullName,
+		Created:  user.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Updated:  updated.UTC().Format("2006-01-02T15:04:05.000Z"),
+		TFA:      tfa,
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+// setupProfileTest 创建用户 alice（密码 Alice-Pass-123），X-Test-User 指定当前用户，
+// X-Test-Session 指定当前 JWT 会话的 jti，X-Test-Readonly 表示使用只读 token
+func setupProfileTest(t *testing.T) (*gin.Engine, *auth.JWTService) {
+	t.Helper()
+	dbtest.Open(t, &db.User{}, &db.UserSession{}, &db.AuditLog{}, &db.AccountLockout{}, &db.UserTwoFactor{})
+	store := auth.NewDBUserStore()
+	if err := store.Create(&auth.User{Username: "alice", Password: "Alice-Pass-123", Email: "alice@example.com", Role: "developer"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	alice, _ := store.Get("alice")
+
+	jwtService := auth.NewJWTService("test-secret", time.Hour)
+	h := NewAuthHandler(store, jwtService, false)
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Test-User") == "alice" {
+			c.Set(string(auth.UserKey), alice)
+		}
+		if jti := c.GetHeader("X-Test-Session"); jti != "" {
+			c.Set(string(auth.SessionKey), jti)
+		}
+		if c.GetHeader("X-Test-Readonly") != "" {
+			c.Set(string(auth.TokenKey), &auth.TokenInfo{ID: 1, Name: "ro", Readonly: true})
+		}
+	})
+	router.GET("/-/ping", Ping)
+	router.GET("/-/whoami", h.Whoami)
+	router.GET("/-/npm/v1/user", h.GetProfile)
+	router.POST("/-/npm/v1/user", h.UpdateProfile)
+	return router, jwtService
+}
+
+func profileRequest(router *gin.Engine, method, target string, headers map[string]string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestWhoami(t *testing.T) {
+	router, _ := setupProfileTest(t)
+	alice := map[string]string{"X-Test-User": "alice"}
+
+	if w := profileRequest(router, http.MethodGet, "/-/ping", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("Ping: expected 200, got %d", w.Code)
+	}
+
+	w := profileRequest(router, http.MethodGet, "/-/whoami", alice, nil)
+	var resp map[string]string
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp["username"] != "alice" {
+		t.Errorf("Whoami: expected alice, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := profileRequest(router, http.MethodGet, "/-/whoami", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Anonymous whoami: expected 401, got %d", w.Code)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	router, _ := setupProfileTest(t)
+	alice := map[string]string{"X-Test-User": "alice"}
+
+	w := profileRequest(router, http.MethodPost, "/-/npm/v1/user", alice, gin.H{"email": " alice@acme.dev ", "fullname": "Alice Liddell"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update profile: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = profileRequest(router, http.MethodGet, "/-/npm/v1/user", alice, nil)
+	var profile ProfileResponse
+	json.Unmarshal(w.Body.Bytes(), &profile)
+	if profile.Name != "alice" || profile.Email != "alice@acme.dev" || profile.FullName != "Alice Liddell" || profile.TFA != false {
+		t.Errorf("Unexpected profile: %+v", profile)
+	}
+
+	if w := profileRequest(router, http.MethodPost, "/-/npm/v1/user", alice, gin.H{"email": "not-an-email"}); w.Code != http.StatusBadRequest {
+		t.Errorf("Invalid email: expected 400, got %d", w.Code)
+	}
+	if w := profileRequest(router, http.MethodGet, "/-/npm/v1/user", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Anonymous profile: expected 401, got %d", w.Code)
+	}
+}
+
+func TestUpdateProfile_ChangePassword(t *testing.T) {
+	router, jwtService := setupProfileTest(t)
+	alice, _ := auth.NewDBUserStore().Get("alice")
+
+	// 当前会话和另一个设备上的会话
+	var jtis []string
+	for range 2 {
+		token, err := jwtService.IssueSession(alice, auth.SessionMethodPassword, "10.0.0.1", "npm/10.0.0")
+		if err != nil {
+			t.Fatalf("IssueSession failed: %v", err)
+		}
+		claims, _ := jwtService.ValidateToken(token)
+		jtis = append(jtis, claims.ID)
+	}
+	headers := map[string]string{"X-Test-User": "alice", "X-Test-Session": jtis[0]}
+
+	w := profileRequest(router, http.MethodPost, "/-/npm/v1/user", headers, gin.H{"password": gin.H{"old": "Wrong-Pass-123", "new": "Alice-Pass-456"}})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Wrong old password: expected 401, got %d", w.Code)
+	}
+
+	w = profileRequest(router, http.MethodPost, "/-/npm/v1/user", headers, gin.H{"password": gin.H{"old": "Alice-Pass-123", "new": "Alice-Pass-456"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Change password: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := auth.NewDBUserStore().Validate("alice", "Alice-Pass-456"); err != nil {
+		t.Errorf("Expected new password to be valid: %v", err)
+	}
+
+	// 保留当前会话，其他会话被撤销
+	for i, jti := range jtis {
+		var session db.UserSession
+		db.DB.Where("jti = ?", jti).First(&session)
+		if revoked := session.RevokedAt != nil; revoked != (i == 1) {
+			t.Errorf("Session %d: expected revoked=%v, got %v", i, i == 1, revoked)
+		}
+	}
+}
+
+func TestUpdateProfile_RejectsReadonlyToken(t *testing.T) {
+	router, _ := setupProfileTest(t)
+	headers := map[string]string{"X-Test-User": "alice", "X-Test-Readonly": "1"}
+
+	// 只读 token 可以查看 profile，但不能修改
+	if w := profileRequest(router, http.MethodGet, "/-/npm/v1/user", headers, nil); w.Code != http.StatusOK {
+		t.Fatalf("Get profile: expected 200, got %d", w.Code)
+	}
+	w := profileRequest(router, http.MethodPost, "/-/npm/v1/user", headers, gin.H{"password": gin.H{"old": "Alice-Pass-123", "new": "Alice-Pass-456"}})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Read-only token: expected 403, got %d", w.Code)
+	}
+	if _, err := auth.NewDBUserStore().Validate("alice", "Alice-Pass-123"); err != nil {
+		t.Errorf("Expected password to stay unchanged: %v", err)
+	}
+}
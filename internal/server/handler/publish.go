@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,18 +15,19 @@ import (
 	"github.com/graperegistry/grape/internal/auth"
 	"github.com/graperegistry/grape/internal/db"
 	"github.com/graperegistry/grape/internal/logger"
-	"github.com/graperegistry/grape/internal/storage/local"
+	"github.com/graperegistry/grape/internal/storage"
 	"github.com/graperegistry/grape/internal/webhook"
 )
 
 type PublishHandler struct {
-	storage    *local.Storage
+	storage    storage.Storage
+	authz      *auth.PackageAuthorizer
 	locks      sync.Map // package name -> *sync.Mutex
 	dispatcher *webhook.Dispatcher
 }
 
-func NewPublishHandler(storage *local.Storage, dispatcher *webhook.Dispatcher) *PublishHandler {
-	return &PublishHandler{storage: storage, dispatcher: dispatcher}
+func NewPublishHandler(storage storage.Storage, authz *auth.PackageAuthorizer, dispatcher *webhook.Dispatcher) *PublishHandler {
+	return &PublishHandler{storage: storage, authz: authz, dispatcher: dispatcher}
 }
 
 // getPackageLock 获取包级别的互斥锁
@@ -48,6 +51,7 @@ type PublishRequest struct {
 	Access        string                            `json:"access"`
 	Attachments   map[string]Attachment             `json:"_attachments"`
 	Readme        string                            `json:"readme"`
+	Maintainers   []interface{}                     `json:"maintainers,omitempty"`
 	Rev           string                            `json:"_rev,omitempty"`
 }
 
@@ -67,12 +71,15 @@ func (h *PublishHandler) Publish(c *gin.Context) {
 		return
 	}
 
-	// 检查是否使用只读 Token
+	// 检查是否使用只读 Token（细粒度 token 的包范围在权限检查时校验）
 	tokenInfo := auth.GetTokenInfo(c)
-	if tokenInfo != nil && tokenInfo.Readonly {
+	if tokenInfo != nil && !tokenInfo.CanWrite() {
 		c.JSON(http.StatusForbidden, gin.H{"error": "read-only token cannot publish"})
 		return
 	}
+	if !requireOTP(c, user, auth.OTPWrite) {
+		return
+	}
 
 	packageName := decodePackageName(c.Param("package"))
 	if packageName == "" {
@@ -103,17 +110,32 @@ func (h *PublishHandler) Publish(c *gin.Context) {
 
 	// 检查包所有权（访问控制）
 	isNewPackage := !h.storage.HasPackage(packageName)
-	if !isNewPackage {
-		// 已有包：检查用户是否有权限发布
-		if !h.canUserPublishPackage(packageName, user) {
-			logger.Warnf("User %s is not allowed to publish package %s", user.Username, packageName)
+	if isNewPackage {
+		// 组织拥有的 scope 只有组织成员可以创建新包
+		if !auth.CanCreatePackage(user, packageName) {
+			logger.Warnf("User %s is not allowed to create package %s", user.Username, packageName)
 			c.JSON(http.StatusForbidden, gin.H{
-				"error": "you are not an owner of this package",
+				"error": "you are not a member of the organization that owns this scope",
 			})
 			return
 		}
 	}
 
+	// 没有附件的 PUT 是对已有包元数据的修改（npm deprecate、npm owner add/rm）
+	if !isNewPackage && len(req.Attachments) == 0 {
+		h.updateMetadata(c, user, packageName, &req)
+		return
+	}
+
+	// 检查用户是否有权限发布；新包会匹配 scope/通配符 owner 规则
+	if !h.authz.Authorize(c, packageName, auth.ActionPublish) {
+		logger.Warnf("User %s is not allowed to publish package %s", user.Username, packageName)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "you are not an owner of this package",
+		})
+		return
+	}
+
 	// 读取现有元数据并合并
 	var existingMeta map[string]interface{}
 	if h.storage.HasPackage(packageName) {
@@ -149,6 +171,8 @@ func (h *PublishHandler) Publish(c *gin.Context) {
 			return
 		}
 
+		// scoped 包的附件名带有 scope 前缀（@scope/name-1.0.0.tgz），只保留文件名
+		filename = path.Base(filename)
 		if err := h.storage.SaveTarball(packageName, filename, tarballData); err != nil {
 			logger.Errorf("Failed to save tarball: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save tarball"})
@@ -172,18 +196,30 @@ func (h *PublishHandler) Publish(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save metadata"})
 		return
 	}
+	indexPackage(h.storage, packageName, metadataJSON)
 
-	// 如果是新包，自动将发布者设为 owner
+	// 如果是新包，自动将发布者设为 owner；maintainers 始终与 package_owners 保持一致
 	if isNewPackage {
-		if err := h.addPackageOwner(packageName, user); err != nil {
+		if err := h.authz.AddOwner(packageName, user.ID); err != nil {
 			logger.Warnf("Failed to add package owner: %v", err)
 		} else {
 			logger.Infof("Added %s as owner of %s", user.Username, packageName)
 		}
+		// npm publish --access restricted
+		if req.Access == auth.AccessRestricted {
+			if err := auth.SetPackageAccess(packageName, auth.AccessRestricted, user.Username); err != nil {
+				logger.Warnf("Failed to set package access: %v", err)
+			}
+		}
+	} else if err := h.authz.SyncMaintainers(packageName); err != nil {
+		logger.Warnf("Failed to sync maintainers: %v", err)
 	}
 
 	logger.Infof("Package published: %s", packageName)
 	db.RecordAudit("package_publish", user.Username, c.ClientIP(), "发布包: "+packageName)
+	for _, version := range sortedVersions(req.Versions) {
+		recordChange(&db.PackageChange{PackageName: packageName, Type: db.ChangePublish, Version: version})
+	}
 
 	h.dispatcher.Dispatch(webhook.EventPackagePublished, gin.H{
 		"package":   packageName,
@@ -209,15 +245,15 @@ func (h *PublishHandler) Unpublish(c *gin.Context) {
 	packageName := decodePackageName(c.Param("package"))
 	filename := c.Param("filename")
 
-	// 权限检查：只有 admin 或包维护者可以删除
-	if user.Role != "admin" {
-		// 检查是否为包维护者
-		if !h.isPackageMaintainer(packageName, user.Username) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "insufficient permissions to unpublish this package",
-			})
-			return
-		}
+	// 权限检查：只有 admin 或包 owner 可以删除
+	if !h.authz.Authorize(c, packageName, auth.ActionUnpublish) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "insufficient permissions to unpublish this package",
+		})
+		return
+	}
+	if !requireOTP(c, user, auth.OTPWrite) {
+		return
 	}
 
 	// 获取包级别锁
@@ -235,6 +271,15 @@ func (h *PublishHandler) Unpublish(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete tarball"})
 			return
 		}
+		if err := db.SetTarballSize(packageName, filename, 0); err != nil {
+			logger.Warnf("Failed to index tarball size: %v", err)
+		}
+		// 没有先提交去掉版本的元数据的客户端：元数据中仍引用该 tarball 的版本一并删除
+		if err := h.unpublishTarballVersions(c, user, packageName, filename); err != nil {
+			logger.Errorf("Failed to remove versions of %s/-/%s: %v", packageName, filename, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update package metadata"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"ok": true})
 		return
 	}
@@ -246,6 +291,8 @@ func (h *PublishHandler) Unpublish(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete package"})
 		return
 	}
+	removePackageIndex(packageName)
+	recordChange(&db.PackageChange{PackageName: packageName, Type: db.ChangeUnpublish})
 
 	h.dispatcher.Dispatch(webhook.EventPackageUnpublished, gin.H{
 		"package":  packageName,
@@ -255,36 +302,34 @@ func (h *PublishHandler) Unpublish(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
-// isPackageMaintainer 检查用户是否为包的维护者
-func (h *PublishHandler) isPackageMaintainer(packageName, username string) bool {
+// unpublishTarballVersions 从元数据中删除 dist.tarball 指向 filename 的版本，调用方需持有包锁
+func (h *PublishHandler) unpublishTarballVersions(c *gin.Context, user *auth.User, packageName, filename string) error {
 	if !h.storage.HasPackage(packageName) {
-		return false
+		return nil
 	}
-
-	data, err := h.storage.GetMetadata(packageName)
+	meta, err := h.loadMetadata(packageName)
 	if err != nil {
-		return false
+		return err
+	}
+	versions, _ := meta["versions"].(map[string]interface{})
+	var removed []string
+	for ver, info := range versions {
+		v, _ := info.(map[string]interface{})
+		dist, _ := v["dist"].(map[string]interface{})
+		if tarball, _ := dist["tarball"].(string); tarball != "" && path.Base(tarball) == filename {
+			removed = append(removed, ver)
+		}
 	}
-
-	var meta map[string]interface{}
-	if err := json.Unmarshal(data, &meta); err != nil {
-		return false
+	if len(removed) == 0 {
+		return nil
 	}
-
-	maintainers, ok := meta["maintainers"].([]interface{})
-	if !ok {
-		return false
+	sort.Strings(removed)
+	removeVersions(meta, removed)
+	if err := h.saveMetadata(packageName, meta); err != nil {
+		return err
 	}
-
-	for _, m := range maintainers {
-		if maintainer, ok := m.(map[string]interface{}); ok {
-			if name, ok := maintainer["name"].(string); ok && name == username {
-				return true
-			}
-		}
-	}
-
-	return false
+	h.recordVersionUnpublish(c, user, packageName, removed)
+	return nil
 }
 
 // mergeMetadata 合并新旧元数据
@@ -336,21 +381,6 @@ func (h *PublishHandler) mergeMetadata(existing map[string]interface{}, req *Pub
 		existing["readme"] = req.Readme
 	}
 
-	// 添加维护者（如果不存在）
-	maintainers, _ := existing["maintainers"].([]interface{})
-	maintainerExists := false
-	for _, m := range maintainers {
-		if m, ok := m.(map[string]interface{}); ok {
-			if name, ok := m["name"].(string); ok && name == publisher {
-				maintainerExists = true
-				break
-			}
-		}
-	}
-	if !maintainerExists {
-		existing["maintainers"] = append(maintainers, map[string]string{"name": publisher})
-	}
-
 	return existing
 }
 
@@ -382,52 +412,3 @@ func decodePackageName(name string) string {
 	name = strings.ReplaceAll(name, "%40", "@")
 	return name
 }
-
-// canUserPublishPackage 检查用户是否有权限发布包
-func (h *PublishHandler) canUserPublishPackage(packageName string, user *auth.User) bool {
-	// admin 可以发布任何包
-	if user.Role == "admin" {
-		return true
-	}
-
-	// 检查是否有 owner 记录
-	var owners []db.PackageOwner
-	if err := db.DB.Where("package_name = ?", packageName).Preload("User").Find(&owners).Error; err != nil {
-		logger.Warnf("Failed to check package owners: %v", err)
-		// 如果查询失败，允许发布（向后兼容）
-		return true
-	}
-
-	// 如果没有 owner 记录，允许任何 developer 发布（向后兼容）
-	if len(owners) == 0 {
-		return true
-	}
-
-	// 检查用户是否在 owner 列表中
-	for _, owner := range owners {
-		if owner.User != nil && owner.User.Username == user.Username && owner.CanPublish {
-			return true
-		}
-	}
-
-	return false
-}
-
-// addPackageOwner 添加包 owner
-func (h *PublishHandler) addPackageOwner(packageName string, user *auth.User) error {
-	owner := &db.PackageOwner{
-		PackageName: packageName,
-		UserID:      user.ID,
-		CanPublish:  true,
-	}
-
-	if err := db.DB.Create(owner).Error; err != nil {
-		// 忽略重复键错误
-		if !strings.Contains(err.Error(), "UNIQUE constraint failed") &&
-			!strings.Contains(err.Error(), "Duplicate entry") {
-			return err
-		}
-	}
-
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+	"github.com/graperegistry/grape/internal/storage"
+	"github.com/graperegistry/grape/internal/storage/local"
+	"github.com/graperegistry/grape/internal/webhook"
+)
+
+// setupPublishTest 以 alice 身份发布 foo 的 1.0.0、1.1.0 两个版本
+func setupPublishTest(t *testing.T) (*gin.Engine, storage.Storage) {
+	t.Helper()
+	dbtest.Open(t, &db.User{}, &db.Package{}, &db.PackageVersion{}, &db.PackageChange{}, &db.PackageOwner{}, &db.PackageGrant{},
+		&db.PackageAccess{}, &db.AuditLog{}, &db.Webhook{}, &db.UserTwoFactor{},
+		&db.Organization{}, &db.OrgMember{}, &db.Team{}, &db.TeamMember{}, &db.TeamGrant{})
+
+	u := &db.User{Username: "alice", Password: "x", Email: "alice@example.com", Role: "developer"}
+	if err := db.DB.Create(u).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	alice := &auth.User{ID: u.ID, Username: u.Username, Role: u.Role}
+
+	store := local.New(t.TempDir())
+	dispatcher := webhook.NewDispatcher()
+	t.Cleanup(dispatcher.Stop)
+	authz := auth.NewPackageAuthorizer(store)
+	h := NewPublishHandler(store, authz, dispatcher)
+	owners := NewOwnerHandler(authz)
+
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set(string(auth.UserKey), alice)
+	})
+	router.PUT("/:package", h.Publish)
+	router.PUT("/:package/-rev/:rev", h.Publish)
+	router.DELETE("/:package/-/:filename/-rev/:rev", h.Unpublish)
+	router.PUT("/-/package/:name/dist-tags/:tag", h.SetDistTag)
+	router.DELETE("/-/package/:name/dist-tags/:tag", h.DeleteDistTag)
+	router.PUT("/-/package/:name/collaborators/:username", owners.AddOwner)
+
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		body := gin.H{
+			"name":      "foo",
+			"dist-tags": gin.H{"latest": version},
+			"versions":  gin.H{version: fooVersion(version)},
+			"_attachments": gin.H{"foo-" + version + ".tgz": gin.H{
+				"content_type": "application/octet-stream",
+				"data":         base64.StdEncoding.EncodeToString([]byte("tarball " + version)),
+			}},
+		}
+		if w := publishRequest(router, http.MethodPut, "/foo", body); w.Code != http.StatusCreated {
+			t.Fatalf("Publish %s: expected 201, got %d: %s", version, w.Code, w.Body.String())
+		}
+	}
+	return router, store
+}
+
+func fooVersion(version string) gin.H {
+	return gin.H{
+		"name":    "foo",
+		"version": version,
+		"dist":    gin.H{"tarball": "http://localhost:4873/foo/-/foo-" + version + ".tgz"},
+	}
+}
+
+func publishRequest(router *gin.Engine, method, target string, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// fooState 返回 foo 元数据中的版本、latest 和 package_versions 中的版本数
+func fooState(t *testing.T, store storage.Storage) (map[string]interface{}, string, int64) {
+	t.Helper()
+	data, err := store.GetMetadata("foo")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	var meta struct {
+		Versions map[string]interface{} `json:"versions"`
+		DistTags map[string]string      `json:"dist-tags"`
+	}
+	json.Unmarshal(data, &meta)
+	var indexed int64
+	db.DB.Model(&db.PackageVersion{}).Where("package_name = ?", "foo").Count(&indexed)
+	return meta.Versions, meta.DistTags["latest"], indexed
+}
+
+func unpublishChanges(t *testing.T) []db.PackageChange {
+	t.Helper()
+	var changes []db.PackageChange
+	db.DB.Where("type = ?", db.ChangeUnpublish).Order("seq").Find(&changes)
+	return changes
+}
+
+func TestUnpublishVersion(t *testing.T) {
+	router, store := setupPublishTest(t)
+
+	// npm unpublish foo@1.1.0：先 PUT 去掉该版本的元数据，再删除 tarball
+	body := gin.H{
+		"name":      "foo",
+		"dist-tags": gin.H{"latest": "1.0.0"},
+		"versions":  gin.H{"1.0.0": fooVersion("1.0.0")},
+	}
+	if w := publishRequest(router, http.MethodPut, "/foo/-rev/2-foo", body); w.Code != http.StatusOK {
+		t.Fatalf("PUT without 1.1.0: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	versions, latest, indexed := fooState(t, store)
+	if _, ok := versions["1.1.0"]; ok || len(versions) != 1 || latest != "1.0.0" || indexed != 1 {
+		t.Fatalf("Expected only 1.0.0 to remain, got versions %v, latest %s, %d indexed", versions, latest, indexed)
+	}
+
+	if w := publishRequest(router, http.MethodDelete, "/foo/-/foo-1.1.0.tgz/-rev/3-foo", nil); w.Code != http.StatusOK {
+		t.Fatalf("DELETE tarball: expected 200, got %d", w.Code)
+	}
+	if store.HasTarball("foo", "foo-1.1.0.tgz") || !store.HasTarball("foo", "foo-1.0.0.tgz") {
+		t.Error("Expected only the 1.1.0 tarball to be deleted")
+	}
+
+	// 只记录一次版本删除，不是删除整个包
+	changes := unpublishChanges(t)
+	if len(changes) != 1 || changes[0].Version != "1.1.0" {
+		t.Fatalf("Expected one unpublish change for 1.1.0, got %+v", changes)
+	}
+	if result := (&RegistryHandler{storage: store}).changeResult(nil, changes[0], false); result.Deleted {
+		t.Error("Version unpublish must not mark the package as deleted")
+	}
+}
+
+func TestUnpublishVersionByTarballDelete(t *testing.T) {
+	router, store := setupPublishTest(t)
+
+	// 不先提交元数据、直接删除 tarball 的客户端
+	if w := publishRequest(router, http.MethodDelete, "/foo/-/foo-1.1.0.tgz/-rev/2-foo", nil); w.Code != http.StatusOK {
+		t.Fatalf("DELETE tarball: expected 200, got %d", w.Code)
+	}
+	versions, latest, indexed := fooState(t, store)
+	if _, ok := versions["1.1.0"]; ok || latest != "1.0.0" || indexed != 1 {
+		t.Fatalf("Expected 1.1.0 to be removed, got versions %v, latest %s, %d indexed", versions, latest, indexed)
+	}
+	if changes := unpublishChanges(t); len(changes) != 1 || changes[0].Version != "1.1.0" {
+		t.Fatalf("Expected one unpublish change for 1.1.0, got %+v", changes)
+	}
+}
+
+func TestRemoveVersions(t *testing.T) {
+	meta := map[string]interface{}{
+		"versions":  map[string]interface{}{"1.0.0": gin.H{}, "1.1.0": gin.H{}, "2.0.0-beta.1": gin.H{}},
+		"time":      map[string]interface{}{"1.0.0": "2024-01-01T00:00:00Z", "1.1.0": "2024-02-01T00:00:00Z", "2.0.0-beta.1": "2024-03-01T00:00:00Z"},
+		"dist-tags": map[string]interface{}{"latest": "1.1.0", "beta": "2.0.0-beta.1", "legacy": "1.0.0"},
+	}
+	removeVersions(meta, []string{"1.1.0", "2.0.0-beta.1"})
+
+	tags := meta["dist-tags"].(map[string]interface{})
+	if len(tags) != 2 || tags["latest"] != "1.0.0" || tags["legacy"] != "1.0.0" {
+		t.Errorf("Unexpected dist-tags: %v", tags)
+	}
+	if _, ok := meta["time"].(map[string]interface{})["1.1.0"]; ok {
+		t.Error("Expected publish time of removed version to be deleted")
+	}
+}
+
+func TestWritesRequireOTP(t *testing.T) {
+	router, store := setupPublishTest(t)
+
+	// alice 开启 auth-and-writes 模式的两步验证后，修改 dist-tag 和 owner 同样需要 OTP
+	var alice db.User
+	db.DB.Where("username = ?", "alice").First(&alice)
+	tfa := db.UserTwoFactor{UserID: alice.ID, Secret: "JBSWY3DPEHPK3PXP", Mode: auth.TwoFactorAuthAndWrites}
+	db.DB.Create(&tfa)
+	db.DB.Model(&tfa).Update("pending", false)
+	db.DB.Create(&db.User{Username: "bob", Password: "x", Email: "bob@example.com", Role: "developer"})
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   interface{}
+	}{
+		{"set dist-tag", http.MethodPut, "/-/package/foo/dist-tags/latest", "1.0.0"},
+		{"delete dist-tag", http.MethodDelete, "/-/package/foo/dist-tags/beta", nil},
+		{"add owner", http.MethodPut, "/-/package/foo/collaborators/bob", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := publishRequest(router, tt.method, tt.target, tt.body)
+			if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "OTP" {
+				t.Errorf("Expected 401 with WWW-Authenticate: OTP, got %d %q", w.Code, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	if _, latest, _ := fooState(t, store); latest != "1.1.0" {
+		t.Errorf("Expected latest to stay 1.1.0, got %s", latest)
+	}
+	var owners int64
+	db.DB.Model(&db.PackageOwner{}).Where("package_name = ?", "foo").Count(&owners)
+	if owners != 1 {
+		t.Errorf("Expected bob not to be added as owner, got %d owners", owners)
+	}
+}
@@ -6,25 +6,36 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
 	"github.com/graperegistry/grape/internal/logger"
 	"github.com/graperegistry/grape/internal/metrics"
 	"github.com/graperegistry/grape/internal/registry"
-	"github.com/graperegistry/grape/internal/storage/local"
+	"github.com/graperegistry/grape/internal/storage"
 )
 
 type RegistryHandler struct {
 	proxy   *registry.Proxy
-	storage *local.Storage
+	storage storage.Storage
 	baseURL string
+
+	longpollTimeout time.Duration // /-/_changes longpoll 的最长等待时间
 }
 
-func NewRegistryHandler(proxy *registry.Proxy, storage *local.Storage, baseURL string) *RegistryHandler {
+// NewRegistryHandler 创建 RegistryHandler，longpoll 会在 writeTimeout 之前返回
+func NewRegistryHandler(proxy *registry.Proxy, storage storage.Storage, baseURL string, writeTimeout time.Duration) *RegistryHandler {
+	longpoll := 60 * time.Second
+	if writeTimeout > 0 {
+		longpoll = min(longpoll, max(writeTimeout-5*time.Second, writeTimeout/2))
+	}
 	return &RegistryHandler{
-		proxy:   proxy,
-		storage: storage,
-		baseURL: strings.TrimSuffix(baseURL, "/"),
+		proxy:           proxy,
+		storage:         storage,
+		baseURL:         strings.TrimSuffix(baseURL, "/"),
+		longpollTimeout: longpoll,
 	}
 }
 
@@ -51,6 +62,12 @@ func (h *RegistryHandler) GetPackage(c *gin.Context) {
 		return
 	}
 
+	// 受限包对无权限用户表现为不存在
+	if !auth.AuthorizeRead(c, packageName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "package not found"})
+		return
+	}
+
 	logger.Debugf("Getting package: %s", packageName)
 
 	baseURL := requestBaseURL(c)
@@ -84,10 +101,8 @@ func (h *RegistryHandler) GetPackage(c *gin.Context) {
 		return
 	}
 
-	// Cache the metadata
-	if err := h.storage.SaveMetadata(packageName, data); err != nil {
-		logger.Warnf("Failed to cache metadata: %v", err)
-	}
+	// Cache the metadata, marked with its upstream so it is indexed as a cached package
+	h.cacheMetadata(packageName, data)
 
 	// Rewrite tarball URLs
 	rewritten, err := h.rewriteTarballURLs(data, packageName, baseURL)
@@ -110,6 +125,11 @@ func (h *RegistryHandler) GetTarball(c *gin.Context) {
 		return
 	}
 
+	if !auth.AuthorizeRead(c, packageName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tarball not found"})
+		return
+	}
+
 	logger.Debugf("Getting tarball: %s/-/%s", packageName, filename)
 
 	// Check local storage first
@@ -139,12 +159,38 @@ func (h *RegistryHandler) GetTarball(c *gin.Context) {
 	// Cache the tarball
 	if err := h.storage.SaveTarball(packageName, filename, data); err != nil {
 		logger.Warnf("Failed to cache tarball: %v", err)
+	} else if err := db.SetTarballSize(packageName, filename, int64(len(data))); err != nil {
+		logger.Warnf("Failed to index tarball size: %v", err)
 	}
 
 	metrics.PackageDownloadsTotal.WithLabelValues(packageName).Inc()
 	c.Data(http.StatusOK, "application/octet-stream", data)
 }
 
+// cacheMetadata 缓存上游 metadata 并更新包索引
+func (h *RegistryHandler) cacheMetadata(packageName string, data []byte) {
+	var meta map[string]interface{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		logger.Warnf("Failed to cache metadata: %v", err)
+		return
+	}
+	upstream := h.proxy.UpstreamName(packageName)
+	if upstream == "" {
+		upstream = "default"
+	}
+	meta["_upstream"] = upstream
+	cached, err := json.Marshal(meta)
+	if err != nil {
+		logger.Warnf("Failed to cache metadata: %v", err)
+		return
+	}
+	if err := h.storage.SaveMetadata(packageName, cached); err != nil {
+		logger.Warnf("Failed to cache metadata: %v", err)
+		return
+	}
+	indexPackage(h.storage, packageName, cached)
+}
+
 func (h *RegistryHandler) rewriteTarballURLs(data []byte, packageName string, baseURL string) ([]byte, error) {
 	var pkg map[string]interface{}
 	if err := json.Unmarshal(data, &pkg); err != nil {
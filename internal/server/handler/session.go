@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// ListUserSessionsAdmin 列出用户的活跃登录会话 (管理员)
+// GET /-/api/admin/users/:username/sessions
+func (h *AuthHandler) ListUserSessionsAdmin(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := auth.ListSessions(user.ID)
+	if err != nil {
+		logger.Errorf("Failed to list sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+
+	current := auth.GetSessionID(c)
+	result := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, gin.H{
+			"id":         s.ID,
+			"method":     s.Method,
+			"ip":         s.IP,
+			"userAgent":  s.UserAgent,
+			"createdAt":  s.CreatedAt,
+			"lastSeenAt": s.LastSeenAt,
+			"expiresAt":  s.ExpiresAt,
+			"current":    current != "" && s.JTI == current,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+// RevokeUserSessionAdmin 撤销用户的单个会话 (管理员)
+// DELETE /-/api/admin/users/:username/sessions/:id
+func (h *AuthHandler) RevokeUserSessionAdmin(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+
+	if err := auth.RevokeSession(user.ID, uint(id)); err != nil {
+		if err == auth.ErrSessionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		logger.Errorf("Failed to revoke session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	adminName := ""
+	if admin := auth.GetCurrentUser(c); admin != nil {
+		adminName = admin.Username
+	}
+	db.RecordAudit("session_revoke", adminName, c.ClientIP(), fmt.Sprintf("撤销用户 %s 的会话 #%d", user.Username, id))
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// RevokeUserSessionsAdmin 撤销用户的全部会话 (管理员)
+// DELETE /-/api/admin/users/:username/sessions
+func (h *AuthHandler) RevokeUserSessionsAdmin(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	count, err := auth.RevokeUserSessions(user.ID, "")
+	if err != nil {
+		logger.Errorf("Failed to revoke sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	adminName := ""
+	if admin := auth.GetCurrentUser(c); admin != nil {
+		adminName = admin.Username
+	}
+	db.RecordAudit("session_revoke", adminName, c.ClientIP(), fmt.Sprintf("撤销用户 %s 的全部会话（%d 个）", user.Username, count))
+	c.JSON(http.StatusOK, gin.H{"ok": true, "revoked": count})
+}
+
+// findUser 按路径参数 username 查找用户，失败时写入响应
+func (h *AuthHandler) findUser(c *gin.Context) (*auth.User, bool) {
+	user, err := h.userStore.Get(c.Param("username"))
+	if err != nil {
+		if err == auth.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return nil, false
+	}
+	return user, true
+}
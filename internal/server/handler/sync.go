@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/importer"
+	"github.com/graperegistry/grape/internal/logger"
+	"github.com/graperegistry/grape/internal/storage"
+)
+
+// SyncJobStatus 同步任务的状态
+type SyncJobStatus struct {
+	Name     string                 `json:"name"`
+	From     string                 `json:"from"`
+	Scope    string                 `json:"scope,omitempty"`
+	Packages []string               `json:"packages,omitempty"`
+	Interval string                 `json:"interval"`
+	Running  bool                   `json:"running"`
+	Progress *importer.SyncProgress `json:"progress,omitempty"` // 运行中时最近处理完的包
+
+	LastStartedAt  *time.Time `json:"lastStartedAt,omitempty"`
+	LastFinishedAt *time.Time `json:"lastFinishedAt,omitempty"`
+	LastPackages   int        `json:"lastPackages"`
+	LastDownloaded int        `json:"lastDownloaded"`
+	LastFailed     int        `json:"lastFailed"`
+	LastErrors     []string   `json:"lastErrors,omitempty"`
+	NextRunAt      *time.Time `json:"nextRunAt,omitempty"`
+}
+
+type syncJob struct {
+	cfg     config.SyncJobConfig
+	trigger chan struct{}
+	status  SyncJobStatus // 由 SyncHandler.mu 保护
+}
+
+// SyncHandler 运行 sync.jobs 中配置的定时同步任务
+type SyncHandler struct {
+	storage storage.Storage
+	authz   *auth.PackageAuthorizer
+
+	mu     sync.Mutex
+	jobs   []*syncJob
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSyncHandler 创建 SyncHandler，调用 Start 后开始调度
+func NewSyncHandler(storage storage.Storage, authz *auth.PackageAuthorizer, jobs []config.SyncJobConfig) *SyncHandler {
+	h := &SyncHandler{storage: storage, authz: authz}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	for _, cfg := range jobs {
+		if !cfg.Enabled {
+			continue
+		}
+		interval := "manual"
+		if cfg.Interval > 0 {
+			interval = cfg.Interval.String()
+		}
+		h.jobs = append(h.jobs, &syncJob{
+			cfg:     cfg,
+			trigger: make(chan struct{}, 1),
+			status: SyncJobStatus{
+				Name:     cfg.Name,
+				From:     cfg.From,
+				Scope:    cfg.Scope,
+				Packages: cfg.Packages,
+				Interval: interval,
+			},
+		})
+	}
+	return h
+}
+
+// Start 为每个任务启动调度 goroutine，配置了间隔的任务启动后立即同步一次
+func (h *SyncHandler) Start() {
+	for _, job := range h.jobs {
+		h.wg.Add(1)
+		go h.schedule(job)
+	}
+}
+
+// Stop 取消正在进行的同步并等待调度 goroutine 退出
+func (h *SyncHandler) Stop() {
+	h.cancel()
+	h.wg.Wait()
+}
+
+func (h *SyncHandler) schedule(job *syncJob) {
+	defer h.wg.Done()
+
+	var timer *time.Timer
+	var tick <-chan time.Time
+	if job.cfg.Interval > 0 {
+		timer = time.NewTimer(0)
+		defer timer.Stop()
+		tick = timer.C
+	}
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-tick:
+		case <-job.trigger:
+		}
+		h.run(job)
+		if timer != nil {
+			timer.Stop()
+			select {
+			case <-timer.C:
+			default:
+			}
+			timer.Reset(job.cfg.Interval)
+			next := time.Now().Add(job.cfg.Interval)
+			h.mu.Lock()
+			job.status.NextRunAt = &next
+			h.mu.Unlock()
+		}
+	}
+}
+
+func (h *SyncHandler) run(job *syncJob) {
+	started := time.Now()
+	h.mu.Lock()
+	job.status.Running = true
+	job.status.Progress = nil
+	job.status.LastStartedAt = &started
+	h.mu.Unlock()
+
+	syncer, err := importer.NewSyncer(h.storage, h.authz, importer.SyncOptions{
+		From:     job.cfg.From,
+		Token:    job.cfg.Token,
+		Scope:    job.cfg.Scope,
+		Packages: job.cfg.Packages,
+		Progress: func(p importer.SyncProgress) {
+			h.mu.Lock()
+			job.status.Progress = &p
+			h.mu.Unlock()
+		},
+	})
+	var result *importer.SyncResult
+	if err == nil {
+		result, err = syncer.Run(h.ctx)
+	}
+
+	finished := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	job.status.Running = false
+	job.status.Progress = nil
+	job.status.LastFinishedAt = &finished
+	job.status.LastErrors = nil
+	job.status.LastPackages, job.status.LastDownloaded, job.status.LastFailed = 0, 0, 0
+	if result != nil {
+		job.status.LastPackages = len(result.Packages)
+		job.status.LastDownloaded = result.Downloaded
+		job.status.LastFailed = result.Failed
+		for _, pkg := range result.Packages {
+			for _, e := range pkg.Errors {
+				job.status.LastErrors = append(job.status.LastErrors, pkg.Name+": "+e)
+			}
+		}
+	}
+	if err != nil {
+		job.status.LastErrors = append(job.status.LastErrors, err.Error())
+		logger.Errorf("Sync job %s failed: %v", job.cfg.Name, err)
+		return
+	}
+	logger.Infof("Sync job %s finished: %d packages, %d versions downloaded, %d failed",
+		job.cfg.Name, len(result.Packages), result.Downloaded, result.Failed)
+}
+
+func (h *SyncHandler) findJob(name string) *syncJob {
+	for _, job := range h.jobs {
+		if job.cfg.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// ListSyncJobs 列出同步任务及其状态 (管理员)
+// GET /-/api/admin/sync
+func (h *SyncHandler) ListSyncJobs(c *gin.Context) {
+	h.mu.Lock()
+	jobs := make([]SyncJobStatus, 0, len(h.jobs))
+	for _, job := range h.jobs {
+		jobs = append(jobs, job.status)
+	}
+	h.mu.Unlock()
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// RunSyncJob 立即运行同步任务 (管理员)
+// POST /-/api/admin/sync/:name/run
+func (h *SyncHandler) RunSyncJob(c *gin.Context) {
+	job := h.findJob(c.Param("name"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sync job not found"})
+		return
+	}
+
+	h.mu.Lock()
+	running := job.status.Running
+	h.mu.Unlock()
+	if running {
+		c.JSON(http.StatusConflict, gin.H{"error": "sync job is already running"})
+		return
+	}
+	select {
+	case job.trigger <- struct{}{}:
+	default: // 已有待执行的触发
+	}
+
+	adminName := ""
+	if admin := auth.GetCurrentUser(c); admin != nil {
+		adminName = admin.Username
+	}
+	db.RecordAudit("package_sync", adminName, c.ClientIP(), fmt.Sprintf("手动触发同步任务: %s", job.cfg.Name))
+	c.JSON(http.StatusAccepted, gin.H{"message": "sync job started"})
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,30 +16,58 @@ import (
 	"github.com/graperegistry/grape/internal/logger"
 )
 
+const (
+	// tokenPrefixLen 列表中展示的 token 明文前缀长度
+	tokenPrefixLen = 6
+	// defaultTokensPerPage token 列表默认分页大小
+	defaultTokensPerPage = 10
+	// maxTokensPerPage token 列表最大分页大小
+	maxTokensPerPage = 100
+	// ssoReauthMaxAge SSO 账号创建 token 时要求的最近一次登录时间
+	ssoReauthMaxAge = 10 * time.Minute
+)
+
 // TokenHandler Token 管理 Handler
-type TokenHandler struct{}
+type TokenHandler struct {
+	userStore auth.UserStore
+}
 
 // NewTokenHandler 创建 TokenHandler
-func NewTokenHandler() *TokenHandler {
-	return &TokenHandler{}
+func NewTokenHandler(userStore auth.UserStore) *TokenHandler {
+	return &TokenHandler{userStore: userStore}
 }
 
 // CreateTokenRequest 创建 token 请求
 type CreateTokenRequest struct {
-	Name     string `json:"name"`              // token 名称，如 "github-ci"
-	Readonly bool   `json:"readonly"`          // 是否只读
-	Days     int    `json:"days,omitempty"`    // 有效期天数，0 表示永不过期
+	Name          string   `json:"name"`                     // token 名称，如 "github-ci"
+	Password      string   `json:"password,omitempty"`       // 当前密码，npm token create 会要求重新输入
+	Readonly      bool     `json:"readonly"`                 // 是否只读
+	CIDRWhitelist []string `json:"cidr_whitelist,omitempty"` // 允许使用该 token 的 CIDR 列表
+	Days          int      `json:"days,omitempty"`           // 有效期天数，0 表示永不过期
+
+	// 细粒度 token（提供 permissions 时创建）
+	Packages        []string `json:"packages,omitempty"`    // 包名或通配符规则，如 @acme/ui-*，为空表示所有包
+	Permissions     []string `json:"permissions,omitempty"` // read、publish、unpublish、deprecate、dist-tag、owner
+	BypassTwoFactor bool     `json:"bypass_2fa,omitempty"`  // 写操作不需要 OTP
 }
 
-// TokenResponse token 响应
+// TokenResponse token 响应（同时兼容 npm /-/npm/v1/tokens 格式）
 type TokenResponse struct {
-	ID        uint       `json:"id"`
-	Name      string     `json:"name"`
-	Readonly  bool       `json:"readonly"`
-	Token     string     `json:"token,omitempty"` // 仅创建时返回
-	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
-	LastUsed  *time.Time `json:"lastUsed,omitempty"`
-	CreatedAt time.Time  `json:"createdAt"`
+	ID            uint       `json:"id"`
+	Key           string     `json:"key"`
+	Name          string     `json:"name"`
+	Readonly      bool       `json:"readonly"`
+	CIDRWhitelist []string   `json:"cidr_whitelist"`
+	Granular      bool       `json:"granular"`
+	Packages      []string   `json:"packages,omitempty"`
+	Permissions   []string   `json:"permissions,omitempty"`
+	BypassTwoFA   bool       `json:"bypass_2fa"`
+	Token         string     `json:"token,omitempty"` // 创建时返回完整 token，列表中只返回前缀
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	LastUsed      *time.Time `json:"lastUsed,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	Created       time.Time  `json:"created"`
+	Updated       time.Time  `json:"updated"`
 }
 
 // CreateToken 创建新 token
@@ -59,6 +89,35 @@ func (h *TokenHandler) CreateToken(c *gin.Context) {
 		req.Name = "token"
 	}
 
+	// 签发新 token 前必须重新认证，避免泄露的 token 或会话被用来继续签发 token
+	otpVerified, ok := h.reauthenticate(c, user, req.Password)
+	if !ok {
+		return
+	}
+
+	cidrWhitelist, err := auth.ParseCIDRList(req.CIDRWhitelist)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	granular := len(req.Permissions) > 0 || len(req.Packages) > 0
+	var packages, permissions []string
+	if granular {
+		packages, permissions, err = auth.ParseTokenScope(req.Packages, req.Permissions)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if req.BypassTwoFactor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bypass_2fa is only supported for granular tokens"})
+		return
+	}
+	// 可以绕过两步验证的 token 需要当前用户通过一次 OTP 校验
+	if req.BypassTwoFactor && !otpVerified && !requireOTP(c, user, auth.OTPAccount) {
+		return
+	}
+
 	// 生成随机 token (32 字节 = 64 字符 hex)
 	tokenBytes := make([]byte, 32)
 	if _, err := rand.Read(tokenBytes); err != nil {
@@ -81,11 +140,21 @@ func (h *TokenHandler) CreateToken(c *gin.Context) {
 
 	// 创建 token 记录
 	tokenRecord := &db.Token{
-		UserID:    user.ID,
-		Name:      req.Name,
-		TokenHash: tokenHash,
-		Readonly:  req.Readonly,
-		ExpiresAt: expiresAt,
+		UserID:          user.ID,
+		Name:            req.Name,
+		TokenHash:       tokenHash,
+		TokenPrefix:     token[:tokenPrefixLen],
+		Readonly:        req.Readonly,
+		CIDRWhitelist:   strings.Join(cidrWhitelist, ","),
+		Granular:        granular,
+		Packages:        strings.Join(packages, ","),
+		Permissions:     strings.Join(permissions, ","),
+		BypassTwoFactor: req.BypassTwoFactor,
+		ExpiresAt:       expiresAt,
+	}
+	if granular {
+		// 只有 read 权限的细粒度 token 同时标记为只读，沿用只读 token 的限制
+		tokenRecord.Readonly = !(&auth.TokenInfo{Granular: true, Permissions: permissions}).CanWrite()
 	}
 
 	if err := db.DB.Create(tokenRecord).Error; err != nil {
@@ -99,23 +168,58 @@ func (h *TokenHandler) CreateToken(c *gin.Context) {
 		Action:   "token_create",
 		Username: user.Username,
 		IP:       c.ClientIP(),
-		Detail:   fmt.Sprintf("Created token '%s' (readonly: %v)", req.Name, req.Readonly),
+		Detail:   tokenAuditDetail(tokenRecord),
 	})
 
 	logger.Infof("User %s created token '%s'", user.Username, req.Name)
 
-	c.JSON(http.StatusCreated, TokenResponse{
-		ID:        tokenRecord.ID,
-		Name:      tokenRecord.Name,
-		Readonly:  tokenRecord.Readonly,
-		Token:     token, // 只在创建时返回一次
-		ExpiresAt: tokenRecord.ExpiresAt,
-		CreatedAt: tokenRecord.CreatedAt,
-	})
+	resp := toTokenResponse(tokenRecord)
+	resp.Token = token // 只在创建时返回一次
+	c.JSON(http.StatusCreated, resp)
 }
 
-// ListTokens 列出当前用户的所有 token
-// GET /-/npm/v1/tokens
+// reauthenticate 校验当前密码，错误密码计入账号锁定的失败次数；
+// 启用了两步验证的用户（如没有本地密码的 SSO 账号）也可以只提供 npm-otp，
+// OIDC 账号还可以用最近 ssoReauthMaxAge 内通过单点登录建立的会话代替密码。
+// 校验失败时写入响应并返回 false，otpVerified 表示已经用掉了请求中的 OTP
+func (h *TokenHandler) reauthenticate(c *gin.Context, user *auth.User, password string) (otpVerified, ok bool) {
+	if lockedUntil := auth.AccountLockedUntil(user.ID); !lockedUntil.IsZero() {
+		rejectLockedAccount(c, lockedUntil)
+		return false, false
+	}
+
+	if password != "" {
+		if _, err := h.userStore.Validate(user.Username, password); err != nil {
+			// 外部账号没有本地密码，猜测密码不计入锁定，否则 SSO 用户会把自己锁住
+			if err == auth.ErrInvalidPassword && !user.IsExternal() {
+				recordPasswordFailure(c, user)
+				return false, false
+			}
+			if err != auth.ErrInvalidPassword {
+				logger.Warnf("Failed to validate password of %s: %v", user.Username, err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
+			return false, false
+		}
+		return false, true
+	}
+
+	if c.GetHeader("npm-otp") != "" && auth.GetTwoFactorStatus(user.ID).Enabled {
+		return true, requireOTP(c, user, auth.OTPAccount)
+	}
+	if user.AuthSource == auth.AuthSourceOIDC {
+		if auth.IsRecentSession(auth.GetSessionID(c), auth.SessionMethodOIDC, ssoReauthMaxAge) {
+			return false, true
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign in again with single sign-on to create a token"})
+		return false, false
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "password required to create a token"})
+	return false, false
+}
+
+// ListTokens 分页列出当前用户的所有 token
+// GET /-/npm/v1/tokens?page=0&perPage=10
 func (h *TokenHandler) ListTokens(c *gin.Context) {
 	user := auth.GetCurrentUser(c)
 	if user == nil {
@@ -123,31 +227,55 @@ func (h *TokenHandler) ListTokens(c *gin.Context) {
 		return
 	}
 
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "0"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("perPage", strconv.Itoa(defaultTokensPerPage)))
+	if page < 0 {
+		page = 0
+	}
+	if perPage < 1 || perPage > maxTokensPerPage {
+		perPage = defaultTokensPerPage
+	}
+
+	var total int64
+	if err := db.DB.Model(&db.Token{}).Where("user_id = ?", user.ID).Count(&total).Error; err != nil {
+		logger.Errorf("Failed to count tokens: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list tokens"})
+		return
+	}
+
 	var tokens []db.Token
-	if err := db.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+	if err := db.DB.Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Offset(page * perPage).
+		Limit(perPage).
+		Find(&tokens).Error; err != nil {
 		logger.Errorf("Failed to list tokens: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list tokens"})
 		return
 	}
 
 	responses := make([]TokenResponse, len(tokens))
-	for i, t := range tokens {
-		responses[i] = TokenResponse{
-			ID:        t.ID,
-			Name:      t.Name,
-			Readonly:  t.Readonly,
-			ExpiresAt: t.ExpiresAt,
-			LastUsed:  t.LastUsed,
-			CreatedAt: t.CreatedAt,
+	for i := range tokens {
+		responses[i] = toTokenResponse(&tokens[i])
+		if tokens[i].TokenPrefix != "" {
+			responses[i].Token = tokens[i].TokenPrefix + "…"
 		}
 	}
 
+	// 下一页链接（npm 通过 urls.next 翻页）
+	urls := gin.H{}
+	if int64((page+1)*perPage) < total {
+		urls["next"] = fmt.Sprintf("%s%s?page=%d&perPage=%d", requestBaseURL(c), c.Request.URL.Path, page+1, perPage)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"objects": responses,
+		"total":   total,
+		"urls":    urls,
 	})
 }
 
-// DeleteToken 撤销 token
+// DeleteToken 撤销 token，支持数字 ID 或 npm 的 token key
 // DELETE /-/npm/v1/tokens/token/:id
 func (h *TokenHandler) DeleteToken(c *gin.Context) {
 	user := auth.GetCurrentUser(c)
@@ -163,8 +291,14 @@ func (h *TokenHandler) DeleteToken(c *gin.Context) {
 	}
 
 	// 查找 token，确保属于当前用户
+	query := db.DB.Where("user_id = ?", user.ID)
+	if len(tokenID) == sha256.Size*2 {
+		query = query.Where("token_hash = ?", strings.ToLower(tokenID))
+	} else {
+		query = query.Where("id = ?", tokenID)
+	}
 	var token db.Token
-	if err := db.DB.Where("id = ? AND user_id = ?", tokenID, user.ID).First(&token).Error; err != nil {
+	if err := query.First(&token).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
 		return
 	}
@@ -187,3 +321,44 @@ func (h *TokenHandler) DeleteToken(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
+
+// toTokenResponse 将 db.Token 转换为响应格式，key 使用 token 的 sha256 哈希
+func toTokenResponse(t *db.Token) TokenResponse {
+	cidrs := auth.SplitCIDRWhitelist(t.CIDRWhitelist)
+	if cidrs == nil {
+		cidrs = []string{}
+	}
+	updated := t.UpdatedAt
+	if updated.IsZero() {
+		updated = t.CreatedAt
+	}
+	return TokenResponse{
+		ID:            t.ID,
+		Key:           t.TokenHash,
+		Name:          t.Name,
+		Readonly:      t.Readonly,
+		CIDRWhitelist: cidrs,
+		Granular:      t.Granular,
+		Packages:      auth.SplitCommaList(t.Packages),
+		Permissions:   auth.SplitCommaList(t.Permissions),
+		BypassTwoFA:   t.BypassTwoFactor,
+		ExpiresAt:     t.ExpiresAt,
+		LastUsed:      t.LastUsed,
+		CreatedAt:     t.CreatedAt,
+		Created:       t.CreatedAt,
+		Updated:       updated,
+	}
+}
+
+// tokenAuditDetail 创建 token 的审计日志内容
+func tokenAuditDetail(t *db.Token) string {
+	if !t.Granular {
+		return fmt.Sprintf("Created token '%s' (readonly: %v, cidr: %s)", t.Name, t.Readonly, t.CIDRWhitelist)
+	}
+	packages := t.Packages
+	if packages == "" {
+		packages = "*"
+	}
+	return fmt.Sprintf("Created granular token '%s' (packages: %s, permissions: %s, bypass_2fa: %v, cidr: %s)",
+		t.Name, packages, t.Permissions, t.BypassTwoFactor, t.CIDRWhitelist)
+}
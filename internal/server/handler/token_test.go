@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+// setupTokenTest 创建用户 alice（密码 Alice-Pass-123）和 bob，X-Test-User 指定当前用户，
+// X-Test-Session 指定当前 JWT 会话的 jti
+func setupTokenTest(t *testing.T) (*gin.Engine, map[string]*auth.User) {
+	t.Helper()
+	dbtest.Open(t, &db.User{}, &db.Token{}, &db.AuditLog{}, &db.AccountLockout{}, &db.UserTwoFactor{}, &db.UserSession{})
+	store := auth.NewDBUserStore()
+	users := make(map[string]*auth.User)
+	for _, name := range []string{"alice", "bob"} {
+		if err := store.Create(&auth.User{Username: name, Password: "Alice-Pass-123", Role: "developer"}); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		users[name], _ = store.Get(name)
+	}
+
+	h := NewTokenHandler(store)
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		if user, ok := users[c.GetHeader("X-Test-User")]; ok {
+			c.Set(string(auth.UserKey), user)
+		}
+		if jti := c.GetHeader("X-Test-Session"); jti != "" {
+			c.Set(string(auth.SessionKey), jti)
+		}
+	})
+	router.POST("/-/npm/v1/tokens", h.CreateToken)
+	router.GET("/-/npm/v1/tokens", h.ListTokens)
+	router.DELETE("/-/npm/v1/tokens/token/:id", h.DeleteToken)
+	return router, users
+}
+
+func tokenRequest(router *gin.Engine, method, target, user string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateToken_RequiresPassword(t *testing.T) {
+	router, users := setupTokenTest(t)
+
+	// JWT 会话同样需要重新输入密码
+	if w := tokenRequest(router, http.MethodPost, "/-/npm/v1/tokens", "alice", gin.H{"name": "ci"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Without password: expected 401, got %d", w.Code)
+	}
+	if w := tokenRequest(router, http.MethodPost, "/-/npm/v1/tokens", "alice", gin.H{"name": "ci", "password": "Wrong-Pass-123"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Wrong password: expected 401, got %d", w.Code)
+	}
+	var lockout db.AccountLockout
+	if err := db.DB.Where("user_id = ?", users["alice"].ID).First(&lockout).Error; err != nil || lockout.FailedCount != 1 {
+		t.Fatalf("Expected wrong password to count as a login failure, got %+v, %v", lockout, err)
+	}
+
+	w := tokenRequest(router, http.MethodPost, "/-/npm/v1/tokens", "alice", gin.H{
+		"name": "ci", "password": "Alice-Pass-123", "cidr_whitelist": []string{"10.1.2.3/8", " 192.168.0.0/24 "},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create token: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp TokenResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Token) != 64 || strings.Join(resp.CIDRWhitelist, ",") != "10.0.0.0/8,192.168.0.0/24" {
+		t.Errorf("Unexpected token response: %+v", resp)
+	}
+
+	w = tokenRequest(router, http.MethodPost, "/-/npm/v1/tokens", "alice", gin.H{
+		"name": "ci", "password": "Alice-Pass-123", "cidr_whitelist": []string{"not-a-cidr"},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Invalid CIDR: expected 400, got %d", w.Code)
+	}
+}
+
+func TestCreateToken_LocksAccountAfterRepeatedFailures(t *testing.T) {
+	router, _ := setupTokenTest(t)
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 5; i++ {
+		w = tokenRequest(router, http.MethodPost, "/-/npm/v1/tokens", "alice", gin.H{"password": "Wrong-Pass-123"})
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected account to be locked after repeated failures, got %d", w.Code)
+	}
+	if w := tokenRequest(router, http.MethodPost, "/-/npm/v1/tokens", "alice", gin.H{"password": "Alice-Pass-123"}); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Locked account: expected 429 even with the right password, got %d", w.Code)
+	}
+}
+
+func TestCreateToken_OIDCUser(t *testing.T) {
+	router, users := setupTokenTest(t)
+	store := auth.NewDBUserStore()
+	// 与 OIDC 自动创建的账号一致：随机本地密码，用户并不知道
+	if err := store.Create(&auth.User{Username: "carol", Password: "Random-Pass-123", Role: "developer", AuthSource: auth.AuthSourceOIDC}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	users["carol"], _ = store.Get("carol")
+	carol := users["carol"]
+
+	now := time.Now()
+	db.DB.Create(&db.UserSession{JTI: "fresh", UserID: carol.ID, Username: "carol", Method: auth.SessionMethodOIDC, ExpiresAt: now.Add(time.Hour)})
+	db.DB.Create(&db.UserSession{JTI: "stale", UserID: carol.ID, Username: "carol", Method: auth.SessionMethodOIDC, ExpiresAt: now.Add(time.Hour), CreatedAt: now.Add(-time.Hour)})
+
+	create := func(jti string, body gin.H) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/-/npm/v1/tokens", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", "carol")
+		req.Header.Set("X-Test-Session", jti)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 猜测密码不计入锁定，SSO 用户不会把自己锁住
+	for i := 0; i < 6; i++ {
+		if w := create("stale", gin.H{"name": "ci", "password": "Wrong-Pass-123"}); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Wrong password: expected 401, got %d", w.Code)
+		}
+	}
+	if until := auth.AccountLockedUntil(carol.ID); !until.IsZero() {
+		t.Fatalf("Expected OIDC user not to be locked out, locked until %v", until)
+	}
+
+	if w := create("stale", gin.H{"name": "ci"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Stale SSO session: expected 401, got %d", w.Code)
+	}
+	if w := create("fresh", gin.H{"name": "ci"}); w.Code != http.StatusCreated {
+		t.Fatalf("Fresh SSO session: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestListTokens_Pagination(t *testing.T) {
+	router, users := setupTokenTest(t)
+	for _, name := range []string{"a", "b", "c"} {
+		db.DB.Create(&db.Token{UserID: users["alice"].ID, Name: name, TokenHash: strings.Repeat(name, 64), TokenPrefix: name})
+	}
+	db.DB.Create(&db.Token{UserID: users["bob"].ID, Name: "bob", TokenHash: strings.Repeat("d", 64)})
+
+	var page struct {
+		Objects []TokenResponse   `json:"objects"`
+		Total   int64             `json:"total"`
+		URLs    map[string]string `json:"urls"`
+	}
+	w := tokenRequest(router, http.MethodGet, "/-/npm/v1/tokens?perPage=2", "alice", nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Objects) != 2 || page.Total != 3 || !strings.Contains(page.URLs["next"], "page=1&perPage=2") {
+		t.Fatalf("Unexpected first page: %s", w.Body.String())
+	}
+	if !strings.HasSuffix(page.Objects[0].Token, "…") {
+		t.Errorf("Expected only the token prefix to be listed, got %q", page.Objects[0].Token)
+	}
+
+	page.URLs = nil
+	w = tokenRequest(router, http.MethodGet, "/-/npm/v1/tokens?page=1&perPage=2", "alice", nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Objects) != 1 || page.URLs["next"] != "" {
+		t.Fatalf("Unexpected last page: %s", w.Body.String())
+	}
+}
+
+func TestDeleteToken(t *testing.T) {
+	router, users := setupTokenTest(t)
+	mine := &db.Token{UserID: users["alice"].ID, Name: "mine", TokenHash: strings.Repeat("a", 64)}
+	other := &db.Token{UserID: users["alice"].ID, Name: "other", TokenHash: strings.Repeat("b", 64)}
+	bobs := &db.Token{UserID: users["bob"].ID, Name: "bob", TokenHash: strings.Repeat("c", 64)}
+	for _, token := range []*db.Token{mine, other, bobs} {
+		db.DB.Create(token)
+	}
+
+	// npm token revoke 传入的是 key（token 的 sha256），大小写不敏感
+	if w := tokenRequest(router, http.MethodDelete, "/-/npm/v1/tokens/token/"+strings.ToUpper(mine.TokenHash), "alice", nil); w.Code != http.StatusOK {
+		t.Fatalf("Delete by key: expected 200, got %d", w.Code)
+	}
+	// 不能删除其他用户的 token
+	if w := tokenRequest(router, http.MethodDelete, "/-/npm/v1/tokens/token/"+bobs.TokenHash, "alice", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Delete other user's token: expected 404, got %d", w.Code)
+	}
+	if w := tokenRequest(router, http.MethodDelete, "/-/npm/v1/tokens/token/"+strconv.FormatUint(uint64(other.ID), 10), "alice", nil); w.Code != http.StatusOK {
+		t.Errorf("Delete by id: expected 200, got %d", w.Code)
+	}
+
+	var remaining int64
+	db.DB.Model(&db.Token{}).Count(&remaining)
+	if remaining != 1 {
+		t.Errorf("Expected only bob's token to remain, got %d tokens", remaining)
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// TrustedPublishingHandler CI 使用 OIDC ID token 换取短期发布凭证
+// 与 npm CLI（>= 11.5）的 trusted publishing 流程兼容：npm publish 在 CI 中自动完成交换
+type TrustedPublishingHandler struct {
+	publisher *auth.TrustedPublisher
+	authz     *auth.PackageAuthorizer
+	userStore auth.UserStore
+}
+
+// NewTrustedPublishingHandler 创建 TrustedPublishingHandler
+func NewTrustedPublishingHandler(publisher *auth.TrustedPublisher, authz *auth.PackageAuthorizer, userStore auth.UserStore) *TrustedPublishingHandler {
+	return &TrustedPublishingHandler{
+		publisher: publisher,
+		authz:     authz,
+		userStore: userStore,
+	}
+}
+
+// Exchange 校验 ID token 并按信任策略签发只能发布该包的短期 token
+// POST /-/npm/v1/oidc/token/exchange/package/:name
+// Authorization: Bearer <CI 签发的 ID token>
+func (h *TrustedPublishingHandler) Exchange(c *gin.Context) {
+	if !h.publisher.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "trusted publishing is not enabled"})
+		return
+	}
+
+	packageName := decodePackageName(c.Param("name"))
+	idToken := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if idToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "oidc id token required"})
+		return
+	}
+
+	identity, err := h.publisher.Verify(idToken)
+	if err != nil {
+		logger.Warnf("Trusted publishing: rejected oidc token for %s: %v", packageName, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid oidc token"})
+		return
+	}
+
+	policy, err := h.publisher.Match(identity, packageName)
+	if err != nil {
+		logger.Warnf("Trusted publishing: %s (%s) is not trusted to publish %s", identity.Subject(), identity.Issuer, packageName)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": fmt.Sprintf("no trusted publisher configuration allows this workflow to publish %s", packageName),
+		})
+		return
+	}
+
+	user, err := h.userStore.Get(policy.User)
+	if err != nil {
+		logger.Errorf("Trusted publishing: policy user %q for %s: %v", policy.User, packageName, err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "trusted publisher account is not available"})
+		return
+	}
+	if !h.authz.Can(user, packageName, auth.ActionPublish) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not an owner of this package"})
+		return
+	}
+
+	token, expiresAt, err := auth.IssueTrustedToken(user, packageName, identity.Subject(), h.publisher.TokenTTL())
+	if err != nil {
+		logger.Errorf("Failed to issue trusted publishing token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+
+	db.RecordAudit("trusted_publish_exchange", user.Username, c.ClientIP(),
+		fmt.Sprintf("%s (%s) -> %s", identity.Subject(), identity.Issuer, packageName))
+	c.JSON(http.StatusCreated, gin.H{
+		"token":   token,
+		"expires": expiresAt,
+	})
+}
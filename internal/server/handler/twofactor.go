@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// requireOTP 检查 npm-otp 请求头，不满足两步验证要求时写入响应并返回 false
+// 缺少或错误的 OTP 返回 401 + WWW-Authenticate: OTP，npm CLI 会提示输入 OTP 并重试
+func requireOTP(c *gin.Context, user *auth.User, scope auth.OTPScope) bool {
+	otp := c.GetHeader("npm-otp")
+	limitKey := "otp:" + user.Username
+	if otp != "" && !getLoginLimiter().checkLimit(limitKey) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many one-time password attempts, please try again later"})
+		return false
+	}
+
+	err := auth.CheckOTP(user, otp, scope)
+	// 允许绕过两步验证的细粒度 token（CI 发布）不需要 OTP，但仍受 require_for_publishers 约束
+	if err == auth.ErrOTPRequired && scope == auth.OTPWrite {
+		if tokenInfo := auth.GetTokenInfo(c); tokenInfo != nil && tokenInfo.Granular && tokenInfo.BypassTwoFactor {
+			err = nil
+		}
+	}
+
+	switch err {
+	case nil:
+		if otp != "" {
+			getLoginLimiter().reset(limitKey)
+		}
+		return true
+	case auth.ErrOTPRequired, auth.ErrInvalidOTP:
+		c.Header("WWW-Authenticate", "OTP")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "otpRequired": true})
+	case auth.ErrTwoFactorRequired:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "two-factor authentication is required to publish, enable it with `npm profile enable-2fa auth-and-writes`",
+		})
+	default:
+		logger.Errorf("Failed to verify one-time password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify one-time password"})
+	}
+	return false
+}
+
+// updateTwoFactor 处理 npm profile enable-2fa / disable-2fa
+//
+//  1. {"tfa": {"password", "mode"}}：生成密钥，返回 {"tfa": "otpauth://..."}
+//  2. {"tfa": ["<otp>"]}：确认开启，返回 {"tfa": [恢复码...]}
+//
+// 已开启时切换模式返回 {"tfa": null}；mode 为 disable 时关闭。已开启时的任何修改都需要 npm-otp
+func (h *AuthHandler) updateTwoFactor(c *gin.Context, user *auth.User, raw json.RawMessage) {
+	var otps []string
+	if err := json.Unmarshal(raw, &otps); err == nil {
+		if len(otps) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "one-time password required"})
+			return
+		}
+		codes, err := auth.ConfirmTwoFactor(user.ID, otps[0])
+		if err != nil {
+			if err == auth.ErrTwoFactorNotPending || err == auth.ErrInvalidOTP {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Errorf("Failed to enable two-factor authentication: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication"})
+			return
+		}
+		db.RecordAudit("2fa_enable", user.Username, c.ClientIP(), "启用两步验证")
+		c.JSON(http.StatusOK, gin.H{"tfa": codes})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Mode     string `json:"mode"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tfa request"})
+		return
+	}
+	if req.Mode != "disable" && !auth.ValidTwoFactorMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": auth.ErrInvalidTwoFactorMode.Error()})
+		return
+	}
+	if _, err := h.userStore.Validate(user.Username, req.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
+		return
+	}
+	if !requireOTP(c, user, auth.OTPAccount) {
+		return
+	}
+
+	status := auth.GetTwoFactorStatus(user.ID)
+	switch {
+	case req.Mode == "disable":
+		if err := auth.DisableTwoFactor(user.ID); err != nil {
+			logger.Errorf("Failed to disable two-factor authentication: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+			return
+		}
+		if status.Enabled {
+			db.RecordAudit("2fa_disable", user.Username, c.ClientIP(), "关闭两步验证")
+		}
+		c.JSON(http.StatusOK, toProfileResponse(user))
+	case status.Enabled:
+		if err := auth.SetTwoFactorMode(user.ID, req.Mode); err != nil {
+			logger.Errorf("Failed to update two-factor mode: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update two-factor authentication"})
+			return
+		}
+		db.RecordAudit("2fa_mode", user.Username, c.ClientIP(), "两步验证模式: "+req.Mode)
+		c.JSON(http.StatusOK, gin.H{"tfa": nil})
+	default:
+		otpauthURL, err := auth.BeginTwoFactor(user, req.Mode)
+		if err != nil {
+			logger.Errorf("Failed to start two-factor setup: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start two-factor setup"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tfa": otpauthURL})
+	}
+}
+
+// ResetTwoFactorAdmin 管理员为丢失认证器和恢复码的用户关闭两步验证
+// DELETE /-/api/admin/users/:username/2fa
+func (h *AuthHandler) ResetTwoFactorAdmin(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if err := auth.DisableTwoFactor(user.ID); err != nil {
+		logger.Errorf("Failed to reset two-factor authentication: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset two-factor authentication"})
+		return
+	}
+
+	adminName := ""
+	if admin := auth.GetCurrentUser(c); admin != nil {
+		adminName = admin.Username
+	}
+	db.RecordAudit("2fa_reset", adminName, c.ClientIP(), "重置用户两步验证: "+user.Username)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
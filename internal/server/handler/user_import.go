@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// maxHtpasswdSize 导入的 htpasswd 内容上限
+const maxHtpasswdSize = 4 << 20
+
+// ImportUsers 从 htpasswd 内容导入用户 (管理员)
+// POST /-/api/admin/users/import
+func (h *AuthHandler) ImportUsers(c *gin.Context) {
+	var req struct {
+		Htpasswd           string `json:"htpasswd" binding:"required"`
+		Role               string `json:"role"`
+		Overwrite          bool   `json:"overwrite"`
+		MustChangePassword bool   `json:"mustChangePassword"`
+		DryRun             bool   `json:"dryRun"`
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxHtpasswdSize)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if err := auth.ValidateRole(req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := auth.ImportHtpasswd(strings.NewReader(req.Htpasswd), auth.HtpasswdImportOptions{
+		Role:               req.Role,
+		Overwrite:          req.Overwrite,
+		MustChangePassword: req.MustChangePassword,
+		DryRun:             req.DryRun,
+	})
+	if err != nil {
+		logger.Errorf("Failed to import users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import users"})
+		return
+	}
+
+	if !req.DryRun {
+		adminName := ""
+		if admin := auth.GetCurrentUser(c); admin != nil {
+			adminName = admin.Username
+		}
+		db.RecordAudit("user_import", adminName, c.ClientIP(),
+			fmt.Sprintf("从 htpasswd 导入用户: 新建 %d, 更新 %d", len(result.Created), len(result.Updated)))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dryRun":  req.DryRun,
+		"created": result.Created,
+		"updated": result.Updated,
+		"skipped": result.Skipped,
+		"errors":  result.Errors,
+	})
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+const (
+	// webLoginTTL 网页登录会话有效期
+	webLoginTTL = 10 * time.Minute
+	// webLoginRetryAfter CLI 轮询 doneUrl 的间隔（秒）
+	webLoginRetryAfter = 5
+)
+
+// WebLoginHandler 处理 npm login --auth-type=web 流程：
+// CLI 创建会话并轮询 doneUrl，用户在 Web UI 确认后，CLI 下一次轮询时签发 token。
+// token 只在轮询响应中出现，不保存在会话记录中
+type WebLoginHandler struct {
+	jwtService *auth.JWTService
+	userStore  auth.UserStore
+	webPort    int
+}
+
+// NewWebLoginHandler 创建 WebLoginHandler，webPort 为 Web UI 端口，用于生成 loginUrl
+func NewWebLoginHandler(jwtService *auth.JWTService, userStore auth.UserStore, webPort int) *WebLoginHandler {
+	return &WebLoginHandler{
+		jwtService: jwtService,
+		userStore:  userStore,
+		webPort:    webPort,
+	}
+}
+
+// StartLogin 创建网页登录会话，返回浏览器登录地址和 CLI 轮询地址
+// POST /-/v1/login
+func (h *WebLoginHandler) StartLogin(c *gin.Context) {
+	var req struct {
+		Hostname string `json:"hostname"`
+	}
+	// 请求体可以为空
+	_ = c.ShouldBindJSON(&req)
+
+	sessionID, err := newWebLoginSessionID()
+	if err != nil {
+		logger.Errorf("Failed to generate web login session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create login session"})
+		return
+	}
+
+	session := &db.WebLoginSession{
+		SessionID: sessionID,
+		Hostname:  req.Hostname,
+		IP:        c.ClientIP(),
+		ExpiresAt: time.Now().Add(webLoginTTL),
+	}
+	if err := db.DB.Create(session).Error; err != nil {
+		logger.Errorf("Failed to save web login session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create login session"})
+		return
+	}
+
+	// 顺便清理过期会话
+	purgeExpiredWebLogins()
+
+	c.JSON(http.StatusOK, gin.H{
+		"loginUrl": fmt.Sprintf("%s/login?cli_session=%s", h.webBaseURL(c), url.QueryEscape(sessionID)),
+		"doneUrl":  fmt.Sprintf("%s/-/v1/done?sessionId=%s", requestBaseURL(c), url.QueryEscape(sessionID)),
+	})
+}
+
+// PollLogin CLI 轮询登录结果，未确认时返回 202，确认后签发 token（只下发一次）
+// GET /-/v1/done?sessionId=xxx
+func (h *WebLoginHandler) PollLogin(c *gin.Context) {
+	purgeExpiredWebLogins()
+
+	session, ok := findWebLoginSession(c.Query("sessionId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "login session not found or expired"})
+		return
+	}
+
+	if session.ConfirmedAt == nil {
+		c.Header("Retry-After", fmt.Sprintf("%d", webLoginRetryAfter))
+		c.JSON(http.StatusAccepted, gin.H{})
+		return
+	}
+
+	// token 只下发一次：并发轮询时只有删除成功的请求签发 token
+	result := db.DB.Where("id = ?", session.ID).Delete(&db.WebLoginSession{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "login session not found or expired"})
+		return
+	}
+
+	// 确认之后账号可能已被删除
+	user, err := h.userStore.Get(session.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "login session not found or expired"})
+		return
+	}
+	token, err := h.jwtService.IssueSession(user, auth.SessionMethodWebLogin, session.IP, session.Hostname)
+	if err != nil {
+		logger.Errorf("Failed to generate token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// GetLoginSession Web UI 查询待确认的登录会话
+// GET /-/api/v1/login/:session
+func (h *WebLoginHandler) GetLoginSession(c *gin.Context) {
+	session, ok := findWebLoginSession(c.Param("session"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "login session not found or expired"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hostname":  session.Hostname,
+		"ip":        session.IP,
+		"confirmed": session.ConfirmedAt != nil,
+		"createdAt": session.CreatedAt,
+		"expiresAt": session.ExpiresAt,
+	})
+}
+
+// ConfirmLogin 已登录的 Web UI 用户确认 CLI 登录，CLI 下一次轮询时以该用户身份签发 token
+// POST /-/api/v1/login/:session
+func (h *WebLoginHandler) ConfirmLogin(c *gin.Context) {
+	user := auth.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	session, ok := findWebLoginSession(c.Param("session"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "login session not found or expired"})
+		return
+	}
+	if session.ConfirmedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "login session already confirmed"})
+		return
+	}
+
+	now := time.Now()
+	result := db.DB.Model(&db.WebLoginSession{}).
+		Where("id = ? AND confirmed_at IS NULL", session.ID).
+		Updates(map[string]interface{}{
+			"username":     user.Username,
+			"confirmed_at": now,
+		})
+	if result.Error != nil {
+		logger.Errorf("Failed to confirm web login session: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm login"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "login session already confirmed"})
+		return
+	}
+
+	logger.Infof("User %s confirmed web login for %s (%s)", user.Username, session.Hostname, session.IP)
+	db.RecordAudit("login", user.Username, c.ClientIP(), fmt.Sprintf("网页登录确认: %s (%s)", session.Hostname, session.IP))
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// CancelLogin Web UI 用户拒绝 CLI 登录
+// DELETE /-/api/v1/login/:session
+func (h *WebLoginHandler) CancelLogin(c *gin.Context) {
+	if auth.GetCurrentUser(c) == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	db.DB.Where("session_id = ? AND confirmed_at IS NULL", c.Param("session")).Delete(&db.WebLoginSession{})
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// webBaseURL 推断 Web UI 的访问地址：反向代理场景沿用转发的 host，否则替换为 Web UI 端口
+func (h *WebLoginHandler) webBaseURL(c *gin.Context) string {
+	base := requestBaseURL(c)
+	if c.GetHeader("X-Forwarded-Host") != "" || h.webPort == 0 {
+		return base
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	host := u.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	u.Host = net.JoinHostPort(host, fmt.Sprintf("%d", h.webPort))
+	return u.String()
+}
+
+// purgeExpiredWebLogins 删除过期的登录会话（包括已确认但 CLI 没有来取 token 的会话）
+func purgeExpiredWebLogins() {
+	db.DB.Where("expires_at < ?", time.Now()).Delete(&db.WebLoginSession{})
+}
+
+// findWebLoginSession 查找未过期的登录会话
+func findWebLoginSession(sessionID string) (*db.WebLoginSession, bool) {
+	if sessionID == "" {
+		return nil, false
+	}
+	var session db.WebLoginSession
+	if err := db.DB.Where("session_id = ? AND expires_at > ?", sessionID, time.Now()).First(&session).Error; err != nil {
+		return nil, false
+	}
+	return &session, true
+}
+
+// newWebLoginSessionID 生成随机会话 ID
+func newWebLoginSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
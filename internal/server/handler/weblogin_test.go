@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+func setupWebLoginTest(t *testing.T) (*gin.Engine, *auth.JWTService, *auth.User) {
+	t.Helper()
+	dbtest.Open(t, &db.User{}, &db.WebLoginSession{}, &db.UserSession{}, &db.AuditLog{})
+
+	store := auth.NewDBUserStore()
+	if err := store.Create(&auth.User{Username: "alice", Password: "Secret-Pass-1", Role: "developer"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	alice, _ := store.Get("alice")
+
+	jwtService := auth.NewJWTService("test-secret", time.Hour)
+	h := NewWebLoginHandler(jwtService, store, 4873)
+
+	router := setupTestRouter()
+	router.POST("/-/v1/login", h.StartLogin)
+	router.GET("/-/v1/done", h.PollLogin)
+	// Web UI 接口：X-Test-User 头模拟已登录用户
+	web := router.Group("/-/api/v1/login", func(c *gin.Context) {
+		if c.GetHeader("X-Test-User") == "alice" {
+			c.Set(string(auth.UserKey), alice)
+		}
+	})
+	web.GET("/:session", h.GetLoginSession)
+	web.POST("/:session", h.ConfirmLogin)
+	web.DELETE("/:session", h.CancelLogin)
+	return router, jwtService, alice
+}
+
+func webLoginRequest(router *gin.Engine, method, target string, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(`{"hostname":"laptop"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// startWebLogin 发起登录，返回会话 ID
+func startWebLogin(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	w := webLoginRequest(router, http.MethodPost, "/-/v1/login", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("StartLogin: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		LoginURL string `json:"loginUrl"`
+		DoneURL  string `json:"doneUrl"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	loginURL, _ := url.Parse(resp.LoginURL)
+	doneURL, _ := url.Parse(resp.DoneURL)
+	session := loginURL.Query().Get("cli_session")
+	if loginURL.Path != "/login" || session == "" || doneURL.Query().Get("sessionId") != session {
+		t.Fatalf("Unexpected login urls: %+v", resp)
+	}
+	return session
+}
+
+func TestWebLogin_StartConfirmPoll(t *testing.T) {
+	router, jwtService, alice := setupWebLoginTest(t)
+	session := startWebLogin(t, router)
+	done := "/-/v1/done?sessionId=" + session
+
+	w := webLoginRequest(router, http.MethodGet, done, "")
+	if w.Code != http.StatusAccepted || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Poll before confirm: expected 202 with Retry-After, got %d", w.Code)
+	}
+
+	if w := webLoginRequest(router, http.MethodGet, "/-/api/v1/login/"+session, "alice"); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), `"hostname":"laptop"`) {
+		t.Fatalf("GetLoginSession: got %d: %s", w.Code, w.Body.String())
+	}
+	if w := webLoginRequest(router, http.MethodPost, "/-/api/v1/login/"+session, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Confirm without login: expected 401, got %d", w.Code)
+	}
+	if w := webLoginRequest(router, http.MethodPost, "/-/api/v1/login/"+session, "alice"); w.Code != http.StatusOK {
+		t.Fatalf("Confirm: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := webLoginRequest(router, http.MethodPost, "/-/api/v1/login/"+session, "alice"); w.Code != http.StatusConflict {
+		t.Fatalf("Second confirm: expected 409, got %d", w.Code)
+	}
+
+	// 确认后还没有签发 token
+	var sessions int64
+	db.DB.Model(&db.UserSession{}).Count(&sessions)
+	if sessions != 0 {
+		t.Fatalf("Expected no JWT session before the CLI polls, got %d", sessions)
+	}
+
+	w = webLoginRequest(router, http.MethodGet, done, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Poll after confirm: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	claims, err := jwtService.ValidateToken(resp.Token)
+	if err != nil || claims.Username != alice.Username {
+		t.Fatalf("Invalid token %q: %v", resp.Token, err)
+	}
+	if err := auth.CheckSession(claims); err != nil {
+		t.Errorf("Issued token has no valid session: %v", err)
+	}
+
+	// token 只下发一次
+	if w := webLoginRequest(router, http.MethodGet, done, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Second poll: expected 404, got %d", w.Code)
+	}
+}
+
+func TestWebLogin_Cancel(t *testing.T) {
+	router, _, _ := setupWebLoginTest(t)
+	session := startWebLogin(t, router)
+
+	if w := webLoginRequest(router, http.MethodDelete, "/-/api/v1/login/"+session, "alice"); w.Code != http.StatusOK {
+		t.Fatalf("Cancel: expected 200, got %d", w.Code)
+	}
+	if w := webLoginRequest(router, http.MethodGet, "/-/v1/done?sessionId="+session, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Poll after cancel: expected 404, got %d", w.Code)
+	}
+}
+
+func TestWebLogin_PurgesExpiredSessions(t *testing.T) {
+	router, _, _ := setupWebLoginTest(t)
+
+	now := time.Now()
+	db.DB.Create(&db.WebLoginSession{SessionID: "stale", Username: "alice", ConfirmedAt: &now, ExpiresAt: now.Add(-time.Minute)})
+
+	if w := webLoginRequest(router, http.MethodGet, "/-/v1/done?sessionId=stale", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("Poll of expired session: expected 404, got %d", w.Code)
+	}
+	var count int64
+	db.DB.Model(&db.WebLoginSession{}).Where("session_id = ?", "stale").Count(&count)
+	if count != 0 {
+		t.Errorf("Expected expired session to be purged")
+	}
+}
@@ -37,6 +37,11 @@ func parseRegistryPath(path string) *RegistryPathInfo {
 		}
 	}
 
+	// npm unpublish 使用 /:package/-rev/:rev，rev 对 Grape 没有意义
+	if idx := strings.Index(path, "/-rev/"); idx != -1 {
+		path = path[:idx]
+	}
+
 	// 否则视为包元数据请求
 	return &RegistryPathInfo{
 		Type:        RequestMetadata,
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graperegistry/grape/internal/logger"
+)
+
+// replicaMiddleware 只读副本模式下的请求分发：已复制到本地的包直接读取，
+// 写入、登录、受限包和管理 API 等其他请求转发（或重定向）到主节点。
+// api 为 false 时用于 Web UI 路由器，只转发 /-/ 下的 API，前端资源仍由本地提供。
+func (s *Server) replicaMiddleware(api bool) gin.HandlerFunc {
+	forward := s.primaryForwarder()
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		switch path {
+		case "/-/health", "/-/ping", "/-/metrics":
+			c.Next()
+			return
+		case "/-/api/admin/system":
+			if c.Request.Method == http.MethodGet {
+				s.replicaSystemInfo(c)
+				c.Abort()
+				return
+			}
+		}
+
+		if !strings.HasPrefix(path, "/-/") {
+			if !api {
+				c.Next()
+				return
+			}
+			if (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) && s.serveReplicated(c) {
+				c.Abort()
+				return
+			}
+		}
+		forward(c)
+		c.Abort()
+	}
+}
+
+// serveReplicated 本地已有副本时直接处理读取请求，返回 false 表示需要转发给主节点
+func (s *Server) serveReplicated(c *gin.Context) bool {
+	pathInfo := parseRegistryPath(c.Request.URL.Path)
+	if pathInfo == nil {
+		return false
+	}
+	if pathInfo.Type == RequestTarball {
+		if !s.storage.HasTarball(pathInfo.PackageName, pathInfo.Filename) {
+			return false
+		}
+	} else if !s.storage.HasPackage(pathInfo.PackageName) {
+		return false
+	}
+
+	// 用户只存在于主节点，需要认证时由主节点验证 token
+	if s.requireReadAuth.Load() {
+		user, err := s.replica.Authenticate(c.GetHeader("Authorization"))
+		if err != nil {
+			logger.Warnf("Failed to verify token with primary: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to reach primary registry"})
+			return true
+		}
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return true
+		}
+	}
+
+	c.Params = gin.Params{{Key: "package", Value: pathInfo.PackageName}}
+	if pathInfo.Type == RequestTarball {
+		c.Params = append(c.Params, gin.Param{Key: "filename", Value: pathInfo.Filename})
+		s.registryHandler.GetTarball(c)
+	} else {
+		s.registryHandler.GetPackage(c)
+	}
+	return true
+}
+
+// replicaSystemInfo 副本的系统信息由本地提供，管理员身份由主节点验证
+func (s *Server) replicaSystemInfo(c *gin.Context) {
+	user, err := s.replica.Authenticate(c.GetHeader("Authorization"))
+	if err != nil {
+		logger.Warnf("Failed to verify token with primary: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to reach primary registry"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if user.Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		return
+	}
+	s.apiHandler.GetSystemInfo(c)
+}
+
+// primaryForwarder 按 replication.write_mode 把请求反向代理或重定向到主节点
+func (s *Server) primaryForwarder() gin.HandlerFunc {
+	primary := s.replica.Primary()
+	if s.cfg.Replication.WriteMode == "redirect" {
+		return func(c *gin.Context) {
+			c.Redirect(http.StatusTemporaryRedirect, primary.String()+c.Request.URL.RequestURI())
+		}
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(primary)
+			// 主节点按 X-Forwarded-Host 生成 tarball 地址，客户端会继续从副本下载
+			r.SetXForwarded()
+			for _, header := range []string{"X-Forwarded-Host", "X-Forwarded-Proto"} {
+				if v := r.In.Header.Get(header); v != "" {
+					r.Out.Header.Set(header, v)
+				}
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// CORS 由副本自己的中间件处理，避免重复的响应头
+			for key := range resp.Header {
+				if strings.HasPrefix(key, "Access-Control-") {
+					resp.Header.Del(key)
+				}
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.Warnf("Failed to forward %s %s to primary: %v", r.Method, r.URL.Path, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"error":"failed to reach primary registry"}`))
+		},
+	}
+	return func(c *gin.Context) {
+		proxy.ServeHTTP(c.Writer, c.Request)
+	}
+}
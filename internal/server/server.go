@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"strconv"
@@ -13,11 +14,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/graperegistry/grape/internal/auth"
 	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/importer"
 	"github.com/graperegistry/grape/internal/logger"
 	"github.com/graperegistry/grape/internal/metrics"
 	"github.com/graperegistry/grape/internal/registry"
+	"github.com/graperegistry/grape/internal/replication"
 	"github.com/graperegistry/grape/internal/server/handler"
-	"github.com/graperegistry/grape/internal/storage/local"
+	"github.com/graperegistry/grape/internal/storage"
+	"github.com/graperegistry/grape/internal/storage/backend"
 	"github.com/graperegistry/grape/internal/web"
 	"github.com/graperegistry/grape/internal/webhook"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -30,7 +35,7 @@ type Server struct {
 	http            *http.Server        // Web UI 服务器
 	apiServer       *http.Server        // npm Registry API 服务器
 	proxy           *registry.Proxy
-	storage         *local.Storage
+	storage         storage.Storage
 	userStore       auth.UserStore
 	jwtService      *auth.JWTService
 	registryHandler *handler.RegistryHandler
@@ -42,8 +47,16 @@ type Server struct {
 	ownerHandler    *handler.OwnerHandler
 	backupHandler   *handler.BackupHandler
 	gcHandler       *handler.GCHandler
+	webLoginHandler *handler.WebLoginHandler
+	accessHandler   *handler.AccessHandler
+	orgHandler      *handler.OrgHandler
+	oidcHandler     *handler.OIDCHandler
+	trustedHandler  *handler.TrustedPublishingHandler
+	syncHandler     *handler.SyncHandler
+	replica         *replication.Replica // 只读副本模式下跟随主节点，主节点模式为 nil
 	webFS           http.FileSystem
 	webDist         fs.FS
+	requireReadAuth atomic.Bool // 读取是否需要认证（可热更新）
 }
 
 func New(cfg *config.Config, version string) *Server {
@@ -57,13 +70,18 @@ func New(cfg *config.Config, version string) *Server {
 
 	// npm Registry API 路由器
 	apiRouter := gin.New()
+	// scoped 包名在 /-/package/:name 路由中以 @scope%2fname 形式出现，按原始路径匹配
+	apiRouter.UseRawPath = true
 	apiRouter.Use(gin.Recovery())
 	apiRouter.Use(requestLogger())
 	apiRouter.Use(maxBytesMiddleware(50 << 20))
 
 	// 初始化组件
 	proxy := registry.NewProxy(&cfg.Registry)
-	storage := local.New(cfg.Storage.Path)
+	storage, err := backend.New(cfg.Storage)
+	if err != nil {
+		logger.Fatalf("Failed to initialize storage: %v", err)
+	}
 
 	// 检查 JWT 密钥安全性
 	if cfg.Auth.JWTSecret == "grape-secret-key-change-in-production" {
@@ -71,12 +89,28 @@ func New(cfg *config.Config, version string) *Server {
 		logger.Warn("⚠️  This is a security risk in production environments!")
 	}
 
-	// 使用数据库用户存储
-	userStore := auth.NewDBUserStore()
+	// 用户存储：数据库或 LDAP 目录
+	userStore := newUserStore(cfg)
 	jwtService := auth.NewJWTService(cfg.Auth.JWTSecret, cfg.Auth.JWTExpiry)
 
 	// 检查是否需要创建默认管理员
 	createDefaultAdminIfNeeded(userStore)
+	flagDefaultAdminPassword()
+
+	// 包级授权服务：publish/unpublish/deprecate/dist-tag/owner 共用
+	authz := auth.NewPackageAuthorizer(storage)
+	indexExistingPackages(storage)
+	reconcilePackageOwners(storage, authz)
+
+	// 只读副本：配置已在启动时校验
+	var replica *replication.Replica
+	if cfg.Replication.IsReplica() {
+		r, err := replication.New(storage, cfg.Replication)
+		if err != nil {
+			logger.Fatalf("Failed to configure replication: %v", err)
+		}
+		replica = r
+	}
 
 	// API 端口作为 baseURL
 	apiPort := cfg.Server.APIPort
@@ -89,14 +123,27 @@ func New(cfg *config.Config, version string) *Server {
 	webhookDispatcher := webhook.NewDispatcher()
 
 	// 创建 handlers
-	registryHandler := handler.NewRegistryHandler(proxy, storage, baseURL)
+	registryHandler := handler.NewRegistryHandler(proxy, storage, baseURL, cfg.Server.WriteTimeout)
 	authHandler := handler.NewAuthHandler(userStore, jwtService, cfg.Auth.AllowRegistration)
-	publishHandler := handler.NewPublishHandler(storage, webhookDispatcher)
+	publishHandler := handler.NewPublishHandler(storage, authz, webhookDispatcher)
 	webhookHandler := handler.NewWebhookHandler(webhookDispatcher)
-	tokenHandler := handler.NewTokenHandler()
-	ownerHandler := handler.NewOwnerHandler()
-	backupHandler := handler.NewBackupHandler(cfg.Storage.Path)
-	gcHandler := handler.NewGCHandler(storage, cfg.Storage.Path)
+	tokenHandler := handler.NewTokenHandler(userStore)
+	ownerHandler := handler.NewOwnerHandler(authz)
+	backupHandler := handler.NewBackupHandler(storage, cfg.Storage.Path)
+	gcHandler := handler.NewGCHandler(storage)
+	webLoginHandler := handler.NewWebLoginHandler(jwtService, userStore, cfg.Server.Port)
+	accessHandler := handler.NewAccessHandler()
+	orgHandler := handler.NewOrgHandler()
+	oidcHandler := handler.NewOIDCHandler(auth.NewOIDCProvider(&cfg.Auth.OIDC), jwtService, cfg.Auth.OIDC.RedirectURL)
+	trustedHandler := handler.NewTrustedPublishingHandler(auth.NewTrustedPublisher(&cfg.Auth.TrustedPublishing), authz, userStore)
+	syncHandler := handler.NewSyncHandler(storage, authz, cfg.Sync.Jobs)
+
+	// 两步验证与密码策略
+	auth.SetTwoFactorPolicy(cfg.Auth.TwoFactor)
+	auth.SetLockoutPolicy(cfg.Auth.Lockout)
+	if err := auth.SetPasswordPolicy(cfg.Auth.PasswordPolicy); err != nil {
+		logger.Warnf("Failed to load breached password list: %v", err)
+	}
 
 	// 获取前端文件系统
 	webFS := web.GetFileSystem()
@@ -118,6 +165,13 @@ func New(cfg *config.Config, version string) *Server {
 		ownerHandler:    ownerHandler,
 		backupHandler:   backupHandler,
 		gcHandler:       gcHandler,
+		webLoginHandler: webLoginHandler,
+		accessHandler:   accessHandler,
+		orgHandler:      orgHandler,
+		oidcHandler:     oidcHandler,
+		trustedHandler:  trustedHandler,
+		syncHandler:     syncHandler,
+		replica:         replica,
 		webFS:           webFS,
 		webDist:         webDist,
 		http: &http.Server{
@@ -138,11 +192,18 @@ func New(cfg *config.Config, version string) *Server {
 	router.Use(s.securityHeadersMiddleware())
 	router.Use(prometheusMiddleware())
 
+	s.requireReadAuth.Store(cfg.Auth.RequireReadAuth)
+
 	apiRouter.Use(s.corsMiddleware())
 	apiRouter.Use(prometheusMiddleware())
+	if replica != nil {
+		router.Use(s.replicaMiddleware(false))
+		apiRouter.Use(s.replicaMiddleware(true))
+	}
+	apiRouter.Use(auth.RequireReadAuth(jwtService, userStore, s.requireReadAuth.Load, isReadAuthExempt))
 
 	// apiHandler 需要引用 s（通过 applyFn），所以在 s 创建后再初始化
-	s.apiHandler = handler.NewAPIHandler(storage, cfg.Storage.Path, proxy, cfg, version, s.applyConfig)
+	s.apiHandler = handler.NewAPIHandler(storage, proxy, cfg, version, s.applyConfig, replica)
 
 	s.setupRoutes()
 	return s
@@ -156,6 +217,14 @@ func (s *Server) applyConfig(cfg *config.Config) {
 	s.jwtService.UpdateSecret(cfg.Auth.JWTSecret, cfg.Auth.JWTExpiry)
 	// 更新自助注册开关
 	s.authHandler.SetAllowRegistration(cfg.Auth.AllowRegistration)
+	// 更新读取认证开关
+	s.requireReadAuth.Store(cfg.Auth.RequireReadAuth)
+	// 更新两步验证与密码策略
+	auth.SetTwoFactorPolicy(cfg.Auth.TwoFactor)
+	auth.SetLockoutPolicy(cfg.Auth.Lockout)
+	if err := auth.SetPasswordPolicy(cfg.Auth.PasswordPolicy); err != nil {
+		logger.Warnf("Failed to load breached password list: %v", err)
+	}
 	// 更新日志级别
 	if err := logger.SetLevel(cfg.Log.Level); err != nil {
 		logger.Warnf("Failed to update log level: %v", err)
@@ -163,6 +232,20 @@ func (s *Server) applyConfig(cfg *config.Config) {
 	logger.Infof("✅ Config hot-reloaded successfully")
 }
 
+// newUserStore 根据 auth.backend 选择用户存储
+func newUserStore(cfg *config.Config) auth.UserStore {
+	switch cfg.Auth.Backend {
+	case "ldap":
+		logger.Infof("🔐 Using LDAP authentication: %s", cfg.Auth.LDAP.URL)
+		return auth.NewLDAPUserStore(&cfg.Auth.LDAP)
+	case "", "db":
+		return auth.NewDBUserStore()
+	default:
+		logger.Warnf("Unknown auth backend %q, falling back to db", cfg.Auth.Backend)
+		return auth.NewDBUserStore()
+	}
+}
+
 // createDefaultAdminIfNeeded 如果数据库中没有用户，创建默认管理员
 func createDefaultAdminIfNeeded(userStore auth.UserStore) {
 	users := userStore.List()
@@ -172,6 +255,8 @@ func createDefaultAdminIfNeeded(userStore auth.UserStore) {
 			Email:    "admin@grape.local",
 			Password: "admin123",
 			Role:     "admin",
+
+			MustChangePassword: true, // 默认密码必须在首次登录时修改
 		}
 		if err := userStore.Create(adminUser); err != nil {
 			logger.Warnf("Failed to create default admin: %v", err)
@@ -181,6 +266,68 @@ func createDefaultAdminIfNeeded(userStore auth.UserStore) {
 	}
 }
 
+// flagDefaultAdminPassword 一次性检查：仍在使用默认密码 admin123 的 admin 账号在下次登录时必须修改密码
+func flagDefaultAdminPassword() {
+	err := db.RunDataMigration(db.DB, "flag_default_admin_password", func() error {
+		var admin db.User
+		if err := db.DB.Where("username = ? AND auth_source = ?", "admin", auth.AuthSourceLocal).First(&admin).Error; err != nil {
+			return nil
+		}
+		if !admin.CheckPassword("admin123") {
+			return nil
+		}
+		logger.Warn("⚠️  The admin account still uses the default password, it must be changed on next login")
+		return db.DB.Model(&admin).Update("must_change_password", true).Error
+	})
+	if err != nil {
+		logger.Warnf("Failed to check default admin password: %v", err)
+	}
+}
+
+// indexExistingPackages 一次性从本地存储建立包索引：旧版本发布的包没有写入 packages 表
+func indexExistingPackages(storage storage.Storage) {
+	err := db.RunDataMigration(db.DB, "index_packages", func() error {
+		result, err := importer.Reindex(storage)
+		if err != nil {
+			return err
+		}
+		for _, msg := range result.Errors {
+			logger.Warnf("Failed to index package: %s", msg)
+		}
+		logger.Infof("Indexed packages: %d packages, %d versions", result.Packages, result.Versions)
+		return nil
+	})
+	if err != nil {
+		logger.Warnf("Failed to index packages: %v", err)
+	}
+}
+
+// reconcilePackageOwners 一次性对账私有包 metadata 中的 maintainers 与 package_owners
+func reconcilePackageOwners(storage storage.Storage, authz *auth.PackageAuthorizer) {
+	err := db.RunDataMigration(db.DB, "reconcile_package_owners", func() error {
+		packages, err := storage.ListPackages()
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, pkg := range packages {
+			if pkg.Private {
+				names = append(names, pkg.Name)
+			}
+		}
+		result, err := authz.Reconcile(names)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Reconciled package owners: %d packages, %d owners added, %d synced",
+			result.Packages, result.OwnersAdded, result.Synced)
+		return nil
+	})
+	if err != nil {
+		logger.Warnf("Failed to reconcile package owners: %v", err)
+	}
+}
+
 func requestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -215,13 +362,25 @@ func (s *Server) setupRoutes() {
 		webAPI.POST("/npm/v1/tokens", s.tokenHandler.CreateToken)
 		webAPI.DELETE("/npm/v1/tokens/token/:id", s.tokenHandler.DeleteToken)
 
+		// npm 网页登录确认（npm login --auth-type=web）
+		webAPI.GET("/api/v1/login/:session", s.webLoginHandler.GetLoginSession)
+		webAPI.POST("/api/v1/login/:session", s.webLoginHandler.ConfirmLogin)
+		webAPI.DELETE("/api/v1/login/:session", s.webLoginHandler.CancelLogin)
+
 		admin := webAPI.Group("/api/admin")
-		admin.Use(auth.RequireAdmin())
+		admin.Use(auth.RequireAdmin(), auth.RequireAdminTwoFactor())
 		{
 			admin.GET("/users", s.authHandler.ListUsers)
 			admin.POST("/users", s.authHandler.CreateUser)
+			admin.POST("/users/import", s.authHandler.ImportUsers)
 			admin.PUT("/users/:username", s.authHandler.UpdateUser)
 			admin.DELETE("/users/:username", s.authHandler.DeleteUser)
+			admin.DELETE("/users/:username/2fa", s.authHandler.ResetTwoFactorAdmin)
+			admin.POST("/users/:username/password-reset", s.authHandler.CreatePasswordResetAdmin)
+			admin.DELETE("/users/:username/lockout", s.authHandler.UnlockUserAdmin)
+			admin.GET("/users/:username/sessions", s.authHandler.ListUserSessionsAdmin)
+			admin.DELETE("/users/:username/sessions", s.authHandler.RevokeUserSessionsAdmin)
+			admin.DELETE("/users/:username/sessions/:id", s.authHandler.RevokeUserSessionAdmin)
 			admin.GET("/system", s.apiHandler.GetSystemInfo)
 			admin.GET("/config", s.apiHandler.GetConfig)
 			admin.PUT("/config", s.apiHandler.UpdateConfig)
@@ -231,10 +390,20 @@ func (s *Server) setupRoutes() {
 			admin.PUT("/webhooks/:id", s.webhookHandler.UpdateWebhook)
 			admin.DELETE("/webhooks/:id", s.webhookHandler.DeleteWebhook)
 			admin.POST("/webhooks/:id/test", s.webhookHandler.TestWebhook)
+			// 包/scope 访问级别
+			admin.GET("/access", s.accessHandler.ListAccessRules)
+			admin.PUT("/access", s.accessHandler.SetAccessRule)
+			admin.DELETE("/access", s.accessHandler.DeleteAccessRule)
+			// 组织管理
+			admin.GET("/orgs", s.orgHandler.ListOrgsAdmin)
+			admin.POST("/orgs", s.orgHandler.CreateOrgAdmin)
+			admin.DELETE("/orgs/:org", s.orgHandler.DeleteOrgAdmin)
 			// Package owner 管理
 			admin.GET("/packages/:name/owners", s.ownerHandler.ListPackageOwnersAdmin)
 			admin.POST("/packages/:name/owners", s.ownerHandler.SetPackageOwnerAdmin)
 			admin.DELETE("/packages/:name/owners/:username", s.ownerHandler.RemovePackageOwnerAdmin)
+			admin.POST("/owners", s.ownerHandler.SetPackageOwnerAdmin)
+			admin.GET("/owners/effective", s.ownerHandler.GetEffectiveOwnersAdmin)
 			// Backup & Restore
 			admin.GET("/backup/info", s.backupHandler.GetBackupInfo)
 			admin.GET("/backup/download", s.backupHandler.CreateBackup)
@@ -244,6 +413,9 @@ func (s *Server) setupRoutes() {
 			admin.GET("/gc/stats", s.gcHandler.GetGCStats)
 			admin.GET("/gc/analyze", s.gcHandler.AnalyzeGC)
 			admin.POST("/gc/run", s.gcHandler.RunGC)
+			// 从其他 registry 同步
+			admin.GET("/sync", s.syncHandler.ListSyncJobs)
+			admin.POST("/sync/:name/run", s.syncHandler.RunSyncJob)
 			// Package deprecation
 			admin.POST("/packages/:name/deprecate", s.gcHandler.DeprecatePackage)
 		}
@@ -252,6 +424,12 @@ func (s *Server) setupRoutes() {
 	// 认证 API 必须在鉴权中间件之外
 	s.router.PUT("/-/user/:username", s.authHandler.Login)
 	s.router.PUT("/-/user/:username/*rev", s.authHandler.Login)
+	// OIDC 单点登录
+	s.router.GET("/-/api/auth/oidc", s.oidcHandler.GetConfig)
+	s.router.GET("/-/api/auth/oidc/login", s.oidcHandler.StartLogin)
+	s.router.GET("/-/api/auth/oidc/callback", s.oidcHandler.Callback)
+	// 使用管理员签发的重置码设置新密码
+	s.router.POST("/-/api/auth/password-reset", s.authHandler.ResetPassword)
 	
 	// 前端静态资源和 SPA
 	s.router.NoRoute(authMiddleware, s.serveFrontend)
@@ -261,10 +439,16 @@ func (s *Server) setupRoutes() {
 	// =====================================
 	// Health check
 	s.apiRouter.GET("/-/health", s.handleHealth)
+	s.apiRouter.GET("/-/ping", handler.Ping)
 	
 	// 认证 API（npm login）
 	s.apiRouter.PUT("/-/user/:username", s.authHandler.Login)
 	s.apiRouter.PUT("/-/user/:username/*rev", s.authHandler.Login)
+	// 网页登录（npm login --auth-type=web）
+	s.apiRouter.POST("/-/v1/login", s.webLoginHandler.StartLogin)
+	s.apiRouter.GET("/-/v1/done", s.webLoginHandler.PollLogin)
+	// trusted publishing：CI 用 OIDC ID token 换取短期发布 token（Authorization 中是 ID token，不经过鉴权中间件）
+	s.apiRouter.POST("/-/npm/v1/oidc/token/exchange/package/:name", s.trustedHandler.Exchange)
 
 	// 管理 API（带认证）
 	apiRegistry := s.apiRouter.Group("/-")
@@ -272,10 +456,47 @@ func (s *Server) setupRoutes() {
 	{
 		apiRegistry.GET("/api/user", s.authHandler.GetCurrentUser)
 		apiRegistry.DELETE("/api/session", s.authHandler.Logout)
+		apiRegistry.DELETE("/user/token/*token", s.authHandler.Logout) // npm logout
+		// npm whoami / profile 命令兼容 API
+		apiRegistry.GET("/whoami", s.authHandler.Whoami)
+		// npm search（也用于 grape sync 按 scope 列出包）
+		apiRegistry.GET("/v1/search", s.apiHandler.NpmSearch)
+		apiRegistry.GET("/_changes", s.registryHandler.Changes)
+		apiRegistry.GET("/npm/v1/user", s.authHandler.GetProfile)
+		apiRegistry.POST("/npm/v1/user", s.authHandler.UpdateProfile)
+		// npm token 命令兼容 API
+		apiRegistry.GET("/npm/v1/tokens", s.tokenHandler.ListTokens)
+		apiRegistry.POST("/npm/v1/tokens", s.tokenHandler.CreateToken)
+		apiRegistry.DELETE("/npm/v1/tokens/token/:id", s.tokenHandler.DeleteToken)
 		// npm owner 命令兼容 API
 		apiRegistry.GET("/package/:name/collaborators", s.ownerHandler.ListOwners)
 		apiRegistry.PUT("/package/:name/collaborators/:username", s.ownerHandler.AddOwner)
 		apiRegistry.DELETE("/package/:name/collaborators/:username", s.ownerHandler.RemoveOwner)
+		// npm access 命令兼容 API
+		apiRegistry.GET("/package/:name/visibility", s.accessHandler.GetVisibility)
+		apiRegistry.POST("/package/:name/access", s.accessHandler.SetAccess)
+		apiRegistry.GET("/package/:name/grants", s.accessHandler.ListGrants)
+		apiRegistry.PUT("/package/:name/grants/:username", s.accessHandler.GrantAccess)
+		apiRegistry.DELETE("/package/:name/grants/:username", s.accessHandler.RevokeAccess)
+		// npm dist-tag
+		apiRegistry.GET("/package/:name/dist-tags", s.publishHandler.ListDistTags)
+		apiRegistry.PUT("/package/:name/dist-tags/:tag", s.publishHandler.SetDistTag)
+		apiRegistry.POST("/package/:name/dist-tags/:tag", s.publishHandler.SetDistTag)
+		apiRegistry.DELETE("/package/:name/dist-tags/:tag", s.publishHandler.DeleteDistTag)
+		// npm org / npm team
+		apiRegistry.GET("/org/:org/user", s.orgHandler.ListMembers)
+		apiRegistry.PUT("/org/:org/user", s.orgHandler.SetMember)
+		apiRegistry.DELETE("/org/:org/user", s.orgHandler.RemoveMember)
+		apiRegistry.GET("/org/:org/team", s.orgHandler.ListTeams)
+		apiRegistry.PUT("/org/:org/team", s.orgHandler.CreateTeam)
+		apiRegistry.GET("/org/:org/package", s.orgHandler.ListOrgPackages)
+		apiRegistry.DELETE("/team/:scope/:team", s.orgHandler.DeleteTeam)
+		apiRegistry.GET("/team/:scope/:team/user", s.orgHandler.ListTeamMembers)
+		apiRegistry.PUT("/team/:scope/:team/user", s.orgHandler.AddTeamMember)
+		apiRegistry.DELETE("/team/:scope/:team/user", s.orgHandler.RemoveTeamMember)
+		apiRegistry.GET("/team/:scope/:team/package", s.orgHandler.ListTeamPackages)
+		apiRegistry.PUT("/team/:scope/:team/package", s.orgHandler.GrantTeamPackage)
+		apiRegistry.DELETE("/team/:scope/:team/package", s.orgHandler.RevokeTeamPackage)
 	}
 	
 	// npm Registry API - 使用 NoRoute 处理所有包请求（包括 scoped 包、发布、删除）
@@ -302,7 +523,7 @@ func (s *Server) handleRegistryRequest(c *gin.Context) {
 	if pathInfo.Type == RequestTarball {
 		switch c.Request.Method {
 		case http.MethodGet:
-			s.registryHandler.GetTarball(c)
+			s.withAuth(c, s.registryHandler.GetTarball)
 		case http.MethodDelete:
 			s.withAuth(c, s.publishHandler.Unpublish)
 		default:
@@ -311,7 +532,7 @@ func (s *Server) handleRegistryRequest(c *gin.Context) {
 	} else {
 		switch c.Request.Method {
 		case http.MethodGet:
-			s.registryHandler.GetPackage(c)
+			s.withAuth(c, s.registryHandler.GetPackage)
 		case http.MethodPut:
 			s.withAuth(c, s.publishHandler.Publish)
 		case http.MethodDelete:
@@ -324,13 +545,25 @@ func (s *Server) handleRegistryRequest(c *gin.Context) {
 
 // withAuth 辅助函数，确保执行特定操作前先进行认证
 func (s *Server) withAuth(c *gin.Context, handler gin.HandlerFunc) {
-	authMiddleware := auth.AuthMiddleware(s.jwtService, s.userStore)
-	authMiddleware(c)
-	if !c.IsAborted() {
+	if auth.Authenticate(c, s.jwtService, s.userStore) {
 		handler(c)
 	}
 }
 
+// isReadAuthExempt 开启"读取需要认证"时仍然放行的请求：健康检查、ping、登录流程和 trusted publishing 交换
+func isReadAuthExempt(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	switch path {
+	case "/-/health", "/-/ping", "/-/v1/login", "/-/v1/done":
+		return true
+	}
+	if strings.HasPrefix(path, "/-/npm/v1/oidc/token/exchange/") {
+		return true
+	}
+	// npm login（PUT /-/user/org.couchdb.user:xxx）
+	return c.Request.Method == http.MethodPut && strings.HasPrefix(path, "/-/user/")
+}
+
 // handleNpmTarball 处理 npm tarball 下载
 func (s *Server) handleNpmTarball(c *gin.Context) {
 	path := strings.TrimPrefix(c.Request.URL.Path, "/")
@@ -476,9 +709,16 @@ func (s *Server) Start() error {
 	logger.Infof("🚀 Grape Web UI server starting on http://%s", webAddr)
 	logger.Infof("📦 npm Registry API server starting on http://%s", apiAddr)
 	logger.Infof("📦 npm registry upstream: %s", s.cfg.Registry.Upstream)
-	logger.Infof("💾 Storage: %s", s.cfg.Storage.Path)
-	logger.Infof("💾 Database: %s", s.cfg.Database.DSN)
+	logger.Infof("💾 Storage: %s (%s)", s.cfg.Storage.Path, s.cfg.Storage.Type)
+	logger.Infof("💾 Database: %s (%s)", s.cfg.Database.RedactedDSN(), s.cfg.Database.Type)
 	
+	// 启动定时同步任务
+	s.syncHandler.Start()
+	if s.replica != nil {
+		logger.Infof("🔁 Running as read-only replica of %s", s.replica.Primary())
+		s.replica.Start()
+	}
+
 	// 启动两个服务器
 	go func() {
 		if err := s.apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -492,6 +732,10 @@ func (s *Server) Start() error {
 func (s *Server) Shutdown(ctx context.Context) error {
 	logger.Info("🛑 Shutting down servers...")
 	handler.StopLoginLimiter()
+	s.syncHandler.Stop()
+	if s.replica != nil {
+		s.replica.Stop()
+	}
 	
 	// 关闭 Web UI 服务器
 	if err := s.http.Shutdown(ctx); err != nil {
@@ -577,7 +821,7 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 		if allowed {
 			c.Header("Access-Control-Allow-Origin", origin)
 			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept")
+			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, npm-otp")
 			c.Header("Access-Control-Allow-Credentials", "true")
 		}
 
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/auth"
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/db"
+	"github.com/graperegistry/grape/internal/db/dbtest"
+)
+
+// newReadAuthServer 创建开启"读取需要认证"的服务器，并创建用户 alice
+func newReadAuthServer(t *testing.T) *Server {
+	t.Helper()
+	dbtest.Open(t,
+		&db.User{}, &db.Package{}, &db.PackageVersion{}, &db.Webhook{},
+		&db.AuditLog{}, &db.Token{}, &db.PackageOwner{},
+		&db.WebLoginSession{}, &db.OIDCLoginState{}, &db.UserTwoFactor{}, &db.UserSession{},
+		&db.PasswordReset{}, &db.AccountLockout{}, &db.SyncState{}, &db.PackageChange{}, &db.ReplicationState{},
+		&db.PackageAccess{}, &db.PackageGrant{},
+		&db.Organization{}, &db.OrgMember{}, &db.Team{}, &db.TeamMember{}, &db.TeamGrant{},
+	)
+	if err := db.ApplyMigrations(db.DB, nil); err != nil {
+		t.Fatalf("ApplyMigrations failed: %v", err)
+	}
+	if err := auth.NewDBUserStore().Create(&auth.User{Username: "alice", Password: "Alice-Pass-123", Role: "developer"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Storage.Path = t.TempDir()
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Auth.RequireReadAuth = true
+	return New(cfg, "test")
+}
+
+func serveAPI(s *Server, method, target, token string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.apiRouter.ServeHTTP(w, req)
+	return w
+}
+
+// isReadAuthRejection 区分全局读取认证的 401 与处理器自身返回的 401
+func isReadAuthRejection(w *httptest.ResponseRecorder) bool {
+	return w.Code == http.StatusUnauthorized && strings.Contains(w.Body.String(), "authentication required")
+}
+
+func TestRequireReadAuth_RejectsAnonymousReads(t *testing.T) {
+	s := newReadAuthServer(t)
+
+	for _, target := range []string{
+		"/lodash",
+		"/@acme%2fui",
+		"/lodash/-/lodash-4.17.21.tgz",
+		"/@acme/ui/-/ui-1.0.0.tgz",
+		"/-/v1/search?text=lodash",
+		"/-/_changes",
+	} {
+		w := serveAPI(s, http.MethodGet, target, "", nil)
+		if !isReadAuthRejection(w) {
+			t.Errorf("GET %s: expected 401 authentication required, got %d: %s", target, w.Code, w.Body.String())
+		}
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("GET %s: expected WWW-Authenticate header", target)
+		}
+	}
+}
+
+func TestRequireReadAuth_Exemptions(t *testing.T) {
+	s := newReadAuthServer(t)
+
+	for _, target := range []string{"/-/ping", "/-/health"} {
+		if w := serveAPI(s, http.MethodGet, target, "", nil); w.Code != http.StatusOK {
+			t.Errorf("GET %s: expected 200, got %d", target, w.Code)
+		}
+	}
+	if w := serveAPI(s, http.MethodPost, "/-/v1/login", "", nil); isReadAuthRejection(w) {
+		t.Errorf("Web login should be exempt, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveAPI(s, http.MethodGet, "/-/v1/done?sessionId=unknown", "", nil); isReadAuthRejection(w) {
+		t.Errorf("Web login polling should be exempt, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveAPI(s, http.MethodPost, "/-/npm/v1/oidc/token/exchange/package/@acme%2fui", "", nil); isReadAuthRejection(w) {
+		t.Errorf("OIDC token exchange should be exempt, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// npm login 不需要事先认证，登录后可以读取
+	body, _ := json.Marshal(map[string]string{"name": "alice", "password": "Alice-Pass-123"})
+	w := serveAPI(s, http.MethodPut, "/-/user/org.couchdb.user:alice", "", body)
+	var resp struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Token == "" {
+		t.Fatalf("Login: expected 200 with token, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveAPI(s, http.MethodGet, "/-/v1/search?text=lodash", resp.Token, nil); w.Code != http.StatusOK {
+		t.Errorf("Authenticated search: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRequireReadAuth_Disabled(t *testing.T) {
+	s := newReadAuthServer(t)
+	s.requireReadAuth.Store(false)
+
+	if w := serveAPI(s, http.MethodGet, "/-/v1/search?text=lodash", "", nil); w.Code != http.StatusOK {
+		t.Errorf("Anonymous search with read auth disabled: expected 200, got %d", w.Code)
+	}
+}
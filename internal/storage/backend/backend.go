@@ -0,0 +1,23 @@
+// Package backend 根据 storage.type 创建存储实现
+package backend
+
+import (
+	"fmt"
+
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/storage"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+// New 根据配置创建存储，新的存储实现在这里注册
+func New(cfg config.StorageConfig) (storage.Storage, error) {
+	switch cfg.Type {
+	case "", "local":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("storage.path is required for local storage")
+		}
+		return local.New(cfg.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Type)
+	}
+}
@@ -0,0 +1,27 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/graperegistry/grape/internal/config"
+	"github.com/graperegistry/grape/internal/storage/local"
+)
+
+func TestNew(t *testing.T) {
+	for _, typ := range []string{"", "local"} {
+		s, err := New(config.StorageConfig{Type: typ, Path: t.TempDir()})
+		if err != nil {
+			t.Fatalf("New(%q) failed: %v", typ, err)
+		}
+		if _, ok := s.(*local.Storage); !ok {
+			t.Errorf("New(%q) returned %T, expected *local.Storage", typ, s)
+		}
+	}
+
+	if _, err := New(config.StorageConfig{Type: "s3", Path: t.TempDir()}); err == nil {
+		t.Error("Expected error for unknown storage type")
+	}
+	if _, err := New(config.StorageConfig{Type: "local"}); err == nil {
+		t.Error("Expected error for local storage without path")
+	}
+}
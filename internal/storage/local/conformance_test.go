@@ -0,0 +1,14 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/graperegistry/grape/internal/storage"
+	"github.com/graperegistry/grape/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New(t.TempDir())
+	})
+}
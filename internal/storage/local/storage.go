@@ -24,6 +24,8 @@ type Storage struct {
 	basePath string
 }
 
+var _ storage.Storage = (*Storage)(nil)
+
 func New(basePath string) *Storage {
 	return &Storage{basePath: basePath}
 }
@@ -222,6 +224,41 @@ func (s *Storage) HasTarball(packageName, filename string) bool {
 	return err == nil
 }
 
+// TarballSize 返回 tarball 文件的大小，文件不存在时返回 0
+func (s *Storage) TarballSize(packageName, filename string) int64 {
+	path, err := s.tarballPath(packageName, filename)
+	if err != nil {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// ListTarballs 列出包的全部 tarball 文件名
+func (s *Storage) ListTarballs(packageName string) ([]string, error) {
+	dir, err := s.tarballsDir(packageName)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list tarballs: %w", err)
+	}
+	var filenames []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			filenames = append(filenames, entry.Name())
+		}
+	}
+	return filenames, nil
+}
+
 func (s *Storage) GetTarball(packageName, filename string) ([]byte, error) {
 	path, err := s.tarballPath(packageName, filename)
 	if err != nil {
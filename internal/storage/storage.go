@@ -19,7 +19,13 @@ type StorageStats struct {
 	PrivateCount  int64
 }
 
-// Storage 存储接口
+// Storage 存储接口，handler、导入和同步只通过它访问包文件。
+//
+// 实现需要满足 storagetest.Run 中的约定：
+//   - 包不存在时 GetMetadata 返回 registry.ErrPackageNotFound，tarball 不存在时 GetTarball 返回 registry.ErrTarballNotFound
+//   - 包名和文件名包含路径穿越字符时返回错误
+//   - SaveMetadata 拒绝不完整的 JSON
+//   - DeletePackage 删除包的 metadata 和全部 tarball，包不存在时不返回错误
 type Storage interface {
 	// 包管理
 	HasPackage(name string) bool
@@ -32,8 +38,13 @@ type Storage interface {
 	GetTarball(name, filename string) ([]byte, error)
 	SaveTarball(name, filename string, data []byte) error
 	DeleteTarball(name, filename string) error
+	// TarballSize 返回 tarball 大小，不存在时返回 0
+	TarballSize(name, filename string) int64
+	// ListTarballs 列出包的全部 tarball 文件名，包不存在时返回空列表
+	ListTarballs(name string) ([]string, error)
 
 	// 查询
+	ListPackageNames() ([]string, error)
 	ListPackages() ([]PackageInfo, error)
 	GetStats() (*StorageStats, error)
 }
@@ -0,0 +1,263 @@
+// Package storagetest 提供 storage.Storage 实现共用的一致性测试
+package storagetest
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/graperegistry/grape/internal/registry"
+	"github.com/graperegistry/grape/internal/storage"
+)
+
+// Run 对 open 返回的存储运行全部一致性测试，每个子测试使用新的空存储
+func Run(t *testing.T, open func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"MissingPackage", testMissingPackage},
+		{"Metadata", testMetadata},
+		{"InvalidMetadata", testInvalidMetadata},
+		{"Tarball", testTarball},
+		{"ScopedPackage", testScopedPackage},
+		{"DeleteTarball", testDeleteTarball},
+		{"DeletePackage", testDeletePackage},
+		{"List", testList},
+		{"Stats", testStats},
+		{"InvalidNames", testInvalidNames},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+func testMissingPackage(t *testing.T, s storage.Storage) {
+	if s.HasPackage("missing") {
+		t.Error("HasPackage returned true for a missing package")
+	}
+	if _, err := s.GetMetadata("missing"); !errors.Is(err, registry.ErrPackageNotFound) {
+		t.Errorf("GetMetadata: expected ErrPackageNotFound, got %v", err)
+	}
+	if s.HasTarball("missing", "missing-1.0.0.tgz") {
+		t.Error("HasTarball returned true for a missing tarball")
+	}
+	if _, err := s.GetTarball("missing", "missing-1.0.0.tgz"); !errors.Is(err, registry.ErrTarballNotFound) {
+		t.Errorf("GetTarball: expected ErrTarballNotFound, got %v", err)
+	}
+	if size := s.TarballSize("missing", "missing-1.0.0.tgz"); size != 0 {
+		t.Errorf("TarballSize: expected 0, got %d", size)
+	}
+	if files, err := s.ListTarballs("missing"); err != nil || len(files) != 0 {
+		t.Errorf("ListTarballs: expected no files, got %v, %v", files, err)
+	}
+	if err := s.DeletePackage("missing"); err != nil {
+		t.Errorf("DeletePackage of a missing package failed: %v", err)
+	}
+}
+
+func testMetadata(t *testing.T, s storage.Storage) {
+	first := []byte(`{"name":"foo","dist-tags":{"latest":"1.0.0"}}`)
+	if err := s.SaveMetadata("foo", first); err != nil {
+		t.Fatalf("SaveMetadata failed: %v", err)
+	}
+	if !s.HasPackage("foo") {
+		t.Error("HasPackage returned false after SaveMetadata")
+	}
+
+	second := []byte(`{"name":"foo","dist-tags":{"latest":"2.0.0"}}`)
+	if err := s.SaveMetadata("foo", second); err != nil {
+		t.Fatalf("SaveMetadata overwrite failed: %v", err)
+	}
+	data, err := s.GetMetadata("foo")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if !bytes.Equal(data, second) {
+		t.Errorf("GetMetadata returned %s, expected %s", data, second)
+	}
+}
+
+func testInvalidMetadata(t *testing.T, s storage.Storage) {
+	if err := s.SaveMetadata("foo", []byte(`{"name":"foo"`)); err == nil {
+		t.Error("SaveMetadata accepted truncated JSON")
+	}
+	if s.HasPackage("foo") {
+		t.Error("HasPackage returned true after rejected SaveMetadata")
+	}
+}
+
+func testTarball(t *testing.T, s storage.Storage) {
+	data := []byte("tarball contents")
+	if err := s.SaveTarball("foo", "foo-1.0.0.tgz", data); err != nil {
+		t.Fatalf("SaveTarball failed: %v", err)
+	}
+	if !s.HasTarball("foo", "foo-1.0.0.tgz") {
+		t.Error("HasTarball returned false after SaveTarball")
+	}
+	got, err := s.GetTarball("foo", "foo-1.0.0.tgz")
+	if err != nil {
+		t.Fatalf("GetTarball failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("GetTarball returned %q, expected %q", got, data)
+	}
+	if size := s.TarballSize("foo", "foo-1.0.0.tgz"); size != int64(len(data)) {
+		t.Errorf("TarballSize: expected %d, got %d", len(data), size)
+	}
+
+	if err := s.SaveTarball("foo", "foo-1.1.0.tgz", []byte("newer")); err != nil {
+		t.Fatalf("SaveTarball failed: %v", err)
+	}
+	files, err := s.ListTarballs("foo")
+	if err != nil {
+		t.Fatalf("ListTarballs failed: %v", err)
+	}
+	sort.Strings(files)
+	if len(files) != 2 || files[0] != "foo-1.0.0.tgz" || files[1] != "foo-1.1.0.tgz" {
+		t.Errorf("ListTarballs returned %v", files)
+	}
+}
+
+func testScopedPackage(t *testing.T, s storage.Storage) {
+	if err := s.SaveMetadata("@scope/foo", []byte(`{"name":"@scope/foo"}`)); err != nil {
+		t.Fatalf("SaveMetadata failed: %v", err)
+	}
+	if err := s.SaveTarball("@scope/foo", "foo-1.0.0.tgz", []byte("scoped")); err != nil {
+		t.Fatalf("SaveTarball failed: %v", err)
+	}
+	if !s.HasPackage("@scope/foo") || !s.HasTarball("@scope/foo", "foo-1.0.0.tgz") {
+		t.Error("scoped package not found after save")
+	}
+	if s.HasPackage("foo") {
+		t.Error("scoped package visible under its unscoped name")
+	}
+
+	names, err := s.ListPackageNames()
+	if err != nil {
+		t.Fatalf("ListPackageNames failed: %v", err)
+	}
+	if len(names) != 1 || names[0] != "@scope/foo" {
+		t.Errorf("ListPackageNames returned %v", names)
+	}
+}
+
+func testDeleteTarball(t *testing.T, s storage.Storage) {
+	s.SaveMetadata("foo", []byte(`{"name":"foo"}`))
+	s.SaveTarball("foo", "foo-1.0.0.tgz", []byte("one"))
+	s.SaveTarball("foo", "foo-2.0.0.tgz", []byte("two"))
+
+	if err := s.DeleteTarball("foo", "foo-1.0.0.tgz"); err != nil {
+		t.Fatalf("DeleteTarball failed: %v", err)
+	}
+	if s.HasTarball("foo", "foo-1.0.0.tgz") {
+		t.Error("HasTarball returned true after DeleteTarball")
+	}
+	if !s.HasTarball("foo", "foo-2.0.0.tgz") || !s.HasPackage("foo") {
+		t.Error("DeleteTarball removed more than one tarball")
+	}
+}
+
+func testDeletePackage(t *testing.T, s storage.Storage) {
+	for _, name := range []string{"foo", "@scope/foo"} {
+		s.SaveMetadata(name, []byte(`{}`))
+		s.SaveTarball(name, "foo-1.0.0.tgz", []byte("data"))
+	}
+
+	if err := s.DeletePackage("@scope/foo"); err != nil {
+		t.Fatalf("DeletePackage failed: %v", err)
+	}
+	if s.HasPackage("@scope/foo") || s.HasTarball("@scope/foo", "foo-1.0.0.tgz") {
+		t.Error("package still present after DeletePackage")
+	}
+	if files, _ := s.ListTarballs("@scope/foo"); len(files) != 0 {
+		t.Errorf("tarballs still listed after DeletePackage: %v", files)
+	}
+	if !s.HasPackage("foo") || !s.HasTarball("foo", "foo-1.0.0.tgz") {
+		t.Error("DeletePackage removed another package")
+	}
+}
+
+func testList(t *testing.T, s storage.Storage) {
+	if names, err := s.ListPackageNames(); err != nil || len(names) != 0 {
+		t.Errorf("ListPackageNames on empty storage returned %v, %v", names, err)
+	}
+
+	s.SaveMetadata("private-pkg", []byte(`{"name":"private-pkg","description":"mine","dist-tags":{"latest":"1.2.0"}}`))
+	s.SaveMetadata("@scope/cached", []byte(`{"name":"@scope/cached","_upstream":"npmjs"}`))
+	// 只有 tarball 没有 metadata 的目录不算包
+	s.SaveTarball("orphan", "orphan-1.0.0.tgz", []byte("data"))
+
+	names, err := s.ListPackageNames()
+	if err != nil {
+		t.Fatalf("ListPackageNames failed: %v", err)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "@scope/cached" || names[1] != "private-pkg" {
+		t.Errorf("ListPackageNames returned %v", names)
+	}
+
+	packages, err := s.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages failed: %v", err)
+	}
+	if len(packages) != 2 {
+		t.Fatalf("ListPackages returned %d packages, expected 2", len(packages))
+	}
+	for _, pkg := range packages {
+		switch pkg.Name {
+		case "private-pkg":
+			if !pkg.Private || pkg.Description != "mine" || pkg.Version != "1.2.0" {
+				t.Errorf("unexpected package info %+v", pkg)
+			}
+		case "@scope/cached":
+			if pkg.Private {
+				t.Errorf("package with _upstream reported as private: %+v", pkg)
+			}
+		default:
+			t.Errorf("unexpected package %q", pkg.Name)
+		}
+	}
+}
+
+func testStats(t *testing.T, s storage.Storage) {
+	s.SaveMetadata("foo", []byte(`{"name":"foo"}`))
+	s.SaveTarball("foo", "foo-1.0.0.tgz", []byte("0123456789"))
+	s.SaveMetadata("bar", []byte(`{"name":"bar"}`))
+
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if stats.TotalPackages != 2 {
+		t.Errorf("TotalPackages: expected 2, got %d", stats.TotalPackages)
+	}
+	if stats.TotalSize < 10 {
+		t.Errorf("TotalSize: expected at least the tarball size, got %d", stats.TotalSize)
+	}
+}
+
+func testInvalidNames(t *testing.T, s storage.Storage) {
+	for _, name := range []string{"", "../escape", "/abs", "foo/../../escape", "nul\x00"} {
+		if err := s.SaveMetadata(name, []byte(`{}`)); err == nil {
+			t.Errorf("SaveMetadata accepted package name %q", name)
+		}
+		if err := s.SaveTarball(name, "x-1.0.0.tgz", []byte("x")); err == nil {
+			t.Errorf("SaveTarball accepted package name %q", name)
+		}
+		if err := s.DeletePackage(name); err == nil {
+			t.Errorf("DeletePackage accepted package name %q", name)
+		}
+	}
+	for _, filename := range []string{"", "../x.tgz", "sub/x.tgz", `sub\x.tgz`} {
+		if err := s.SaveTarball("foo", filename, []byte("x")); err == nil {
+			t.Errorf("SaveTarball accepted filename %q", filename)
+		}
+		if s.HasTarball("foo", filename) {
+			t.Errorf("HasTarball returned true for filename %q", filename)
+		}
+	}
+}
@@ -82,11 +82,42 @@ export const packageApi = {
 // Auth APIs (Web API - 端口 4873)
 export const authApi = {
   // Login (npm 兼容 API - 端口 4874)
-  login(username: string, password: string) {
-    return api.put('/-/user/org.couchdb.user:' + encodeURIComponent(username), {
-      name: username,
-      password: password,
-    })
+  // newPassword: 账号被要求修改密码时一并提交的新密码
+  login(username: string, password: string, otp?: string, newPassword?: string) {
+    return api.put(
+      '/-/user/org.couchdb.user:' + encodeURIComponent(username),
+      {
+        name: username,
+        password: password,
+        ...(newPassword ? { new_password: newPassword } : {}),
+      },
+      otp ? { headers: { 'npm-otp': otp } } : undefined,
+    )
+  },
+
+  // Reset password with a one-time code issued by an admin
+  resetPassword(code: string, password: string) {
+    return webApi.post('/-/api/auth/password-reset', { code, password })
+  },
+
+  // Pending `npm login --auth-type=web` session
+  getCliLogin(session: string) {
+    return webApi.get(`/-/api/v1/login/${encodeURIComponent(session)}`)
+  },
+
+  // Approve a pending CLI login, the CLI then receives a token
+  confirmCliLogin(session: string) {
+    return webApi.post(`/-/api/v1/login/${encodeURIComponent(session)}`)
+  },
+
+  // Reject a pending CLI login
+  cancelCliLogin(session: string) {
+    return webApi.delete(`/-/api/v1/login/${encodeURIComponent(session)}`)
+  },
+
+  // OIDC single sign-on config
+  getOidcConfig() {
+    return webApi.get('/-/api/auth/oidc')
   },
 
   // Get current user
@@ -108,12 +139,17 @@ export const adminApi = {
   },
 
   // Create user
-  createUser(user: { name: string; password: string; email: string; role?: string }) {
+  createUser(user: { name: string; password: string; email: string; role?: string; mustChangePassword?: boolean }) {
     return webApi.post('/-/api/admin/users', user)
   },
 
+  // Import users from htpasswd content
+  importUsers(data: { htpasswd: string; role?: string; overwrite?: boolean; mustChangePassword?: boolean; dryRun?: boolean }) {
+    return webApi.post('/-/api/admin/users/import', data)
+  },
+
   // Update user
-  updateUser(name: string, data: { email?: string; password?: string; role?: string }) {
+  updateUser(name: string, data: { email?: string; password?: string; role?: string; mustChangePassword?: boolean }) {
     return webApi.put(`/-/api/admin/users/${encodeURIComponent(name)}`, data)
   },
 
@@ -122,6 +158,31 @@ export const adminApi = {
     return webApi.delete(`/-/api/admin/users/${encodeURIComponent(name)}`)
   },
 
+  // Unlock an account locked after failed logins
+  unlockUser(name: string) {
+    return webApi.delete(`/-/api/admin/users/${encodeURIComponent(name)}/lockout`)
+  },
+
+  // Issue a one-time password reset code/link
+  createPasswordReset(name: string) {
+    return webApi.post(`/-/api/admin/users/${encodeURIComponent(name)}/password-reset`)
+  },
+
+  // Active login sessions of a user
+  getUserSessions(name: string) {
+    return webApi.get(`/-/api/admin/users/${encodeURIComponent(name)}/sessions`)
+  },
+
+  // Revoke one session
+  revokeUserSession(name: string, id: number) {
+    return webApi.delete(`/-/api/admin/users/${encodeURIComponent(name)}/sessions/${id}`)
+  },
+
+  // Revoke all sessions
+  revokeUserSessions(name: string) {
+    return webApi.delete(`/-/api/admin/users/${encodeURIComponent(name)}/sessions`)
+  },
+
   // Get stats
   getStats() {
     return webApi.get('/-/api/stats')
@@ -231,8 +292,19 @@ export const tokenApi = {
   },
 
   // Create token
-  create(data: { name: string; readonly?: boolean; days?: number }) {
-    return webApi.post('/-/npm/v1/tokens', data)
+  create(
+    data: {
+      name: string
+      password?: string
+      readonly?: boolean
+      days?: number
+      packages?: string[]
+      permissions?: string[]
+      bypass_2fa?: boolean
+    },
+    otp?: string
+  ) {
+    return webApi.post('/-/npm/v1/tokens', data, otp ? { headers: { 'npm-otp': otp } } : undefined)
   },
 
   // Delete token
@@ -35,6 +35,8 @@ export default {
     empty: 'Empty',
     saveSuccess: 'Saved successfully',
     download: 'Download',
+    copy: 'Copy',
+    copied: 'Copied to clipboard',
   },
   nav: {
     home: 'Home',
@@ -68,6 +70,54 @@ export default {
     welcomeBack: 'Welcome Back',
     rememberMe: 'Remember me',
     forgotPassword: 'Forgot password?',
+    otp: 'One-time password',
+    otpPlaceholder: 'Code from your authenticator app or a recovery code',
+    otpRequired: 'Please enter your one-time password',
+    otpInvalid: 'Invalid one-time password',
+    forgotPasswordHint: 'Ask an administrator for a password reset link or code',
+    mustChangePassword: 'You must set a new password before continuing',
+    newPassword: 'New password',
+    confirmPassword: 'Confirm new password',
+    newPasswordRequired: 'Please enter a new password',
+    newPasswordInvalid: 'The new password does not meet the password policy',
+    passwordMismatch: 'Passwords do not match',
+    accountLocked: 'Too many failed attempts, the account is temporarily locked. Try again later or contact your administrator',
+    ssoLogin: 'Sign in with {name}',
+    ssoOr: 'or',
+    cli: {
+      title: 'Authorize command line login',
+      subtitle: 'An npm command line is asking to log in as you',
+      hostname: 'Hostname',
+      ip: 'IP address',
+      expiresAt: 'Expires at',
+      account: 'Account',
+      confirm: 'Allow',
+      cancel: 'Deny',
+      confirmed: 'Login approved, you can return to your terminal',
+      cancelled: 'Login request denied',
+      notFound: 'The login request does not exist or has expired, run npm login again',
+      alreadyConfirmed: 'This login request has already been approved',
+      failed: 'Failed to approve the login, please try again later',
+    },
+    ssoErrors: {
+      access_denied: 'Sign-in was cancelled at the identity provider',
+      invalid_state: 'Sign-in session expired, please try again',
+      no_permitted_group: 'Your account is not in a group allowed to use this registry',
+      user_not_found: 'No local account exists for you, contact your administrator',
+      account_conflict: 'A local account with your username already exists, contact your administrator',
+      login_failed: 'Single sign-on failed, please try again later',
+    },
+  },
+  resetPassword: {
+    title: 'Reset Password',
+    subtitle: 'Set a new password with the code from your administrator',
+    code: 'Reset code',
+    codePlaceholder: 'XXXX-XXXX-XXXX-XXXX',
+    codeRequired: 'Please enter the reset code',
+    submit: 'Set New Password',
+    success: 'Password changed, please log in with the new password',
+    failed: 'Failed to reset password',
+    backToLogin: 'Back to login',
   },
   home: {
     heroTitle: 'Grape Registry',
@@ -201,6 +251,39 @@ export default {
     passwordMinLength: 'Password must be at least 6 characters',
     passwordChanged: 'Password changed successfully',
     passwordChangeFailed: 'Failed to change password',
+    sessions: 'Sessions',
+    sessionsTitle: 'Active sessions of {name}',
+    sessionMethod: 'Method',
+    sessionClient: 'Client',
+    sessionLastSeen: 'Last Active',
+    sessionCurrent: 'Current',
+    sessionRevoke: 'Revoke',
+    sessionRevokeAll: 'Revoke All',
+    sessionRevokeAllConfirm: 'Sign {name} out of all sessions?',
+    sessionRevoked: 'Session revoked',
+    mustChangePassword: 'Must change password',
+    locked: 'Locked',
+    lockedUntil: 'Locked until {time}',
+    unlock: 'Unlock',
+    unlocked: 'Account unlocked',
+    importUsers: 'Import htpasswd',
+    importHint: 'Import accounts from an htpasswd file (e.g. Verdaccio). bcrypt, SHA1 and crypt hashes are supported; SHA1 and crypt are upgraded to bcrypt at first login.',
+    importFile: 'htpasswd file',
+    importOverwrite: 'Overwrite passwords of existing users',
+    importPreview: 'Preview',
+    importSubmit: 'Import',
+    importCreated: 'New',
+    importUpdated: 'Updated',
+    importSkipped: 'Skipped (already exist)',
+    importDone: '{count} users imported',
+    mustChangePasswordHint: 'Require a password change at next login',
+    resetLink: 'Reset Link',
+    resetLinkConfirm: 'Issue a password reset code for {name}? Previously issued codes stop working.',
+    resetLinkTitle: 'Password reset for {name}',
+    resetLinkHint: 'Send the link or code to the user over a trusted channel. It can be used once and is shown only now.',
+    resetCode: 'Reset code',
+    resetUrl: 'Reset link',
+    resetExpires: 'Expires at {time}',
   },
   tokens: {
     title: 'CI/CD Tokens',
@@ -220,6 +303,18 @@ export default {
     tokenWarning: 'Please save this token now. It will not be shown again.',
     lastUsed: 'Last Used',
     never: 'Never',
+    granular: 'Granular',
+    scope: 'Scope',
+    allPackages: 'All packages',
+    packages: 'Packages',
+    packagesPlaceholder: 'e.g. @acme/* or @acme/ui-*, leave empty for all packages',
+    permissions: 'Permissions',
+    permissionsRequired: 'Select at least one permission',
+    bypass2fa: 'Bypass two-factor authentication for publishing',
+    password: 'Current Password',
+    passwordHint: 'Users with two-factor authentication may enter a one-time password instead',
+    ssoReauthHint: 'Single sign-on accounts can create a token within 10 minutes of signing in. Sign in again if the request is rejected',
+    passwordRequired: 'Please enter your current password or a one-time password',
   },
   backup: {
     title: 'Backup & Restore',
@@ -236,6 +331,7 @@ export default {
     backupList: 'Backup History',
     noBackups: 'No backups available',
     selectFile: 'Select backup file',
+    unsupported: 'Web UI backup and restore only support SQLite. For PostgreSQL, back up the database with pg_dump and the storage directory separately',
     dragDrop: 'Drag and drop file here, or click to select',
   },
   gc: {
@@ -34,7 +34,9 @@ export default {
     hours: '小时',
     empty: '空',
     saveSuccess: '保存成功',
-    download: '下载'
+    download: '下载',
+    copy: '复制',
+    copied: '已复制到剪贴板'
   },
   nav: {
     home: '首页',
@@ -67,7 +69,55 @@ export default {
     errorRetry: '登录失败，请稍后重试',
     welcomeBack: '欢迎回来',
     rememberMe: '记住我',
-    forgotPassword: '忘记密码？'
+    forgotPassword: '忘记密码？',
+    otp: '一次性密码',
+    otpPlaceholder: '认证器 App 中的验证码或恢复码',
+    otpRequired: '请输入一次性密码',
+    otpInvalid: '一次性密码错误',
+    forgotPasswordHint: '请联系管理员获取密码重置链接或重置码',
+    mustChangePassword: '继续之前必须设置新密码',
+    newPassword: '新密码',
+    confirmPassword: '确认新密码',
+    newPasswordRequired: '请输入新密码',
+    newPasswordInvalid: '新密码不满足密码策略',
+    passwordMismatch: '两次输入的密码不一致',
+    accountLocked: '登录失败次数过多，账号已被临时锁定，请稍后再试或联系管理员',
+    ssoLogin: '使用 {name} 登录',
+    ssoOr: '或',
+    cli: {
+      title: '授权命令行登录',
+      subtitle: '一个 npm 命令行正在请求以你的身份登录',
+      hostname: '主机名',
+      ip: 'IP 地址',
+      expiresAt: '有效期至',
+      account: '登录账号',
+      confirm: '允许登录',
+      cancel: '拒绝',
+      confirmed: '已授权，可以回到终端继续操作',
+      cancelled: '已拒绝此次登录',
+      notFound: '登录请求不存在或已过期，请在终端重新执行 npm login',
+      alreadyConfirmed: '此登录请求已经授权过',
+      failed: '授权失败，请稍后重试'
+    },
+    ssoErrors: {
+      access_denied: '已在身份提供方取消登录',
+      invalid_state: '登录会话已过期，请重试',
+      no_permitted_group: '你的账号不在允许访问的用户组中',
+      user_not_found: '没有对应的本地账号，请联系管理员',
+      account_conflict: '已存在同名的本地账号，请联系管理员',
+      login_failed: '单点登录失败，请稍后重试'
+    }
+  },
+  resetPassword: {
+    title: '重置密码',
+    subtitle: '使用管理员提供的重置码设置新密码',
+    code: '重置码',
+    codePlaceholder: 'XXXX-XXXX-XXXX-XXXX',
+    codeRequired: '请输入重置码',
+    submit: '设置新密码',
+    success: '密码已修改，请使用新密码登录',
+    failed: '重置密码失败',
+    backToLogin: '返回登录',
   },
   home: {
     heroTitle: 'Grape Registry',
@@ -200,7 +250,40 @@ export default {
     confirmPasswordRequired: '请确认新密码',
     passwordMinLength: '密码至少6位',
     passwordChanged: '密码修改成功',
-    passwordChangeFailed: '密码修改失败'
+    passwordChangeFailed: '密码修改失败',
+    sessions: '会话',
+    sessionsTitle: '{name} 的活跃会话',
+    sessionMethod: '登录方式',
+    sessionClient: '客户端',
+    sessionLastSeen: '最后活跃',
+    sessionCurrent: '当前',
+    sessionRevoke: '撤销',
+    sessionRevokeAll: '全部撤销',
+    sessionRevokeAllConfirm: '确定让 {name} 的所有会话下线吗？',
+    sessionRevoked: '会话已撤销',
+    mustChangePassword: '需修改密码',
+    locked: '已锁定',
+    lockedUntil: '锁定至 {time}',
+    unlock: '解锁',
+    unlocked: '账号已解锁',
+    importUsers: '导入 htpasswd',
+    importHint: '从 htpasswd 文件（如 Verdaccio）导入账号，支持 bcrypt、SHA1 和 crypt 哈希，SHA1 和 crypt 会在首次登录时升级为 bcrypt。',
+    importFile: 'htpasswd 文件',
+    importOverwrite: '覆盖已存在用户的密码',
+    importPreview: '预览',
+    importSubmit: '导入',
+    importCreated: '新建',
+    importUpdated: '更新',
+    importSkipped: '跳过（已存在）',
+    importDone: '已导入 {count} 个用户',
+    mustChangePasswordHint: '下次登录时必须修改密码',
+    resetLink: '重置链接',
+    resetLinkConfirm: '为 {name} 签发密码重置码？之前签发的重置码将失效。',
+    resetLinkTitle: '重置 {name} 的密码',
+    resetLinkHint: '请通过可信渠道把链接或重置码发给用户。重置码只能使用一次，且只在此处显示。',
+    resetCode: '重置码',
+    resetUrl: '重置链接',
+    resetExpires: '过期时间：{time}',
   },
   tokens: {
     title: 'CI/CD Token',
@@ -219,7 +302,19 @@ export default {
     tokenCopied: 'Token 已复制到剪贴板',
     tokenWarning: '请立即保存此 Token，关闭后将无法再次查看。',
     lastUsed: '最后使用',
-    never: '从未使用'
+    never: '从未使用',
+    granular: '细粒度',
+    scope: '权限范围',
+    allPackages: '所有包',
+    packages: '包',
+    packagesPlaceholder: '如 @acme/* 或 @acme/ui-*，留空表示所有包',
+    permissions: '操作权限',
+    permissionsRequired: '请至少选择一个操作权限',
+    bypass2fa: '发布时跳过两步验证',
+    password: '当前密码',
+    passwordHint: '已开启两步验证的用户也可以只填写一次性验证码',
+    ssoReauthHint: '单点登录账号需要在登录后 10 分钟内创建 token，请求被拒绝时请重新登录',
+    passwordRequired: '请输入当前密码或一次性验证码'
   },
   backup: {
     title: '备份恢复',
@@ -236,6 +331,7 @@ export default {
     backupList: '备份历史',
     noBackups: '暂无备份',
     selectFile: '选择备份文件',
+    unsupported: 'Web 界面的备份与恢复仅支持 SQLite，PostgreSQL 请使用 pg_dump 备份数据库，并单独备份存储目录',
     dragDrop: '将文件拖到此处，或点击选择'
   },
   gc: {
@@ -7,12 +7,23 @@ export const useUserStore = defineStore('user', () => {
   const username = ref<string | null>(localStorage.getItem('username'))
   const role = ref<string | null>(localStorage.getItem('role'))
 
+  // 最近一次设置新密码失败的原因（密码策略的错误信息）
+  const passwordError = ref('')
+
   const isLoggedIn = computed(() => !!token.value)
   const isAdmin = computed(() => role.value === 'admin')
 
-  async function login(name: string, password: string) {
+  // 返回 'otp' 表示账号已启用两步验证，需要带上 OTP 重新登录
+  // 返回 'change-password' 表示账号必须先设置新密码，newPassword 为空或不满足密码策略
+  // 返回 'locked' 表示连续登录失败，账号已被临时锁定
+  async function login(
+    name: string,
+    password: string,
+    otp?: string,
+    newPassword?: string,
+  ): Promise<boolean | 'otp' | 'change-password' | 'locked'> {
     try {
-      const res = await authApi.login(name, password)
+      const res = await authApi.login(name, password, otp, newPassword)
       if (res.data.ok) {
         token.value = res.data.token
         username.value = name
@@ -33,12 +44,40 @@ export const useUserStore = defineStore('user', () => {
         return true
       }
       return false
-    } catch {
+    } catch (error: any) {
+      if (error.response?.data?.otpRequired) {
+        return 'otp'
+      }
+      if (error.response?.data?.locked) {
+        return 'locked'
+      }
+      if (error.response?.data?.mustChangePassword) {
+        passwordError.value = newPassword ? error.response.data.error || '' : ''
+        return 'change-password'
+      }
       return false
     }
   }
 
-  function logout() {
+  // SSO 回调携带的 token
+  function loginWithToken(newToken: string, name: string, newRole: string) {
+    token.value = newToken
+    username.value = name
+    role.value = newRole
+    localStorage.setItem('token', newToken)
+    localStorage.setItem('username', name)
+    localStorage.setItem('role', newRole)
+  }
+
+  async function logout() {
+    // 撤销服务端会话，失败不影响本地登出
+    if (token.value) {
+      try {
+        await authApi.logout()
+      } catch {
+        // 忽略
+      }
+    }
     token.value = null
     username.value = null
     role.value = null
@@ -63,7 +102,9 @@ export const useUserStore = defineStore('user', () => {
     role,
     isLoggedIn,
     isAdmin,
+    passwordError,
     login,
+    loginWithToken,
     logout,
   }
 })